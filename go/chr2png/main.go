@@ -2,48 +2,147 @@ package main
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
 	"image/png"
 	"io"
-	"math/bits"
+	"io/ioutil"
+	"mgnes/pkg/chr"
+	"mgnes/pkg/ines"
+	"mgnes/pkg/nespalette"
 	"os"
+	"strconv"
+	"strings"
 
 	flag "github.com/spf13/pflag"
 )
 
 const (
-	kPaletteSize     = 64       // NES palette have 64 colors
-	kRGBSize         = 3        // RGB 3 bytes
-	kCHRSize         = 1024 * 8 // process 8KB per time
-	kPageSizeInBytes = 256 * 16 // 16x16 tiles, 16 bytes per tile
+	kPaletteSize = 64       // NES palette have 64 colors
+	kRGBSize     = 3        // RGB 3 bytes
+	kCHRSize     = 1024 * 8 // process 8KB per time
+	kTileSize    = 16       // 8x8 pixels, 2 bits per pixel
+	kLabelHeight = 7        // 5 pixels digits with 1 pixel margin
+	kSwatchSize  = 16       // size in pixels of a color in the palette preview
+	kMaxPixels   = 1 << 26  // largest scaled image, 256MB in RGBA
 )
 
 var (
 	palette       []byte
 	spritePalette []byte
 	outFile       string
+	tileCols      int // tiles per row of a page
+	tileRows      int // tile rows of a page
+	sheetGap      int // pixels between banks in sheet mode
+	sheetLabels   bool
+	indexed       bool // write paletted PNGs using the sprite palette
+	grid          bool // separate tiles with 1 pixel lines of gridColor
+	scale         int  // integer zoom factor applied before encoding
+	gridColor     color.RGBA
+
+	// reused across banks to keep allocations flat on large CHR dumps
+	pageImage draw.Image
 )
 
 func main() {
 	chr := flag.String("chr", "", "chr file to convert")
+	rom := flag.String("rom", "", "iNES ROM file to extract and convert CHR from")
 	pal := flag.String("pal", "RGB", "palette format")
+	emphasis := flag.Int("emphasis", 0, "64 colors block to use from a PAL file with several")
 	sprpal := flag.String("sp", "22271618", "sprite palette")
 	out := flag.String("out", "chr", "output file")
+	cols := flag.Int("cols", 16, "tiles per row of a page")
+	rows := flag.Int("rows", 16, "tile rows of a page")
+	sheet := flag.Bool("sheet", false, "stack all banks vertically into a single PNG")
+	gap := flag.Int("gap", 8, "pixels between banks in sheet mode")
+	labels := flag.Bool("labels", false, "label each bank with its number in sheet mode")
+	indexedPNG := flag.Bool("indexed", false, "write indexed PNGs with the 4 colors of the sprite palette")
+	swatch := flag.Bool("swatch", false, "write a preview of the 64 colors of the palette instead")
+	gridLines := flag.Bool("grid", false, "draw 1 pixel lines between tiles, moving tiles apart to make room")
+	gridRGB := flag.String("grid-color", "FF00FF", "RGB hex color of the grid lines")
+	zoom := flag.Int("scale", 1, "nearest neighbor upscale factor of the output")
 	flag.Parse()
 
-	if *chr == "" || *out == "" {
+	if *zoom < 1 {
+		fmt.Printf("invalid scale %v, expect 1 or more\n", *zoom)
+		os.Exit(-1)
+	}
+	scale = *zoom
+
+	if *swatch && *out != "" {
+		loadPalette(*pal, *emphasis)
+		fn := *out
+		if !strings.HasSuffix(fn, ".png") {
+			fn += ".png"
+		}
+		writePNG(fn, drawSwatches(palette))
+		return
+	}
+
+	if (*chr == "") == (*rom == "") || *out == "" {
 		flag.Usage()
 		os.Exit(86)
 	}
 
+	// each 8KB bank is split into pages of cols x rows tiles, placed side by side
+	if err := setTileGrid(*cols, *rows); err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+
+	outFile = *out
+	sheetGap = *gap
+	sheetLabels = *labels
+	indexed = *indexedPNG
+	grid = *gridLines
+	if err := setGridColor(*gridRGB); err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+	if sheetGap < 0 {
+		sheetGap = 0
+	}
+
 	// load sprite palette
 	loadSpritePalette(*sprpal)
 	// load palette
-	loadPalette(*pal)
+	loadPalette(*pal, *emphasis)
 	// process CHR file
-	processCHR(*chr)
+	var chrReader io.Reader
+	if *rom != "" {
+		chrReader = openROM(*rom)
+	} else {
+		chrReader = openCHR(*chr)
+	}
+	processCHR(chrReader, *sheet)
+}
+
+func setTileGrid(cols, rows int) error {
+	// a page holds at most the tiles of a bank, this also keeps the
+	// product below from overflowing
+	maxTiles := kCHRSize / kTileSize
+	if cols <= 0 || rows <= 0 || cols > maxTiles || rows > maxTiles {
+		return fmt.Errorf("invalid tile grid %vx%v", cols, rows)
+	}
+	if kCHRSize%(cols*rows*kTileSize) != 0 {
+		return fmt.Errorf("tile grid %vx%v does not evenly divide a %v bytes CHR bank", cols, rows, kCHRSize)
+	}
+	tileCols = cols
+	tileRows = rows
+	return nil
+}
+
+// setGridColor parses rgb as 6 hex digits into gridColor
+func setGridColor(rgb string) error {
+	c, err := hex.DecodeString(rgb)
+	if err != nil || len(c) != kRGBSize {
+		return fmt.Errorf("invalid grid color '%v', expect RRGGBB", rgb)
+	}
+	gridColor = color.RGBA{c[0], c[1], c[2], 255}
+	return nil
 }
 
 func loadSpritePalette(sp string) {
@@ -55,105 +154,309 @@ func loadSpritePalette(sp string) {
 	}
 }
 
-func loadPalette(paletteName string) {
-	palette = getPalette(paletteName)
-	if palette == nil {
+func loadPalette(paletteName string, emphasis int) {
+	var ok bool
+	if palette, ok = nespalette.Get(paletteName); !ok {
 		f, err := os.Open(paletteName)
 		defer f.Close()
 		if err != nil {
 			fmt.Printf("'%v' is not a valid palette name or file\n", paletteName)
 			fmt.Println("use one of the palettes below or a valid PAL file")
-			for k, _ := range paletteMap {
+			for _, k := range nespalette.Names() {
 				fmt.Println("    " + k)
 			}
 			os.Exit(-1)
 		}
-		palette = make([]byte, kPaletteSize*kRGBSize)
-		n, err := f.Read(palette)
+		palette, err = ioutil.ReadAll(f)
 		if err != nil {
 			fmt.Printf("error while reading palette file '%v'\n", err)
 			os.Exit(-1)
 		}
-		if n < len(palette) {
-			fmt.Printf("invalid PAL file, expect 192 bytes, got %v\n", n)
-			os.Exit(-1)
-		}
 	}
+
+	var err error
+	if palette, err = selectPaletteBlock(palette, emphasis); err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+}
+
+// selectPaletteBlock returns the 64 colors block at index of a palette made
+// of one or more blocks, e.g. a 512 colors palette with all emphasis variants
+func selectPaletteBlock(data []byte, index int) ([]byte, error) {
+	blockSize := kPaletteSize * kRGBSize
+	if len(data) == 0 || len(data)%blockSize != 0 {
+		return nil, fmt.Errorf("invalid PAL file, expect a multiple of %v bytes, got %v", blockSize, len(data))
+	}
+	if index < 0 || index >= len(data)/blockSize {
+		return nil, fmt.Errorf("invalid emphasis %v, palette has %v blocks", index, len(data)/blockSize)
+	}
+	return data[index*blockSize : (index+1)*blockSize], nil
+}
+
+func openCHR(fileName string) io.Reader {
+	inFile, err := os.Open(fileName)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(-1)
+	}
+	return inFile
 }
 
-func processCHR(fileName string) {
+func openROM(fileName string) io.Reader {
 	inFile, err := os.Open(fileName)
 	if err != nil {
 		fmt.Printf("%v\n", err)
+		os.Exit(-1)
+	}
+
+	chrReader, err := extractCHR(inFile)
+	if err != nil {
+		fmt.Printf("%v: %v\n", fileName, err)
+		os.Exit(-1)
+	}
+	return chrReader
+}
+
+// extractCHR parses the iNES header from r, skips the trainer and PRG ROM
+// and returns a reader limited to the CHR ROM
+func extractCHR(r io.Reader) (io.Reader, error) {
+	header, err := ines.NewHeader(r)
+	if header == nil {
+		if err == nil {
+			err = errors.New("invalid iNES header")
+		}
+		return nil, err
+	}
+
+	if header.CHRROMSize() == 0 {
+		return nil, errors.New("ROM has no CHR ROM, it uses CHR RAM only")
+	}
+
+	skip := int64(header.PRGROMSize())
+	if header.Trainer() {
+		skip += 512
+	}
+	if _, err = io.CopyN(ioutil.Discard, r, skip); err != nil {
+		return nil, errors.New("invalid PRG data")
 	}
 
+	return io.LimitReader(r, int64(header.CHRROMSize())), nil
+}
+
+func processCHR(r io.Reader, sheet bool) {
 	fileNo := 0
+	var banks [][]byte
 	buf := make([]byte, kCHRSize)
 	for {
-		bytesRead, err := inFile.Read(buf)
-		if err != nil {
-			if err != io.EOF {
-				fmt.Println(err)
-				os.Exit(-1)
-			}
+		bytesRead, err := io.ReadFull(r, buf)
+		if err == io.EOF {
 			break
 		}
-		drawPNG(fileNo, buf[:bytesRead])
+		if err != nil && err != io.ErrUnexpectedEOF {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+		if sheet {
+			banks = append(banks, append([]byte(nil), buf[:bytesRead]...))
+		} else {
+			drawPNG(fileNo, buf[:bytesRead])
+		}
 		fileNo++
 	}
-}
 
-func setTilePixel(y int, line byte, buf []uint, add bool) {
-	mirror := bits.Reverse8(line)
-	for x := 0; x < 8; x++ {
-		c := uint(mirror) >> uint(x) & 0x1
-		pos := y*8 + x
-		if add {
-			buf[pos] = buf[pos]*2 + c
-		} else {
-			buf[pos] = c
+	if sheet {
+		fn := outFile
+		if !strings.HasSuffix(fn, ".png") {
+			fn += ".png"
 		}
+		writePNG(fn, drawSheet(banks))
 	}
 }
 
-func writeTile(img *image.RGBA, page, tx, ty int, pixels []uint) {
+func writeTile(img draw.Image, top, page, tx, ty int, pixels *[64]uint8) {
 	for y := 0; y < 8; y++ {
 		for x := 0; x < 8; x++ {
 			pixel := pixels[y*8+x]
-			ox := (tx+page*16)*8 + x
-			oy := ty*8 + y
-			paletteValue := spritePalette[pixel]
-			r := palette[paletteValue*kRGBSize]
-			g := palette[paletteValue*kRGBSize+1]
-			b := palette[paletteValue*kRGBSize+2]
-			img.Set(ox, oy, color.RGBA{r, g, b, 255})
+			ox := gridBorder() + (tx+page*tileCols)*tilePitch() + x
+			oy := top + gridBorder() + ty*tilePitch() + y
+			if p, ok := img.(*image.Paletted); ok {
+				p.SetColorIndex(ox, oy, pixel)
+				continue
+			}
+			c := spriteColor(pixel)
+			if p, ok := img.(*image.RGBA); ok {
+				// SetRGBA does not box the color into an interface
+				p.SetRGBA(ox, oy, c)
+				continue
+			}
+			img.Set(ox, oy, c)
 		}
 	}
 }
 
+// spriteColor returns the color of entry i of the sprite palette
+func spriteColor(i uint8) color.RGBA {
+	paletteValue := spritePalette[i]
+	r := palette[paletteValue*kRGBSize]
+	g := palette[paletteValue*kRGBSize+1]
+	b := palette[paletteValue*kRGBSize+2]
+	return color.RGBA{r, g, b, 255}
+}
+
+// tilePitch returns the distance in pixels between the tops, or lefts, of
+// two adjacent tiles
+func tilePitch() int {
+	if grid {
+		return 9
+	}
+	return 8
+}
+
+// gridBorder returns the width of the lines around the tiles of a bank
+func gridBorder() int {
+	if grid {
+		return 1
+	}
+	return 0
+}
+
+// pageBounds returns the size of all pages of a CHR bank
+func pageBounds() image.Rectangle {
+	pages := kCHRSize / (tileCols * tileRows * kTileSize)
+	w := pages*tileCols*tilePitch() + gridBorder()
+	h := tileRows*tilePitch() + gridBorder()
+	return image.Rect(0, 0, w, h)
+}
+
+// newPageImage returns an image large enough for all pages of a CHR bank
+func newPageImage() draw.Image {
+	return newImage(pageBounds())
+}
+
+// newImage returns a paletted image in indexed mode, RGBA otherwise
+func newImage(r image.Rectangle) draw.Image {
+	if indexed {
+		return image.NewPaletted(r, spriteColors())
+	}
+	return image.NewRGBA(r)
+}
+
+// spriteColors returns the colors of the sprite palette
+func spriteColors() color.Palette {
+	colors := make(color.Palette, len(spritePalette))
+	for i := range spritePalette {
+		colors[i] = spriteColor(uint8(i))
+	}
+	if grid {
+		colors = append(colors, gridColor)
+	}
+	return colors
+}
+
 func drawPNG(number int, data []byte) {
 	fn := fmt.Sprintf("%v_%04d.png", outFile, number)
-	img := image.NewRGBA(image.Rect(0, 0, 256, 128))
-
-	tileData := make([]uint, 64)
-	for i, b := range data {
-		page := i / kPageSizeInBytes
-		ii := i % kPageSizeInBytes
-		tileX := ii / 16 % 16
-		tileY := ii / 256
-		ti := i % 16
-		if ti < 8 {
-			// first pass
-			setTilePixel(i%8, b, tileData, false)
-		} else {
-			// second pass
-			setTilePixel(i%8, b, tileData, true)
+	writePNG(fn, drawPage(data))
+}
+
+// drawPage draws a CHR bank to pageImage, which is allocated once and
+// cleared between banks so a short last bank leaves the rest transparent
+func drawPage(data []byte) draw.Image {
+	if pageImage == nil {
+		pageImage = newPageImage()
+	} else {
+		clearImage(pageImage)
+	}
+	drawBank(pageImage, 0, data)
+	return pageImage
+}
+
+// clearImage sets all pixels of an image made by newImage to zero
+func clearImage(img draw.Image) {
+	var pix []uint8
+	switch p := img.(type) {
+	case *image.RGBA:
+		pix = p.Pix
+	case *image.Paletted:
+		pix = p.Pix
+	}
+	for i := range pix {
+		pix[i] = 0
+	}
+}
+
+// drawSheet draws all banks into one image, from top to bottom, separated
+// by sheetGap pixels. With sheetLabels each bank is preceded by a strip
+// holding its number
+func drawSheet(banks [][]byte) draw.Image {
+	bank := pageBounds()
+	labelHeight := 0
+	if sheetLabels {
+		labelHeight = kLabelHeight
+	}
+
+	height := 0
+	if len(banks) > 0 {
+		height = len(banks)*(bank.Dy()+labelHeight) + (len(banks)-1)*sheetGap
+	}
+	img := newImage(image.Rect(0, 0, bank.Dx(), height))
+
+	top := 0
+	for i, data := range banks {
+		if sheetLabels {
+			drawLabel(img, 1, top+1, i)
+			top += labelHeight
+		}
+		drawBank(img, top, data)
+		top += bank.Dy() + sheetGap
+	}
+
+	return img
+}
+
+// drawBank draws the tiles of a CHR bank to img, starting at row top
+func drawBank(img draw.Image, top int, data []byte) {
+	if grid {
+		drawGrid(img, top)
+	}
+	pageTiles := tileCols * tileRows
+	for i := 0; i < len(data)/kTileSize; i++ {
+		var plane0, plane1 [8]byte
+		copy(plane0[:], data[i*kTileSize:])
+		copy(plane1[:], data[i*kTileSize+8:])
+		pixels := chr.DecodeTile(plane0, plane1)
+
+		tile := i % pageTiles
+		writeTile(img, top, i/pageTiles, tile%tileCols, tile/tileCols, &pixels)
+	}
+}
+
+// drawGrid draws the lines between the tiles of a bank starting at row top,
+// in indexed mode the grid color follows the sprite palette
+func drawGrid(img draw.Image, top int) {
+	bank := pageBounds()
+	set := func(x, y int) {
+		if p, ok := img.(*image.Paletted); ok {
+			p.SetColorIndex(x, y, uint8(len(spritePalette)))
+			return
 		}
-		if ti == 15 {
-			// draw
-			writeTile(img, page, tileX, tileY, tileData)
+		img.Set(x, y, gridColor)
+	}
+	for y := 0; y < bank.Dy(); y++ {
+		for x := 0; x < bank.Dx(); x++ {
+			if x%tilePitch() == 0 || y%tilePitch() == 0 {
+				set(x, top+y)
+			}
 		}
 	}
+}
+
+func writePNG(fn string, img image.Image) {
+	img, err := scaleImage(img, scale)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
 
 	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE, 0600)
 	defer f.Close()
@@ -164,3 +467,91 @@ func drawPNG(number int, data []byte) {
 
 	png.Encode(f, img)
 }
+
+// scaleImage returns img upscaled n times with nearest neighbor, it expects
+// an image made by newImage or drawSwatches
+func scaleImage(img image.Image, n int) (image.Image, error) {
+	if n <= 1 {
+		return img, nil
+	}
+	b := img.Bounds()
+	if n > kMaxPixels || b.Dx()*b.Dy() > kMaxPixels/(n*n) {
+		return nil, fmt.Errorf("scaled image %vx%v is too large", b.Dx()*n, b.Dy()*n)
+	}
+
+	r := image.Rect(0, 0, b.Dx()*n, b.Dy()*n)
+	var dst image.Image
+	var src, pix []uint8
+	var srcStride, dstStride, bpp int
+	switch p := img.(type) {
+	case *image.RGBA:
+		d := image.NewRGBA(r)
+		dst, src, pix, srcStride, dstStride, bpp = d, p.Pix, d.Pix, p.Stride, d.Stride, 4
+	case *image.Paletted:
+		d := image.NewPaletted(r, p.Palette)
+		dst, src, pix, srcStride, dstStride, bpp = d, p.Pix, d.Pix, p.Stride, d.Stride, 1
+	default:
+		return nil, fmt.Errorf("cannot scale %T", img)
+	}
+
+	for y := 0; y < r.Dy(); y++ {
+		srow := src[y/n*srcStride:]
+		drow := pix[y*dstStride:]
+		for x := 0; x < r.Dx(); x++ {
+			copy(drow[x*bpp:(x+1)*bpp], srow[x/n*bpp:])
+		}
+	}
+	return dst, nil
+}
+
+// drawSwatches draws the 64 colors of pal as a 16x4 grid, each color is
+// labeled with its index
+func drawSwatches(pal []byte) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 16*kSwatchSize, 4*kSwatchSize))
+	for i := 0; i < kPaletteSize; i++ {
+		r := pal[i*kRGBSize]
+		g := pal[i*kRGBSize+1]
+		b := pal[i*kRGBSize+2]
+		ox := i % 16 * kSwatchSize
+		oy := i / 16 * kSwatchSize
+		for y := 0; y < kSwatchSize; y++ {
+			for x := 0; x < kSwatchSize; x++ {
+				img.Set(ox+x, oy+y, color.RGBA{r, g, b, 255})
+			}
+		}
+
+		// dark label on bright colors
+		labelColor := color.RGBA{255, 255, 255, 255}
+		if (299*int(r)+587*int(g)+114*int(b))/1000 > 128 {
+			labelColor = color.RGBA{0, 0, 0, 255}
+		}
+		drawText(img, ox+1, oy+1, i, labelColor)
+	}
+	return img
+}
+
+// 3x5 pixels digits for bank labels, one row per byte, bit 2 is the leftmost pixel
+var digitFont = [10][5]byte{
+	{7, 5, 5, 5, 7}, {2, 6, 2, 2, 7}, {7, 1, 7, 4, 7}, {7, 1, 7, 1, 7}, {5, 5, 7, 1, 1},
+	{7, 4, 7, 1, 7}, {7, 4, 7, 5, 7}, {7, 1, 1, 1, 1}, {7, 5, 7, 5, 7}, {7, 5, 7, 1, 7},
+}
+
+// drawLabel writes number in decimal at x, y
+func drawLabel(img draw.Image, x, y, number int) {
+	drawText(img, x, y, number, color.RGBA{255, 255, 255, 255})
+}
+
+// drawText writes number in decimal at x, y with color c
+func drawText(img draw.Image, x, y, number int, c color.Color) {
+	for _, d := range strconv.Itoa(number) {
+		glyph := digitFont[d-'0']
+		for row := 0; row < 5; row++ {
+			for col := 0; col < 3; col++ {
+				if glyph[row]>>uint(2-col)&1 != 0 {
+					img.Set(x+col, y+row, c)
+				}
+			}
+		}
+		x += 4
+	}
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"math/rand"
+	"mgnes/pkg/nespalette"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// kBenchCHRSize is the size of the synthetic CHR stream, 512 banks
+const kBenchCHRSize = 4 * 1024 * 1024
+
+// setDefaults sets the globals main would set for a default conversion,
+// output files go to a temporary directory
+func setDefaults(tb testing.TB) {
+	tb.Helper()
+	var ok bool
+	if palette, ok = nespalette.Get("RGB"); !ok {
+		tb.Fatal("RGB palette missing")
+	}
+	loadSpritePalette("22271618")
+	if err := setTileGrid(16, 16); err != nil {
+		tb.Fatal(err)
+	}
+	outFile = filepath.Join(tb.TempDir(), "chr")
+	scale = 1
+	sheetGap = 0
+	sheetLabels = false
+	indexed = false
+	grid = false
+	gridColor = color.RGBA{255, 0, 255, 255}
+	pageImage = nil
+}
+
+// setupBench sets the default globals and returns a random CHR stream
+func setupBench(b *testing.B) []byte {
+	b.Helper()
+	setDefaults(b)
+
+	data := make([]byte, kBenchCHRSize)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+// BenchmarkDrawPNG converts the whole stream to one PNG per bank. The page
+// image is reused, the allocations left come from the PNG encoder and the
+// files. Compare BenchmarkDrawPage and BenchmarkDrawPageFresh for the
+// drawing alone
+func BenchmarkDrawPNG(b *testing.B) {
+	data := setupBench(b)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		processCHR(bytes.NewReader(data), false)
+	}
+}
+
+// BenchmarkDrawPage draws every bank of the stream into the reused page
+// image, without encoding
+func BenchmarkDrawPage(b *testing.B) {
+	data := setupBench(b)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for bank := 0; bank < len(data); bank += kCHRSize {
+			drawPage(data[bank : bank+kCHRSize])
+		}
+	}
+}
+
+// BenchmarkDrawPageFresh is BenchmarkDrawPage allocating a new image per
+// bank like chr2png used to, as the baseline of the reuse
+func BenchmarkDrawPageFresh(b *testing.B) {
+	data := setupBench(b)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for bank := 0; bank < len(data); bank += kCHRSize {
+			pageImage = nil
+			drawPage(data[bank : bank+kCHRSize])
+		}
+	}
+}
+
+// romImage returns an iNES image with prgBanks of 16KB filled with 0xEA and
+// chrBanks of 8KB holding their bank number in every byte
+func romImage(prgBanks, chrBanks int, trainer bool) []byte {
+	image := []byte{'N', 'E', 'S', 0x1A, uint8(prgBanks), uint8(chrBanks), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	if trainer {
+		image[6] |= 0x04
+		image = append(image, bytes.Repeat([]byte{0x55}, 512)...)
+	}
+	image = append(image, bytes.Repeat([]byte{0xEA}, prgBanks*16*1024)...)
+	for i := 0; i < chrBanks; i++ {
+		image = append(image, bytes.Repeat([]byte{uint8(i + 1)}, kCHRSize)...)
+	}
+	return image
+}
+
+func TestExtractCHRRAMOnly(t *testing.T) {
+	_, err := extractCHR(bytes.NewReader(romImage(2, 0, false)))
+	if err == nil || !strings.Contains(err.Error(), "CHR RAM only") {
+		t.Errorf("extractCHR() error = %v, want the CHR RAM only error", err)
+	}
+}
+
+func TestSetTileGrid(t *testing.T) {
+	tests := []struct {
+		cols, rows int
+		wantErr    bool
+	}{
+		{16, 16, false},
+		{8, 8, false},
+		{512, 1, false},
+		{0, 16, true},
+		{16, -1, true},
+		{3, 16, true},
+		{513, 1, true},
+		// the product overflows to 0 without the bound
+		{1 << 31, 1 << 31, true},
+		{math.MaxInt, 2, true},
+	}
+	for _, tt := range tests {
+		if err := setTileGrid(tt.cols, tt.rows); (err != nil) != tt.wantErr {
+			t.Errorf("setTileGrid(%v, %v) error = %v, want error %v", tt.cols, tt.rows, err, tt.wantErr)
+		}
+	}
+
+	// 8 pages of 8x8 tiles side by side
+	if err := setTileGrid(8, 8); err != nil {
+		t.Fatal(err)
+	}
+	grid = false
+	if got, want := pageBounds(), image.Rect(0, 0, 8*8*8, 8*8); got != want {
+		t.Errorf("pageBounds() = %v, want %v", got, want)
+	}
+	grid = true
+	defer func() { grid = false }()
+	if got, want := pageBounds(), image.Rect(0, 0, 8*8*9+1, 8*9+1); got != want {
+		t.Errorf("pageBounds() with grid = %v, want %v", got, want)
+	}
+}
+
+func TestDrawSheetHeight(t *testing.T) {
+	// a bank is two pages of 16x16 tiles, 256x128 pixels
+	tests := []struct {
+		name   string
+		banks  int
+		gap    int
+		labels bool
+		want   int
+	}{
+		{"no banks", 0, 8, true, 0},
+		{"one bank", 1, 8, false, 128},
+		{"three banks", 3, 0, false, 3 * 128},
+		{"three banks with gap", 3, 8, false, 3*128 + 2*8},
+		{"three banks with labels", 3, 0, true, 3 * (128 + kLabelHeight)},
+		{"three banks with gap and labels", 3, 8, true, 3*(128+kLabelHeight) + 2*8},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setDefaults(t)
+			sheetGap = test.gap
+			sheetLabels = test.labels
+
+			banks := make([][]byte, test.banks)
+			for i := range banks {
+				banks[i] = make([]byte, kCHRSize)
+			}
+			b := drawSheet(banks).Bounds()
+			if b.Dx() != 256 || b.Dy() != test.want {
+				t.Errorf("sheet is %vx%v, want 256x%v", b.Dx(), b.Dy(), test.want)
+			}
+		})
+	}
+}
+
+func TestExtractCHR(t *testing.T) {
+	tests := []struct {
+		name    string
+		image   []byte
+		banks   int
+		wantErr bool
+	}{
+		{"one bank", romImage(1, 1, false), 1, false},
+		{"two banks", romImage(2, 2, false), 2, false},
+		{"trainer", romImage(1, 2, true), 2, false},
+		{"truncated PRG", romImage(2, 1, false)[:16+0x4000], 0, true},
+		{"not a ROM", []byte("not a ROM image"), 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := extractCHR(bytes.NewReader(test.image))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("extractCHR() error = %v, want error %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+
+			// exactly the CHR ROM, each bank filled with its number
+			data, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := make([]byte, 0, test.banks*kCHRSize)
+			for i := 0; i < test.banks; i++ {
+				want = append(want, bytes.Repeat([]byte{uint8(i + 1)}, kCHRSize)...)
+			}
+			if !bytes.Equal(data, want) {
+				t.Errorf("extracted %v bytes, want the %v banks of CHR ROM", len(data), test.banks)
+			}
+		})
+	}
+}
+
+func TestIndexedColorModel(t *testing.T) {
+	setDefaults(t)
+	indexed = true
+
+	img := drawPage(make([]byte, kCHRSize))
+	p, ok := img.ColorModel().(color.Palette)
+	if !ok {
+		t.Fatalf("color model is %T, want color.Palette", img.ColorModel())
+	}
+	if len(p) != 4 {
+		t.Fatalf("palette has %v colors, want 4", len(p))
+	}
+	for i := range p {
+		if p[i] != spriteColor(uint8(i)) {
+			t.Errorf("color %v = %v, want %v", i, p[i], spriteColor(uint8(i)))
+		}
+	}
+
+	// the encoded PNG keeps the palette
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := decoded.ColorModel().(color.Palette); !ok || len(p) != 4 {
+		t.Errorf("decoded color model is %T of %v colors, want a palette of 4", decoded.ColorModel(), len(p))
+	}
+
+	// the grid color is a fifth entry
+	grid = true
+	pageImage = nil
+	if p := drawPage(make([]byte, kCHRSize)).ColorModel().(color.Palette); len(p) != 5 || p[4] != gridColor {
+		t.Errorf("palette with grid = %v, want the 4 colors and %v", p, gridColor)
+	}
+}
+
+func TestSelectPaletteBlock(t *testing.T) {
+	// two blocks, the second one marked by its first byte
+	data := make([]byte, 2*kPaletteSize*kRGBSize)
+	data[kPaletteSize*kRGBSize] = 0x42
+
+	block, err := selectPaletteBlock(data, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block) != kPaletteSize*kRGBSize || block[0] != 0x42 {
+		t.Errorf("block 1 has %v bytes starting with %#02x, want 192 starting with 0x42", len(block), block[0])
+	}
+	if block, err := selectPaletteBlock(data, 0); err != nil || block[0] != 0x00 {
+		t.Errorf("block 0 = %v, %v", block, err)
+	}
+
+	tests := []struct {
+		name  string
+		size  int
+		index int
+	}{
+		{"empty", 0, 0},
+		{"short", 191, 0},
+		{"not a multiple", 193, 0},
+		{"index past the end", 384, 2},
+		{"negative index", 384, -1},
+	}
+	for _, test := range tests {
+		if _, err := selectPaletteBlock(make([]byte, test.size), test.index); err == nil {
+			t.Errorf("%v: selectPaletteBlock(%v bytes, %v) returned no error", test.name, test.size, test.index)
+		}
+	}
+}
+
+func TestDrawSwatches(t *testing.T) {
+	pal, ok := nespalette.Get("RGB")
+	if !ok {
+		t.Fatal("RGB palette missing")
+	}
+	img := drawSwatches(pal)
+	if b := img.Bounds(); b.Dx() != 16*kSwatchSize || b.Dy() != 4*kSwatchSize {
+		t.Fatalf("bounds = %v, want 256x64", b)
+	}
+
+	// the bottom right corner of a swatch is clear of the label
+	for i := 0; i < kPaletteSize; i++ {
+		x := i%16*kSwatchSize + kSwatchSize - 1
+		y := i/16*kSwatchSize + kSwatchSize - 1
+		want := color.RGBA{pal[i*3], pal[i*3+1], pal[i*3+2], 255}
+		if got := img.RGBAAt(x, y); got != want {
+			t.Errorf("swatch %#02x = %v, want %v", i, got, want)
+		}
+	}
+
+	// the top left pixel of a label is set for all digits but 1 and 4, it is
+	// dark on bright colors
+	tests := []struct {
+		index         int
+		swatch, label color.RGBA
+	}{
+		{0x20, color.RGBA{255, 255, 255, 255}, color.RGBA{0, 0, 0, 255}},          // white
+		{0x30, color.RGBA{255, 255, 255, 255}, color.RGBA{0, 0, 0, 255}},          // white
+		{0x00, color.RGBA{0x6d, 0x6d, 0x6d, 255}, color.RGBA{255, 255, 255, 255}}, // grey
+		{0x3F, color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255}},          // black
+	}
+	for _, tt := range tests {
+		ox, oy := tt.index%16*kSwatchSize, tt.index/16*kSwatchSize
+		if got := img.RGBAAt(ox+kSwatchSize-1, oy); got != tt.swatch {
+			t.Errorf("swatch %#02x = %v, want %v", tt.index, got, tt.swatch)
+		}
+		if got := img.RGBAAt(ox+1, oy+1); got != tt.label {
+			t.Errorf("label of %#02x = %v, want %v", tt.index, got, tt.label)
+		}
+	}
+}
+
+func TestDrawGrid(t *testing.T) {
+	setDefaults(t)
+	grid = true
+	gridColor = color.RGBA{0x12, 0x34, 0x56, 255}
+
+	// a single tile of color 3, at 1,1 inside its lines
+	img := newPageImage().(*image.RGBA)
+	drawBank(img, 0, bytes.Repeat([]byte{0xFF}, kTileSize))
+
+	for y := 0; y <= 9; y++ {
+		for x := 0; x <= 9; x++ {
+			want := spriteColor(3)
+			if x == 0 || x == 9 || y == 0 || y == 9 {
+				want = gridColor
+			}
+			if got := img.RGBAAt(x, y); got != want {
+				t.Errorf("pixel (%v, %v) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+
+	// the lines of the last tile close the bank
+	b := img.Bounds()
+	for _, p := range []image.Point{{b.Dx() - 1, 0}, {b.Dx() - 1, b.Dy() - 1}, {0, b.Dy() - 1}} {
+		if got := img.RGBAAt(p.X, p.Y); got != gridColor {
+			t.Errorf("corner %v = %v, want %v", p, got, gridColor)
+		}
+	}
+}
+
+func TestScaleImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	src.SetRGBA(1, 0, red)
+	src.SetRGBA(3, 1, blue)
+
+	img, err := scaleImage(src, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := img.(*image.RGBA)
+	if b := dst.Bounds(); b.Dx() != 12 || b.Dy() != 6 {
+		t.Fatalf("bounds = %v, want 12x6", b)
+	}
+
+	// every source pixel is a 3x3 block
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 12; x++ {
+			if got, want := dst.RGBAAt(x, y), src.RGBAAt(x/3, y/3); got != want {
+				t.Errorf("pixel (%v, %v) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+	if dst.RGBAAt(3, 0) != red || dst.RGBAAt(5, 2) != red || dst.RGBAAt(11, 5) != blue {
+		t.Errorf("replicated blocks misplaced")
+	}
+
+	// indexed images keep their palette
+	p := image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{red, blue})
+	p.SetColorIndex(1, 1, 1)
+	img, err = scaleImage(p, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dp := img.(*image.Paletted); len(dp.Palette) != 2 || dp.ColorIndexAt(5, 5) != 1 || dp.ColorIndexAt(2, 2) != 0 {
+		t.Errorf("scaled paletted image is wrong")
+	}
+
+	// too large
+	if _, err := scaleImage(src, 1<<16); err == nil {
+		t.Errorf("scaleImage() of a 4x2 image 65536 times returned no error")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"strings"
@@ -62,7 +63,56 @@ func extractSection(r io.Reader, outputPath string, size int) error {
 	return nil
 }
 
-func ExtractROM(romFile string) error {
+// PlayChoice-10 games have an 8KB INST-ROM with the hint screen data after
+// CHR, optionally followed by 16 bytes of PROM data and 16 bytes of PROM
+// CounterOut
+const (
+	pc10INSTROMSize = 8 * 1024
+	pc10PROMSize    = 32
+)
+
+// extractPC10 extracts the PlayChoice-10 data to outputPath, returns false
+// if the ROM has no such data
+func extractPC10(r io.Reader, outputPath string) (bool, error) {
+	buf := make([]byte, pc10INSTROMSize+pc10PROMSize)
+	n, err := io.ReadFull(r, buf)
+	if n == 0 {
+		return false, nil
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	if n != pc10INSTROMSize && n != len(buf) {
+		return false, ErrorInvalidROM
+	}
+
+	w, err := makeFile(outputPath)
+	if err != nil {
+		return false, err
+	}
+	defer w.Close()
+
+	if _, err = w.Write(buf[:n]); err != nil {
+		return false, err
+	}
+	w.Sync()
+
+	return true, nil
+}
+
+// skipSection reads size bytes from r and drops them
+func skipSection(r io.Reader, size int) error {
+	n, err := io.CopyN(ioutil.Discard, r, int64(size))
+	if n != int64(size) {
+		return ErrorInvalidROM
+	}
+	return err
+}
+
+// ExtractROM splits romFile into its sections in a directory named after
+// it and prints CRC32 and SHA-1 of PRG, CHR and both combined. With
+// hashOnly nothing is extracted, only the digests are printed.
+func ExtractROM(romFile string, hashOnly bool) error {
 	// open NES Rom
 	r, err := os.Open(romFile)
 	defer r.Close()
@@ -70,36 +120,69 @@ func ExtractROM(romFile string) error {
 		return err
 	}
 	// create output dir
-	outputDir, err := makeOutputDir(romFile)
-	if err != nil {
-		return err
+	var outputDir string
+	if !hashOnly {
+		outputDir, err = makeOutputDir(romFile)
+		if err != nil {
+			return err
+		}
 	}
 	// header
 	header := NewHeader(r)
 	if header == nil {
 		return ErrorInvalidHeader
 	}
-	fmt.Println(header)
+	if !hashOnly {
+		fmt.Println(header)
+	}
+
+	section := func(r io.Reader, name string, size int) error {
+		if hashOnly {
+			return skipSection(r, size)
+		}
+		return extractSection(r, path.Join(outputDir, name), size)
+	}
 
 	// trainer
 	if header.Trainer() {
-		err := extractSection(r, path.Join(outputDir, "TRAINER.bin"), 512)
+		err := section(r, "TRAINER.bin", 512)
 		if err != nil {
 			return err
 		}
 	}
+
+	// digests exclude header and trainer
+	prgDigest := NewDigest()
+	chrDigest := NewDigest()
+	romDigest := NewDigest()
+
 	if header.PRGROMSize() != 0 {
-		err := extractSection(r, path.Join(outputDir, "PRGROM.bin"), header.PRGROMSize())
+		tee := io.TeeReader(r, io.MultiWriter(prgDigest, romDigest))
+		err := section(tee, "PRGROM.bin", header.PRGROMSize())
 		if err != nil {
 			return err
 		}
 	}
 	if header.CHRROMSize() != 0 {
-		err := extractSection(r, path.Join(outputDir, "CHRROM.bin"), header.CHRROMSize())
+		tee := io.TeeReader(r, io.MultiWriter(chrDigest, romDigest))
+		err := section(tee, "CHRROM.bin", header.CHRROMSize())
 		if err != nil {
 			return err
 		}
 	}
+	if header.PlayChoice10() && !hashOnly {
+		found, err := extractPC10(r, path.Join(outputDir, "PC10.bin"))
+		if err != nil {
+			return err
+		}
+		if !found {
+			fmt.Println("PlayChoice-10 flag is set but the ROM has no INST-ROM data")
+		}
+	}
+
+	fmt.Printf("PRG %v\n", prgDigest)
+	fmt.Printf("CHR %v\n", chrDigest)
+	fmt.Printf("ROM %v\n", romDigest)
 
 	return nil
 }
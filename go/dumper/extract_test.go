@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractPC10(t *testing.T) {
+	tests := []struct {
+		name  string
+		size  int
+		found bool
+		err   error
+	}{
+		{"absent", 0, false, nil},
+		{"INST-ROM", pc10INSTROMSize, true, nil},
+		{"INST-ROM with PROM", pc10INSTROMSize + pc10PROMSize, true, nil},
+		{"truncated", 100, false, ErrorInvalidROM},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := make([]byte, test.size)
+			for i := range data {
+				data[i] = byte(i)
+			}
+			out := filepath.Join(t.TempDir(), "PC10.bin")
+
+			found, err := extractPC10(bytes.NewReader(data), out)
+			if found != test.found || err != test.err {
+				t.Fatalf("extractPC10 = %v, %v, want %v, %v", found, err, test.found, test.err)
+			}
+
+			written, err := os.ReadFile(out)
+			if !test.found {
+				if !os.IsNotExist(err) {
+					t.Errorf("PC10.bin written without PC-10 data, err = %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(written, data) {
+				t.Errorf("PC10.bin has %v bytes, want the %v bytes after CHR", len(written), len(data))
+			}
+		})
+	}
+}
+
+func TestExtractROMPlayChoice10(t *testing.T) {
+	// one 16KB PRG bank, one 8KB CHR bank and the INST-ROM with PROM
+	const size = HeaderSize + 16*1024 + 8*1024 + pc10INSTROMSize + pc10PROMSize
+	path := writeROM(t, 1, 1, 0x00, 0x02, size)
+
+	// the output directory is created in the working directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	if err := ExtractROM(path, false); err != nil {
+		t.Fatalf("ExtractROM: %v", err)
+	}
+
+	sizes := map[string]int64{
+		"PRGROM.bin": 16 * 1024,
+		"CHRROM.bin": 8 * 1024,
+		"PC10.bin":   pc10INSTROMSize + pc10PROMSize,
+	}
+	for name, want := range sizes {
+		info, err := os.Stat(filepath.Join("test", name))
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+			continue
+		}
+		if info.Size() != want {
+			t.Errorf("%v is %v bytes, want %v", name, info.Size(), want)
+		}
+	}
+}
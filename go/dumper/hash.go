@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"hash"
+	"hash/crc32"
+)
+
+// Digest computes CRC32 and SHA-1 of the data written to it, used to
+// identify ROMs against databases
+type Digest struct {
+	crc hash.Hash32
+	sha hash.Hash
+}
+
+// NewDigest creates an empty digest
+func NewDigest() *Digest {
+	return &Digest{
+		crc: crc32.NewIEEE(),
+		sha: sha1.New(),
+	}
+}
+
+// Write implements io.Writer
+func (d *Digest) Write(p []byte) (int, error) {
+	d.crc.Write(p)
+	d.sha.Write(p)
+	return len(p), nil
+}
+
+// CRC32 returns the CRC32 of the data written so far
+func (d *Digest) CRC32() uint32 {
+	return d.crc.Sum32()
+}
+
+// SHA1 returns the SHA-1 of the data written so far
+func (d *Digest) SHA1() []byte {
+	return d.sha.Sum(nil)
+}
+
+func (d *Digest) String() string {
+	return fmt.Sprintf("CRC32: %08X SHA-1: %X", d.CRC32(), d.SHA1())
+}
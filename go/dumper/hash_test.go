@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDigest(t *testing.T) {
+	tests := []struct {
+		data string
+		crc  uint32
+		sha  string
+	}{
+		{"", 0x00000000, "DA39A3EE5E6B4B0D3255BFEF95601890AFD80709"},
+		{"123456789", 0xCBF43926, "F7C3BC1D808E04732ADF679965CCC34CA7AE3441"},
+		{"The quick brown fox jumps over the lazy dog", 0x414FA339, "2FD4E1C67A2D28FCED849EE1BB76E7391B93EB12"},
+	}
+
+	for _, test := range tests {
+		d := NewDigest()
+		// written in two parts, as the sections are streamed
+		half := len(test.data) / 2
+		io.WriteString(d, test.data[:half])
+		io.WriteString(d, test.data[half:])
+
+		if d.CRC32() != test.crc {
+			t.Errorf("%q: CRC32 = %08X, want %08X", test.data, d.CRC32(), test.crc)
+		}
+		if sha := fmt.Sprintf("%X", d.SHA1()); sha != test.sha {
+			t.Errorf("%q: SHA-1 = %v, want %v", test.data, sha, test.sha)
+		}
+		want := fmt.Sprintf("CRC32: %08X SHA-1: %v", test.crc, test.sha)
+		if d.String() != want {
+			t.Errorf("%q: String() = %q, want %q", test.data, d.String(), want)
+		}
+	}
+}
+
+// captureStdout returns what f prints to stdout
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- string(out)
+	}()
+	f()
+	w.Close()
+	return <-done
+}
+
+func TestExtractROMHashOnly(t *testing.T) {
+	// header, trainer, one 16KB PRG bank and one 8KB CHR bank
+	const prgSize, chrSize = 16 * 1024, 8 * 1024
+	data := make([]byte, HeaderSize+512+prgSize+chrSize)
+	copy(data, []byte{'N', 'E', 'S', 0x1A, 1, 1, 0x04, 0x00})
+	for i := HeaderSize; i < len(data); i++ {
+		data[i] = byte(i * 7)
+	}
+	path := filepath.Join(t.TempDir(), "test.nes")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	prg := data[HeaderSize+512 : HeaderSize+512+prgSize]
+	chr := data[HeaderSize+512+prgSize:]
+	digest := func(sections ...[]byte) string {
+		d := NewDigest()
+		for _, s := range sections {
+			d.Write(s)
+		}
+		return d.String()
+	}
+
+	var err error
+	out := captureStdout(t, func() { err = ExtractROM(path, true) })
+	if err != nil {
+		t.Fatalf("ExtractROM: %v", err)
+	}
+
+	// only the digests are printed and they exclude header and trainer
+	want := "PRG " + digest(prg) + "\n" +
+		"CHR " + digest(chr) + "\n" +
+		"ROM " + digest(prg, chr) + "\n"
+	if out != want {
+		t.Errorf("ExtractROM printed\n%v\nwant\n%v", out, want)
+	}
+}
@@ -101,9 +101,19 @@ func NewHeader(r io.Reader) *Header {
 	h.PRGRAM = buf[8]
 	h.Flag9 = buf[9]
 	h.Flag10 = buf[10]
-	copy(h.padding[:], buf[10:])
-	if bytes.Compare(h.padding[:], standardPadding) != 0 {
-		return nil
+	copy(h.padding[:], buf[11:16])
+	if h.Flag7&0x0C != 0x08 && bytes.Compare(h.padding[:], standardPadding) != 0 {
+		// bytes 11-15 of iNES 1.0 headers should be zero, but some old ROM
+		// tools wrote their signature ("DiskDude!" etc.) from byte 7 on.
+		// When any of bytes 12-15 is set, bytes 7-15 are all garbage and
+		// cleared as NES 2.0 aware tools do, a stray byte 11 is just masked
+		if bytes.Compare(h.padding[1:], standardPadding[1:]) != 0 {
+			h.Flag7 = 0
+			h.PRGRAM = 0
+			h.Flag9 = 0
+			h.Flag10 = 0
+		}
+		copy(h.padding[:], standardPadding)
 	}
 
 	return h
@@ -274,3 +284,49 @@ BUS Conflict: %v`,
 		h.BusConflict(),
 	)
 }
+
+// HeaderInfo is the machine readable form of a Header
+type HeaderInfo struct {
+	Version        string `json:"version"`
+	Mapper         uint8  `json:"mapper"`
+	MapperName     string `json:"mapperName"`
+	PRGROMSize     int    `json:"prgRomSize"`
+	CHRROMSize     int    `json:"chrRomSize"`
+	PRGRAMSize     int    `json:"prgRamSize"`
+	Mirroring      string `json:"mirroring"`
+	FourScreen     bool   `json:"fourScreen"`
+	Trainer        bool   `json:"trainer"`
+	PersistentSRAM bool   `json:"persistentSram"`
+	PlayChoice10   bool   `json:"playChoice10"`
+	VsUnisystem    bool   `json:"vsUnisystem"`
+	TVSystem       string `json:"tvSystem"`
+	TVCompatible   string `json:"tvCompatible"`
+	PRGRAMPresent  bool   `json:"prgRamPresent"`
+	BusConflict    bool   `json:"busConflict"`
+}
+
+// Info returns the header as HeaderInfo, sizes are in bytes
+func (h *Header) Info() *HeaderInfo {
+	ver := "iNES1.0"
+	if h.NES20() {
+		ver = "iNES2.0"
+	}
+	return &HeaderInfo{
+		Version:        ver,
+		Mapper:         h.Mapper(),
+		MapperName:     getMapper(int(h.Mapper())),
+		PRGROMSize:     h.PRGROMSize(),
+		CHRROMSize:     h.CHRROMSize(),
+		PRGRAMSize:     h.PRGRAMSize() * 1024,
+		Mirroring:      h.Mirroring().String(),
+		FourScreen:     h.FourScreenMode(),
+		Trainer:        h.Trainer(),
+		PersistentSRAM: h.PersistentSRAM(),
+		PlayChoice10:   h.PlayChoice10(),
+		VsUnisystem:    h.Vs(),
+		TVSystem:       h.TVSystem().String(),
+		TVCompatible:   h.TVCompatible().String(),
+		PRGRAMPresent:  h.PRGRAMPresent(),
+		BusConflict:    h.BusConflict(),
+	}
+}
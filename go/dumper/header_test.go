@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestHeaderPadding(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		mapper      uint8
+		tvSystem    TVSystemType
+		busConflict bool
+		prgRAM      bool
+	}{
+		{
+			"bus conflict",
+			[]byte{'N', 'E', 'S', 0x1A, 2, 1, 0x20, 0x00, 0x00, 0x00, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00},
+			2, TVSystemNTSC, true, true,
+		},
+		{
+			// only byte 11 is dirty, bytes 7-10 are kept
+			"stray byte 11",
+			[]byte{'N', 'E', 'S', 0x1A, 2, 1, 0x20, 0x00, 0x00, 0x01, 0x30, 0xAA, 0x00, 0x00, 0x00, 0x00},
+			2, TVSystemPAL, true, false,
+		},
+		{
+			// "DiskDude!" from byte 7 on, 's' in byte 9 reads as PAL and
+			// 'k' in byte 10 as bus conflict without PRG RAM
+			"DiskDude",
+			append([]byte{'N', 'E', 'S', 0x1A, 2, 1, 0x10}, []byte("DiskDude!")...),
+			1, TVSystemNTSC, false, true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := NewHeader(bytes.NewReader(test.data))
+			if h == nil {
+				t.Fatal("NewHeader() = nil")
+			}
+			if got := h.Mapper(); got != test.mapper {
+				t.Errorf("Mapper() = %v, want %v", got, test.mapper)
+			}
+			if got := h.TVSystem(); got != test.tvSystem {
+				t.Errorf("TVSystem() = %v, want %v", got, test.tvSystem)
+			}
+			if got := h.BusConflict(); got != test.busConflict {
+				t.Errorf("BusConflict() = %v, want %v", got, test.busConflict)
+			}
+			if got := h.PRGRAMPresent(); got != test.prgRAM {
+				t.Errorf("PRGRAMPresent() = %v, want %v", got, test.prgRAM)
+			}
+			if h.padding != [5]byte{} {
+				t.Errorf("padding = % x, want zeros", h.padding)
+			}
+		})
+	}
+}
+
+func TestHeaderInfo(t *testing.T) {
+	// MMC1 with battery and vertical mirroring, 16KB of PRG RAM, PAL
+	// without PRG RAM in the unofficial flag 10
+	data := []byte{'N', 'E', 'S', 0x1A, 8, 0, 0x13, 0x00, 0x02, 0x01, 0x12, 0, 0, 0, 0, 0}
+	h := NewHeader(bytes.NewReader(data))
+	if h == nil {
+		t.Fatal("NewHeader() = nil")
+	}
+
+	want := HeaderInfo{
+		Version:        "iNES1.0",
+		Mapper:         1,
+		MapperName:     "MMC1",
+		PRGROMSize:     128 * 1024,
+		CHRROMSize:     0,
+		PRGRAMSize:     16 * 1024,
+		Mirroring:      "Vertical",
+		PersistentSRAM: true,
+		TVSystem:       "PAL",
+		TVCompatible:   "PAL",
+	}
+	info := h.Info()
+	if *info != want {
+		t.Errorf("Info() = %+v, want %+v", *info, want)
+	}
+
+	// what -json prints decodes back to the same info
+	out, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{`"mapperName": "MMC1"`, `"prgRomSize": 131072`, `"persistentSram": true`} {
+		if !strings.Contains(string(out), key) {
+			t.Errorf("JSON has no %v:\n%s", key, out)
+		}
+	}
+	var decoded HeaderInfo
+	if err := json.Unmarshal(out, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != want {
+		t.Errorf("decoded JSON = %+v, want %+v", decoded, want)
+	}
+}
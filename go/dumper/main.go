@@ -1,6 +1,8 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
 )
@@ -13,16 +15,43 @@ func checkErr(err error) {
 }
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("usage: dumper rom")
+	jsonOut := flag.Bool("json", false, "print the header as JSON instead of extracting")
+	hashOnly := flag.Bool("hash-only", false, "print CRC32 and SHA-1 of the ROM without extracting")
+	verify := flag.Bool("verify", false, "check the file size against the header instead of extracting")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		fmt.Println("usage: dumper [-json] [-hash-only] [-verify] rom")
 		os.Exit(0)
 	}
+	romFile := flag.Arg(0)
 
-	f, err := os.Open(os.Args[1])
+	f, err := os.Open(romFile)
 	defer f.Close()
 	checkErr(err)
 
-	if err = ExtractROM(os.Args[1]); err != nil {
+	if *jsonOut {
+		header := NewHeader(f)
+		if header == nil {
+			checkErr(ErrorInvalidHeader)
+		}
+		data, err := json.MarshalIndent(header.Info(), "", "  ")
+		checkErr(err)
+		fmt.Println(string(data))
+		return
+	}
+
+	if *verify {
+		size, err := VerifyROM(romFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("OK: %v bytes\n", size)
+		return
+	}
+
+	if err = ExtractROM(romFile, *hashOnly); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// SizeMismatchError is returned by VerifyROM when the file size differs
+// from the size declared by the header
+type SizeMismatchError struct {
+	Expected int64
+	Actual   int64
+}
+
+func (e *SizeMismatchError) Error() string {
+	return fmt.Sprintf("size mismatch: header declares %v bytes, file has %v bytes (%+d)",
+		e.Expected, e.Actual, e.Actual-e.Expected)
+}
+
+// ExpectedSize returns the file size declared by the header, that is the
+// header itself, the trainer, PRG, CHR and the PlayChoice-10 INST-ROM
+func (h *Header) ExpectedSize() int64 {
+	size := int64(HeaderSize + h.PRGROMSize() + h.CHRROMSize())
+	if h.Trainer() {
+		size += 512
+	}
+	if h.PlayChoice10() {
+		size += pc10INSTROMSize
+	}
+	return size
+}
+
+// VerifyROM checks the size of romFile against its header, it returns the
+// expected size and a *SizeMismatchError if they differ. The PROM data of
+// PlayChoice-10 games is optional and accepted after the INST-ROM
+func VerifyROM(romFile string) (int64, error) {
+	r, err := os.Open(romFile)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	header := NewHeader(r)
+	if header == nil {
+		return 0, ErrorInvalidHeader
+	}
+	st, err := r.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	expected, actual := header.ExpectedSize(), st.Size()
+	if actual == expected || (header.PlayChoice10() && actual == expected+pc10PROMSize) {
+		return expected, nil
+	}
+	return expected, &SizeMismatchError{Expected: expected, Actual: actual}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeROM writes a ROM with the given header flags and size to a
+// temporary file and returns its path
+func writeROM(t *testing.T, prg, chr, flag6, flag7 uint8, size int) string {
+	t.Helper()
+	data := make([]byte, size)
+	copy(data, []byte{'N', 'E', 'S', 0x1A, prg, chr, flag6, flag7})
+
+	path := filepath.Join(t.TempDir(), "test.nes")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVerifyROM(t *testing.T) {
+	// one 16KB PRG bank and one 8KB CHR bank
+	const nrom = HeaderSize + 16*1024 + 8*1024
+
+	tests := []struct {
+		name         string
+		flag6, flag7 uint8
+		size         int
+		expected     int64
+		mismatch     bool
+	}{
+		{"exact", 0x00, 0x00, nrom, nrom, false},
+		{"truncated", 0x00, 0x00, nrom - 100, nrom, true},
+		{"truncated header only", 0x00, 0x00, HeaderSize, nrom, true},
+		{"trailing bytes", 0x00, 0x00, nrom + 1, nrom, true},
+		{"trainer", 0x04, 0x00, nrom + 512, nrom + 512, false},
+		{"trainer missing", 0x04, 0x00, nrom, nrom + 512, true},
+		{"PlayChoice-10", 0x00, 0x02, nrom + pc10INSTROMSize, nrom + pc10INSTROMSize, false},
+		{"PlayChoice-10 with PROM", 0x00, 0x02, nrom + pc10INSTROMSize + pc10PROMSize, nrom + pc10INSTROMSize, false},
+		{"PlayChoice-10 truncated", 0x00, 0x02, nrom + 100, nrom + pc10INSTROMSize, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeROM(t, 1, 1, test.flag6, test.flag7, test.size)
+
+			expected, err := VerifyROM(path)
+			if expected != test.expected {
+				t.Errorf("expected size = %v, want %v", expected, test.expected)
+			}
+
+			var mismatch *SizeMismatchError
+			if !test.mismatch {
+				if err != nil {
+					t.Errorf("VerifyROM: %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &mismatch) {
+				t.Fatalf("VerifyROM error = %v, want a size mismatch", err)
+			}
+			if mismatch.Expected != test.expected || mismatch.Actual != int64(test.size) {
+				t.Errorf("mismatch = %v/%v bytes, want %v/%v", mismatch.Actual, mismatch.Expected, test.size, test.expected)
+			}
+		})
+	}
+}
+
+func TestVerifyROMTruncatedMessage(t *testing.T) {
+	path := writeROM(t, 2, 1, 0x00, 0x00, 20000)
+
+	_, err := VerifyROM(path)
+	want := "size mismatch: header declares 40976 bytes, file has 20000 bytes (-20976)"
+	if err == nil || err.Error() != want {
+		t.Errorf("VerifyROM error = %v, want %q", err, want)
+	}
+}
+
+func TestVerifyROMInvalid(t *testing.T) {
+	if _, err := VerifyROM(writeROM(t, 1, 1, 0, 0, 8)); err != ErrorInvalidHeader {
+		t.Errorf("short file: error = %v, want %v", err, ErrorInvalidHeader)
+	}
+	if _, err := VerifyROM(filepath.Join(t.TempDir(), "missing.nes")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: error = %v, want not exist", err)
+	}
+}
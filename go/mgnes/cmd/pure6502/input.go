@@ -0,0 +1,116 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// prompt is a one line text entry shown in place of the tips, key events
+// go to it while it is open
+type prompt struct {
+	label  string
+	text   string
+	err    string
+	submit func(text string) error
+}
+
+// handle processes a key event, it returns true once the prompt is closed
+// by a successful submit or by escape
+func (p *prompt) handle(id string) (closed bool) {
+	switch id {
+	case "<Escape>", "<C-c>":
+		return true
+	case "<Enter>":
+		if err := p.submit(p.text); err != nil {
+			p.err = err.Error()
+			return false
+		}
+		return true
+	case "<Backspace>", "<C-<Backspace>>":
+		if len(p.text) > 0 {
+			p.text = p.text[:len(p.text)-1]
+		}
+	case "<Space>":
+		p.text += " "
+	default:
+		// printable characters are reported as themselves
+		if len(id) == 1 {
+			p.text += id
+		}
+	}
+	p.err = ""
+	return false
+}
+
+// String returns the prompt line
+func (p *prompt) String() string {
+	line := p.label + ": " + p.text + "_"
+	if p.err != "" {
+		line += "    [" + p.err + "](fg:red)"
+	}
+	return line
+}
+
+// parseHex parses a hexadecimal number of at most bitSize bits, with an
+// optional "$" or "0x" prefix
+func parseHex(s string, bitSize int) (uint64, error) {
+	s = strings.TrimPrefix(s, "$")
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		s = s[2:]
+	}
+	if s == "" {
+		return 0, errors.New("missing value")
+	}
+	v, err := strconv.ParseUint(s, 16, bitSize)
+	if err != nil {
+		return 0, errors.New("invalid hex value " + s)
+	}
+	return v, nil
+}
+
+// parseAddr parses a 16-bit hexadecimal address
+func parseAddr(s string) (uint16, error) {
+	v, err := parseHex(strings.TrimSpace(s), 16)
+	return uint16(v), err
+}
+
+// parsePoke parses "address value", both in hexadecimal, e.g. "$0200 FF"
+func parsePoke(s string) (addr uint16, value uint8, err error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		err = errors.New("expected address and value")
+		return
+	}
+
+	var v uint64
+	if v, err = parseHex(fields[0], 16); err != nil {
+		return
+	}
+	addr = uint16(v)
+	if v, err = parseHex(fields[1], 8); err != nil {
+		return
+	}
+	value = uint8(v)
+	return
+}
@@ -0,0 +1,81 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import "testing"
+
+func TestParseAddr(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    uint16
+		wantErr bool
+	}{
+		{"0200", 0x0200, false},
+		{"$C000", 0xC000, false},
+		{"0xfffc", 0xFFFC, false},
+		{"0X12", 0x0012, false},
+		{" $8000 ", 0x8000, false},
+		{"$", 0, true},
+		{"0x", 0, true},
+		{"", 0, true},
+		{"$10000", 0, true},
+		{"$$12", 0, true},
+		{"12G4", 0, true},
+		{"-1", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseAddr(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseAddr(%q) = %#04x, %v, want %#04x, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParsePoke(t *testing.T) {
+	tests := []struct {
+		in      string
+		addr    uint16
+		value   uint8
+		wantErr bool
+	}{
+		{"$0200 FF", 0x0200, 0xFF, false},
+		{"0x10 $7", 0x0010, 0x07, false},
+		{"  4016\t01 ", 0x4016, 0x01, false},
+		{"$0200", 0, 0, true},
+		{"$0200 FF 00", 0, 0, true},
+		{"", 0, 0, true},
+		{"$ FF", 0, 0, true},
+		{"$0200 0x", 0, 0, true},
+		{"$0200 100", 0, 0, true},
+		{"$10000 FF", 0, 0, true},
+		{"$0200 ZZ", 0, 0, true},
+	}
+	for _, tt := range tests {
+		addr, value, err := parsePoke(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parsePoke(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (addr != tt.addr || value != tt.value) {
+			t.Errorf("parsePoke(%q) = %#04x, %#02x, want %#04x, %#02x", tt.in, addr, value, tt.addr, tt.value)
+		}
+	}
+}
@@ -23,45 +23,45 @@ package main
 import (
 	"fmt"
 	"log"
+	"mgnes/pkg/debugger"
 	"mgnes/pkg/mg6502"
 	"strings"
+	"time"
 
 	ui "github.com/gizak/termui/v3"
 	"github.com/gizak/termui/v3/widgets"
 )
 
 var (
-	cpu           *mg6502.MG6502
-	reader        mg6502.Reader
-	disassembly   *mg6502.Disassembly
-	paragraphCPU  *widgets.Paragraph
-	paragraphCode *widgets.Paragraph
-	paragraphRam0 *widgets.Paragraph
-	paragraphRam1 *widgets.Paragraph
-	paragraphTips *widgets.Paragraph
+	cpu            *mg6502.MG6502
+	dbg            *debugger.Debugger
+	bus            *PlainBus
+	ramView        []byte
+	disassembly    *mg6502.Disassembly
+	paragraphCPU   *widgets.Paragraph
+	paragraphCode  *widgets.Paragraph
+	paragraphRam0  *widgets.Paragraph
+	paragraphRam1  *widgets.Paragraph
+	paragraphTips  *widgets.Paragraph
+	paragraphWatch *widgets.Paragraph
+	watches        debugger.Watches
+	input          *prompt
+	running        *runner
 )
 
 func renderCpu(p *widgets.Paragraph) {
 	sb := &strings.Builder{}
-	flags := []uint8{
-		mg6502.FlagNegative,
-		mg6502.FlagOverflow,
-		mg6502.FlagUnused,
-		mg6502.FlagBreak,
-		mg6502.FlagDecimal,
-		mg6502.FlagInterrupt,
-		mg6502.FlagZero,
-		mg6502.FlagCarry,
-	}
+	f := cpu.Flags()
+	flags := []bool{f.N, f.V, f.U, f.B, f.D, f.I, f.Z, f.C}
 	symbols := []rune{'N', 'V', '-', 'B', 'D', 'I', 'Z', 'C'}
 
 	sb.WriteString("STATUS: ")
-	for i, f := range flags {
+	for i, set := range flags {
 		sb.WriteRune('[')
 		sb.WriteRune(symbols[i])
 		sb.WriteRune(']')
 		sb.WriteString("(fg:")
-		if cpu.GetFlag(f) != 0 {
+		if set {
 			sb.WriteString("green")
 		} else {
 			sb.WriteString("red")
@@ -81,14 +81,17 @@ func renderCpu(p *widgets.Paragraph) {
 }
 
 func renderRam(p *widgets.Paragraph, addr uint16, numRow, numCol int) {
-	curAddr := addr
+	if len(ramView) < numRow*numCol {
+		ramView = make([]byte, numRow*numCol)
+	}
+	data := ramView[:numRow*numCol]
+	bus.PeekRange(addr, data)
 	sb := &strings.Builder{}
 	for row := 0; row < numRow; row++ {
-		sb.WriteString(fmt.Sprintf("$%04X:", curAddr))
+		sb.WriteString(fmt.Sprintf("$%04X:", addr+uint16(row*numCol)))
 		for col := 0; col < numCol; col++ {
 			sb.WriteRune(' ')
-			sb.WriteString(fmt.Sprintf("%02X", reader.CpuRead(curAddr, true)))
-			curAddr++
+			sb.WriteString(fmt.Sprintf("%02X", data[row*numCol+col]))
 		}
 		sb.WriteRune('\n')
 	}
@@ -98,27 +101,127 @@ func renderRam(p *widgets.Paragraph, addr uint16, numRow, numCol int) {
 func renderCode(p *widgets.Paragraph) {
 	sb := strings.Builder{}
 	pc := cpu.PC
-	for i := pc - 6; i <= pc+34; i++ {
-		if i > 0xFFFF {
-			i = i % 0xFFFF
-		}
-		for j := 0; j < len(disassembly.Index); j++ {
-			if disassembly.Index[j] == i {
-				line := disassembly.Stringify(i, 32)
-				if i == pc {
-					sb.WriteString(fmt.Sprintf("[%s](fg:cyan)", line))
-				} else {
-					sb.WriteString(line)
-				}
-				sb.WriteRune('\n')
+	// addresses wrap around at 0xFFFF
+	for n := uint16(0); n <= 40; n++ {
+		i := pc - 6 + n
+		if _, ok := disassembly.Lines[i]; ok {
+			line := disassembly.Stringify(i, 32)
+			if i == pc {
+				sb.WriteString(fmt.Sprintf("[%s](fg:cyan)", line))
+			} else {
+				sb.WriteString(line)
 			}
+			sb.WriteRune('\n')
 		}
 	}
 	p.Text = sb.String()
 }
 
 func renderTips(p *widgets.Paragraph) {
-	p.Text = "SPACE = Step Instruction    R = RESET    I = IRQ    N = NMI"
+	if input != nil {
+		p.Title = "Input"
+		p.Text = input.String()
+		return
+	}
+	p.Title = "Tips"
+	if running != nil {
+		p.Text = "Running...    P = Pause"
+		return
+	}
+	p.Text = "SPACE = Step  O = Over  G = Run  C = Run To  R = RESET  I = IRQ  N = NMI  E = Edit  W = Watch"
+}
+
+func renderWatches(p *widgets.Paragraph) {
+	p.Text = strings.Join(watches.Format(bus), "\n")
+}
+
+// watchPrompt asks for a watch to add
+func watchPrompt() *prompt {
+	return &prompt{
+		label: "Watch (label=addr or label=addr:2)",
+		submit: func(text string) error {
+			watch, err := debugger.ParseWatch(text)
+			if err != nil {
+				return err
+			}
+			return watches.Add(watch)
+		},
+	}
+}
+
+// pokePrompt asks for an address and a value to write to memory
+func pokePrompt() *prompt {
+	return &prompt{
+		label: "Write (address value)",
+		submit: func(text string) error {
+			addr, value, err := parsePoke(text)
+			if err != nil {
+				return err
+			}
+			bus.CpuWrite(addr, value)
+			// the write may have changed code
+			disassembly = cpu.Disassemble(0x0000, 0xFFFF)
+			return nil
+		},
+	}
+}
+
+// runToPrompt asks for the address to run to
+func runToPrompt() *prompt {
+	return &prompt{
+		label: "Run to address",
+		submit: func(text string) error {
+			addr, err := parseAddr(text)
+			if err != nil {
+				return err
+			}
+			running = startRunner(debugger.AtAddress(addr))
+			return nil
+		},
+	}
+}
+
+func isQuit(id string) bool {
+	return id == "q" || id == "Q" || id == "<C-c>"
+}
+
+func isPause(id string) bool {
+	return id == "p" || id == "P"
+}
+
+// handleKey processes a key press, it returns false to quit
+func handleKey(id string) bool {
+	if input != nil {
+		if input.handle(id) {
+			input = nil
+		}
+		return true
+	}
+
+	if isQuit(id) {
+		return false
+	}
+
+	if id == "<Space>" {
+		dbg.Step()
+	} else if id == "o" || id == "O" {
+		dbg.StepOver()
+	} else if id == "g" || id == "G" {
+		running = startRunner(nil)
+	} else if id == "c" || id == "C" {
+		input = runToPrompt()
+	} else if id == "r" || id == "R" {
+		cpu.Reset()
+	} else if id == "i" || id == "I" {
+		cpu.IRQ()
+	} else if id == "n" || id == "N" {
+		cpu.NMI()
+	} else if id == "e" || id == "E" {
+		input = pokePrompt()
+	} else if id == "w" || id == "W" {
+		input = watchPrompt()
+	}
+	return true
 }
 
 func draw() {
@@ -127,8 +230,9 @@ func draw() {
 	renderCpu(paragraphCPU)
 	renderCode(paragraphCode)
 	renderTips(paragraphTips)
+	renderWatches(paragraphWatch)
 
-	ui.Render(paragraphRam0, paragraphRam1, paragraphCPU, paragraphCode, paragraphCode, paragraphTips)
+	ui.Render(paragraphRam0, paragraphRam1, paragraphCPU, paragraphCode, paragraphCode, paragraphTips, paragraphWatch)
 }
 
 func loadCPU() {
@@ -139,30 +243,30 @@ func loadCPU() {
 		return
 	}
 
-	bus := &PlainBus{
+	bus = &PlainBus{
 		mem: make([]uint8, 65536),
 	}
 	cpu.SetWriter(bus)
 	cpu.SetReader(bus)
-	reader = bus
 	bus.Reset()
+	dbg = debugger.NewDebugger(cpuSystem{bus}, cpu)
 
-	// load bytecode
+	// load bytecode and set reset vector
 	codes := []byte{0xA2, 0x0A, 0x8E, 0x00, 0x00, 0xA2, 0x03, 0x8E, 0x01, 0x00, 0xAC, 0x00, 0x00, 0xA9, 0x00, 0x18, 0x6D, 0x01, 0x00, 0x88, 0xD0, 0xFA, 0x8D, 0x02, 0x00, 0xEA, 0xEA, 0xEA}
-	var offset uint16 = 0x8000
-	for i, b := range codes {
-		bus.CpuWrite(offset+uint16(i), b)
+	if err := bus.LoadProgram(0x8000, codes, 0x8000); err != nil {
+		log.Fatalf("could not load program: %v", err)
 	}
 
-	// set reset vector
-	bus.CpuWrite(0xFFFC, 0x00)
-	bus.CpuWrite(0xFFFD, 0x80)
-
 	// disassembly
 	disassembly = cpu.Disassemble(0x0000, 0xFFFF)
 
-	// reset
-	cpu.Reset()
+	// operands and result of the multiplication
+	watches.Add(debugger.Watch{Label: "a", Addr: 0x0000, Width: 1})
+	watches.Add(debugger.Watch{Label: "b", Addr: 0x0001, Width: 1})
+	watches.Add(debugger.Watch{Label: "result", Addr: 0x0002, Width: 1})
+
+	// power up
+	cpu.PowerUp()
 }
 
 func initLayout() {
@@ -185,10 +289,15 @@ func initLayout() {
 	paragraphCode.Title = "Disassembly"
 	paragraphCode.SetRect(56, 7, 56+34, 7+29)
 
+	// Watches
+	paragraphWatch = widgets.NewParagraph()
+	paragraphWatch.Title = "Watches"
+	paragraphWatch.SetRect(56+34, 0, 56+34+30, 36)
+
 	// Tips
 	paragraphTips = widgets.NewParagraph()
 	paragraphTips.Title = "Tips"
-	paragraphTips.SetRect(0, 36, 56+34, 39)
+	paragraphTips.SetRect(0, 36, 56+34+30, 39)
 }
 
 func main() {
@@ -202,23 +311,45 @@ func main() {
 
 	draw()
 
-	for e := range ui.PollEvents() {
-		if e.Type == ui.KeyboardEvent {
-			if e.ID == "q" || e.ID == "Q" || e.ID == "<C-c>" {
-				break
-			} else if e.ID == "<Space>" {
-				cpu.Clock()
-				for !cpu.Complete() {
-					cpu.Clock()
+	redraw := time.NewTicker(redrawInterval)
+	defer redraw.Stop()
+
+	events := ui.PollEvents()
+	for {
+		select {
+		case e := <-events:
+			if e.Type != ui.KeyboardEvent {
+				continue
+			}
+			if running != nil {
+				// only pausing and quitting are allowed while running, the
+				// runner is stopped before taking the lock it competes for
+				if !isPause(e.ID) && !isQuit(e.ID) {
+					continue
 				}
-			} else if e.ID == "r" || e.ID == "R" {
-				cpu.Reset()
-			} else if e.ID == "i" || e.ID == "I" {
-				cpu.IRQ()
-			} else if e.ID == "n" || e.ID == "N" {
-				cpu.NMI()
+				running.pause()
+				running = nil
+			}
+			machine.Lock()
+			quit := !handleKey(e.ID)
+			machine.Unlock()
+			if quit {
+				return
+			}
+		case <-redraw.C:
+			if running == nil {
+				continue
+			}
+			// the runner stops by itself when it reaches its target
+			select {
+			case <-running.done:
+				running = nil
+			default:
 			}
-			draw()
 		}
+
+		machine.Lock()
+		draw()
+		machine.Unlock()
 	}
 }
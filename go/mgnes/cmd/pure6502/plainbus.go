@@ -20,10 +20,14 @@
 
 package main
 
+import "errors"
+
 type PlainBus struct {
 	mem []uint8
 }
 
+// CpuRead reads plain memory, which has no side effects so readonly
+// reads are honored by nature
 func (bus *PlainBus) CpuRead(addr uint16, readonly bool) (data uint8) {
 	return bus.mem[int(addr)%len(bus.mem)]
 }
@@ -37,3 +41,42 @@ func (bus *PlainBus) Reset() {
 		bus.mem[i] = 0xFF
 	}
 }
+
+func (bus *PlainBus) DumpRange(start, end uint16) []byte {
+	data := make([]byte, int(end-start)+1)
+	bus.PeekRange(start, data)
+	return data
+}
+
+func (bus *PlainBus) PeekRange(start uint16, buf []byte) {
+	for i := range buf {
+		buf[i] = bus.CpuRead(start+uint16(i), true)
+	}
+}
+
+func (bus *PlainBus) LoadRange(start uint16, data []byte) {
+	for i, b := range data {
+		bus.CpuWrite(start+uint16(i), b)
+	}
+}
+
+func (bus *PlainBus) LoadProgram(offset uint16, code []byte, resetVector uint16) error {
+	if int(offset)+len(code) > 0x10000 {
+		return errors.New("program overflows the address space")
+	}
+
+	bus.LoadRange(offset, code)
+	bus.CpuWrite(0xFFFC, uint8(resetVector&0x00FF))
+	bus.CpuWrite(0xFFFD, uint8(resetVector>>8))
+
+	return nil
+}
+
+// cpuSystem lets the debugger clock the bare CPU on a plain bus
+type cpuSystem struct {
+	*PlainBus
+}
+
+func (s cpuSystem) Clock() {
+	cpu.Clock()
+}
@@ -0,0 +1,49 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPlainBusLoadProgram(t *testing.T) {
+	bus := &PlainBus{mem: make([]uint8, 65536)}
+	code := []byte{0xA2, 0x0A, 0x8E, 0x00, 0x00}
+	if err := bus.LoadProgram(0x8000, code, 0x8000); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := bus.DumpRange(0x8000, 0x8004); !bytes.Equal(got, code) {
+		t.Errorf("code = % x, want % x", got, code)
+	}
+	// the reset vector is little endian
+	if got := bus.DumpRange(0xFFFC, 0xFFFD); !bytes.Equal(got, []byte{0x00, 0x80}) {
+		t.Errorf("reset vector = % x, want 00 80", got)
+	}
+
+	if err := bus.LoadProgram(0xFFFB, code, 0x8000); err != nil {
+		t.Errorf("program ending at 0xFFFF: %v", err)
+	}
+	if err := bus.LoadProgram(0xFFFC, code, 0x8000); err == nil {
+		t.Errorf("program overflowing 0xFFFF returned no error")
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// the runner executes a batch of instructions every tick
+	runTick            = 10 * time.Millisecond
+	instructionsPerRun = 1000
+	// how often the panels are redrawn while running
+	redrawInterval = 100 * time.Millisecond
+)
+
+// machine guards cpu, bus and dbg, the UI and the runner goroutine both
+// use them
+var machine sync.Mutex
+
+// runner executes instructions in the background until it is stopped or
+// reaches its target
+type runner struct {
+	stop chan struct{}
+	// closed when the goroutine has exited
+	done chan struct{}
+}
+
+// startRunner starts running, until is passed to dbg.Run and may be nil
+// to run until paused
+func startRunner(until func(pc uint16) bool) *runner {
+	r := &runner{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go r.loop(until)
+	return r
+}
+
+func (r *runner) loop(until func(pc uint16) bool) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(runTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+
+		machine.Lock()
+		finished := dbg.Run(instructionsPerRun, until)
+		machine.Unlock()
+		if finished {
+			return
+		}
+	}
+}
+
+// pause stops the runner and waits for it to exit
+func (r *runner) pause() {
+	select {
+	case <-r.done:
+	default:
+		close(r.stop)
+		<-r.done
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// screenshot runs an iNES ROM headless for a number of frames and writes
+// the picture of the PPU to a PNG file, for comparing the output against
+// golden images in CI:
+//
+//	go run ./cmd/screenshot --frames 60 --out shot.png game.nes
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"mgnes/pkg/bus"
+	"mgnes/pkg/mg6502"
+	"os"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run parses the command line, runs the ROM and writes the PNG
+func run(args []string) error {
+	flags := flag.NewFlagSet("screenshot", flag.ContinueOnError)
+	frames := flags.Int("frames", 60, "frames to run before taking the screenshot")
+	out := flags.String("out", "screenshot.png", "PNG file to write")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: screenshot [--frames n] [--out file.png] rom.nes")
+	}
+	if *frames < 1 {
+		return errors.New("frames must be at least 1")
+	}
+
+	system := bus.NewBus(mg6502.NewMG6502())
+	if err := system.InsertCartridgeFromPath(flags.Arg(0)); err != nil {
+		return err
+	}
+	system.Reset()
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return fmt.Errorf("could not create screenshot: %w", err)
+	}
+	if err := system.Screenshot(f, *frames); err != nil {
+		f.Close()
+		return fmt.Errorf("could not write screenshot: %w", err)
+	}
+	return f.Close()
+}
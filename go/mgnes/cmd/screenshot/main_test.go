@@ -0,0 +1,159 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// spriteROM returns a 16KB NROM image drawing four 8x16 sprites, one per
+// palette and flip combination, over a light blue backdrop
+func spriteROM() []byte {
+	const header = 16
+	image := make([]byte, header+0x4000+0x2000)
+	copy(image, []byte{'N', 'E', 'S', 0x1A, 1, 1})
+
+	program := []byte{
+		0x78,       // $8000 SEI
+		0xA2, 0xFF, // $8001 LDX #$FF
+		0x9A,       // $8003 TXS
+		0xA9, 0x3F, // $8004 LDA #$3F
+		0x8D, 0x06, 0x20, // $8006 STA $2006
+		0xA9, 0x00, // $8009 LDA #$00
+		0x8D, 0x06, 0x20, // $800B STA $2006
+		0xA2, 0x00, // $800E LDX #$00
+		0xBD, 0x00, 0x81, // $8010 LDA palette,X
+		0x8D, 0x07, 0x20, // $8013 STA $2007
+		0xE8,       // $8016 INX
+		0xE0, 0x20, // $8017 CPX #$20
+		0xD0, 0xF5, // $8019 BNE $8010
+		0xA9, 0xF0, // $801B LDA #$F0
+		0xA2, 0x00, // $801D LDX #$00
+		0x9D, 0x00, 0x02, // $801F STA $0200,X, hide all sprites
+		0xE8,       // $8022 INX
+		0xD0, 0xFA, // $8023 BNE $801F
+		0xA2, 0x00, // $8025 LDX #$00
+		0xBD, 0x20, 0x81, // $8027 LDA sprites,X
+		0x9D, 0x00, 0x02, // $802A STA $0200,X
+		0xE8,       // $802D INX
+		0xE0, 0x10, // $802E CPX #$10
+		0xD0, 0xF5, // $8030 BNE $8027
+		0xA9, 0x02, // $8032 LDA #$02
+		0x8D, 0x14, 0x40, // $8034 STA $4014
+		0xA9, 0x20, // $8037 LDA #$20
+		0x8D, 0x00, 0x20, // $8039 STA $2000, 8x16 sprites
+		0xA9, 0x14, // $803C LDA #$14
+		0x8D, 0x01, 0x20, // $803E STA $2001, show sprites
+		0x4C, 0x41, 0x80, // $8041 JMP $8041
+	}
+	palette := []byte{
+		0x21, 0x00, 0x10, 0x30, 0x21, 0x00, 0x10, 0x30, 0x21, 0x00, 0x10, 0x30, 0x21, 0x00, 0x10, 0x30,
+		0x21, 0x16, 0x27, 0x18, 0x21, 0x1A, 0x2A, 0x3A, 0x21, 0x12, 0x22, 0x32, 0x21, 0x14, 0x24, 0x34,
+	}
+	// Y, tile, attributes, X
+	sprites := []byte{
+		40, 0x01, 0x00, 40,
+		40, 0x01, 0x41, 80,
+		40, 0x01, 0x82, 120,
+		40, 0x01, 0xC3, 160,
+	}
+	copy(image[header:], program)
+	copy(image[header+0x0100:], palette)
+	copy(image[header+0x0120:], sprites)
+	// reset vector
+	image[header+0x3FFC] = 0x00
+	image[header+0x3FFD] = 0x80
+
+	// tiles 0 and 1 of the second pattern table, an arrow pointing up
+	// left over a stand, so every flip is visible
+	chr := image[header+0x4000+0x1000:]
+	copy(chr, []byte{
+		0xF0, 0xE0, 0xF0, 0xB8, 0x1C, 0x0E, 0x07, 0x03, // tile 0 plane 0
+		0x00, 0x40, 0x60, 0x30, 0x18, 0x0C, 0x06, 0x02, // tile 0 plane 1
+		0x18, 0x18, 0x18, 0x18, 0x3C, 0x3C, 0x7E, 0xFF, // tile 1 plane 0
+		0x08, 0x08, 0x08, 0x08, 0x0C, 0x0C, 0x0E, 0x0F, // tile 1 plane 1
+	})
+	return image
+}
+
+// the SHA-256 of the RGBA pixels after 3 frames of spriteROM
+const spriteROMHash = "dde5b13980e18324d18ed2bab8165c20a80651f875511610389358c8dafe7f86"
+
+func TestScreenshot(t *testing.T) {
+	dir := t.TempDir()
+	rom := filepath.Join(dir, "sprites.nes")
+	out := filepath.Join(dir, "sprites.png")
+	if err := os.WriteFile(rom, spriteROM(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"--frames", "3", "--out", out, rom}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("could not decode the screenshot: %v", err)
+	}
+
+	// hash the pixels rather than the file, the PNG encoder is free to
+	// compress differently
+	h := sha256.New()
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			h.Write([]byte{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)})
+		}
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != spriteROMHash {
+		t.Errorf("screenshot hash = %v, want %v", got, spriteROMHash)
+	}
+}
+
+func TestScreenshotUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"no ROM", []string{"--frames", "1"}},
+		{"zero frames", []string{"--frames", "0", "rom.nes"}},
+		{"missing ROM", []string{filepath.Join(t.TempDir(), "missing.nes")}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := run(test.args); err == nil {
+				t.Errorf("run(%q) succeeded", test.args)
+			}
+		})
+	}
+}
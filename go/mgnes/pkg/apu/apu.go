@@ -0,0 +1,267 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package apu
+
+// Reader defines an interface for the DMC to fetch samples from
+type Reader interface {
+	CpuRead(addr uint16, readonly bool) (data uint8)
+}
+
+// APU emulates the audio processing unit of the 2A03. It is clocked once
+// per CPU cycle, the pulse channels run at half that rate, the "APU cycle"
+type APU struct {
+	pulse1   pulse
+	pulse2   pulse
+	triangle triangle
+	noise    noise
+	dmc      dmc
+
+	// DMC memory reader
+	reader Reader
+
+	// resampled output for the host
+	output *resampler
+
+	// frame sequencer
+	frameFiveStep   bool   // 5-step sequence instead of 4-step
+	frameIRQInhibit bool   // no frame interrupt when set
+	frameIRQ        bool   // frame interrupt flag
+	frameCounter    uint32 // CPU cycles into the current sequence
+
+	clockCounter uint64 // CPU cycles since power up
+}
+
+// NewAPU creates and returns an APU reference
+func NewAPU() *APU {
+	apu := &APU{output: newResampler()}
+	apu.Reset()
+	return apu
+}
+
+// SetReader sets the bus the DMC fetches its samples from
+func (apu *APU) SetReader(reader Reader) {
+	apu.reader = reader
+}
+
+// Reset silences all channels and restarts the frame sequencer, the output
+// buffer and sample rate are kept
+func (apu *APU) Reset() {
+	*apu = APU{reader: apu.reader, output: apu.output}
+	apu.pulse2.channel = 1
+	apu.noise.shift = 0x0001
+	apu.dmc.bitsRemaining = 8
+	apu.dmc.bufferEmpty = true
+	apu.dmc.silence = true
+}
+
+// CpuWrite writes data to the APU registers at 0x4000-0x4013, 0x4015 and 0x4017
+func (apu *APU) CpuWrite(addr uint16, data uint8) {
+	switch {
+	case addr >= 0x4000 && addr <= 0x4003:
+		apu.pulse1.write(addr&0x0003, data)
+	case addr >= 0x4004 && addr <= 0x4007:
+		apu.pulse2.write(addr&0x0003, data)
+	case addr >= 0x4008 && addr <= 0x400B:
+		apu.triangle.write(addr&0x0003, data)
+	case addr >= 0x400C && addr <= 0x400F:
+		apu.noise.write(addr&0x0003, data)
+	case addr >= 0x4010 && addr <= 0x4013:
+		apu.dmc.write(addr&0x0003, data)
+	case addr == 0x4015:
+		// Status
+		// --------
+		// 76543210
+		// ---DNT21
+		//    |||||
+		//    ||||+- Pulse 1 length counter enable
+		//    |||+-- Pulse 2 length counter enable
+		//    ||+--- Triangle length counter enable
+		//    |+---- Noise length counter enable
+		//    +----- DMC enable
+		apu.pulse1.length.setEnabled(data&0x01 != 0)
+		apu.pulse2.length.setEnabled(data&0x02 != 0)
+		apu.triangle.length.setEnabled(data&0x04 != 0)
+		apu.noise.length.setEnabled(data&0x08 != 0)
+		apu.dmc.setEnabled(data&0x10 != 0)
+	case addr == 0x4017:
+		// Frame counter
+		// --------
+		// 76543210
+		// MI------
+		// ||
+		// |+------- IRQ inhibit
+		// +-------- Sequencer mode. 0 = 4-step, 1 = 5-step
+		apu.frameFiveStep = data&0x80 != 0
+		apu.frameIRQInhibit = data&0x40 != 0
+		if apu.frameIRQInhibit {
+			apu.frameIRQ = false
+		}
+		apu.frameCounter = 0
+		if apu.frameFiveStep {
+			apu.clockQuarterFrame()
+			apu.clockHalfFrame()
+		}
+	}
+}
+
+// CpuRead reads the status register at 0x4015, reading clears the frame
+// interrupt flag unless readonly is set
+func (apu *APU) CpuRead(addr uint16, readonly bool) (data uint8) {
+	if addr != 0x4015 {
+		return
+	}
+
+	// Status
+	// --------
+	// 76543210
+	// IF-DNT21
+	// || |||||
+	// || ||||+- Pulse 1 length counter > 0
+	// || |||+-- Pulse 2 length counter > 0
+	// || ||+--- Triangle length counter > 0
+	// || |+---- Noise length counter > 0
+	// || +----- DMC bytes remaining > 0
+	// |+------- Frame interrupt
+	// +-------- DMC interrupt
+	if apu.pulse1.length.value > 0 {
+		data |= 0x01
+	}
+	if apu.pulse2.length.value > 0 {
+		data |= 0x02
+	}
+	if apu.triangle.length.value > 0 {
+		data |= 0x04
+	}
+	if apu.noise.length.value > 0 {
+		data |= 0x08
+	}
+	if apu.dmc.bytesRemaining > 0 {
+		data |= 0x10
+	}
+	if apu.frameIRQ {
+		data |= 0x40
+	}
+	if apu.dmc.irq {
+		data |= 0x80
+	}
+
+	if !readonly {
+		apu.frameIRQ = false
+	}
+	return
+}
+
+// IRQ returns true while the frame sequencer or the DMC asserts an interrupt
+func (apu *APU) IRQ() bool {
+	return apu.frameIRQ || apu.dmc.irq
+}
+
+// Clock performs one CPU cycle worth of APU work
+func (apu *APU) Clock() {
+	// triangle, noise and DMC timers are clocked at CPU rate
+	apu.triangle.clockTimer()
+	apu.noise.clockTimer()
+	apu.dmc.clockTimer(apu.reader)
+
+	// pulse timers are clocked every APU cycle, every other CPU cycle
+	if apu.clockCounter%2 == 1 {
+		apu.pulse1.clockTimer()
+		apu.pulse2.clockTimer()
+	}
+
+	apu.clockFrameSequencer()
+	apu.output.add(apu.GetSample())
+	apu.clockCounter++
+}
+
+// clockFrameSequencer steps the frame sequencer, which clocks envelopes and
+// the triangle linear counter every quarter frame, length counters and
+// sweeps every half frame. Steps are given in CPU cycles
+//
+//	4-step: 7457 Q, 14913 Q H, 22371 Q, 29829 Q H IRQ, restarts at 29830
+//	5-step: 7457 Q, 14913 Q H, 22371 Q, 37281 Q H,     restarts at 37282
+func (apu *APU) clockFrameSequencer() {
+	apu.frameCounter++
+
+	switch apu.frameCounter {
+	case 7457, 22371:
+		apu.clockQuarterFrame()
+	case 14913:
+		apu.clockQuarterFrame()
+		apu.clockHalfFrame()
+	case 29829:
+		if !apu.frameFiveStep {
+			apu.clockQuarterFrame()
+			apu.clockHalfFrame()
+			if !apu.frameIRQInhibit {
+				apu.frameIRQ = true
+			}
+		}
+	case 29830:
+		if !apu.frameFiveStep {
+			apu.frameCounter = 0
+		}
+	case 37281:
+		apu.clockQuarterFrame()
+		apu.clockHalfFrame()
+	case 37282:
+		apu.frameCounter = 0
+	}
+}
+
+func (apu *APU) clockQuarterFrame() {
+	apu.pulse1.envelope.clock()
+	apu.pulse2.envelope.clock()
+	apu.noise.envelope.clock()
+	apu.triangle.clockLinear()
+}
+
+func (apu *APU) clockHalfFrame() {
+	apu.pulse1.length.clock()
+	apu.pulse2.length.clock()
+	apu.triangle.length.clock()
+	apu.noise.length.clock()
+	apu.pulse1.clockSweep()
+	apu.pulse2.clockSweep()
+}
+
+// GetSample returns the current output of all channels mixed together in
+// the range 0.0 to 1.0, using the nonlinear formula of the NES mixer
+//
+//	pulse = 95.88 / (8128 / (pulse1 + pulse2) + 100)
+//	tnd   = 159.79 / (1 / (triangle / 8227 + noise / 12241 + dmc / 22638) + 100)
+func (apu *APU) GetSample() float32 {
+	var pulseOut, tndOut float32
+
+	pulseSum := float32(apu.pulse1.output()) + float32(apu.pulse2.output())
+	if pulseSum > 0 {
+		pulseOut = 95.88 / (8128/pulseSum + 100)
+	}
+
+	tndSum := float32(apu.triangle.output())/8227 +
+		float32(apu.noise.output())/12241 +
+		float32(apu.dmc.output)/22638
+	if tndSum > 0 {
+		tndOut = 159.79 / (1/tndSum + 100)
+	}
+
+	return pulseOut + tndOut
+}
@@ -0,0 +1,106 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package apu
+
+import "testing"
+
+// CPU cycles of one 4-step frame sequence
+const frameCycles = 29830
+
+func TestPulseSequencer(t *testing.T) {
+	// with period 253 the sequencer steps every 254 APU cycles, 508 CPU cycles
+	const period = 253
+	const stepCycles = 2 * (period + 1)
+
+	tests := []struct {
+		name      string
+		duty      uint8
+		highSteps int
+	}{
+		{"12.5%", 0, 1},
+		{"25%", 1, 2},
+		{"50%", 2, 4},
+		{"25% negated", 3, 6},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apu := NewAPU()
+			apu.CpuWrite(0x4015, 0x01)
+			// duty, length counter halted, constant volume 15
+			apu.CpuWrite(0x4000, test.duty<<6|0x30|0x0F)
+			apu.CpuWrite(0x4001, 0x00)
+			apu.CpuWrite(0x4002, period&0xFF)
+			apu.CpuWrite(0x4003, period>>8)
+
+			// lengths of the runs of equal output over one frame
+			var runs []int
+			var values []uint8
+			for i := 0; i < frameCycles; i++ {
+				apu.Clock()
+				out := apu.pulse1.output()
+				if len(values) == 0 || values[len(values)-1] != out {
+					runs = append(runs, 0)
+					values = append(values, out)
+				}
+				runs[len(runs)-1]++
+			}
+
+			// the first and last runs are cut by the frame
+			if len(runs) < 4 {
+				t.Fatalf("%v runs of output, want a square wave", len(runs))
+			}
+			for i := 1; i < len(runs)-1; i++ {
+				want := (8 - test.highSteps) * stepCycles
+				if values[i] != 0 {
+					want = test.highSteps * stepCycles
+					if values[i] != 15 {
+						t.Errorf("run %v outputs %v, want 15", i, values[i])
+					}
+				}
+				if runs[i] != want {
+					t.Errorf("run %v of %v lasts %v cycles, want %v", i, values[i], runs[i], want)
+				}
+			}
+
+			if got := apu.CpuRead(0x4015, false); got != 0x41 {
+				t.Errorf("status after one frame = %#02x, want 0x41", got)
+			}
+		})
+	}
+}
+
+func TestPulseLengthCounter(t *testing.T) {
+	apu := NewAPU()
+	apu.CpuWrite(0x4015, 0x01)
+	apu.CpuWrite(0x4000, 0x9F)
+	apu.CpuWrite(0x4002, 0xFD)
+	// length index 3 loads 2 half frames
+	apu.CpuWrite(0x4003, 0x03<<3)
+
+	for i := 0; i < frameCycles; i++ {
+		apu.Clock()
+	}
+	// two half frame clocks per frame silence the channel
+	if got := apu.CpuRead(0x4015, true) & 0x01; got != 0 {
+		t.Errorf("pulse 1 still playing after one frame")
+	}
+}
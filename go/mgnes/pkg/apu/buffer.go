@@ -0,0 +1,120 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package apu
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// CPU clock rate of the NTSC console, the APU produces one sample per cycle
+	cpuClockRate = 1789773
+	// DefaultSampleRate is the output rate until SetSampleRate is called
+	DefaultSampleRate = 44100
+	// capacity of the output ring buffer in samples, about 185ms at 44100Hz
+	bufferSize = 8192
+)
+
+// ringBuffer is a fixed size FIFO of samples safe for one producer and one
+// consumer running on different goroutines
+type ringBuffer struct {
+	mutex sync.Mutex
+	data  [bufferSize]float32
+	head  int // index of the oldest sample
+	size  int // number of queued samples
+}
+
+// write queues a sample, dropping the oldest one when full
+func (r *ringBuffer) write(sample float32) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.data[(r.head+r.size)%bufferSize] = sample
+	if r.size < bufferSize {
+		r.size++
+	} else {
+		r.head = (r.head + 1) % bufferSize
+	}
+}
+
+// read dequeues up to len(buf) samples, the rest of buf is zero filled
+func (r *ringBuffer) read(buf []float32) (n int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for n < len(buf) && n < r.size {
+		buf[n] = r.data[(r.head+n)%bufferSize]
+		n++
+	}
+	r.head = (r.head + n) % bufferSize
+	r.size -= n
+
+	for i := n; i < len(buf); i++ {
+		buf[i] = 0
+	}
+	return
+}
+
+// resampler converts the CPU rate output of the mixer down to the host
+// rate, averaging all samples that fall into one output period
+type resampler struct {
+	rate  int64 // output rate in Hz, accessed atomically
+	phase int64
+	sum   float32
+	count int
+	ring  ringBuffer
+}
+
+func newResampler() *resampler {
+	return &resampler{rate: DefaultSampleRate}
+}
+
+// add accumulates one sample at CPU rate
+func (r *resampler) add(sample float32) {
+	r.sum += sample
+	r.count++
+
+	r.phase += atomic.LoadInt64(&r.rate)
+	if r.phase < cpuClockRate {
+		return
+	}
+	r.phase -= cpuClockRate
+
+	r.ring.write(r.sum / float32(r.count))
+	r.sum = 0
+	r.count = 0
+}
+
+// SetSampleRate sets the host output rate in Hz, it may be called from any goroutine
+func (apu *APU) SetSampleRate(hz int) {
+	if hz <= 0 || hz > cpuClockRate {
+		return
+	}
+	atomic.StoreInt64(&apu.output.rate, int64(hz))
+}
+
+// Read fills buf with resampled output and returns the number of samples
+// that were available, on underflow the rest of buf is filled with silence.
+// It may be called from the host audio callback goroutine
+func (apu *APU) Read(buf []float32) int {
+	return apu.output.ring.read(buf)
+}
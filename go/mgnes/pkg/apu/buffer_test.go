@@ -0,0 +1,135 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package apu
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestResamplerLength(t *testing.T) {
+	tests := []struct {
+		name string
+		rate int
+	}{
+		{"44100", 44100},
+		{"48000", 48000},
+		{"22050", 22050},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apu := NewAPU()
+			apu.SetSampleRate(test.rate)
+
+			// a tenth of a second of a 440Hz sine
+			input := cpuClockRate / 10
+			for i := 0; i < input; i++ {
+				apu.output.add(float32(math.Sin(2 * math.Pi * 440 * float64(i) / cpuClockRate)))
+			}
+
+			want := test.rate / 10
+			buf := make([]float32, want+100)
+			if n := apu.Read(buf); n < want-1 || n > want {
+				t.Errorf("Read() = %v samples, want %v", n, want)
+			}
+			for i, sample := range buf {
+				if sample < -1 || sample > 1 {
+					t.Fatalf("sample %v = %v, out of range", i, sample)
+				}
+			}
+		})
+	}
+}
+
+func TestRingBufferUnderrun(t *testing.T) {
+	r := &ringBuffer{}
+	r.write(0.25)
+	r.write(0.5)
+
+	buf := []float32{1, 1, 1, 1}
+	if n := r.read(buf); n != 2 {
+		t.Errorf("read() = %v, want 2", n)
+	}
+	if want := []float32{0.25, 0.5, 0, 0}; !equalSamples(buf, want) {
+		t.Errorf("read() filled %v, want %v", buf, want)
+	}
+
+	// nothing left, the whole buffer is silence
+	buf = []float32{1, 1}
+	if n := r.read(buf); n != 0 {
+		t.Errorf("read() on empty buffer = %v, want 0", n)
+	}
+	if want := []float32{0, 0}; !equalSamples(buf, want) {
+		t.Errorf("read() on empty buffer filled %v, want %v", buf, want)
+	}
+	if n := r.read(nil); n != 0 {
+		t.Errorf("read(nil) = %v, want 0", n)
+	}
+}
+
+func TestRingBufferOverflow(t *testing.T) {
+	r := &ringBuffer{}
+	for i := 0; i < bufferSize+10; i++ {
+		r.write(float32(i))
+	}
+
+	// the oldest samples are dropped
+	buf := make([]float32, bufferSize+1)
+	if n := r.read(buf); n != bufferSize {
+		t.Errorf("read() = %v, want %v", n, bufferSize)
+	}
+	if buf[0] != 10 || buf[bufferSize-1] != bufferSize+9 {
+		t.Errorf("read() = %v ... %v, want 10 ... %v", buf[0], buf[bufferSize-1], bufferSize+9)
+	}
+}
+
+func TestReadConcurrent(t *testing.T) {
+	apu := NewAPU()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		buf := make([]float32, 512)
+		for i := 0; i < 1000; i++ {
+			apu.Read(buf)
+		}
+	}()
+
+	for i := 0; i < 100000; i++ {
+		apu.Clock()
+	}
+	wg.Wait()
+}
+
+func equalSamples(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package apu
+
+// NTSC DMC rates in CPU cycles
+var dmcPeriods = [16]uint16{
+	428, 380, 340, 320, 286, 254, 226, 214, 190, 160, 142, 128, 106, 84, 72, 54,
+}
+
+// dmc is the delta modulation channel, it plays 1-bit delta encoded
+// samples fetched from CPU memory, or 7-bit PCM written directly
+type dmc struct {
+	irqEnabled bool
+	loop       bool
+	irq        bool
+
+	timer  uint16
+	period uint16
+	output uint8
+
+	// memory reader
+	sampleAddr     uint16
+	sampleLength   uint16
+	currentAddr    uint16
+	bytesRemaining uint16
+	buffer         uint8
+	bufferEmpty    bool
+
+	// output unit
+	shift         uint8
+	bitsRemaining uint8
+	silence       bool
+}
+
+func (d *dmc) write(reg uint16, data uint8) {
+	switch reg {
+	case 0:
+		// IL-- RRRR: IRQ enabled, loop, rate
+		d.irqEnabled = data&0x80 != 0
+		d.loop = data&0x40 != 0
+		d.period = dmcPeriods[data&0x0F]
+		if !d.irqEnabled {
+			d.irq = false
+		}
+	case 1:
+		// -DDD DDDD: direct load
+		d.output = data & 0x7F
+	case 2:
+		// AAAA AAAA: sample address = 0xC000 + A * 64
+		d.sampleAddr = 0xC000 | uint16(data)<<6
+	case 3:
+		// LLLL LLLL: sample length = L * 16 + 1 bytes
+		d.sampleLength = uint16(data)<<4 | 0x0001
+	}
+}
+
+func (d *dmc) setEnabled(enabled bool) {
+	d.irq = false
+	if !enabled {
+		d.bytesRemaining = 0
+	} else if d.bytesRemaining == 0 {
+		d.restart()
+	}
+}
+
+func (d *dmc) restart() {
+	d.currentAddr = d.sampleAddr
+	d.bytesRemaining = d.sampleLength
+}
+
+// fill fetches the next sample byte when the buffer is empty
+func (d *dmc) fill(reader Reader) {
+	if !d.bufferEmpty || d.bytesRemaining == 0 || reader == nil {
+		return
+	}
+
+	d.buffer = reader.CpuRead(d.currentAddr, false)
+	d.bufferEmpty = false
+
+	// address wraps around to 0x8000
+	d.currentAddr++
+	if d.currentAddr == 0x0000 {
+		d.currentAddr = 0x8000
+	}
+
+	d.bytesRemaining--
+	if d.bytesRemaining == 0 {
+		if d.loop {
+			d.restart()
+		} else if d.irqEnabled {
+			d.irq = true
+		}
+	}
+}
+
+// clockTimer is called every CPU cycle, each time the timer expires one
+// bit of the shift register moves the output level up or down by 2
+func (d *dmc) clockTimer(reader Reader) {
+	d.fill(reader)
+
+	if d.timer > 0 {
+		d.timer--
+		return
+	}
+	if d.period > 0 {
+		d.timer = d.period - 1
+	}
+
+	if !d.silence {
+		if d.shift&0x01 != 0 {
+			if d.output <= 125 {
+				d.output += 2
+			}
+		} else if d.output >= 2 {
+			d.output -= 2
+		}
+	}
+	d.shift >>= 1
+
+	d.bitsRemaining--
+	if d.bitsRemaining == 0 {
+		d.bitsRemaining = 8
+		if d.bufferEmpty {
+			d.silence = true
+		} else {
+			d.silence = false
+			d.shift = d.buffer
+			d.bufferEmpty = true
+		}
+	}
+}
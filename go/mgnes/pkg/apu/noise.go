@@ -0,0 +1,83 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package apu
+
+// NTSC noise timer periods in CPU cycles
+var noisePeriods = [16]uint16{
+	4, 8, 16, 32, 64, 96, 128, 160, 202, 254, 380, 508, 762, 1016, 2034, 4068,
+}
+
+// noise is a pseudo-random channel driven by a 15-bit linear feedback
+// shift register
+type noise struct {
+	envelope envelope
+	length   lengthCounter
+
+	mode   bool // short, 93 steps, sequence
+	shift  uint16
+	timer  uint16
+	period uint16
+}
+
+func (n *noise) write(reg uint16, data uint8) {
+	switch reg {
+	case 0:
+		// --LC VVVV: loop envelope/halt length, constant volume, volume/envelope period
+		n.envelope.loop = data&0x20 != 0
+		n.length.halt = data&0x20 != 0
+		n.envelope.constant = data&0x10 != 0
+		n.envelope.volume = data & 0x0F
+	case 2:
+		// M--- PPPP: mode, period
+		n.mode = data&0x80 != 0
+		n.period = noisePeriods[data&0x0F]
+	case 3:
+		// LLLL L---: length counter load
+		n.length.load(data)
+		n.envelope.start = true
+	}
+}
+
+// clockTimer is called every CPU cycle, feedback is bit 0 xor bit 1, or
+// bit 6 in short mode, shifted in at bit 14
+func (n *noise) clockTimer() {
+	if n.timer > 0 {
+		n.timer--
+		return
+	}
+	if n.period > 0 {
+		n.timer = n.period - 1
+	}
+
+	other := uint16(1)
+	if n.mode {
+		other = 6
+	}
+	feedback := (n.shift ^ n.shift>>other) & 0x0001
+	n.shift = n.shift>>1 | feedback<<14
+}
+
+func (n *noise) output() uint8 {
+	if n.shift&0x0001 != 0 || n.length.value == 0 {
+		return 0
+	}
+	return n.envelope.output()
+}
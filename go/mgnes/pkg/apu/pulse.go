@@ -0,0 +1,126 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package apu
+
+// waveforms of the four duty cycles, 12.5%, 25%, 50% and 25% negated
+var dutyTable = [4][8]uint8{
+	{0, 1, 0, 0, 0, 0, 0, 0},
+	{0, 1, 1, 0, 0, 0, 0, 0},
+	{0, 1, 1, 1, 1, 0, 0, 0},
+	{1, 0, 0, 1, 1, 1, 1, 1},
+}
+
+// pulse is a square wave channel with volume envelope and frequency sweep
+type pulse struct {
+	channel  int // 0 for pulse 1, 1 for pulse 2, they negate differently
+	envelope envelope
+	length   lengthCounter
+
+	duty   uint8
+	step   uint8
+	timer  uint16
+	period uint16
+
+	sweepEnabled bool
+	sweepNegate  bool
+	sweepReload  bool
+	sweepPeriod  uint8
+	sweepShift   uint8
+	sweepDivider uint8
+}
+
+func (p *pulse) write(reg uint16, data uint8) {
+	switch reg {
+	case 0:
+		// DDLC VVVV: duty, loop envelope/halt length, constant volume, volume/envelope period
+		p.duty = data >> 6
+		p.envelope.loop = data&0x20 != 0
+		p.length.halt = data&0x20 != 0
+		p.envelope.constant = data&0x10 != 0
+		p.envelope.volume = data & 0x0F
+	case 1:
+		// EPPP NSSS: sweep enabled, period, negate, shift
+		p.sweepEnabled = data&0x80 != 0
+		p.sweepPeriod = (data >> 4) & 0x07
+		p.sweepNegate = data&0x08 != 0
+		p.sweepShift = data & 0x07
+		p.sweepReload = true
+	case 2:
+		// TTTT TTTT: timer low
+		p.period = p.period&0x0700 | uint16(data)
+	case 3:
+		// LLLL LTTT: length counter load, timer high
+		p.period = p.period&0x00FF | uint16(data&0x07)<<8
+		p.length.load(data)
+		p.step = 0
+		p.envelope.start = true
+	}
+}
+
+func (p *pulse) clockTimer() {
+	if p.timer == 0 {
+		p.timer = p.period
+		p.step = (p.step + 1) & 0x07
+	} else {
+		p.timer--
+	}
+}
+
+// sweepTarget returns the period the sweep unit is heading to. Pulse 1
+// negates with one's complement, pulse 2 with two's complement
+func (p *pulse) sweepTarget() int {
+	change := int(p.period >> p.sweepShift)
+	if !p.sweepNegate {
+		return int(p.period) + change
+	}
+
+	target := int(p.period) - change - 1 + p.channel
+	if target < 0 {
+		target = 0
+	}
+	return target
+}
+
+// muted returns true when the period is out of range, even if the sweep
+// unit is disabled
+func (p *pulse) muted() bool {
+	return p.period < 8 || p.sweepTarget() > 0x07FF
+}
+
+func (p *pulse) clockSweep() {
+	if p.sweepDivider == 0 && p.sweepEnabled && p.sweepShift > 0 && !p.muted() {
+		p.period = uint16(p.sweepTarget())
+	}
+
+	if p.sweepDivider == 0 || p.sweepReload {
+		p.sweepDivider = p.sweepPeriod
+		p.sweepReload = false
+	} else {
+		p.sweepDivider--
+	}
+}
+
+func (p *pulse) output() uint8 {
+	if p.muted() || p.length.value == 0 || dutyTable[p.duty][p.step] == 0 {
+		return 0
+	}
+	return p.envelope.output()
+}
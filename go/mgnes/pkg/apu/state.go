@@ -0,0 +1,263 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package apu
+
+import "encoding/gob"
+
+// apuState is the serializable part of the APU, the resampled output
+// waiting for the host is not included
+type apuState struct {
+	Pulse1   pulseState
+	Pulse2   pulseState
+	Triangle triangleState
+	Noise    noiseState
+	DMC      dmcState
+
+	FrameFiveStep   bool
+	FrameIRQInhibit bool
+	FrameIRQ        bool
+	FrameCounter    uint32
+
+	ClockCounter uint64
+}
+
+type envelopeState struct {
+	Start, Loop, Constant  bool
+	Volume, Divider, Decay uint8
+}
+
+type lengthState struct {
+	Enabled, Halt bool
+	Value         uint8
+}
+
+type pulseState struct {
+	Envelope envelopeState
+	Length   lengthState
+
+	Duty, Step    uint8
+	Timer, Period uint16
+
+	SweepEnabled, SweepNegate, SweepReload bool
+	SweepPeriod, SweepShift, SweepDivider  uint8
+}
+
+type triangleState struct {
+	Length lengthState
+
+	Control, ReloadFlag  bool
+	LinearReload, Linear uint8
+
+	Step          uint8
+	Timer, Period uint16
+}
+
+type noiseState struct {
+	Envelope envelopeState
+	Length   lengthState
+
+	Mode                 bool
+	Shift, Timer, Period uint16
+}
+
+type dmcState struct {
+	IRQEnabled, Loop, IRQ bool
+
+	Timer, Period uint16
+	Output        uint8
+
+	SampleAddr, SampleLength    uint16
+	CurrentAddr, BytesRemaining uint16
+	Buffer                      uint8
+	BufferEmpty                 bool
+
+	Shift, BitsRemaining uint8
+	Silence              bool
+}
+
+// Serialize writes the channels and the frame sequencer for a save state
+func (apu *APU) Serialize(enc *gob.Encoder) error {
+	return enc.Encode(apuState{
+		Pulse1:          apu.pulse1.state(),
+		Pulse2:          apu.pulse2.state(),
+		Triangle:        apu.triangle.state(),
+		Noise:           apu.noise.state(),
+		DMC:             apu.dmc.state(),
+		FrameFiveStep:   apu.frameFiveStep,
+		FrameIRQInhibit: apu.frameIRQInhibit,
+		FrameIRQ:        apu.frameIRQ,
+		FrameCounter:    apu.frameCounter,
+		ClockCounter:    apu.clockCounter,
+	})
+}
+
+// Deserialize restores the state written by Serialize
+func (apu *APU) Deserialize(dec *gob.Decoder) error {
+	var state apuState
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	apu.pulse1.restore(state.Pulse1)
+	apu.pulse2.restore(state.Pulse2)
+	apu.triangle.restore(state.Triangle)
+	apu.noise.restore(state.Noise)
+	apu.dmc.restore(state.DMC)
+	apu.frameFiveStep = state.FrameFiveStep
+	apu.frameIRQInhibit = state.FrameIRQInhibit
+	apu.frameIRQ = state.FrameIRQ
+	apu.frameCounter = state.FrameCounter
+	apu.clockCounter = state.ClockCounter
+	return nil
+}
+
+func (e *envelope) state() envelopeState {
+	return envelopeState{e.start, e.loop, e.constant, e.volume, e.divider, e.decay}
+}
+
+func (e *envelope) restore(s envelopeState) {
+	*e = envelope{s.Start, s.Loop, s.Constant, s.Volume, s.Divider, s.Decay}
+}
+
+func (l *lengthCounter) state() lengthState {
+	return lengthState{l.enabled, l.halt, l.value}
+}
+
+func (l *lengthCounter) restore(s lengthState) {
+	*l = lengthCounter{s.Enabled, s.Halt, s.Value}
+}
+
+func (p *pulse) state() pulseState {
+	return pulseState{
+		Envelope:     p.envelope.state(),
+		Length:       p.length.state(),
+		Duty:         p.duty,
+		Step:         p.step,
+		Timer:        p.timer,
+		Period:       p.period,
+		SweepEnabled: p.sweepEnabled,
+		SweepNegate:  p.sweepNegate,
+		SweepReload:  p.sweepReload,
+		SweepPeriod:  p.sweepPeriod,
+		SweepShift:   p.sweepShift,
+		SweepDivider: p.sweepDivider,
+	}
+}
+
+// restore keeps the channel number, it is not part of the state
+func (p *pulse) restore(s pulseState) {
+	p.envelope.restore(s.Envelope)
+	p.length.restore(s.Length)
+	p.duty = s.Duty
+	p.step = s.Step
+	p.timer = s.Timer
+	p.period = s.Period
+	p.sweepEnabled = s.SweepEnabled
+	p.sweepNegate = s.SweepNegate
+	p.sweepReload = s.SweepReload
+	p.sweepPeriod = s.SweepPeriod
+	p.sweepShift = s.SweepShift
+	p.sweepDivider = s.SweepDivider
+}
+
+func (t *triangle) state() triangleState {
+	return triangleState{
+		Length:       t.length.state(),
+		Control:      t.control,
+		ReloadFlag:   t.reloadFlag,
+		LinearReload: t.linearReload,
+		Linear:       t.linear,
+		Step:         t.step,
+		Timer:        t.timer,
+		Period:       t.period,
+	}
+}
+
+func (t *triangle) restore(s triangleState) {
+	t.length.restore(s.Length)
+	t.control = s.Control
+	t.reloadFlag = s.ReloadFlag
+	t.linearReload = s.LinearReload
+	t.linear = s.Linear
+	t.step = s.Step
+	t.timer = s.Timer
+	t.period = s.Period
+}
+
+func (n *noise) state() noiseState {
+	return noiseState{
+		Envelope: n.envelope.state(),
+		Length:   n.length.state(),
+		Mode:     n.mode,
+		Shift:    n.shift,
+		Timer:    n.timer,
+		Period:   n.period,
+	}
+}
+
+func (n *noise) restore(s noiseState) {
+	n.envelope.restore(s.Envelope)
+	n.length.restore(s.Length)
+	n.mode = s.Mode
+	n.shift = s.Shift
+	n.timer = s.Timer
+	n.period = s.Period
+}
+
+func (d *dmc) state() dmcState {
+	return dmcState{
+		IRQEnabled:     d.irqEnabled,
+		Loop:           d.loop,
+		IRQ:            d.irq,
+		Timer:          d.timer,
+		Period:         d.period,
+		Output:         d.output,
+		SampleAddr:     d.sampleAddr,
+		SampleLength:   d.sampleLength,
+		CurrentAddr:    d.currentAddr,
+		BytesRemaining: d.bytesRemaining,
+		Buffer:         d.buffer,
+		BufferEmpty:    d.bufferEmpty,
+		Shift:          d.shift,
+		BitsRemaining:  d.bitsRemaining,
+		Silence:        d.silence,
+	}
+}
+
+func (d *dmc) restore(s dmcState) {
+	*d = dmc{
+		irqEnabled:     s.IRQEnabled,
+		loop:           s.Loop,
+		irq:            s.IRQ,
+		timer:          s.Timer,
+		period:         s.Period,
+		output:         s.Output,
+		sampleAddr:     s.SampleAddr,
+		sampleLength:   s.SampleLength,
+		currentAddr:    s.CurrentAddr,
+		bytesRemaining: s.BytesRemaining,
+		buffer:         s.Buffer,
+		bufferEmpty:    s.BufferEmpty,
+		shift:          s.Shift,
+		bitsRemaining:  s.BitsRemaining,
+		silence:        s.Silence,
+	}
+}
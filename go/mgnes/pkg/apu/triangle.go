@@ -0,0 +1,88 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package apu
+
+// the 32 steps triangle waveform
+var triangleSequence = [32]uint8{
+	15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0,
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+}
+
+// triangle is a triangle wave channel without volume control, its
+// duration is controlled by both a length counter and a linear counter
+type triangle struct {
+	length lengthCounter
+
+	control      bool // halts the length counter and keeps reloading the linear counter
+	linearReload uint8
+	linear       uint8
+	reloadFlag   bool
+
+	step   uint8
+	timer  uint16
+	period uint16
+}
+
+func (t *triangle) write(reg uint16, data uint8) {
+	switch reg {
+	case 0:
+		// CRRR RRRR: control, linear counter reload value
+		t.control = data&0x80 != 0
+		t.length.halt = t.control
+		t.linearReload = data & 0x7F
+	case 2:
+		// TTTT TTTT: timer low
+		t.period = t.period&0x0700 | uint16(data)
+	case 3:
+		// LLLL LTTT: length counter load, timer high
+		t.period = t.period&0x00FF | uint16(data&0x07)<<8
+		t.length.load(data)
+		t.reloadFlag = true
+	}
+}
+
+// clockTimer is called every CPU cycle, the waveform only advances while
+// both counters are non-zero
+func (t *triangle) clockTimer() {
+	if t.timer == 0 {
+		t.timer = t.period
+		if t.linear > 0 && t.length.value > 0 {
+			t.step = (t.step + 1) & 0x1F
+		}
+	} else {
+		t.timer--
+	}
+}
+
+func (t *triangle) clockLinear() {
+	if t.reloadFlag {
+		t.linear = t.linearReload
+	} else if t.linear > 0 {
+		t.linear--
+	}
+	if !t.control {
+		t.reloadFlag = false
+	}
+}
+
+func (t *triangle) output() uint8 {
+	return triangleSequence[t.step]
+}
@@ -0,0 +1,94 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package apu
+
+// lengthTable maps the 5-bit length index written to a channel to the
+// number of half frames it keeps sounding
+var lengthTable = [32]uint8{
+	10, 254, 20, 2, 40, 4, 80, 6, 160, 8, 60, 10, 14, 12, 26, 14,
+	12, 16, 24, 18, 48, 20, 96, 22, 192, 24, 72, 26, 16, 28, 32, 30,
+}
+
+// lengthCounter silences a channel once it counts down to zero, unless halted
+type lengthCounter struct {
+	enabled bool
+	halt    bool
+	value   uint8
+}
+
+// load sets the counter from the top 5 bits of data
+func (l *lengthCounter) load(data uint8) {
+	if l.enabled {
+		l.value = lengthTable[data>>3]
+	}
+}
+
+func (l *lengthCounter) setEnabled(enabled bool) {
+	l.enabled = enabled
+	if !enabled {
+		l.value = 0
+	}
+}
+
+func (l *lengthCounter) clock() {
+	if !l.halt && l.value > 0 {
+		l.value--
+	}
+}
+
+// envelope produces either a constant volume or a sawtooth decaying from
+// 15 to 0, optionally looping
+type envelope struct {
+	start    bool
+	loop     bool
+	constant bool
+	volume   uint8 // constant volume, divider period otherwise
+	divider  uint8
+	decay    uint8
+}
+
+func (e *envelope) clock() {
+	if e.start {
+		e.start = false
+		e.decay = 15
+		e.divider = e.volume
+		return
+	}
+
+	if e.divider > 0 {
+		e.divider--
+		return
+	}
+
+	e.divider = e.volume
+	if e.decay > 0 {
+		e.decay--
+	} else if e.loop {
+		e.decay = 15
+	}
+}
+
+func (e *envelope) output() uint8 {
+	if e.constant {
+		return e.volume
+	}
+	return e.decay
+}
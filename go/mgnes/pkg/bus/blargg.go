@@ -0,0 +1,115 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package bus
+
+import (
+	"errors"
+	"mgnes/pkg/cartridge"
+	"mgnes/pkg/mg6502"
+	"strings"
+)
+
+// blargg's test ROMs report through work RAM: a status byte at 0x6000, a
+// signature at 0x6001-0x6003 once it is valid, and a zero terminated text
+// from 0x6004
+const (
+	blarggStatus    = 0x6000
+	blarggSignature = 0x6001
+	blarggMessage   = 0x6004
+
+	// BlarggRunning status while the test is running
+	BlarggRunning = 0x80
+	// BlarggNeedsReset status when the test asks for the reset button
+	BlarggNeedsReset = 0x81
+
+	// frames to wait before pressing reset, about 100ms
+	blarggResetDelay = 6
+	// longest text read from 0x6004
+	blarggMessageSize = 0x1000
+)
+
+var blarggMagic = [3]uint8{0xDE, 0xB0, 0x61}
+
+// RunBlargg runs one of blargg's test ROMs until it reports a result or
+// maxFrames have been run. A status of 0 means the test passed, other
+// values are the number of the failed test. The message is the text the
+// ROM printed
+func RunBlargg(cart *cartridge.Cartridge, maxFrames int) (status uint8, message string, err error) {
+	if cart == nil {
+		err = errors.New("invalid cartridge")
+		return
+	}
+
+	bus := NewBus(mg6502.NewMG6502())
+	if err = bus.InsertCartridge(cart); err != nil {
+		return
+	}
+	bus.Reset()
+
+	resetAt := -1
+	for frame := 0; frame < maxFrames; frame++ {
+		bus.RunFrames(1)
+
+		if !bus.blarggValid() {
+			continue
+		}
+		status = bus.CpuRead(blarggStatus, true)
+		switch {
+		case status == BlarggNeedsReset:
+			if resetAt < 0 {
+				resetAt = frame + blarggResetDelay
+			} else if frame >= resetAt {
+				resetAt = -1
+				bus.Reset()
+			}
+		case status < BlarggRunning:
+			message = bus.blarggText()
+			return
+		}
+	}
+
+	err = errors.New("test ROM did not finish")
+	message = bus.blarggText()
+	return
+}
+
+// blarggValid returns true once the test ROM wrote its signature
+func (bus *Bus) blarggValid() bool {
+	for i, magic := range blarggMagic {
+		if bus.CpuRead(blarggSignature+uint16(i), true) != magic {
+			return false
+		}
+	}
+	return true
+}
+
+// blarggText returns the text the test ROM wrote from 0x6004
+func (bus *Bus) blarggText() string {
+	sb := &strings.Builder{}
+	for i := uint16(0); i < blarggMessageSize; i++ {
+		c := bus.CpuRead(blarggMessage+i, true)
+		if c == 0 {
+			break
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
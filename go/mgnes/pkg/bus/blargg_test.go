@@ -0,0 +1,110 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package bus
+
+import (
+	"bytes"
+	"mgnes/pkg/cartridge"
+	"strings"
+	"testing"
+)
+
+// blarggCart returns an NROM cartridge running code from $8000
+func blarggCart(t *testing.T, code []byte) *cartridge.Cartridge {
+	t.Helper()
+
+	image := make([]byte, 16+0x4000+0x2000)
+	copy(image, []byte{'N', 'E', 'S', 0x1A, 1, 1})
+	prg := image[16 : 16+0x4000]
+	copy(prg, code)
+	prg[0x3FFC], prg[0x3FFD] = 0x00, 0x80
+	cart, err := cartridge.Load(bytes.NewReader(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cart
+}
+
+// store returns LDA #value, STA addr
+func store(addr uint16, value uint8) []byte {
+	return []byte{0xA9, value, 0x8D, uint8(addr), uint8(addr >> 8)}
+}
+
+// report returns the code writing status, the signature and text
+func report(status uint8, text string) []byte {
+	code := store(0x6000, BlarggRunning)
+	code = append(code, store(0x6001, 0xDE)...)
+	code = append(code, store(0x6002, 0xB0)...)
+	code = append(code, store(0x6003, 0x61)...)
+	for i, c := range []byte(text + "\x00") {
+		code = append(code, store(0x6004+uint16(i), c)...)
+	}
+	return append(code, store(0x6000, status)...)
+}
+
+// halt returns a JMP to itself for code placed at $8000+len(code)
+func halt(code []byte) []byte {
+	at := 0x8000 + uint16(len(code))
+	return append(code, 0x4C, uint8(at), uint8(at>>8))
+}
+
+func TestRunBlargg(t *testing.T) {
+	// the first run asks for a reset and marks $6100, work RAM survives
+	// the reset so the second run passes
+	resetCode := []byte{
+		0xAD, 0x00, 0x61, // $8000 LDA $6100
+		0xD0, 0x00, // $8003 BNE, patched below
+	}
+	resetCode = append(resetCode, store(0x6100, 0x01)...)
+	resetCode = halt(append(resetCode, report(BlarggNeedsReset, "")...))
+	resetCode[4] = uint8(len(resetCode) - 5)
+	resetCode = halt(append(resetCode, report(0x00, "after reset\n")...))
+
+	tests := []struct {
+		name    string
+		code    []byte
+		status  uint8
+		message string
+		wantErr bool
+	}{
+		{"passed", halt(report(0x00, "Passed\n")), 0x00, "Passed\n", false},
+		{"failed", halt(report(0x03, "3 failed\n")), 0x03, "3 failed\n", false},
+		{"reset", resetCode, 0x00, "after reset\n", false},
+		{"still running", halt(report(BlarggRunning, "running")), BlarggRunning, "running", true},
+		{"no signature", halt(nil), 0x00, "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, message, err := RunBlargg(blarggCart(t, test.code), 30)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("RunBlargg() error = %v, want error %v", err, test.wantErr)
+			}
+			if status != test.status || message != test.message {
+				t.Errorf("RunBlargg() = %#02x, %q, want %#02x, %q", status, message, test.status, test.message)
+			}
+		})
+	}
+
+	if _, _, err := RunBlargg(nil, 1); err == nil || !strings.Contains(err.Error(), "cartridge") {
+		t.Errorf("RunBlargg(nil) error = %v", err)
+	}
+}
@@ -21,23 +21,72 @@
 package bus
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"mgnes/pkg/apu"
 	"mgnes/pkg/cartridge"
+	"mgnes/pkg/controller"
+	"mgnes/pkg/ines"
 	"mgnes/pkg/log"
 	"mgnes/pkg/memory"
 	"mgnes/pkg/mg2c02"
 	"mgnes/pkg/mg6502"
+	"os"
 )
 
 // Bus transmit data between cpu and other components in the NES console
 type Bus struct {
 	cpu  *mg6502.MG6502
 	ppu  *mg2c02.MG2C02
+	apu  *apu.APU
 	cart *cartridge.Cartridge
-	ram  memory.Memory
+	// work RAM is accessed directly rather than through memory.Memory, it
+	// is hit by most CPU cycles
+	ram [memory.CpuMemoryCapacity]uint8
+
+	controllers [2]*controller.Controller
 
 	systemClockCounter int
+
+	// timing of the console region, the CPU is clocked cpuTicks times
+	// every ppuTicks PPU clocks
+	region      ines.TVSystemType
+	cpuTicks    int
+	ppuTicks    int
+	frameClocks int
+
+	// A write to 0x4014 starts a DMA transfer of a 256 byte page of CPU
+	// memory into PPU OAM, the CPU is suspended during the transfer
+	dmaPage     uint8
+	dmaAddr     uint8
+	dmaData     uint8
+	dmaTransfer bool
+	// DMA transfer has to wait for an even clock cycle to start
+	dmaDummy bool
+
+	// last value driven on the CPU data bus, returned for reads no
+	// device responds to when open bus emulation is enabled
+	lastData uint8
+	openBus  bool
+
+	// sources asserting the IRQ line
+	irq IRQSource
+
+	// debugger support
+	readHook    Hook
+	writeHook   Hook
+	breakpoints []addrRange
+	paused      bool
+	trace       *bufio.Writer
 }
 
+// the bus is what the CPU reads from and writes to
+var (
+	_ mg6502.Reader = (*Bus)(nil)
+	_ mg6502.Writer = (*Bus)(nil)
+)
+
 // NewBus create and return a new bus reference
 func NewBus(cpu *mg6502.MG6502) (bus *Bus) {
 	if cpu == nil {
@@ -46,19 +95,35 @@ func NewBus(cpu *mg6502.MG6502) (bus *Bus) {
 	}
 	bus = &Bus{
 		cpu:  cpu,
-		ppu:  nil,
-		ram:  memory.NewCpuMemory(),
+		ppu:  mg2c02.NewMG2C02(),
+		apu:  apu.NewAPU(),
 		cart: nil,
+		controllers: [2]*controller.Controller{
+			controller.NewController(),
+			controller.NewController(),
+		},
 	}
+	// work RAM powers up with the content of memory.SetRandomSource
+	memory.PowerUpFill(bus.ram[:])
 	cpu.SetReader(bus)
 	cpu.SetWriter(bus)
+	bus.apu.SetReader(bus)
+	bus.SetRegion(ines.TVSystemNTSC)
 
 	return
 }
 
 // CpuWrite writes data to the bus
 func (bus *Bus) CpuWrite(addr uint16, data uint8) {
-	if bus.cart.CpuWrite(addr, data) {
+	bus.lastData = data
+	if bus.writeHook != nil {
+		bus.writeHook(addr, data)
+	}
+	if len(bus.breakpoints) > 0 {
+		bus.checkBreakpoints(addr)
+	}
+
+	if bus.cart != nil && bus.cart.CpuWrite(addr, data) {
 		// The cartridge "sees all" and has the facility to veto
 		// the propagation of the bus transaction if it requires.
 		// This allows the cartridge to map any address to some
@@ -69,41 +134,192 @@ func (bus *Bus) CpuWrite(addr uint16, data uint8) {
 		// there is only 2KB available. That 2KB is "mirrored"
 		// through this address range. Using bitwise AND to mask
 		// the bottom 11 bits is the same as addr % 2048.
-		bus.ram.Write(addr, data)
+		bus.ram[addr&0x07FF] = data
 	} else if addr >= 0x2000 && addr <= 0x3FFF {
 		// PPU Address range. The PPU only has 8 primary registers
 		// and these are repeated throughout this range. We can
 		// use bitwise AND operation to mask the bottom 3 bits,
 		// which is the equivalent of addr % 8.
 		bus.ppu.CpuWrite(addr, data)
+	} else if (addr >= 0x4000 && addr <= 0x4013) || addr == 0x4015 || addr == 0x4017 {
+		// APU registers, 0x4017 is the frame counter on write but
+		// controller 2 on read
+		bus.apu.CpuWrite(addr, data)
+	} else if addr == 0x4014 {
+		bus.dmaPage = data
+		bus.dmaAddr = 0x00
+		bus.dmaTransfer = true
+		bus.dmaDummy = true
+	} else if addr == 0x4016 {
+		// Writing the strobe bit latches the state of both controllers
+		bus.controllers[0].Write(data)
+		bus.controllers[1].Write(data)
 	}
+	// nothing is connected to the disabled APU test registers at
+	// 0x4018-0x401F and the expansion area up to 0x5FFF, unless the
+	// cartridge claimed the write above
 }
 
 // CpuRead data from the bus
 func (bus *Bus) CpuRead(addr uint16, readonly bool) (data uint8) {
 	flag := false
-	if data, flag = bus.cart.CpuRead(addr); flag {
+	if bus.cart != nil {
+		data, flag = bus.cart.CpuRead(addr)
+	}
+	if flag {
 		// cartridge address range
 	} else if addr <= 0x1FFF {
 		// system RAM address range, mirrored every 2048 bytes
-		data = bus.ram.Read(addr)
+		data = bus.ram[addr&0x07FF]
 	} else if addr >= 0x2000 && addr <= 0x3FFF {
 		// PPU address range, mirrored every 8 bytes
 		data = bus.ppu.CpuRead(addr, readonly)
+	} else if addr == 0x4015 {
+		// APU status
+		data = bus.apu.CpuRead(addr, readonly)
+	} else if addr == 0x4016 || addr == 0x4017 {
+		// controllers, one bit is shifted out per read, the top 3
+		// bits are not driven
+		data = bus.controllers[addr&0x0001].Read(readonly)
+		if bus.openBus {
+			data |= bus.lastData & 0xE0
+		}
+	} else if bus.openBus {
+		// nothing responds, the last value is still on the bus. This is
+		// the case of the write only APU registers, the disabled APU
+		// test registers at 0x4018-0x401F and the expansion area up to
+		// 0x5FFF when the cartridge does not map it
+		data = bus.lastData
+	}
+
+	if !readonly {
+		bus.lastData = data
+		if bus.readHook != nil {
+			bus.readHook(addr, data)
+		}
+		if len(bus.breakpoints) > 0 {
+			bus.checkBreakpoints(addr)
+		}
 	}
 	return
 }
 
-// InsertCartridge attach a cartridge to the bus
-func (bus *Bus) InsertCartridge(cart *cartridge.Cartridge) {
+// DumpRange returns the bytes from start to end inclusive, the range wraps
+// around at 0xFFFF. Reads are readonly so no device changes state
+func (bus *Bus) DumpRange(start, end uint16) []byte {
+	data := make([]byte, int(end-start)+1)
+	bus.PeekRange(start, data)
+	return data
+}
+
+// PeekRange fills buf with the bytes starting at start, wrapping around at
+// 0xFFFF. It guarantees no side effects: no device changes state, hooks and
+// breakpoints are not triggered and the open bus value is untouched
+func (bus *Bus) PeekRange(start uint16, buf []byte) {
+	for i := range buf {
+		buf[i] = bus.CpuRead(start+uint16(i), true)
+	}
+}
+
+// LoadRange writes data to the bus starting at start through CpuWrite,
+// wrapping around at 0xFFFF
+func (bus *Bus) LoadRange(start uint16, data []byte) {
+	for i, b := range data {
+		bus.CpuWrite(start+uint16(i), b)
+	}
+}
+
+// LoadProgram writes code to the bus starting at offset and points the
+// reset vector at 0xFFFC to resetVector. The bytes go through CpuWrite, so
+// they land wherever the bus maps the addresses
+func (bus *Bus) LoadProgram(offset uint16, code []byte, resetVector uint16) error {
+	if int(offset)+len(code) > 0x10000 {
+		return errors.New("program overflows the address space")
+	}
+
+	bus.LoadRange(offset, code)
+	bus.CpuWrite(0xFFFC, uint8(resetVector&0x00FF))
+	bus.CpuWrite(0xFFFD, uint8(resetVector>>8))
+
+	return nil
+}
+
+// SetOpenBus enables open bus emulation, reads from addresses no device
+// responds to return the last value on the bus instead of 0. This also
+// applies to the undriven bits of PPU and controller registers
+func (bus *Bus) SetOpenBus(enabled bool) {
+	bus.openBus = enabled
+	if bus.ppu != nil {
+		bus.ppu.SetOpenBus(enabled)
+	}
+}
+
+// SetButtons sets the button state of controller of given player (0 or 1)
+func (bus *Bus) SetButtons(player int, state uint8) {
+	if player < 0 || player >= len(bus.controllers) {
+		return
+	}
+	bus.controllers[player].SetButtons(state)
+}
+
+// SetSampleRate sets the rate in Hz of the audio returned by ReadAudio
+func (bus *Bus) SetSampleRate(hz int) {
+	bus.apu.SetSampleRate(hz)
+}
+
+// ReadAudio pulls resampled audio into buf and returns the number of
+// samples available, the rest is filled with silence
+func (bus *Bus) ReadAudio(buf []float32) int {
+	return bus.apu.Read(buf)
+}
+
+// InsertCartridge attach a cartridge to the bus, it fails if the bus has
+// no PPU to attach the CHR memory to
+func (bus *Bus) InsertCartridge(cart *cartridge.Cartridge) error {
+	if cart == nil {
+		return errors.New("invalid cartridge")
+	}
+	if bus.ppu == nil {
+		return errors.New("no PPU attached to the bus")
+	}
 	bus.cart = cart
 	bus.ppu.AttachCartridge(cart)
+	bus.SetRegion(cart.Region())
+	return nil
+}
+
+// InsertCartridgeFromPath loads the iNES file at path and inserts it like
+// InsertCartridge. The returned error wraps the one of opening or loading
+// the file
+func (bus *Bus) InsertCartridgeFromPath(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open cartridge: %w", err)
+	}
+	defer f.Close()
+
+	cart, err := cartridge.Load(f)
+	if err != nil {
+		return fmt.Errorf("could not load cartridge %v: %w", path, err)
+	}
+	return bus.InsertCartridge(cart)
 }
 
 // Reset sends a reset signal to all components attached to this bus
 func (bus *Bus) Reset() {
+	if bus.cart != nil {
+		bus.cart.Reset()
+	}
 	bus.cpu.Reset()
+	bus.apu.Reset()
 	bus.systemClockCounter = 0
+	bus.irq = 0
+
+	bus.dmaPage = 0x00
+	bus.dmaAddr = 0x00
+	bus.dmaData = 0x00
+	bus.dmaTransfer = false
+	bus.dmaDummy = true
 }
 
 // Clock ticks the whole system
@@ -119,12 +335,69 @@ func (bus *Bus) Clock() {
 	// each time this function is called.
 	bus.ppu.Clock()
 
-	// The CPU runs 3 times slower than the PPU so we only call its
-	// clock() function every 3 times this function is called. We
-	// have a global counter to keep track of this.
-	if bus.systemClockCounter%3 == 0 {
-		bus.cpu.Clock()
+	// The CPU runs 3 times slower than the PPU on NTSC and 3.2 times
+	// slower on PAL, so we only call its clock() function on some of
+	// the times this function is called. We have a global counter to
+	// keep track of this.
+	if bus.cpuClock() {
+		// The APU is clocked at CPU rate and keeps running while
+		// the CPU is stalled by DMA
+		bus.apu.Clock()
+
+		if bus.dmaTransfer {
+			bus.clockDMA()
+		} else {
+			bus.pollIRQ()
+			if bus.trace != nil && bus.cpu.Complete() && !bus.cpu.InterruptPending() {
+				bus.traceInstruction()
+			}
+			bus.cpu.Clock()
+		}
+
+		// sampled after the CPU cycle, a PPUSTATUS read on the cycle the
+		// vertical blank starts clears the flag before the NMI is seen
+		bus.cpu.SetNMI(bus.ppu.NMI())
 	}
 
 	bus.systemClockCounter++
 }
+
+// cpuClock returns true if the CPU is clocked on the current PPU clock
+func (bus *Bus) cpuClock() bool {
+	return bus.systemClockCounter*bus.cpuTicks%bus.ppuTicks < bus.cpuTicks
+}
+
+// cpuCycle returns the number of CPU cycles since the last reset
+func (bus *Bus) cpuCycle() int {
+	return bus.systemClockCounter * bus.cpuTicks / bus.ppuTicks
+}
+
+// clockDMA performs one CPU cycle of the OAM DMA transfer. The CPU is
+// stalled while the transfer takes place, the transfer alternates between
+// reading a byte from the CPU bus on even cycles and writing it to OAM on
+// odd cycles, 256 bytes takes 512 cycles. There are also one or two idle
+// cycles for the transfer to start on an even cycle, so 513 or 514 cycles
+// in total.
+func (bus *Bus) clockDMA() {
+	if bus.dmaDummy {
+		// wait for an even cycle to start
+		if bus.cpuCycle()%2 == 1 {
+			bus.dmaDummy = false
+		}
+		return
+	}
+
+	if bus.cpuCycle()%2 == 0 {
+		// read from CPU memory on even cycles
+		bus.dmaData = bus.CpuRead(uint16(bus.dmaPage)<<8|uint16(bus.dmaAddr), false)
+	} else {
+		// write to PPU OAM on odd cycles
+		bus.ppu.WriteOAM(bus.dmaAddr, bus.dmaData)
+		bus.dmaAddr++
+		// the address wraps around after 256 bytes, end of transfer
+		if bus.dmaAddr == 0x00 {
+			bus.dmaTransfer = false
+			bus.dmaDummy = true
+		}
+	}
+}
@@ -0,0 +1,434 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package bus
+
+import (
+	"bytes"
+	"errors"
+	"mgnes/pkg/cartridge"
+	"mgnes/pkg/mg6502"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestBus returns a bus with a 16KB NROM cartridge inserted, the PRG
+// ROM is writable so LoadProgram can place code anywhere
+func newTestBus(t testing.TB) *Bus {
+	t.Helper()
+
+	image := make([]byte, 16+0x4000+0x2000)
+	copy(image, []byte{'N', 'E', 'S', 0x1A, 1, 1})
+	cart, err := cartridge.Load(bytes.NewReader(image))
+	if err != nil {
+		t.Fatalf("could not load cartridge: %v", err)
+	}
+
+	bus := NewBus(mg6502.NewMG6502())
+	if err := bus.InsertCartridge(cart); err != nil {
+		t.Fatalf("could not insert cartridge: %v", err)
+	}
+	return bus
+}
+
+func TestControllerRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		buttons uint8
+		want    [8]uint8
+	}{
+		{"none", 0x00, [8]uint8{0, 0, 0, 0, 0, 0, 0, 0}},
+		{"A", 0x80, [8]uint8{1, 0, 0, 0, 0, 0, 0, 0}},
+		{"Right", 0x01, [8]uint8{0, 0, 0, 0, 0, 0, 0, 1}},
+		{"B Start Down", 0x54, [8]uint8{0, 1, 0, 1, 0, 1, 0, 0}},
+		{"all", 0xFF, [8]uint8{1, 1, 1, 1, 1, 1, 1, 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bus := newTestBus(t)
+			bus.SetButtons(1, test.buttons)
+
+			// strobe high then low latches the buttons
+			bus.CpuWrite(0x4016, 0x01)
+			bus.CpuWrite(0x4016, 0x00)
+
+			for i, want := range test.want {
+				if got := bus.CpuRead(0x4017, false); got != want {
+					t.Errorf("read %v = %v, want %v", i, got, want)
+				}
+			}
+			// official controllers report 1 once all buttons are read
+			if got := bus.CpuRead(0x4017, false); got != 1 {
+				t.Errorf("read 8 = %v, want 1", got)
+			}
+			// the other controller is untouched
+			if got := bus.CpuRead(0x4016, false); got != 0 {
+				t.Errorf("player 0 read = %v, want 0", got)
+			}
+		})
+	}
+}
+
+func TestControllerStrobeHigh(t *testing.T) {
+	bus := newTestBus(t)
+	bus.SetButtons(0, 0x80)
+	bus.CpuWrite(0x4016, 0x01)
+
+	// while strobe is high every read returns the A button
+	for i := 0; i < 3; i++ {
+		if got := bus.CpuRead(0x4016, false); got != 1 {
+			t.Errorf("read %v = %v, want 1", i, got)
+		}
+	}
+}
+
+// loopForever loads "JMP $8000" at 0x8000 and resets into it
+func loopForever(t testing.TB, bus *Bus) {
+	t.Helper()
+	if err := bus.LoadProgram(0x8000, []byte{0x4C, 0x00, 0x80}, 0x8000); err != nil {
+		t.Fatal(err)
+	}
+	bus.Reset()
+}
+
+func TestOAMDMA(t *testing.T) {
+	bus := newTestBus(t)
+	loopForever(t, bus)
+	bus.RunCycles(16)
+
+	want := make([]byte, 256)
+	for i := range want {
+		want[i] = uint8(i*7 + 3)
+	}
+	bus.LoadRange(0x0200, want)
+
+	retired := 0
+	bus.cpu.OnInstructionRetired = func(pc uint16, opcode uint8, cycles uint8) {
+		retired++
+	}
+
+	bus.CpuWrite(0x4014, 0x02)
+	bus.RunCycles(512)
+	if retired != 0 {
+		t.Errorf("%v instructions retired during the transfer, want 0", retired)
+	}
+	if !bus.dmaTransfer {
+		t.Errorf("transfer finished after 512 cycles, want 513 or 514")
+	}
+
+	bus.RunCycles(2)
+	if bus.dmaTransfer {
+		t.Fatalf("transfer still running after 514 cycles")
+	}
+	for i, b := range want {
+		if got := bus.ppu.ReadOAM(uint8(i)); got != b {
+			t.Fatalf("OAM[%#02x] = %#02x, want %#02x", i, got, b)
+		}
+	}
+
+	bus.RunCycles(8)
+	if retired == 0 {
+		t.Errorf("the CPU did not resume after the transfer")
+	}
+}
+
+func TestAPUStatus(t *testing.T) {
+	bus := newTestBus(t)
+	loopForever(t, bus)
+
+	// enable pulse 1, triangle and noise, then load their length counters
+	// with index 1 (254 half frames) through the bus
+	bus.CpuWrite(0x4015, 0x0D)
+	bus.CpuWrite(0x4003, 0x08)
+	bus.CpuWrite(0x4007, 0x08)
+	bus.CpuWrite(0x400B, 0x08)
+	bus.CpuWrite(0x400F, 0x08)
+	if got := bus.CpuRead(0x4015, false); got != 0x0D {
+		t.Fatalf("status = %#02x, want 0x0D", got)
+	}
+
+	// disabling a channel clears its length counter
+	bus.CpuWrite(0x4015, 0x09)
+	if got := bus.CpuRead(0x4015, false); got != 0x09 {
+		t.Fatalf("status after disabling triangle = %#02x, want 0x09", got)
+	}
+
+	// the 4-step sequence raises the frame interrupt at the end of the
+	// first frame, a readonly read leaves it set and a normal read clears it
+	bus.RunCycles(30000)
+	if got := bus.CpuRead(0x4015, true); got != 0x49 {
+		t.Fatalf("readonly status = %#02x, want 0x49", got)
+	}
+	if got := bus.CpuRead(0x4015, false); got != 0x49 {
+		t.Fatalf("status = %#02x, want 0x49", got)
+	}
+	if got := bus.CpuRead(0x4015, false); got != 0x09 {
+		t.Errorf("status after acknowledge = %#02x, want 0x09", got)
+	}
+}
+
+// BenchmarkRAMLoop runs the CPU alone through the bus on a loop that reads
+// and writes work RAM every instruction, the path behind the fixed RAM array
+func BenchmarkRAMLoop(b *testing.B) {
+	bus := newTestBus(b)
+	program := []byte{
+		0xA2, 0x00, // $8000 LDX #$00
+		0xB5, 0x00, // $8002 LDA $00,X
+		0x69, 0x01, // $8004 ADC #$01
+		0x9D, 0x00, 0x07, // $8006 STA $0700,X
+		0xE8,       // $8009 INX
+		0xD0, 0xF6, // $800A BNE $8002
+		0x4C, 0x00, 0x80, // $800C JMP $8000
+	}
+	if err := bus.LoadProgram(0x8000, program, 0x8000); err != nil {
+		b.Fatal(err)
+	}
+	bus.Reset()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bus.cpu.StepInstruction()
+	}
+}
+
+func TestInsertCartridge(t *testing.T) {
+	image := make([]byte, 16+0x4000+0x2000)
+	copy(image, []byte{'N', 'E', 'S', 0x1A, 1, 1})
+	cart, err := cartridge.Load(bytes.NewReader(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// NewBus attaches its own PPU, the cartridge goes in without one being
+	// set up by hand
+	bus := NewBus(mg6502.NewMG6502())
+	if err := bus.InsertCartridge(nil); err == nil {
+		t.Errorf("InsertCartridge(nil) returned no error")
+	}
+	if err := bus.InsertCartridge(cart); err != nil {
+		t.Fatal(err)
+	}
+	bus.Reset()
+	bus.RunCycles(100)
+
+	noPPU := NewBus(mg6502.NewMG6502())
+	noPPU.ppu = nil
+	if err := noPPU.InsertCartridge(cart); err == nil {
+		t.Errorf("InsertCartridge() without a PPU returned no error")
+	}
+}
+
+func TestInsertCartridgeFromPath(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.nes")
+	image := make([]byte, 16+0x4000+0x2000)
+	copy(image, []byte{'N', 'E', 'S', 0x1A, 1, 1})
+	image[16] = 0xEA
+	if err := os.WriteFile(valid, image, 0644); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.nes")
+	if err := os.WriteFile(invalid, []byte("not a ROM"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	bus := NewBus(mg6502.NewMG6502())
+	if err := bus.InsertCartridgeFromPath(valid); err != nil {
+		t.Fatal(err)
+	}
+	if got := bus.CpuRead(0x8000, false); got != 0xEA {
+		t.Errorf("read of 0x8000 = %#02x, want 0xEA", got)
+	}
+
+	err := bus.InsertCartridgeFromPath(filepath.Join(dir, "missing.nes"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file error = %v, want one wrapping os.ErrNotExist", err)
+	}
+	if err := bus.InsertCartridgeFromPath(invalid); err == nil {
+		t.Errorf("invalid file returned no error")
+	} else if !strings.Contains(err.Error(), invalid) {
+		t.Errorf("invalid file error = %v, want it to name the path", err)
+	}
+
+	// the cartridge inserted before stays on failure
+	if got := bus.CpuRead(0x8000, false); got != 0xEA {
+		t.Errorf("read of 0x8000 = %#02x after failed inserts, want 0xEA", got)
+	}
+}
+
+func TestNoCartridge(t *testing.T) {
+	bus := NewBus(mg6502.NewMG6502())
+
+	// the CPU still reaches work RAM, the cartridge space is unmapped
+	bus.CpuWrite(0x0010, 0x42)
+	if got := bus.CpuRead(0x0810, false); got != 0x42 {
+		t.Errorf("RAM mirror read = %#02x, want 0x42", got)
+	}
+	bus.CpuWrite(0x8000, 0x55)
+	bus.CpuWrite(0x6000, 0x55)
+	if got := bus.CpuRead(0x6000, false); got != 0x00 {
+		t.Errorf("read of 0x6000 = %#02x, want 0x00", got)
+	}
+
+	bus.Reset()
+	bus.RunCycles(100)
+}
+
+func TestLoadProgram(t *testing.T) {
+	bus := newTestBus(t)
+	code := []byte{0xA9, 0x01, 0x8D, 0x00, 0x02, 0x4C, 0x05, 0x81}
+	if err := bus.LoadProgram(0x8100, code, 0x8100); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := bus.DumpRange(0x8100, 0x8107); !bytes.Equal(got, code) {
+		t.Errorf("code = % x, want % x", got, code)
+	}
+	// the reset vector is little endian
+	if got := bus.DumpRange(0xFFFC, 0xFFFD); !bytes.Equal(got, []byte{0x00, 0x81}) {
+		t.Errorf("reset vector = % x, want 00 81", got)
+	}
+	bus.Reset()
+	if pc := bus.cpu.Snapshot().PC; pc != 0x8100 {
+		t.Errorf("PC after reset = %#04x, want 0x8100", pc)
+	}
+
+	// the last byte of the address space is still in range
+	if err := bus.LoadProgram(0xFFF8, make([]byte, 8), 0x8000); err != nil {
+		t.Errorf("program ending at 0xFFFF: %v", err)
+	}
+	if err := bus.LoadProgram(0xFFF8, make([]byte, 9), 0x8000); err == nil {
+		t.Errorf("program overflowing 0xFFFF returned no error")
+	}
+}
+
+func TestOpenBus(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		want    uint8 // read by LDA $5000
+		after   uint8 // read after one of $A5
+	}{
+		// the high byte of the operand is the last value on the bus
+		{"enabled", true, 0x50, 0xA5},
+		{"disabled", false, 0x00, 0x00},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := newTestBus(t)
+			program := []byte{
+				0xAD, 0x00, 0x50, // $8000 LDA $5000
+				0x4C, 0x03, 0x80, // $8003 JMP $8003
+			}
+			if err := bus.LoadProgram(0x8000, program, 0x8000); err != nil {
+				t.Fatal(err)
+			}
+			bus.SetOpenBus(tt.enabled)
+			bus.Reset()
+			bus.cpu.A = 0xFF
+			bus.RunCycles(7 + 4)
+
+			if bus.cpu.A != tt.want {
+				t.Errorf("LDA $5000 = %#02x, want %#02x", bus.cpu.A, tt.want)
+			}
+
+			// a direct read sees the value of the previous one
+			bus.ram[0x0010] = 0xA5
+			bus.CpuRead(0x0010, false)
+			if data := bus.CpuRead(0x4018, false); data != tt.after {
+				t.Errorf("read $4018 = %#02x, want %#02x", data, tt.after)
+			}
+		})
+	}
+}
+
+func TestDumpRange(t *testing.T) {
+	bus := newTestBus(t)
+
+	data := make([]byte, 0x100)
+	for i := range data {
+		data[i] = uint8(i*13 + 1)
+	}
+	bus.LoadRange(0x0300, data)
+	if got := bus.DumpRange(0x0300, 0x03FF); !bytes.Equal(got, data) {
+		t.Errorf("DumpRange(0x0300, 0x03FF) = % x, want % x", got, data)
+	}
+
+	// RAM is mirrored, the dump of a mirror is the same
+	if got := bus.DumpRange(0x0B00, 0x0BFF); !bytes.Equal(got, data) {
+		t.Errorf("DumpRange of the mirror at 0x0B00 differs")
+	}
+
+	// the range wraps around at 0xFFFF
+	bus.LoadRange(0x0000, []byte{0x11, 0x22})
+	rom, _ := bus.PeekPRG(0x3FFF)
+	got := bus.DumpRange(0xFFFF, 0x0001)
+	if want := []byte{rom, 0x11, 0x22}; !bytes.Equal(got, want) {
+		t.Errorf("DumpRange(0xFFFF, 0x0001) = % x, want % x", got, want)
+	}
+}
+
+func TestPeekRangeSideEffects(t *testing.T) {
+	bus := newTestBus(t)
+	loopForever(t, bus)
+	bus.SetOpenBus(true)
+	hooked := 0
+	bus.SetReadHook(func(addr uint16, val uint8) { hooked++ })
+
+	// run into the vertical blank of the first frame
+	status := make([]byte, 1)
+	for i := 0; status[0]&0x80 == 0; i++ {
+		if i > 2*clocksPerFrame {
+			t.Fatalf("vertical blank flag never set")
+		}
+		bus.Clock()
+		bus.PeekRange(0x2002, status)
+	}
+
+	hooked = 0
+	bus.lastData = 0x5A
+	buf := make([]byte, 8)
+	for i := 0; i < 3; i++ {
+		bus.PeekRange(0x2000, buf)
+	}
+	if buf[2]&0x80 == 0 {
+		t.Errorf("PPUSTATUS = %#02x, want the vertical blank flag set", buf[2])
+	}
+	if hooked != 0 {
+		t.Errorf("read hook called %v times", hooked)
+	}
+	if bus.lastData != 0x5A {
+		t.Errorf("open bus value = %#02x, want 0x5A untouched", bus.lastData)
+	}
+
+	// a real read clears the flag
+	if data := bus.CpuRead(0x2002, false); data&0x80 == 0 {
+		t.Errorf("read PPUSTATUS = %#02x, want the vertical blank flag set", data)
+	}
+	bus.PeekRange(0x2002, status)
+	if status[0]&0x80 != 0 {
+		t.Errorf("vertical blank flag still set after reading PPUSTATUS")
+	}
+}
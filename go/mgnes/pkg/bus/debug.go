@@ -0,0 +1,150 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package bus
+
+import (
+	"bufio"
+	"io"
+)
+
+// trace lines are about 80 bytes, the buffer holds several hundred of them
+const traceBufferSize = 64 * 1024
+
+// Hook is called with the address and value of a CPU bus access
+type Hook func(addr uint16, val uint8)
+
+// addrRange is an inclusive range of addresses
+type addrRange struct {
+	lo, hi uint16
+}
+
+// SetReadHook sets a hook called on every CPU read, readonly peeks do not
+// fire it. nil removes the hook
+func (bus *Bus) SetReadHook(hook Hook) {
+	bus.readHook = hook
+}
+
+// SetWriteHook sets a hook called on every CPU write, nil removes the hook
+func (bus *Bus) SetWriteHook(hook Hook) {
+	bus.writeHook = hook
+}
+
+// AddBreakpoint pauses the run loop when the CPU reads or writes an
+// address between lo and hi inclusive. This includes opcode fetches, so a
+// range covering code breaks on execution. The CPU executes a whole
+// instruction at once, the run loop stops after the instruction that made
+// the access
+func (bus *Bus) AddBreakpoint(lo, hi uint16) {
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	bus.breakpoints = append(bus.breakpoints, addrRange{lo, hi})
+}
+
+// ClearBreakpoints removes all breakpoints
+func (bus *Bus) ClearBreakpoints() {
+	bus.breakpoints = nil
+}
+
+// Paused returns true after a breakpoint was hit, RunCycles and RunFrames
+// return immediately until Resume is called
+func (bus *Bus) Paused() bool {
+	return bus.paused
+}
+
+// Resume clears the paused state set by a breakpoint
+func (bus *Bus) Resume() {
+	bus.paused = false
+}
+
+// checkBreakpoints pauses the run loop if addr is in a breakpoint range
+func (bus *Bus) checkBreakpoints(addr uint16) {
+	for _, r := range bus.breakpoints {
+		if addr >= r.lo && addr <= r.hi {
+			bus.paused = true
+			return
+		}
+	}
+}
+
+// PeekPRG reads the PRG memory of the cartridge at offset regardless of
+// the current bank mapping, flag is false without a cartridge or if offset
+// is out of range
+func (bus *Bus) PeekPRG(offset uint32) (data uint8, flag bool) {
+	if bus.cart == nil {
+		return
+	}
+	return bus.cart.PeekPRG(offset)
+}
+
+// PokePRG writes the PRG memory of the cartridge at offset regardless of
+// the current bank mapping, e.g. to patch ROM code
+func (bus *Bus) PokePRG(offset uint32, data uint8) (flag bool) {
+	if bus.cart == nil {
+		return
+	}
+	return bus.cart.PokePRG(offset, data)
+}
+
+// PeekCHR reads the CHR memory of the cartridge like PeekPRG
+func (bus *Bus) PeekCHR(offset uint32) (data uint8, flag bool) {
+	if bus.cart == nil {
+		return
+	}
+	return bus.cart.PeekCHR(offset)
+}
+
+// PokeCHR writes the CHR memory of the cartridge like PokePRG
+func (bus *Bus) PokeCHR(offset uint32, data uint8) (flag bool) {
+	if bus.cart == nil {
+		return
+	}
+	return bus.cart.PokeCHR(offset, data)
+}
+
+// SetTraceWriter logs every instruction the CPU executes to w, one line in
+// the nestest log format before the instruction runs. The lines are
+// buffered, call FlushTrace before reading w. A nil w stops tracing and
+// flushes the previous writer
+func (bus *Bus) SetTraceWriter(w io.Writer) error {
+	err := bus.FlushTrace()
+	if w == nil {
+		bus.trace = nil
+	} else {
+		bus.trace = bufio.NewWriterSize(w, traceBufferSize)
+	}
+	return err
+}
+
+// FlushTrace writes out the buffered trace lines
+func (bus *Bus) FlushTrace() error {
+	if bus.trace == nil {
+		return nil
+	}
+	return bus.trace.Flush()
+}
+
+// traceInstruction logs the instruction at the program counter
+func (bus *Bus) traceInstruction() {
+	// formatted straight into the free space of the buffer
+	line := bus.cpu.AppendNestestLine(bus.trace.AvailableBuffer())
+	bus.trace.Write(append(line, '\n'))
+}
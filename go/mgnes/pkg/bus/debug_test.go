@@ -0,0 +1,165 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package bus
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"mgnes/pkg/mg6502"
+)
+
+func TestWriteHook(t *testing.T) {
+	bus := newTestBus(t)
+	program := []byte{
+		0xA9, 0x42, // $8000 LDA #$42
+		0x8D, 0x34, 0x03, // $8002 STA $0334
+		0x4C, 0x05, 0x80, // $8005 JMP $8005
+	}
+	if err := bus.LoadProgram(0x8000, program, 0x8000); err != nil {
+		t.Fatal(err)
+	}
+	bus.Reset()
+
+	type write struct {
+		addr uint16
+		val  uint8
+	}
+	var writes []write
+	bus.SetWriteHook(func(addr uint16, val uint8) {
+		writes = append(writes, write{addr, val})
+	})
+	bus.RunCycles(20)
+
+	if len(writes) != 1 || writes[0] != (write{0x0334, 0x42}) {
+		t.Errorf("writes = %x, want [{0334 42}]", writes)
+	}
+
+	// removing the hook stops the calls
+	bus.SetWriteHook(nil)
+	bus.CpuWrite(0x0000, 0x01)
+	if len(writes) != 1 {
+		t.Errorf("hook called after being removed")
+	}
+}
+
+func TestTraceWriter(t *testing.T) {
+	bus := newTestBus(t)
+	program := []byte{
+		0xA9, 0x42, // $8000 LDA #$42
+		0x8D, 0x34, 0x03, // $8002 STA $0334
+		0xA2, 0x01, // $8005 LDX #$01
+		0x4C, 0x07, 0x80, // $8007 JMP $8007
+	}
+	if err := bus.LoadProgram(0x8000, program, 0x8000); err != nil {
+		t.Fatal(err)
+	}
+	bus.Reset()
+
+	var buf bytes.Buffer
+	if err := bus.SetTraceWriter(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// the 7 cycles of the reset sequence, then 13 of the program
+	bus.RunCycles(20)
+	if buf.Len() != 0 {
+		t.Errorf("trace written before FlushTrace")
+	}
+	if err := bus.FlushTrace(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"8000  A9 42     LDA #$42                        A:00 X:00 Y:00 P:24 SP:FD CYC:7",
+		"8002  8D 34 03  STA $0334 = 00                  A:42 X:00 Y:00 P:24 SP:FD CYC:9",
+		"8005  A2 01     LDX #$01                        A:42 X:00 Y:00 P:24 SP:FD CYC:13",
+		"8007  4C 07 80  JMP $8007                       A:42 X:01 Y:00 P:24 SP:FD CYC:15",
+		"8007  4C 07 80  JMP $8007                       A:42 X:01 Y:00 P:24 SP:FD CYC:18",
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("trace has %v lines, want %v:\n%v", len(lines), len(want), buf.String())
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %v = %q, want %q", i, lines[i], want[i])
+		}
+	}
+
+	// a nil writer stops tracing
+	if err := bus.SetTraceWriter(nil); err != nil {
+		t.Fatal(err)
+	}
+	n := buf.Len()
+	bus.RunCycles(20)
+	if buf.Len() != n {
+		t.Errorf("trace written after SetTraceWriter(nil)")
+	}
+}
+
+func TestPeekPokeROM(t *testing.T) {
+	bus := newTestBus(t)
+
+	// patched code is what the CPU reads, on both mirrors of the 16KB bank
+	if !bus.PokePRG(0x0123, 0xEA) {
+		t.Fatalf("PokePRG(0x0123) failed")
+	}
+	if data, ok := bus.PeekPRG(0x0123); !ok || data != 0xEA {
+		t.Errorf("PeekPRG(0x0123) = %#02x, %v, want 0xEA, true", data, ok)
+	}
+	if data := bus.CpuRead(0x8123, false); data != 0xEA {
+		t.Errorf("read $8123 = %#02x, want 0xEA", data)
+	}
+	if data := bus.CpuRead(0xC123, false); data != 0xEA {
+		t.Errorf("read $C123 = %#02x, want 0xEA", data)
+	}
+
+	if !bus.PokeCHR(0x1FFF, 0x5A) {
+		t.Fatalf("PokeCHR(0x1FFF) failed")
+	}
+	if data, ok := bus.PeekCHR(0x1FFF); !ok || data != 0x5A {
+		t.Errorf("PeekCHR(0x1FFF) = %#02x, %v, want 0x5A, true", data, ok)
+	}
+
+	// offsets past the end of the ROMs
+	if bus.PokePRG(0x4000, 0x01) {
+		t.Errorf("PokePRG(0x4000) succeeded on a 16KB PRG ROM")
+	}
+	if _, ok := bus.PeekPRG(0x4000); ok {
+		t.Errorf("PeekPRG(0x4000) succeeded on a 16KB PRG ROM")
+	}
+	if bus.PokeCHR(0x2000, 0x01) {
+		t.Errorf("PokeCHR(0x2000) succeeded on an 8KB CHR ROM")
+	}
+	if _, ok := bus.PeekCHR(0x2000); ok {
+		t.Errorf("PeekCHR(0x2000) succeeded on an 8KB CHR ROM")
+	}
+
+	// and without a cartridge
+	empty := NewBus(mg6502.NewMG6502())
+	if _, ok := empty.PeekPRG(0); ok {
+		t.Errorf("PeekPRG succeeded without a cartridge")
+	}
+	if empty.PokeCHR(0, 0x01) {
+		t.Errorf("PokeCHR succeeded without a cartridge")
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package bus
+
+// IRQSource is a device driving the shared IRQ line of the CPU, the line
+// is active while any source asserts it
+type IRQSource uint8
+
+const (
+	// IRQMapper is the interrupt of the cartridge mapper, e.g. the MMC3
+	// scanline counter
+	IRQMapper IRQSource = 1 << iota
+	// IRQAPU is the frame counter or DMC interrupt of the APU
+	IRQAPU
+	// IRQExternal is free for devices outside the console
+	IRQExternal
+)
+
+// SetIRQ asserts or releases the IRQ line for source. Releasing one source
+// keeps the line active while another one still asserts it
+func (bus *Bus) SetIRQ(source IRQSource, asserted bool) {
+	if asserted {
+		bus.irq |= source
+	} else {
+		bus.irq &^= source
+	}
+}
+
+// IRQLine returns true while any source asserts the IRQ line
+func (bus *Bus) IRQLine() bool {
+	return bus.irq != 0
+}
+
+// pollIRQ samples the interrupt outputs of the APU and the cartridge and
+// drives the IRQ line of the CPU, which polls it near the end of each
+// instruction. The CPU ignores it while its interrupt disable flag is set,
+// the devices keep asserting until the program acknowledges them
+func (bus *Bus) pollIRQ() {
+	bus.SetIRQ(IRQAPU, bus.apu.IRQ())
+	if bus.cart != nil {
+		bus.SetIRQ(IRQMapper, bus.cart.IRQState())
+	}
+
+	bus.cpu.SetIRQ(bus.irq != 0)
+}
@@ -0,0 +1,94 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package bus
+
+import "testing"
+
+func TestIRQLine(t *testing.T) {
+	bus := newTestBus(t)
+
+	steps := []struct {
+		name     string
+		source   IRQSource
+		asserted bool
+		want     bool
+	}{
+		{"mapper asserts", IRQMapper, true, true},
+		{"external asserts", IRQExternal, true, true},
+		{"mapper releases, external holds the line", IRQMapper, false, true},
+		{"releasing twice keeps external", IRQMapper, false, true},
+		{"external releases", IRQExternal, false, false},
+	}
+
+	for _, step := range steps {
+		bus.SetIRQ(step.source, step.asserted)
+		if got := bus.IRQLine(); got != step.want {
+			t.Errorf("%v: IRQLine = %v, want %v", step.name, got, step.want)
+		}
+	}
+}
+
+func TestIRQSharedLine(t *testing.T) {
+	bus := newTestBus(t)
+	program := []byte{
+		0x58,             // $8000 CLI
+		0x4C, 0x01, 0x80, // $8001 JMP $8001
+	}
+	handler := []byte{
+		0xE6, 0x00, // $8010 INC $00
+		0x40, // $8012 RTI
+	}
+	if err := bus.LoadProgram(0x8000, program, 0x8000); err != nil {
+		t.Fatal(err)
+	}
+	bus.LoadRange(0x8010, handler)
+	bus.LoadRange(0xFFFE, []byte{0x10, 0x80})
+	bus.Reset()
+
+	// the APU frame counter interrupt fires at the end of the first frame,
+	// by then both sources assert the line
+	bus.SetIRQ(IRQExternal, true)
+	bus.RunCycles(30000)
+	if bus.irq != IRQExternal|IRQAPU {
+		t.Fatalf("sources = %03b, want external and APU", bus.irq)
+	}
+
+	// releasing one source keeps interrupting the CPU
+	bus.SetIRQ(IRQExternal, false)
+	taken := bus.ram[0x00]
+	bus.RunCycles(200)
+	if bus.ram[0x00] == taken {
+		t.Errorf("no IRQ taken while the APU still asserts the line")
+	}
+
+	// acknowledging the APU releases the line, the handler stops running
+	bus.CpuWrite(0x4017, 0x40)
+	bus.CpuRead(0x4015, false)
+	bus.RunCycles(20)
+	if bus.IRQLine() {
+		t.Fatalf("line still active with no source asserting it, sources = %03b", bus.irq)
+	}
+	taken = bus.ram[0x00]
+	bus.RunCycles(1000)
+	if bus.ram[0x00] != taken {
+		t.Errorf("%v IRQs taken with the line released", bus.ram[0x00]-taken)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package bus
+
+import (
+	"errors"
+	"image/png"
+	"io"
+	"mgnes/pkg/ines"
+)
+
+const (
+	// CyclesPerFrame is the number of CPU cycles in an NTSC frame
+	CyclesPerFrame = 29780
+
+	// the PPU is clocked 3 times per CPU cycle
+	clocksPerCycle = 3
+	clocksPerFrame = CyclesPerFrame * clocksPerCycle
+
+	// a PAL frame is 312 scanlines of 341 PPU clocks, the PPU is
+	// clocked 3.2 times per CPU cycle
+	clocksPerFramePAL = 312 * 341
+)
+
+// SetRegion sets the timing of the console: the PPU to CPU clock ratio,
+// the frame length and the scanlines per frame of the PPU. InsertCartridge
+// sets it from the cartridge header, call it afterwards to override it
+func (bus *Bus) SetRegion(region ines.TVSystemType) {
+	bus.region = region
+	if region == ines.TVSystemPAL {
+		bus.cpuTicks = 5
+		bus.ppuTicks = 16
+		bus.frameClocks = clocksPerFramePAL
+	} else {
+		bus.cpuTicks = 1
+		bus.ppuTicks = clocksPerCycle
+		bus.frameClocks = clocksPerFrame
+	}
+	if bus.ppu != nil {
+		bus.ppu.SetRegion(region)
+	}
+}
+
+// Region returns the region the console is timed for
+func (bus *Bus) Region() ines.TVSystemType {
+	return bus.region
+}
+
+// RunCycles clocks the whole system for n CPU cycles, cycles spent on a
+// DMA transfer count as well. It returns early when a breakpoint is hit
+func (bus *Bus) RunCycles(n uint64) {
+	// count the CPU clocks instead of converting n to PPU clocks, on PAL
+	// a cycle is 3.2 PPU clocks and the conversion would truncate
+	for i := uint64(0); i < n && !bus.paused; {
+		if bus.cpuClock() {
+			i++
+		}
+		bus.Clock()
+	}
+}
+
+// RunFrames clocks the whole system until n frame boundaries have been
+// crossed, a frame boundary is every CyclesPerFrame CPU cycles since the
+// last reset, or every 312 scanlines on PAL. When called in the middle of a frame, the current frame
+// counts as the first one. It returns early when a breakpoint is hit
+func (bus *Bus) RunFrames(n int) {
+	if n <= 0 {
+		return
+	}
+
+	target := (bus.systemClockCounter/bus.frameClocks + n) * bus.frameClocks
+	for bus.systemClockCounter < target && !bus.paused {
+		bus.Clock()
+	}
+}
+
+// Screenshot runs n frames headless and writes the picture of the PPU to w
+// as a PNG, for comparing the output against known good images
+func (bus *Bus) Screenshot(w io.Writer, frames int) error {
+	if bus.ppu == nil {
+		return errors.New("no PPU attached")
+	}
+
+	bus.RunFrames(frames)
+	return png.Encode(w, bus.ppu.GetScreen())
+}
@@ -0,0 +1,121 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package bus
+
+import (
+	"mgnes/pkg/ines"
+	"testing"
+)
+
+func TestRunCycles(t *testing.T) {
+	tests := []struct {
+		name   string
+		region ines.TVSystemType
+	}{
+		{"NTSC", ines.TVSystemNTSC},
+		{"PAL", ines.TVSystemPAL},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bus := newTestBus(t)
+			bus.SetRegion(test.region)
+			loopForever(t, bus)
+
+			start := bus.cpu.Snapshot().ClockCount
+			var total uint32
+			for _, n := range []uint64{1, 2, 3, 5, 7, 16, 1000} {
+				bus.RunCycles(n)
+				total += uint32(n)
+				if got := bus.cpu.Snapshot().ClockCount - start; got != total {
+					t.Fatalf("CPU clocked %v times, want %v", got, total)
+				}
+			}
+		})
+	}
+}
+
+func TestPALClocking(t *testing.T) {
+	bus := newTestBus(t)
+	loopForever(t, bus)
+	bus.SetRegion(ines.TVSystemPAL)
+
+	// the CPU runs 5 cycles every 16 PPU clocks, spread as evenly as
+	// possible: on PPU clocks 0, 4, 7, 10 and 13 of each group
+	want := [16]bool{0: true, 4: true, 7: true, 10: true, 13: true}
+	start := bus.cpu.Snapshot().ClockCount
+	scanline, cycle := bus.ppu.Scanline(), bus.ppu.Cycle()
+	for i := 0; i < clocksPerFramePAL; i++ {
+		before := bus.cpu.Snapshot().ClockCount
+		bus.Clock()
+		clocked := bus.cpu.Snapshot().ClockCount != before
+		if clocked != want[i%16] {
+			t.Fatalf("PPU clock %v: CPU clocked %v, want %v", i, clocked, want[i%16])
+		}
+	}
+
+	// 106392 PPU clocks are 6649 groups of 16 and 8 more clocks holding
+	// 3 CPU cycles
+	if got := bus.cpu.Snapshot().ClockCount - start; got != 6649*5+3 {
+		t.Errorf("CPU clocked %v times over a frame, want %v", got, 6649*5+3)
+	}
+	// a PAL frame is 312 scanlines, the PPU is back where it started
+	if bus.ppu.Scanline() != scanline || bus.ppu.Cycle() != cycle {
+		t.Errorf("PPU at scanline %v cycle %v after a frame, want %v %v",
+			bus.ppu.Scanline(), bus.ppu.Cycle(), scanline, cycle)
+	}
+}
+
+func TestRunCyclesDeterministic(t *testing.T) {
+	for _, region := range []ines.TVSystemType{ines.TVSystemNTSC, ines.TVSystemPAL} {
+		// one call and many small calls end on the same system clock
+		once := newTestBus(t)
+		once.SetRegion(region)
+		loopForever(t, once)
+		once.RunCycles(1000)
+
+		split := newTestBus(t)
+		split.SetRegion(region)
+		loopForever(t, split)
+		for i := 0; i < 1000; i++ {
+			split.RunCycles(1)
+		}
+
+		if once.systemClockCounter != split.systemClockCounter {
+			t.Errorf("region %v: system clock %v after one call, %v after 1000 calls",
+				region, once.systemClockCounter, split.systemClockCounter)
+		}
+		if once.cpu.Snapshot() != split.cpu.Snapshot() {
+			t.Errorf("region %v: CPU state differs", region)
+		}
+	}
+}
+
+func BenchmarkRunCycles(b *testing.B) {
+	bus := newTestBus(b)
+	loopForever(b, bus)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bus.RunCycles(CyclesPerFrame)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package bus
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+	"mgnes/pkg/memory"
+)
+
+// A save state starts with a 4 byte magic and a version byte, followed by
+// a gob stream of the bus, CPU, APU, both controllers, PPU and cartridge
+// state in that order
+const (
+	stateMagic   = "MGSS"
+	stateVersion = 2
+)
+
+// busState is the serializable part of the bus
+type busState struct {
+	RAM [memory.CpuMemoryCapacity]uint8
+
+	SystemClockCounter int
+
+	DMAPage     uint8
+	DMAAddr     uint8
+	DMAData     uint8
+	DMATransfer bool
+	DMADummy    bool
+
+	LastData uint8
+
+	IRQ IRQSource
+
+	// components attached when the state was saved
+	HasPPU  bool
+	HasCart bool
+}
+
+// Serialize writes a save state of the whole system to w. Audio samples
+// waiting for the host are not included
+func (bus *Bus) Serialize(w io.Writer) error {
+	if _, err := w.Write(append([]byte(stateMagic), stateVersion)); err != nil {
+		return err
+	}
+
+	state := busState{
+		SystemClockCounter: bus.systemClockCounter,
+		DMAPage:            bus.dmaPage,
+		DMAAddr:            bus.dmaAddr,
+		DMAData:            bus.dmaData,
+		DMATransfer:        bus.dmaTransfer,
+		DMADummy:           bus.dmaDummy,
+		LastData:           bus.lastData,
+		IRQ:                bus.irq,
+		HasPPU:             bus.ppu != nil,
+		HasCart:            bus.cart != nil,
+		RAM:                bus.ram,
+	}
+
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(state); err != nil {
+		return err
+	}
+	if err := bus.cpu.Serialize(enc); err != nil {
+		return err
+	}
+	if err := bus.apu.Serialize(enc); err != nil {
+		return err
+	}
+	for _, c := range bus.controllers {
+		if err := c.Serialize(enc); err != nil {
+			return err
+		}
+	}
+	if bus.ppu != nil {
+		if err := bus.ppu.Serialize(enc); err != nil {
+			return err
+		}
+	}
+	if bus.cart != nil {
+		if err := bus.cart.Serialize(enc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deserialize restores a save state written by Serialize. The same
+// cartridge must be inserted, the system is left in an undefined state if
+// an error is returned
+func (bus *Bus) Deserialize(r io.Reader) error {
+	header := make([]byte, len(stateMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err
+	}
+	if string(header[:len(stateMagic)]) != stateMagic {
+		return errors.New("not a save state")
+	}
+	if header[len(stateMagic)] != stateVersion {
+		return errors.New("unsupported save state version")
+	}
+
+	dec := gob.NewDecoder(r)
+	var state busState
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+	if state.HasPPU != (bus.ppu != nil) || state.HasCart != (bus.cart != nil) {
+		return errors.New("save state does not match the attached components")
+	}
+
+	if err := bus.cpu.Deserialize(dec); err != nil {
+		return err
+	}
+	if err := bus.apu.Deserialize(dec); err != nil {
+		return err
+	}
+	for _, c := range bus.controllers {
+		if err := c.Deserialize(dec); err != nil {
+			return err
+		}
+	}
+	if bus.ppu != nil {
+		if err := bus.ppu.Deserialize(dec); err != nil {
+			return err
+		}
+	}
+	if bus.cart != nil {
+		if err := bus.cart.Deserialize(dec); err != nil {
+			return err
+		}
+	}
+
+	bus.ram = state.RAM
+	bus.systemClockCounter = state.SystemClockCounter
+	bus.dmaPage = state.DMAPage
+	bus.dmaAddr = state.DMAAddr
+	bus.dmaData = state.DMAData
+	bus.dmaTransfer = state.DMATransfer
+	bus.dmaDummy = state.DMADummy
+	bus.lastData = state.LastData
+	bus.irq = state.IRQ
+	return nil
+}
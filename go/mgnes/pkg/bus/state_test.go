@@ -0,0 +1,104 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package bus
+
+import (
+	"bytes"
+	"testing"
+)
+
+// saveState serializes bus and fails the test on error
+func saveState(t *testing.T, bus *Bus) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := bus.Serialize(&buf); err != nil {
+		t.Fatalf("could not save state: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	const frames = 3
+
+	bus := newTestBus(t)
+	program := []byte{
+		0xA9, 0x0F, // LDA #$0F
+		0x8D, 0x15, 0x40, // STA $4015, enable the channels
+		0xA9, 0xBF, // LDA #$BF
+		0x8D, 0x00, 0x40, // STA $4000, pulse 1 duty and volume
+		0xA9, 0x08, // LDA #$08
+		0x8D, 0x03, 0x40, // STA $4003, pulse 1 length
+		// loop:
+		0xA9, 0x01, // LDA #$01
+		0x8D, 0x16, 0x40, // STA $4016
+		0xA9, 0x00, // LDA #$00
+		0x8D, 0x16, 0x40, // STA $4016, latch the buttons
+		0xAD, 0x16, 0x40, // LDA $4016
+		0x65, 0x00, // ADC $00
+		0x85, 0x00, // STA $00
+		0xE6, 0x01, // INC $01
+		0x4C, 0x0F, 0x80, // JMP loop
+	}
+	if err := bus.LoadProgram(0x8000, program, 0x8000); err != nil {
+		t.Fatal(err)
+	}
+	bus.Reset()
+	bus.SetButtons(0, 0x81)
+	bus.RunFrames(frames)
+
+	saved := saveState(t, bus)
+	bus.RunFrames(frames)
+	want := saveState(t, bus)
+	wantStatus := bus.CpuRead(0x4015, true)
+
+	// diverge from the saved state before restoring it
+	bus.SetButtons(0, 0x00)
+	bus.CpuWrite(0x4015, 0x00)
+	bus.RunFrames(frames)
+
+	if err := bus.Deserialize(bytes.NewReader(saved)); err != nil {
+		t.Fatalf("could not restore state: %v", err)
+	}
+	if got := bus.controllers[0].Buttons(); got != 0x81 {
+		t.Errorf("buttons = %#02x, want 0x81", got)
+	}
+	bus.RunFrames(frames)
+
+	if got := bus.CpuRead(0x4015, true); got != wantStatus {
+		t.Errorf("$4015 = %#02x, want %#02x", got, wantStatus)
+	}
+	if got := saveState(t, bus); !bytes.Equal(got, want) {
+		t.Errorf("state after %v frames differs from the first run", frames)
+	}
+}
+
+func TestStateBadHeader(t *testing.T) {
+	bus := newTestBus(t)
+	state := saveState(t, bus)
+
+	state[len(stateMagic)] = stateVersion + 1
+	if err := bus.Deserialize(bytes.NewReader(state)); err == nil {
+		t.Error("restored a state with an unknown version")
+	}
+	if err := bus.Deserialize(bytes.NewReader([]byte("XXXX\x02"))); err == nil {
+		t.Error("restored a state without the magic")
+	}
+}
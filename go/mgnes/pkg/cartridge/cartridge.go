@@ -21,23 +21,61 @@
 package cartridge
 
 import (
+	"fmt"
+	"io"
 	"mgnes/pkg/ines"
+	"mgnes/pkg/log"
 	"mgnes/pkg/mappers"
 )
 
+const (
+	// SRAMSize size of the work RAM mapped at 0x6000-0x7FFF
+	SRAMSize = 8 * 1024
+	// CHRRAMSize size of the pattern memory of cartridges without CHR ROM
+	CHRRAMSize = 8 * 1024
+	// TrainerSize size of the optional trainer stored before PRG ROM, it is
+	// loaded into work RAM at 0x7000-0x71FF
+	TrainerSize = 512
+	// trainerOffset offset of the trainer in work RAM
+	trainerOffset = 0x1000
+)
+
+// Mirror nametable mirroring mode, some mappers are able to switch
+// it at runtime so the definition lives in the mappers package
+type Mirror = mappers.Mirror
+
+const (
+	MirrorHorizontal  = mappers.MirrorHorizontal
+	MirrorVertical    = mappers.MirrorVertical
+	MirrorOneScreenLo = mappers.MirrorOneScreenLo
+	MirrorOneScreenHi = mappers.MirrorOneScreenHi
+)
+
 // Cartridge represents a NES cartridge from a software perspective
 type Cartridge struct {
-	Mirroring ines.MirroringDirection
+	Mirror Mirror
 
 	imageValid  bool
-	mapperId    uint8
-	numPRGBanks uint8
-	numCHRBanks uint8
+	mapperId    uint16
+	numPRGBanks uint16
+	numCHRBanks uint16
+	region      ines.TVSystemType
 
 	memPRG []uint8
 	memCHR []uint8
+	chrRAM bool
+
+	// trainer data, nil if the image has none
+	trainer []uint8
+
+	// work RAM, persisted when battery backed
+	sram    [SRAMSize]uint8
+	battery bool
 
 	mapper mappers.Mapper
+
+	// set once an out of range mapped address has been logged
+	badAccessLogged bool
 }
 
 func (cart *Cartridge) IsImageValid() bool {
@@ -47,23 +85,52 @@ func (cart *Cartridge) IsImageValid() bool {
 func (cart *Cartridge) CpuRead(addr uint16) (data uint8, flag bool) {
 	var mappedAddr uint32
 	if mappedAddr, flag = cart.mapper.CpuMapRead(addr); flag {
-		data = cart.memPRG[mappedAddr]
+		if mappedAddr == mappers.MappedAddrInternal {
+			// handled by the mapper
+		} else if mappedAddr < uint32(len(cart.memPRG)) {
+			data = cart.memPRG[mappedAddr]
+		} else {
+			// nothing drives the bus, leave it to open bus
+			cart.badAccess("PRG read", addr, mappedAddr)
+			flag = false
+		}
+	} else if addr >= 0x6000 && addr <= 0x7FFF {
+		data = cart.sram[addr&0x1FFF]
+		flag = true
 	}
 	return
 }
 
 func (cart *Cartridge) CpuWrite(addr uint16, data uint8) (flag bool) {
 	var mappedAddr uint32
-	if mappedAddr, flag = cart.mapper.CpuMapWrite(addr); flag {
-		cart.memPRG[mappedAddr] = data
+	if mappedAddr, flag = cart.mapper.CpuMapWrite(addr, data); flag {
+		if mappedAddr == mappers.MappedAddrInternal {
+			// handled by the mapper
+		} else if mappedAddr < uint32(len(cart.memPRG)) {
+			cart.memPRG[mappedAddr] = data
+		} else {
+			cart.badAccess("PRG write", addr, mappedAddr)
+		}
+	} else if addr >= 0x6000 && addr <= 0x7FFF {
+		cart.sram[addr&0x1FFF] = data
+		flag = true
 	}
+
+	// the write may have hit a mapper register which changes mirroring
+	cart.updateMirror()
 	return
 }
 
 func (cart *Cartridge) PpuRead(addr uint16) (data uint8, flag bool) {
 	var mappedAddr uint32
 	if mappedAddr, flag = cart.mapper.PpuMapRead(addr); flag {
-		data = cart.memCHR[mappedAddr]
+		if mappedAddr < uint32(len(cart.memCHR)) {
+			data = cart.memCHR[mappedAddr]
+		} else {
+			// like PRG reads, leave it to the PPU
+			cart.badAccess("CHR read", addr, mappedAddr)
+			flag = false
+		}
 	}
 	return
 }
@@ -71,7 +138,169 @@ func (cart *Cartridge) PpuRead(addr uint16) (data uint8, flag bool) {
 func (cart *Cartridge) PpuWrite(addr uint16, data uint8) (flag bool) {
 	var mappedAddr uint32
 	if mappedAddr, flag = cart.mapper.PpuMapWrite(addr); flag {
-		cart.memCHR[mappedAddr] = data
+		if mappedAddr < uint32(len(cart.memCHR)) {
+			cart.memCHR[mappedAddr] = data
+		} else {
+			cart.badAccess("CHR write", addr, mappedAddr)
+		}
+	}
+	return
+}
+
+// Metadata describes a loaded cartridge
+type Metadata struct {
+	MapperID   uint16
+	MapperName string
+	PRGBanks   uint16 // 16KB units
+	CHRBanks   uint16 // 8KB units, 0 means CHR RAM
+	Mirror     Mirror
+	Region     ines.TVSystemType
+	Battery    bool
+}
+
+// Metadata returns the description of the cartridge
+func (cart *Cartridge) Metadata() Metadata {
+	return Metadata{
+		MapperID:   cart.MapperID(),
+		MapperName: cart.MapperName(),
+		PRGBanks:   cart.PRGBanks(),
+		CHRBanks:   cart.CHRBanks(),
+		Mirror:     cart.Mirror,
+		Region:     cart.Region(),
+		Battery:    cart.Battery(),
+	}
+}
+
+// MapperID returns the iNES mapper number, 12 bits wide for NES 2.0 images
+func (cart *Cartridge) MapperID() uint16 {
+	return cart.mapperId
+}
+
+// MapperName returns the name of the mapper, "Unknown" if it is not known
+func (cart *Cartridge) MapperName() string {
+	return ines.Magic2Mapper(int(cart.mapperId))
+}
+
+// PRGBanks returns the number of 16KB PRG ROM banks
+func (cart *Cartridge) PRGBanks() uint16 {
+	return cart.numPRGBanks
+}
+
+// CHRBanks returns the number of 8KB CHR ROM banks, 0 for CHR RAM
+func (cart *Cartridge) CHRBanks() uint16 {
+	return cart.numCHRBanks
+}
+
+// Region returns the TV system the cartridge was made for
+func (cart *Cartridge) Region() ines.TVSystemType {
+	return cart.region
+}
+
+// badAccess logs the first access the mapper maps outside of PRG or CHR
+// memory, a broken ROM would flood the log otherwise
+func (cart *Cartridge) badAccess(kind string, addr uint16, mappedAddr uint32) {
+	if cart.badAccessLogged {
+		return
+	}
+	cart.badAccessLogged = true
+	log.L(fmt.Sprintf("cartridge: %v at 0x%04X mapped out of range to 0x%X", kind, addr, mappedAddr))
+}
+
+// PeekPRG reads PRG memory at offset, bypassing the mapper. flag is false if
+// offset is outside of PRG memory
+func (cart *Cartridge) PeekPRG(offset uint32) (data uint8, flag bool) {
+	if offset < uint32(len(cart.memPRG)) {
+		data, flag = cart.memPRG[offset], true
+	}
+	return
+}
+
+// PokePRG writes PRG memory at offset, bypassing the mapper, ROM included.
+// It returns false if offset is outside of PRG memory
+func (cart *Cartridge) PokePRG(offset uint32, data uint8) (flag bool) {
+	if offset < uint32(len(cart.memPRG)) {
+		cart.memPRG[offset], flag = data, true
+	}
+	return
+}
+
+// PeekCHR reads CHR memory at offset, bypassing the mapper. flag is false if
+// offset is outside of CHR memory
+func (cart *Cartridge) PeekCHR(offset uint32) (data uint8, flag bool) {
+	if offset < uint32(len(cart.memCHR)) {
+		data, flag = cart.memCHR[offset], true
+	}
+	return
+}
+
+// PokeCHR writes CHR memory at offset, bypassing the mapper, ROM included.
+// It returns false if offset is outside of CHR memory
+func (cart *Cartridge) PokeCHR(offset uint32, data uint8) (flag bool) {
+	if offset < uint32(len(cart.memCHR)) {
+		cart.memCHR[offset], flag = data, true
+	}
+	return
+}
+
+// CHRRAM returns true if the pattern memory is RAM instead of ROM
+func (cart *Cartridge) CHRRAM() bool {
+	return cart.chrRAM
+}
+
+// Trainer returns the 512 byte trainer of the image, nil if it has none
+func (cart *Cartridge) Trainer() []byte {
+	return cart.trainer
+}
+
+// Battery returns true if the work RAM is battery backed
+func (cart *Cartridge) Battery() bool {
+	return cart.battery
+}
+
+// SaveRAM writes the content of battery backed work RAM to w, nothing is
+// written if the cartridge has no battery
+func (cart *Cartridge) SaveRAM(w io.Writer) (err error) {
+	if !cart.battery {
+		return
 	}
+	_, err = w.Write(cart.sram[:])
 	return
 }
+
+// LoadRAM restores the content of battery backed work RAM from r, nothing
+// is read if the cartridge has no battery
+func (cart *Cartridge) LoadRAM(r io.Reader) (err error) {
+	if !cart.battery {
+		return
+	}
+	_, err = io.ReadFull(r, cart.sram[:])
+	return
+}
+
+// IRQState returns true while the mapper asserts an interrupt, always
+// false for mappers without interrupt support
+func (cart *Cartridge) IRQState() bool {
+	if source, ok := cart.mapper.(mappers.IRQSource); ok {
+		return source.IRQState()
+	}
+	return false
+}
+
+// ClearIRQ acknowledges a pending mapper interrupt
+func (cart *Cartridge) ClearIRQ() {
+	if source, ok := cart.mapper.(mappers.IRQSource); ok {
+		source.ClearIRQ()
+	}
+}
+
+// Reset resets the mapper on the cartridge, the ROM data is untouched
+func (cart *Cartridge) Reset() {
+	cart.mapper.Reset()
+	cart.updateMirror()
+}
+
+func (cart *Cartridge) updateMirror() {
+	if mirror, flag := cart.mapper.Mirror(); flag {
+		cart.Mirror = mirror
+	}
+}
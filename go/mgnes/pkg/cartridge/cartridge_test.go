@@ -0,0 +1,124 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cartridge
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+// outOfRangeMapper maps every access past the end of PRG and CHR memory
+type outOfRangeMapper struct{}
+
+const outOfRange = 0x100000
+
+func (outOfRangeMapper) CpuMapRead(addr uint16) (uint32, bool) { return outOfRange, addr >= 0x8000 }
+func (outOfRangeMapper) CpuMapWrite(addr uint16, data uint8) (uint32, bool) {
+	return outOfRange, addr >= 0x8000
+}
+func (outOfRangeMapper) PpuMapRead(addr uint16) (uint32, bool)  { return outOfRange, addr <= 0x1FFF }
+func (outOfRangeMapper) PpuMapWrite(addr uint16) (uint32, bool) { return outOfRange, addr <= 0x1FFF }
+func (outOfRangeMapper) Mirror() (Mirror, bool)                 { return MirrorHorizontal, false }
+func (outOfRangeMapper) Reset()                                 {}
+func (outOfRangeMapper) Serialize(enc *gob.Encoder) error       { return nil }
+func (outOfRangeMapper) Deserialize(dec *gob.Decoder) error     { return nil }
+
+func TestOutOfRangeMapping(t *testing.T) {
+	cart := &Cartridge{
+		memPRG: make([]uint8, 0x4000),
+		memCHR: make([]uint8, 0x2000),
+		mapper: outOfRangeMapper{},
+	}
+	for i := range cart.memPRG {
+		cart.memPRG[i] = 0xEA
+	}
+	for i := range cart.memCHR {
+		cart.memCHR[i] = 0xEA
+	}
+
+	if data, flag := cart.CpuRead(0x8000); flag || data != 0 {
+		t.Errorf("CpuRead() = %#02x, %v, want unmapped", data, flag)
+	}
+	if data, flag := cart.PpuRead(0x0000); flag || data != 0 {
+		t.Errorf("PpuRead() = %#02x, %v, want unmapped", data, flag)
+	}
+	cart.CpuWrite(0x8000, 0x00)
+	cart.PpuWrite(0x0000, 0x00)
+	if !cart.badAccessLogged {
+		t.Errorf("out of range access not logged")
+	}
+
+	// work RAM is still reachable
+	cart.CpuWrite(0x6000, 0x42)
+	if data, flag := cart.CpuRead(0x6000); !flag || data != 0x42 {
+		t.Errorf("CpuRead(0x6000) = %#02x, %v, want 0x42, true", data, flag)
+	}
+}
+
+func TestBatteryRAM(t *testing.T) {
+	load := func(flag6 uint8) *Cartridge {
+		image := make([]byte, 16+0x4000+0x2000)
+		copy(image, []byte{'N', 'E', 'S', 0x1A, 1, 1, flag6})
+		cart, err := Load(bytes.NewReader(image))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cart
+	}
+
+	cart := load(0x02)
+	for addr := uint16(0x6000); addr <= 0x7FFF; addr++ {
+		cart.CpuWrite(addr, uint8(addr^addr>>8))
+	}
+	var buf bytes.Buffer
+	if err := cart.SaveRAM(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != SRAMSize {
+		t.Fatalf("SaveRAM wrote %v bytes, want %v", buf.Len(), SRAMSize)
+	}
+
+	restored := load(0x02)
+	if err := restored.LoadRAM(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	for addr := uint16(0x6000); addr <= 0x7FFF; addr++ {
+		if data, _ := restored.CpuRead(addr); data != uint8(addr^addr>>8) {
+			t.Fatalf("CpuRead(%#04x) = %#02x after LoadRAM, want %#02x", addr, data, uint8(addr^addr>>8))
+		}
+	}
+
+	// a short save is an error
+	if err := restored.LoadRAM(bytes.NewReader(buf.Bytes()[:100])); err == nil {
+		t.Errorf("LoadRAM of 100 bytes succeeded")
+	}
+
+	// nothing is saved or loaded without a battery
+	plain := load(0x00)
+	buf.Reset()
+	if err := plain.SaveRAM(&buf); err != nil || buf.Len() != 0 {
+		t.Errorf("SaveRAM without battery wrote %v bytes, error %v", buf.Len(), err)
+	}
+	if err := plain.LoadRAM(bytes.NewReader(nil)); err != nil {
+		t.Errorf("LoadRAM without battery error = %v", err)
+	}
+}
@@ -22,8 +22,8 @@ package cartridge
 
 import (
 	"errors"
+	"fmt"
 	"io"
-	"io/ioutil"
 	"mgnes/pkg/ines"
 	"mgnes/pkg/mappers"
 )
@@ -36,26 +36,30 @@ func Load(reader io.Reader) (cart *Cartridge, err error) {
 	}
 
 	var header *ines.Header
-	header, err = ines.NewHeader(reader)
+	header, err = ines.NewHeader(reader, ines.MaskCorruptMapper)
 	if header == nil {
-		err = errors.New("invalid iNES header")
+		err = fmt.Errorf("invalid iNES header: %w", err)
 		return
 	}
 
+	var trainer []uint8
 	if header.Trainer() {
-		var discarded int64
-		discarded, err = io.CopyN(ioutil.Discard, reader, 512)
-		if discarded != 512 {
+		trainer = make([]uint8, TrainerSize)
+		if _, err = io.ReadFull(reader, trainer); err != nil {
 			err = errors.New("invalid iNES header with trainer flag set")
 			return
 		}
-		if err != nil {
-			return
-		}
 	}
 
 	memPRG := make([]uint8, header.PRGROMSize())
-	memCHR := make([]uint8, header.CHRROMSize())
+	// cartridges without CHR ROM have 8KB of CHR RAM instead
+	chrRAM := header.CHRROMSize() == 0
+	var memCHR []uint8
+	if chrRAM {
+		memCHR = make([]uint8, CHRRAMSize)
+	} else {
+		memCHR = make([]uint8, header.CHRROMSize())
+	}
 
 	n := 0
 	n, err = reader.Read(memPRG)
@@ -67,25 +71,50 @@ func Load(reader io.Reader) (cart *Cartridge, err error) {
 		return
 	}
 
-	n, err = reader.Read(memCHR)
-	if n != header.CHRROMSize() {
-		err = errors.New("invalid CHR data")
-		return
+	if !chrRAM {
+		n, err = reader.Read(memCHR)
+		if n != header.CHRROMSize() {
+			err = errors.New("invalid CHR data")
+			return
+		}
+		if err != nil {
+			return
+		}
 	}
-	if err != nil {
+
+	var mapper mappers.Mapper
+	if mapper, err = mappers.Create(header); err != nil {
 		return
 	}
 
+	// flag 9 is the official TV system bit, flag 10 is unofficial but
+	// set by some dumps instead
+	region := header.TVSystem()
+	if header.TVCompatible() == ines.TVCompatiblePAL {
+		region = ines.TVSystemPAL
+	}
+
+	mirror := MirrorHorizontal
+	if header.Mirroring() == ines.MirroringVertical {
+		mirror = MirrorVertical
+	}
+
 	cart = &Cartridge{
-		Mirroring:   header.Mirroring(),
+		Mirror:      mirror,
 		imageValid:  true,
-		mapperId:    header.Mapper(),
-		numPRGBanks: header.PRG,
-		numCHRBanks: header.CHR,
+		mapperId:    header.MapperExtended(),
+		numPRGBanks: uint16(header.PRGBanks()),
+		numCHRBanks: uint16(header.CHRBanks()),
+		region:      region,
 		memPRG:      memPRG,
 		memCHR:      memCHR,
-		mapper:      mappers.Create(header),
+		chrRAM:      chrRAM,
+		trainer:     trainer,
+		mapper:      mapper,
+		battery:     header.PersistentSRAM(),
 	}
+	// the trainer is expected at 0x7000 when the program starts
+	copy(cart.sram[trainerOffset:], trainer)
 
 	return
 }
@@ -0,0 +1,182 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cartridge
+
+import (
+	"bytes"
+	"mgnes/pkg/ines"
+	"strings"
+	"testing"
+)
+
+// nes20Image returns a NES 2.0 image with the given header bytes 4-9 and
+// zeroed ROM data of the size they describe
+func nes20Image(prg, chr, flag6, flag7, byte8, byte9 uint8, size int) []byte {
+	image := make([]byte, 16+size)
+	copy(image, []byte{'N', 'E', 'S', 0x1A, prg, chr, flag6, flag7 | 0x08, byte8, byte9})
+	return image
+}
+
+func TestLoadNES20(t *testing.T) {
+	tests := []struct {
+		name    string
+		image   []byte
+		mapper  uint16
+		prg     uint16
+		chr     uint16
+		wantErr string
+	}{
+		{"NROM", nes20Image(2, 1, 0x00, 0x00, 0x00, 0x00, 0x8000+0x2000), 0, 2, 1, ""},
+		{"MMC3", nes20Image(32, 32, 0x40, 0x00, 0x00, 0x00, 0x80000+0x40000), 4, 32, 32, ""},
+		// 0x104 is not MMC3, the mapper bits of byte 8 are not ignored
+		{"extended mapper", nes20Image(2, 1, 0x40, 0x00, 0x01, 0x00, 0x8000+0x2000), 0, 0, 0, "unsupported mapper 260"},
+		// 256 CHR banks through the MSB nibble of byte 9
+		{"extended CHR banks", nes20Image(2, 0, 0x00, 0x00, 0x00, 0x10, 0x8000+0x200000), 0, 0, 0, "does not support"},
+		{"PRG size overflow", nes20Image(0xFC, 0, 0x00, 0x00, 0x00, 0x0F, 0), 0, 0, 0, "out of range"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cart, err := Load(bytes.NewReader(test.image))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cart.MapperID() != test.mapper {
+				t.Errorf("MapperID() = %v, want %v", cart.MapperID(), test.mapper)
+			}
+			if cart.PRGBanks() != test.prg || cart.CHRBanks() != test.chr {
+				t.Errorf("banks = %v PRG %v CHR, want %v PRG %v CHR",
+					cart.PRGBanks(), cart.CHRBanks(), test.prg, test.chr)
+			}
+		})
+	}
+}
+
+func TestLoadCorruptMapper(t *testing.T) {
+	// 'D' of "DiskDude!" in byte 7 would make MMC1 the unsupported 0x41
+	image := make([]byte, 16+0x8000+0x2000)
+	copy(image, []byte{'N', 'E', 'S', 0x1A, 2, 1, 0x10, 'D'})
+
+	cart, err := Load(bytes.NewReader(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cart.MapperID() != 1 {
+		t.Errorf("MapperID() = %#x, want 1", cart.MapperID())
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		size   int
+		want   Metadata
+		chrRAM bool
+	}{
+		{"NROM", []byte{'N', 'E', 'S', 0x1A, 2, 1, 0x00, 0x00}, 0x8000 + 0x2000,
+			Metadata{0, "No Mapper", 2, 1, MirrorHorizontal, ines.TVSystemNTSC, false}, false},
+		// mapper 1 in the high nibble of flag 6, battery and vertical
+		// mirroring in the low one, PAL in flag 9
+		{"MMC1 battery PAL", []byte{'N', 'E', 'S', 0x1A, 8, 0, 0x13, 0x00, 0x00, 0x01}, 0x20000,
+			Metadata{1, "MMC1", 8, 0, MirrorVertical, ines.TVSystemPAL, true}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			image := make([]byte, 16+test.size)
+			copy(image, test.header)
+			cart, err := Load(bytes.NewReader(image))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := cart.Metadata(); got != test.want {
+				t.Errorf("Metadata() = %+v, want %+v", got, test.want)
+			}
+			if cart.MapperID() != test.want.MapperID || cart.MapperName() != test.want.MapperName {
+				t.Errorf("mapper = %v %q, want %v %q", cart.MapperID(), cart.MapperName(),
+					test.want.MapperID, test.want.MapperName)
+			}
+			if cart.PRGBanks() != test.want.PRGBanks || cart.CHRBanks() != test.want.CHRBanks {
+				t.Errorf("banks = %v PRG %v CHR, want %v PRG %v CHR",
+					cart.PRGBanks(), cart.CHRBanks(), test.want.PRGBanks, test.want.CHRBanks)
+			}
+			if cart.Region() != test.want.Region || cart.Battery() != test.want.Battery {
+				t.Errorf("region %v battery %v, want %v and %v",
+					cart.Region(), cart.Battery(), test.want.Region, test.want.Battery)
+			}
+			if cart.CHRRAM() != test.chrRAM {
+				t.Errorf("CHRRAM() = %v, want %v", cart.CHRRAM(), test.chrRAM)
+			}
+			if cart.Trainer() != nil {
+				t.Errorf("Trainer() = %v bytes, want nil", len(cart.Trainer()))
+			}
+		})
+	}
+}
+
+func TestLoadTrainer(t *testing.T) {
+	trainer := make([]byte, TrainerSize)
+	for i := range trainer {
+		trainer[i] = uint8(i*7 + 1)
+	}
+	image := []byte{'N', 'E', 'S', 0x1A, 1, 1, 0x04, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	image = append(image, trainer...)
+	prg := make([]byte, 0x4000+0x2000)
+	prg[0] = 0xEA
+	image = append(image, prg...)
+
+	cart, err := Load(bytes.NewReader(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cart.Trainer(), trainer) {
+		t.Errorf("Trainer() differs from the trainer of the image")
+	}
+
+	// loaded into work RAM at 0x7000-0x71FF and nowhere else
+	for addr := uint16(0x6FFF); addr <= 0x7200; addr++ {
+		want := uint8(0)
+		if addr >= 0x7000 && addr < 0x7200 {
+			want = trainer[addr-0x7000]
+		}
+		if data, _ := cart.CpuRead(addr); data != want {
+			t.Fatalf("CpuRead(%#04x) = %#02x, want %#02x", addr, data, want)
+		}
+	}
+
+	// PRG ROM starts after the trainer
+	if data, _ := cart.CpuRead(0x8000); data != 0xEA {
+		t.Errorf("CpuRead(0x8000) = %#02x, want 0xEA", data)
+	}
+
+	// a truncated trainer fails to load
+	if _, err := Load(bytes.NewReader(image[:16+100])); err == nil {
+		t.Errorf("Load() of a truncated trainer succeeded")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cartridge
+
+import (
+	"encoding/gob"
+	"errors"
+)
+
+// cartridgeState is the serializable part of the cartridge, ROM data is
+// not included so a state can only be restored into the same cartridge
+type cartridgeState struct {
+	MapperId    uint16
+	NumPRGBanks uint16
+	NumCHRBanks uint16
+
+	Mirror Mirror
+	SRAM   [SRAMSize]uint8
+	CHRRAM []uint8
+}
+
+// Serialize writes work RAM, CHR RAM and mapper registers for a save state
+func (cart *Cartridge) Serialize(enc *gob.Encoder) error {
+	state := cartridgeState{
+		MapperId:    cart.mapperId,
+		NumPRGBanks: cart.numPRGBanks,
+		NumCHRBanks: cart.numCHRBanks,
+		Mirror:      cart.Mirror,
+		SRAM:        cart.sram,
+	}
+	if cart.chrRAM {
+		state.CHRRAM = cart.memCHR
+	}
+
+	if err := enc.Encode(state); err != nil {
+		return err
+	}
+	return cart.mapper.Serialize(enc)
+}
+
+// Deserialize restores the state written by Serialize
+func (cart *Cartridge) Deserialize(dec *gob.Decoder) error {
+	var state cartridgeState
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	if state.MapperId != cart.mapperId ||
+		state.NumPRGBanks != cart.numPRGBanks ||
+		state.NumCHRBanks != cart.numCHRBanks {
+		return errors.New("save state belongs to a different cartridge")
+	}
+	if cart.chrRAM && len(state.CHRRAM) != len(cart.memCHR) {
+		return errors.New("invalid CHR RAM in save state")
+	}
+
+	if err := cart.mapper.Deserialize(dec); err != nil {
+		return err
+	}
+
+	cart.Mirror = state.Mirror
+	cart.sram = state.SRAM
+	if cart.chrRAM {
+		copy(cart.memCHR, state.CHRRAM)
+	}
+	return nil
+}
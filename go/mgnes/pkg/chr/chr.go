@@ -0,0 +1,52 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package chr
+
+// TileSize is the size in bytes of an 8x8 tile, two 8 bytes bit planes
+const TileSize = 16
+
+// DecodeTile returns the 64 pixels of a tile as 2-bit values, row by row
+// from the top left. plane0 holds the least significant bits and plane1 the
+// most significant ones, the most significant bit of a byte is the leftmost
+// pixel of its row
+func DecodeTile(plane0, plane1 [8]byte) (pixels [64]uint8) {
+	for y := 0; y < 8; y++ {
+		lsb, msb := plane0[y], plane1[y]
+		for x := 0; x < 8; x++ {
+			bit := uint(7 - x)
+			pixels[y*8+x] = (msb>>bit&0x01)<<1 | lsb>>bit&0x01
+		}
+	}
+	return
+}
+
+// DecodeBank decodes all tiles of data as laid out in CHR ROM, an incomplete
+// trailing tile is ignored
+func DecodeBank(data []byte) [][64]uint8 {
+	tiles := make([][64]uint8, len(data)/TileSize)
+	for i := range tiles {
+		var plane0, plane1 [8]byte
+		copy(plane0[:], data[i*TileSize:])
+		copy(plane1[:], data[i*TileSize+8:])
+		tiles[i] = DecodeTile(plane0, plane1)
+	}
+	return tiles
+}
@@ -0,0 +1,113 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package chr
+
+import "testing"
+
+// the "1/2" tile from the nesdev wiki, worked out by hand:
+//
+//	plane 0  plane 1  pixels
+//	0x41     0x01     .1.....3
+//	0xC2     0x02     11....3.
+//	0x44     0x04     .1...3..
+//	0x48     0x08     .1..3...
+//	0x10     0x16     ...3.22.
+//	0x20     0x21     ..3....2
+//	0x40     0x42     .3....2.
+//	0x80     0x87     3....222
+var (
+	halfPlane0 = [8]byte{0x41, 0xC2, 0x44, 0x48, 0x10, 0x20, 0x40, 0x80}
+	halfPlane1 = [8]byte{0x01, 0x02, 0x04, 0x08, 0x16, 0x21, 0x42, 0x87}
+	halfPixels = [64]uint8{
+		0, 1, 0, 0, 0, 0, 0, 3,
+		1, 1, 0, 0, 0, 0, 3, 0,
+		0, 1, 0, 0, 0, 3, 0, 0,
+		0, 1, 0, 0, 3, 0, 0, 0,
+		0, 0, 0, 3, 0, 2, 2, 0,
+		0, 0, 3, 0, 0, 0, 0, 2,
+		0, 3, 0, 0, 0, 0, 2, 0,
+		3, 0, 0, 0, 0, 2, 2, 2,
+	}
+)
+
+func TestDecodeTile(t *testing.T) {
+	tests := []struct {
+		name           string
+		plane0, plane1 [8]byte
+		// pixel index and value, all other pixels are 0
+		index int
+		value uint8
+	}{
+		// the most significant bit is the leftmost pixel
+		{"bit 7 of plane 0", [8]byte{0x80}, [8]byte{}, 0, 1},
+		{"bit 0 of plane 0", [8]byte{0x01}, [8]byte{}, 7, 1},
+		{"bit 7 of plane 1", [8]byte{}, [8]byte{0x80}, 0, 2},
+		{"bit 0 of plane 1", [8]byte{}, [8]byte{0x01}, 7, 2},
+		{"both planes", [8]byte{0x10}, [8]byte{0x10}, 3, 3},
+		{"last row", [8]byte{7: 0x02}, [8]byte{}, 62, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pixels := DecodeTile(test.plane0, test.plane1)
+			for i, got := range pixels {
+				want := uint8(0)
+				if i == test.index {
+					want = test.value
+				}
+				if got != want {
+					t.Errorf("pixel (%v, %v) = %v, want %v", i%8, i/8, got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("worked example", func(t *testing.T) {
+		if got := DecodeTile(halfPlane0, halfPlane1); got != halfPixels {
+			t.Errorf("DecodeTile = %v, want %v", got, halfPixels)
+		}
+	})
+}
+
+func TestDecodeBank(t *testing.T) {
+	// an empty tile, the worked example and half a tile which is ignored
+	data := make([]byte, 2*TileSize+TileSize/2)
+	copy(data[TileSize:], halfPlane0[:])
+	copy(data[TileSize+8:], halfPlane1[:])
+	for i := 2 * TileSize; i < len(data); i++ {
+		data[i] = 0xFF
+	}
+
+	tiles := DecodeBank(data)
+	if len(tiles) != 2 {
+		t.Fatalf("%v tiles decoded, want 2", len(tiles))
+	}
+	if tiles[0] != [64]uint8{} {
+		t.Errorf("tile 0 = %v, want all 0", tiles[0])
+	}
+	if tiles[1] != halfPixels {
+		t.Errorf("tile 1 = %v, want %v", tiles[1], halfPixels)
+	}
+
+	if tiles := DecodeBank(nil); len(tiles) != 0 {
+		t.Errorf("DecodeBank(nil) returned %v tiles", len(tiles))
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package controller
+
+const (
+	// Buttons, the first bit shifted out is the most significant one
+	ButtonA      uint8 = 0x80
+	ButtonB      uint8 = 0x40
+	ButtonSelect uint8 = 0x20
+	ButtonStart  uint8 = 0x10
+	ButtonUp     uint8 = 0x08
+	ButtonDown   uint8 = 0x04
+	ButtonLeft   uint8 = 0x02
+	ButtonRight  uint8 = 0x01
+)
+
+// Controller emulates a standard NES joypad
+// The joypad is a parallel-in serial-out shift register. While the strobe
+// bit written to 0x4016 is high, the register keeps reloading the live
+// button state. Once strobe goes low, each read from 0x4016/0x4017 returns
+// the next button, starting from A.
+type Controller struct {
+	buttons uint8
+	shifter uint8
+	strobe  bool
+}
+
+// NewController creates and returns a controller reference
+func NewController() *Controller {
+	return &Controller{}
+}
+
+// SetButtons sets the live button state, use the Button* bit masks
+func (c *Controller) SetButtons(state uint8) {
+	c.buttons = state
+	if c.strobe {
+		c.shifter = c.buttons
+	}
+}
+
+// Buttons returns the live button state
+func (c *Controller) Buttons() uint8 {
+	return c.buttons
+}
+
+// Write sets the strobe bit, bit 0 of data
+func (c *Controller) Write(data uint8) {
+	c.strobe = data&0x01 != 0
+	if c.strobe {
+		c.shifter = c.buttons
+	}
+}
+
+// Read shifts out the next button state in bit 0. Reads with readonly set
+// return the next bit without shifting.
+func (c *Controller) Read(readonly bool) (data uint8) {
+	if c.strobe {
+		c.shifter = c.buttons
+	}
+
+	if c.shifter&0x80 != 0 {
+		data = 0x01
+	}
+
+	if !readonly && !c.strobe {
+		// official controllers report 1 after all 8 buttons are read
+		c.shifter = c.shifter<<1 | 0x01
+	}
+	return
+}
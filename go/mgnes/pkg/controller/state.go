@@ -0,0 +1,48 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package controller
+
+import "encoding/gob"
+
+// controllerState is the serializable part of a controller
+type controllerState struct {
+	Buttons uint8
+	Shifter uint8
+	Strobe  bool
+}
+
+// Serialize writes the button state and the shift register for a save state
+func (c *Controller) Serialize(enc *gob.Encoder) error {
+	return enc.Encode(controllerState{c.buttons, c.shifter, c.strobe})
+}
+
+// Deserialize restores the state written by Serialize
+func (c *Controller) Deserialize(dec *gob.Decoder) error {
+	var state controllerState
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	c.buttons = state.Buttons
+	c.shifter = state.Shifter
+	c.strobe = state.Strobe
+	return nil
+}
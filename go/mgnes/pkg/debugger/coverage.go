@@ -0,0 +1,91 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package debugger
+
+import (
+	"mgnes/pkg/bus"
+	"mgnes/pkg/mg6502"
+)
+
+// Coverage flags of an address, as stored in the bitmap of a CoverageMap
+const (
+	CoverCode  uint8 = 1 << iota // fetched as an opcode
+	CoverRead                    // read as data, operand fetches included
+	CoverWrite                   // written
+)
+
+// CoverageMap records which addresses of the CPU address space were
+// executed, read or written. Addresses never covered after a long run are
+// good candidates for unused code and data when reverse engineering a ROM
+type CoverageMap struct {
+	flags [0x10000]uint8
+}
+
+// NewCoverageMap creates and returns an empty coverage map
+func NewCoverageMap() *CoverageMap {
+	return &CoverageMap{}
+}
+
+// Attach starts recording the accesses of cpu on b. It replaces the read
+// and write hooks of the bus and the retirement hook of the CPU
+func (c *CoverageMap) Attach(b *bus.Bus, cpu *mg6502.MG6502) {
+	b.SetReadHook(c.OnRead)
+	b.SetWriteHook(c.OnWrite)
+	cpu.OnInstructionRetired = c.OnInstructionRetired
+}
+
+// OnRead is a bus read hook marking addr as read
+func (c *CoverageMap) OnRead(addr uint16, val uint8) {
+	c.flags[addr] |= CoverRead
+}
+
+// OnWrite is a bus write hook marking addr as written
+func (c *CoverageMap) OnWrite(addr uint16, val uint8) {
+	c.flags[addr] |= CoverWrite
+}
+
+// OnInstructionRetired is a CPU retirement hook marking pc as code
+func (c *CoverageMap) OnInstructionRetired(pc uint16, opcode uint8, cycles uint8) {
+	c.flags[pc] |= CoverCode
+}
+
+// Covered returns true if addr was executed, read or written
+func (c *CoverageMap) Covered(addr uint16) bool {
+	return c.flags[addr] != 0
+}
+
+// Flags returns the coverage flags of addr
+func (c *CoverageMap) Flags(addr uint16) uint8 {
+	return c.flags[addr]
+}
+
+// ExportBitmap returns a copy of the coverage flags, one byte per address
+// from 0x0000 to 0xFFFF
+func (c *CoverageMap) ExportBitmap() []byte {
+	bitmap := make([]byte, len(c.flags))
+	copy(bitmap, c.flags[:])
+	return bitmap
+}
+
+// Clear forgets everything recorded so far
+func (c *CoverageMap) Clear() {
+	c.flags = [0x10000]uint8{}
+}
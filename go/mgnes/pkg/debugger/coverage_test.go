@@ -0,0 +1,107 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package debugger
+
+import (
+	"bytes"
+	"mgnes/pkg/bus"
+	"mgnes/pkg/cartridge"
+	"mgnes/pkg/mg6502"
+	"testing"
+)
+
+func TestCoverageMap(t *testing.T) {
+	image := make([]byte, 16+0x4000+0x2000)
+	copy(image, []byte{'N', 'E', 'S', 0x1A, 1, 1})
+	cart, err := cartridge.Load(bytes.NewReader(image))
+	if err != nil {
+		t.Fatalf("could not load cartridge: %v", err)
+	}
+
+	cpu := mg6502.NewMG6502()
+	b := bus.NewBus(cpu)
+	if err := b.InsertCartridge(cart); err != nil {
+		t.Fatalf("could not insert cartridge: %v", err)
+	}
+
+	// copies page 3 to page 4, then loops forever
+	program := []byte{
+		0xA2, 0x00, // $8000 LDX #$00
+		0xBD, 0x00, 0x03, // $8002 LDA $0300,X
+		0x9D, 0x00, 0x04, // $8005 STA $0400,X
+		0xE8,       // $8008 INX
+		0xD0, 0xF7, // $8009 BNE $8002
+		0x4C, 0x0B, 0x80, // $800B JMP $800B
+	}
+	if err := b.LoadProgram(0x8000, program, 0x8000); err != nil {
+		t.Fatal(err)
+	}
+
+	coverage := NewCoverageMap()
+	coverage.Attach(b, cpu)
+	b.Reset()
+	b.RunCycles(10000)
+
+	for _, pc := range []uint16{0x8000, 0x8002, 0x8005, 0x8008, 0x8009, 0x800B} {
+		if coverage.Flags(pc)&CoverCode == 0 {
+			t.Errorf("opcode at %#04x not covered as code", pc)
+		}
+	}
+	for _, addr := range []uint16{0x8001, 0x8003, 0x8004, 0x800A, 0x800C} {
+		if flags := coverage.Flags(addr); flags&CoverRead == 0 || flags&CoverCode != 0 {
+			t.Errorf("operand at %#04x has flags %03b, want read only", addr, flags)
+		}
+	}
+	for addr := 0x0300; addr <= 0x03FF; addr++ {
+		if coverage.Flags(uint16(addr)) != CoverRead {
+			t.Fatalf("%#04x has flags %03b, want read", addr, coverage.Flags(uint16(addr)))
+		}
+	}
+	for addr := 0x0400; addr <= 0x04FF; addr++ {
+		if coverage.Flags(uint16(addr)) != CoverWrite {
+			t.Fatalf("%#04x has flags %03b, want written", addr, coverage.Flags(uint16(addr)))
+		}
+	}
+
+	// pages the program never touches
+	for _, page := range []uint16{0x0000, 0x0500, 0x0700, 0x6000, 0x9000, 0xC000} {
+		for addr := page; addr <= page|0x00FF; addr++ {
+			if coverage.Covered(addr) {
+				t.Fatalf("%#04x covered, the program does not touch page %#04x", addr, page)
+			}
+		}
+	}
+
+	bitmap := coverage.ExportBitmap()
+	if len(bitmap) != 0x10000 {
+		t.Fatalf("bitmap has %v bytes, want %v", len(bitmap), 0x10000)
+	}
+	for addr := range bitmap {
+		if bitmap[addr] != coverage.Flags(uint16(addr)) {
+			t.Fatalf("bitmap[%#04x] = %03b, want %03b", addr, bitmap[addr], coverage.Flags(uint16(addr)))
+		}
+	}
+
+	coverage.Clear()
+	if coverage.Covered(0x8000) {
+		t.Errorf("$8000 still covered after Clear")
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package debugger
+
+import (
+	"mgnes/pkg/mg6502"
+	"sync/atomic"
+)
+
+// opcode of JSR, the only instruction StepOver treats specially
+const opJSR = 0x20
+
+// System is what the debugger runs, Clock advances it by one tick and the
+// reader gives readonly access to the memory the CPU sees. *bus.Bus
+// implements it to run the whole console
+type System interface {
+	mg6502.Reader
+	Clock()
+}
+
+// pauser is implemented by systems with their own breakpoints, like the
+// memory breakpoints of *bus.Bus
+type pauser interface {
+	Paused() bool
+	Resume()
+}
+
+// Registers is a copy of the CPU registers
+type Registers struct {
+	A, X, Y, SP, FLAG uint8
+	PC                uint16
+}
+
+// Debugger runs the CPU one instruction at a time or until a breakpoint is
+// hit, it does not depend on any UI toolkit
+type Debugger struct {
+	system System
+	cpu    *mg6502.MG6502
+
+	breakpoints map[uint16]bool
+
+	// set by Stop from another goroutine
+	stop int32
+}
+
+// NewDebugger creates and returns a debugger reference, system is clocked
+// until cpu has executed the requested instructions
+func NewDebugger(system System, cpu *mg6502.MG6502) *Debugger {
+	return &Debugger{
+		system:      system,
+		cpu:         cpu,
+		breakpoints: make(map[uint16]bool),
+	}
+}
+
+// Registers returns the current CPU registers
+func (d *Debugger) Registers() Registers {
+	return Registers{
+		A:    d.cpu.A,
+		X:    d.cpu.X,
+		Y:    d.cpu.Y,
+		SP:   d.cpu.SP,
+		FLAG: d.cpu.FLAG,
+		PC:   d.cpu.PC,
+	}
+}
+
+// SetBreakpoint halts Continue and StepOver before the instruction at addr
+func (d *Debugger) SetBreakpoint(addr uint16) {
+	d.breakpoints[addr] = true
+}
+
+// ClearBreakpoint removes the breakpoint at addr
+func (d *Debugger) ClearBreakpoint(addr uint16) {
+	delete(d.breakpoints, addr)
+}
+
+// Step executes exactly one instruction, an instruction or interrupt
+// sequence still in flight is completed first
+func (d *Debugger) Step() {
+	d.finish()
+
+	// the CPU does all the work of an instruction on its first cycle
+	start := d.cpu.Snapshot().ClockCount
+	for d.cpu.Snapshot().ClockCount == start {
+		d.system.Clock()
+	}
+	d.finish()
+}
+
+// StepOver executes one instruction like Step, but runs a subroutine call
+// until the stack is back at its depth before the JSR. It returns false if
+// it was halted by a breakpoint or Stop before that
+func (d *Debugger) StepOver() bool {
+	d.resume()
+	d.finish()
+	if d.opcode() != opJSR {
+		d.Step()
+		return true
+	}
+
+	sp := d.cpu.SP
+	d.Step()
+	return d.run(func() bool { return d.cpu.SP == sp })
+}
+
+// Continue runs until a breakpoint is hit or Stop is called, it returns
+// true for a breakpoint. The instruction at the current PC is executed
+// even if it has a breakpoint, so continuing from a breakpoint works
+func (d *Debugger) Continue() bool {
+	d.resume()
+	d.Step()
+	return !d.run(func() bool { return false })
+}
+
+// Run steps at most max instructions and returns true once until matches
+// the address of the next instruction, or when halted by a breakpoint or
+// Stop. It returns false when max instructions ran, so a UI can call it
+// repeatedly to run at a limited pace. A nil until never matches. Like
+// Continue, the instruction at the current PC is always executed
+func (d *Debugger) Run(max int, until func(pc uint16) bool) bool {
+	d.resume()
+	for i := 0; i < max; i++ {
+		if i > 0 && d.halted() {
+			return true
+		}
+		d.Step()
+		if until != nil && until(d.cpu.PC) {
+			return true
+		}
+	}
+	return false
+}
+
+// AtAddress returns an until function for Run matching addr, to run to
+// the instruction at addr
+func AtAddress(addr uint16) func(pc uint16) bool {
+	return func(pc uint16) bool {
+		return pc == addr
+	}
+}
+
+// Stop makes a running Continue, StepOver or Run return, it may be called from
+// any goroutine
+func (d *Debugger) Stop() {
+	atomic.StoreInt32(&d.stop, 1)
+}
+
+// run steps until done returns true, it returns false when halted by a
+// breakpoint or Stop first
+func (d *Debugger) run(done func() bool) bool {
+	for !done() {
+		if d.halted() {
+			return false
+		}
+		d.Step()
+	}
+	return true
+}
+
+// halted returns true if there is a breakpoint at PC, Stop was called or
+// the system paused itself
+func (d *Debugger) halted() bool {
+	if d.breakpoints[d.cpu.PC] || atomic.LoadInt32(&d.stop) != 0 {
+		return true
+	}
+	if p, ok := d.system.(pauser); ok && p.Paused() {
+		return true
+	}
+	return false
+}
+
+// resume clears a previous Stop and the paused state of the system
+func (d *Debugger) resume() {
+	atomic.StoreInt32(&d.stop, 0)
+	if p, ok := d.system.(pauser); ok {
+		p.Resume()
+	}
+}
+
+// finish clocks the system until the instruction in flight is completed
+func (d *Debugger) finish() {
+	for !d.cpu.Complete() {
+		d.system.Clock()
+	}
+}
+
+// opcode peeks the opcode at PC
+func (d *Debugger) opcode() uint8 {
+	return d.system.CpuRead(d.cpu.PC, true)
+}
@@ -0,0 +1,153 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package debugger
+
+import (
+	"mgnes/pkg/mg6502"
+	"testing"
+)
+
+// cpuSystem is a bare CPU on 64KB of RAM
+type cpuSystem struct {
+	*mg6502.RAMBus
+	cpu *mg6502.MG6502
+}
+
+func (s *cpuSystem) Clock() {
+	s.cpu.Clock()
+}
+
+// newTestDebugger loads code at $8000 and resets the CPU to it
+func newTestDebugger(code []uint8) (*Debugger, *mg6502.MG6502) {
+	ram := mg6502.NewRAMBus()
+	copy(ram[0x8000:], code)
+	ram[0xFFFC], ram[0xFFFD] = 0x00, 0x80
+
+	cpu := mg6502.NewMG6502()
+	cpu.SetReader(ram)
+	cpu.SetWriter(ram)
+	cpu.PowerUp()
+	return NewDebugger(&cpuSystem{ram, cpu}, cpu), cpu
+}
+
+// subroutineProgram calls a subroutine which calls another one
+var subroutineProgram = func() []uint8 {
+	code := make([]uint8, 0x30)
+	copy(code[0x00:], []uint8{
+		0x20, 0x10, 0x80, // $8000 JSR $8010
+		0xA2, 0x01, // $8003 LDX #$01
+		0x4C, 0x05, 0x80, // $8005 JMP $8005
+	})
+	copy(code[0x10:], []uint8{
+		0xA9, 0x42, // $8010 LDA #$42
+		0x20, 0x20, 0x80, // $8012 JSR $8020
+		0x60, // $8015 RTS
+	})
+	copy(code[0x20:], []uint8{
+		0xC8, // $8020 INY
+		0x60, // $8021 RTS
+	})
+	return code
+}()
+
+func TestStepOver(t *testing.T) {
+	d, cpu := newTestDebugger(subroutineProgram)
+
+	// the whole call, nested one included, runs as one step
+	if !d.StepOver() {
+		t.Fatalf("StepOver of JSR halted")
+	}
+	if regs := d.Registers(); regs.PC != 0x8003 || regs.A != 0x42 || regs.Y != 0x01 || regs.SP != 0xFD {
+		t.Errorf("after StepOver of JSR: %+v, want PC 0x8003, A 0x42, Y 0x01, SP 0xFD", regs)
+	}
+
+	// anything else is a single step
+	if !d.StepOver() {
+		t.Fatalf("StepOver of LDX halted")
+	}
+	if cpu.PC != 0x8005 || cpu.X != 0x01 {
+		t.Errorf("after StepOver of LDX: PC = %#04x, X = %#02x, want 0x8005, 0x01", cpu.PC, cpu.X)
+	}
+}
+
+func TestStepOverBreakpoint(t *testing.T) {
+	d, cpu := newTestDebugger(subroutineProgram)
+	d.SetBreakpoint(0x8020)
+
+	// halted inside the nested call, before the instruction at the breakpoint
+	if d.StepOver() {
+		t.Fatalf("StepOver did not halt at the breakpoint")
+	}
+	if cpu.PC != 0x8020 || cpu.Y != 0x00 {
+		t.Errorf("halted at PC = %#04x with Y = %#02x, want 0x8020 and 0x00", cpu.PC, cpu.Y)
+	}
+
+	// continuing from the breakpoint runs to the next one
+	d.SetBreakpoint(0x8005)
+	if !d.Continue() {
+		t.Fatalf("Continue did not halt at the breakpoint")
+	}
+	if cpu.PC != 0x8005 || cpu.Y != 0x01 || cpu.X != 0x01 {
+		t.Errorf("halted at PC = %#04x with X, Y = %#02x, %#02x, want 0x8005, 0x01, 0x01", cpu.PC, cpu.X, cpu.Y)
+	}
+}
+
+func TestRunToAddress(t *testing.T) {
+	// counts X up in a 4 instruction loop from $8002 to $8005
+	d, cpu := newTestDebugger([]uint8{
+		0xA2, 0x00, // $8000 LDX #$00
+		0xEA,       // $8002 NOP
+		0xEA,       // $8003 NOP
+		0xE8,       // $8004 INX
+		0xD0, 0xFB, // $8005 BNE $8002
+		0x4C, 0x00, 0x80, // $8007 JMP $8000
+	})
+
+	// stops before the instruction at the address, the first time it is
+	// reached
+	if !d.Run(100, AtAddress(0x8004)) {
+		t.Fatalf("Run did not reach $8004")
+	}
+	if cpu.PC != 0x8004 || cpu.X != 0x00 {
+		t.Errorf("stopped at PC = %#04x with X = %#02x, want 0x8004 and 0x00", cpu.PC, cpu.X)
+	}
+
+	// the instruction at PC runs even if it matches, so this is one loop
+	if !d.Run(100, AtAddress(0x8004)) {
+		t.Fatalf("Run did not reach $8004 again")
+	}
+	if cpu.PC != 0x8004 || cpu.X != 0x01 {
+		t.Errorf("stopped at PC = %#04x with X = %#02x, want 0x8004 and 0x01", cpu.PC, cpu.X)
+	}
+
+	// max reached before the address, 4 instructions per loop
+	if d.Run(3*4, AtAddress(0x8007)) {
+		t.Fatalf("Run reached $8007 within 12 instructions")
+	}
+	if cpu.PC != 0x8004 || cpu.X != 0x04 {
+		t.Errorf("stopped at PC = %#04x with X = %#02x, want 0x8004 and 0x04", cpu.PC, cpu.X)
+	}
+
+	// a nil until only stops at max
+	if d.Run(4, nil) {
+		t.Errorf("Run with nil until returned true")
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"mgnes/pkg/mg6502"
+	"strconv"
+	"strings"
+)
+
+// Watch is a named memory location, Width is 1 for a byte or 2 for a
+// little endian word
+type Watch struct {
+	Label string
+	Addr  uint16
+	Width int
+}
+
+// Watches is a list of memory locations to show while debugging
+type Watches struct {
+	list []Watch
+}
+
+// Add appends a watch, a watch with the same label is replaced
+func (w *Watches) Add(watch Watch) error {
+	if watch.Label == "" {
+		return errors.New("missing label")
+	}
+	if watch.Width != 1 && watch.Width != 2 {
+		return errors.New("width must be 1 or 2")
+	}
+
+	for i := range w.list {
+		if w.list[i].Label == watch.Label {
+			w.list[i] = watch
+			return nil
+		}
+	}
+	w.list = append(w.list, watch)
+	return nil
+}
+
+// Remove removes the watch with label
+func (w *Watches) Remove(label string) {
+	for i := range w.list {
+		if w.list[i].Label == label {
+			w.list = append(w.list[:i], w.list[i+1:]...)
+			return
+		}
+	}
+}
+
+// List returns the watches in the order they were added
+func (w *Watches) List() []Watch {
+	return w.list
+}
+
+// Format returns one line per watch with its current value, read from r
+// without side effects
+func (w *Watches) Format(r mg6502.Reader) []string {
+	lines := make([]string, 0, len(w.list))
+	for _, watch := range w.list {
+		lo := r.CpuRead(watch.Addr, true)
+		if watch.Width == 2 {
+			hi := r.CpuRead(watch.Addr+1, true)
+			value := uint16(hi)<<8 | uint16(lo)
+			lines = append(lines, fmt.Sprintf("%s $%04X = $%04X %d", watch.Label, watch.Addr, value, value))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s $%04X = $%02X %d", watch.Label, watch.Addr, lo, lo))
+		}
+	}
+	return lines
+}
+
+// ParseWatch parses "label=addr" for a byte or "label=addr:2" for a word,
+// the address is hexadecimal with an optional "$" prefix
+func ParseWatch(s string) (watch Watch, err error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		err = errors.New("expected label=addr")
+		return
+	}
+	watch.Label = strings.TrimSpace(parts[0])
+	watch.Width = 1
+
+	addr := strings.TrimSpace(parts[1])
+	if i := strings.IndexByte(addr, ':'); i >= 0 {
+		if watch.Width, err = strconv.Atoi(addr[i+1:]); err != nil {
+			err = errors.New("invalid width " + addr[i+1:])
+			return
+		}
+		addr = addr[:i]
+	}
+
+	var v uint64
+	if v, err = strconv.ParseUint(strings.TrimPrefix(addr, "$"), 16, 16); err != nil {
+		err = errors.New("invalid address " + addr)
+		return
+	}
+	watch.Addr = uint16(v)
+
+	if watch.Label == "" {
+		err = errors.New("missing label")
+	} else if watch.Width != 1 && watch.Width != 2 {
+		err = errors.New("width must be 1 or 2")
+	}
+	return
+}
@@ -0,0 +1,105 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package debugger
+
+import "testing"
+
+// fakeReader returns the low byte of the address, it counts reads that
+// are not readonly
+type fakeReader struct {
+	reads int
+}
+
+func (r *fakeReader) CpuRead(addr uint16, readonly bool) uint8 {
+	if !readonly {
+		r.reads++
+	}
+	return uint8(addr)
+}
+
+func TestParseWatch(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Watch
+		wantErr bool
+	}{
+		{"lives=$0075", Watch{"lives", 0x0075, 1}, false},
+		{" score = 07DE:2", Watch{"score", 0x07DE, 2}, false},
+		{"ptr=$00FF:1", Watch{"ptr", 0x00FF, 1}, false},
+		{"lives", Watch{}, true},
+		{"=$0075", Watch{}, true},
+		{"lives=", Watch{}, true},
+		{"lives=$10000", Watch{}, true},
+		{"lives=$0075:3", Watch{}, true},
+		{"lives=$0075:w", Watch{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseWatch(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseWatch(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseWatch(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWatchesFormat(t *testing.T) {
+	var w Watches
+	for _, watch := range []Watch{
+		{"lives", 0x0075, 1},
+		{"score", 0x07DE, 2},
+		{"wrap", 0xFFFF, 2},
+	} {
+		if err := w.Add(watch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := &fakeReader{}
+	want := []string{
+		"lives $0075 = $75 117",
+		"score $07DE = $DFDE 57310", // little endian
+		"wrap $FFFF = $00FF 255",    // the high byte wraps to $0000
+	}
+	got := w.Format(r)
+	if len(got) != len(want) {
+		t.Fatalf("Format = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %v = %q, want %q", i, got[i], want[i])
+		}
+	}
+	if r.reads != 0 {
+		t.Errorf("Format made %v reads that are not readonly", r.reads)
+	}
+
+	// a watch with the same label replaces the old one in place
+	if err := w.Add(Watch{"lives", 0x0076, 1}); err != nil {
+		t.Fatal(err)
+	}
+	w.Remove("score")
+	if got := w.Format(r); len(got) != 2 || got[0] != "lives $0076 = $76 118" {
+		t.Errorf("Format after replace and remove = %q", got)
+	}
+}
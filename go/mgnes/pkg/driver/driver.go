@@ -0,0 +1,167 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package driver
+
+import (
+	"mgnes/pkg/ines"
+	"sync"
+	"time"
+)
+
+const (
+	// NTSCFrameRate frames per second of an NTSC console
+	NTSCFrameRate = 60.0988
+	// PALFrameRate frames per second of a PAL console
+	PALFrameRate = 50.0070
+
+	// DefaultMaxBurst is how many frames Tick runs at most to catch up
+	// after a stall, the rest of the delay is dropped
+	DefaultMaxBurst = 4
+)
+
+// Runner is what the driver paces, *bus.Bus implements it
+type Runner interface {
+	RunFrames(n int)
+}
+
+// Clock is the time source of the driver, tests inject their own
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// realClock is the wall clock
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// Driver runs a system one frame at a time in real time
+type Driver struct {
+	runner Runner
+	clock  Clock
+
+	mutex    sync.Mutex
+	rate     float64
+	speed    float64
+	maxBurst int
+	// when the next frame is due, zero before the first Tick
+	next time.Time
+}
+
+// NewDriver creates and returns a driver reference pacing runner at the
+// frame rate of region on the wall clock
+func NewDriver(runner Runner, region ines.TVSystemType) *Driver {
+	return NewDriverWithClock(runner, region, realClock{})
+}
+
+// NewDriverWithClock is like NewDriver with a custom time source
+func NewDriverWithClock(runner Runner, region ines.TVSystemType, clock Clock) *Driver {
+	rate := NTSCFrameRate
+	if region == ines.TVSystemPAL {
+		rate = PALFrameRate
+	}
+	return &Driver{
+		runner:   runner,
+		clock:    clock,
+		rate:     rate,
+		speed:    1,
+		maxBurst: DefaultMaxBurst,
+	}
+}
+
+// SetSpeed sets the pacing relative to real time, 2 runs twice as fast
+// and 0.5 at half speed. Values not above zero are ignored
+func (d *Driver) SetSpeed(multiplier float64) {
+	if multiplier <= 0 {
+		return
+	}
+	d.mutex.Lock()
+	d.speed = multiplier
+	d.mutex.Unlock()
+}
+
+// SetMaxBurst sets how many frames Tick runs at most, values below 1 are
+// ignored
+func (d *Driver) SetMaxBurst(frames int) {
+	if frames < 1 {
+		return
+	}
+	d.mutex.Lock()
+	d.maxBurst = frames
+	d.mutex.Unlock()
+}
+
+// period returns the duration of a frame at the current speed
+func (d *Driver) period() time.Duration {
+	return time.Duration(float64(time.Second) / (d.rate * d.speed))
+}
+
+// Tick runs the frames that are due and returns how many were run. When
+// more than the maximum burst are due, only that many run and the driver
+// resynchronizes to the clock, so a stall does not cause a long fast
+// forward
+func (d *Driver) Tick() (frames int) {
+	d.mutex.Lock()
+	now := d.clock.Now()
+	if d.next.IsZero() {
+		d.next = now
+	}
+	period := d.period()
+	for !now.Before(d.next) && frames < d.maxBurst {
+		frames++
+		d.next = d.next.Add(period)
+	}
+	if !now.Before(d.next) {
+		// still behind, drop the frames that were missed
+		d.next = now.Add(period)
+	}
+	d.mutex.Unlock()
+
+	d.runner.RunFrames(frames)
+	return
+}
+
+// Run calls Tick and sleeps until the next frame is due, it returns once
+// stop is closed
+func (d *Driver) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		d.Tick()
+
+		d.mutex.Lock()
+		wait := d.next.Sub(d.clock.Now())
+		d.mutex.Unlock()
+		if wait > 0 {
+			d.clock.Sleep(wait)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package driver
+
+import (
+	"mgnes/pkg/ines"
+	"testing"
+	"time"
+)
+
+// fakeClock only advances when told to, Sleep advances it by d
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// frameCounter is a Runner counting the frames it was asked to run
+type frameCounter struct {
+	frames int
+}
+
+func (r *frameCounter) RunFrames(n int) {
+	r.frames += n
+}
+
+func newTestDriver(region ines.TVSystemType) (*Driver, *fakeClock, *frameCounter) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	runner := &frameCounter{}
+	return NewDriverWithClock(runner, region, clock), clock, runner
+}
+
+func TestTickPacing(t *testing.T) {
+	d, clock, runner := newTestDriver(ines.TVSystemNTSC)
+	period := d.period()
+
+	if got := d.Tick(); got != 1 {
+		t.Errorf("first Tick ran %v frames, want 1", got)
+	}
+	clock.Sleep(period / 2)
+	if got := d.Tick(); got != 0 {
+		t.Errorf("Tick half a frame later ran %v frames, want 0", got)
+	}
+	clock.Sleep(period - period/2)
+	if got := d.Tick(); got != 1 {
+		t.Errorf("Tick a frame later ran %v frames, want 1", got)
+	}
+	if runner.frames != 2 {
+		t.Errorf("runner ran %v frames, want 2", runner.frames)
+	}
+}
+
+func TestTickCatchUpCapped(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBurst int
+		want     int
+	}{
+		{"default", 0, DefaultMaxBurst},
+		{"one", 1, 1},
+		{"ten", 10, 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d, clock, runner := newTestDriver(ines.TVSystemNTSC)
+			d.SetMaxBurst(test.maxBurst)
+			d.Tick()
+			runner.frames = 0
+
+			// a one second stall makes 60 frames due
+			clock.Sleep(time.Second)
+			if got := d.Tick(); got != test.want {
+				t.Errorf("Tick after a stall ran %v frames, want %v", got, test.want)
+			}
+			if runner.frames != test.want {
+				t.Errorf("runner ran %v frames, want %v", runner.frames, test.want)
+			}
+
+			// the missed frames are dropped, not run on the next Tick
+			if got := d.Tick(); got != 0 {
+				t.Errorf("Tick right after the burst ran %v frames, want 0", got)
+			}
+			clock.Sleep(d.period())
+			if got := d.Tick(); got != 1 {
+				t.Errorf("Tick a frame after the burst ran %v frames, want 1", got)
+			}
+		})
+	}
+}
+
+func TestSetSpeed(t *testing.T) {
+	tests := []struct {
+		name   string
+		region ines.TVSystemType
+		speed  float64
+		rate   float64 // frames per second of real time
+	}{
+		{"NTSC", ines.TVSystemNTSC, 1, NTSCFrameRate},
+		{"PAL", ines.TVSystemPAL, 1, PALFrameRate},
+		{"fast forward", ines.TVSystemNTSC, 2, NTSCFrameRate * 2},
+		{"slow motion", ines.TVSystemPAL, 0.5, PALFrameRate * 0.5},
+		{"ignored", ines.TVSystemNTSC, -1, NTSCFrameRate},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d, _, _ := newTestDriver(test.region)
+			d.SetSpeed(test.speed)
+			want := time.Duration(float64(time.Second) / test.rate)
+			if got := d.period(); got != want {
+				t.Errorf("period = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	d, clock, runner := newTestDriver(ines.TVSystemPAL)
+	start := clock.now
+
+	// the runner stops the driver after 50 frames, one second of PAL
+	stop := make(chan struct{})
+	d.runner = runnerFunc(func(n int) {
+		runner.RunFrames(n)
+		if runner.frames == 50 {
+			close(stop)
+		}
+	})
+	d.Run(stop)
+
+	if runner.frames != 50 {
+		t.Errorf("ran %v frames, want 50", runner.frames)
+	}
+	// Run sleeps until the frame after the last one is due
+	if elapsed := clock.now.Sub(start); elapsed != 50*d.period() {
+		t.Errorf("elapsed %v, want %v", elapsed, 50*d.period())
+	}
+}
+
+// runnerFunc adapts a function to Runner
+type runnerFunc func(n int)
+
+func (f runnerFunc) RunFrames(n int) {
+	f(n)
+}
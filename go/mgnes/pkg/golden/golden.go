@@ -0,0 +1,112 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package golden compares traces produced by the emulator, like nestest
+// logs or the text of blargg's test ROMs, against known good files. Set
+// MGNES_UPDATE_GOLDEN=1 to write the files instead after an intended change
+package golden
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// UpdateEnv is the environment variable making Check write golden files
+const UpdateEnv = "MGNES_UPDATE_GOLDEN"
+
+// MismatchError is returned by Check when the output differs from the
+// golden file, Line is the first line which differs, counting from 1
+type MismatchError struct {
+	Path string
+	Line int
+	Want string
+	Got  string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("%v:%v: got %q, want %q (set %v=1 to update)", e.Path, e.Line, e.Got, e.Want, UpdateEnv)
+}
+
+// Updating returns true if golden files are being regenerated
+func Updating() bool {
+	return os.Getenv(UpdateEnv) != ""
+}
+
+// Check compares got with the content of the file at path, or writes got to
+// it when Updating. It returns a *MismatchError if they differ
+func Check(path string, got []byte) error {
+	if Updating() {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(path, got, 0644)
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read golden file: %w (set %v=1 to create it)", err, UpdateEnv)
+	}
+	if bytes.Equal(got, want) {
+		return nil
+	}
+	return mismatch(path, want, got)
+}
+
+// noNewline is reported for the side missing the final newline when the
+// lines are otherwise the same
+const noNewline = "<no newline at end of file>"
+
+// mismatch returns the error for the first line which differs
+func mismatch(path string, want, got []byte) error {
+	wantLines := lines(want)
+	gotLines := lines(got)
+	line := 0
+	for line < len(wantLines) && line < len(gotLines) && bytes.Equal(wantLines[line], gotLines[line]) {
+		line++
+	}
+
+	e := &MismatchError{Path: path, Line: line + 1, Want: "<EOF>", Got: "<EOF>"}
+	if line < len(wantLines) {
+		e.Want = string(wantLines[line])
+	}
+	if line < len(gotLines) {
+		e.Got = string(gotLines[line])
+	}
+	if line == len(wantLines) && line == len(gotLines) {
+		// only the newline terminating the last line differs
+		e.Line = line
+		e.Want, e.Got = string(wantLines[line-1]), noNewline
+		if !bytes.HasSuffix(want, []byte("\n")) {
+			e.Want, e.Got = noNewline, string(gotLines[line-1])
+		}
+	}
+	return e
+}
+
+// lines splits data into lines, a newline terminating the last line does
+// not start another one
+func lines(data []byte) [][]byte {
+	if len(data) == 0 {
+		return nil
+	}
+	return bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+}
@@ -0,0 +1,174 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package golden
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// trace is the content of testdata/nestest.golden, the first lines of the
+// nestest log
+const trace = "C000  4C F5 C5  JMP $C5F5                       A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7\n" +
+	"C5F5  A2 00     LDX #$00                        A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 30 CYC:10\n" +
+	"C5F7  86 00     STX $00 = 00                    A:00 X:00 Y:00 P:26 SP:FD PPU:  0, 36 CYC:12\n"
+
+const tracePath = "testdata/nestest.golden"
+
+func TestCheckMatch(t *testing.T) {
+	t.Setenv(UpdateEnv, "")
+	if err := Check(tracePath, []byte(trace)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckMismatch(t *testing.T) {
+	t.Setenv(UpdateEnv, "")
+	tests := []struct {
+		name string
+		got  string
+		line int
+		want string
+		gotL string
+	}{
+		{
+			"changed line",
+			"C000  4C F5 C5  JMP $C5F5                       A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7\n" +
+				"C5F5  A2 00     LDX #$00                        A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 30 CYC:11\n",
+			2,
+			"C5F5  A2 00     LDX #$00                        A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 30 CYC:10",
+			"C5F5  A2 00     LDX #$00                        A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 30 CYC:11",
+		},
+		{
+			"empty output",
+			"",
+			1,
+			"C000  4C F5 C5  JMP $C5F5                       A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7",
+			"<EOF>",
+		},
+		{
+			"output shorter",
+			"C000  4C F5 C5  JMP $C5F5                       A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7",
+			2,
+			"C5F5  A2 00     LDX #$00                        A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 30 CYC:10",
+			"<EOF>",
+		},
+		{
+			"output shorter terminated",
+			"C000  4C F5 C5  JMP $C5F5                       A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7\n",
+			2,
+			"C5F5  A2 00     LDX #$00                        A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 30 CYC:10",
+			"<EOF>",
+		},
+		{
+			"missing final newline",
+			trace[:len(trace)-1],
+			3,
+			"C5F7  86 00     STX $00 = 00                    A:00 X:00 Y:00 P:26 SP:FD PPU:  0, 36 CYC:12",
+			"<no newline at end of file>",
+		},
+		{
+			"output longer",
+			trace + "C5F9  86 10     STX $10 = 00                    A:00 X:00 Y:00 P:26 SP:FD PPU:  0, 45 CYC:15\n",
+			4,
+			"<EOF>",
+			"C5F9  86 10     STX $10 = 00                    A:00 X:00 Y:00 P:26 SP:FD PPU:  0, 45 CYC:15",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Check(tracePath, []byte(test.got))
+			var mismatch *MismatchError
+			if !errors.As(err, &mismatch) {
+				t.Fatalf("Check() = %v, want a *MismatchError", err)
+			}
+			if mismatch.Path != tracePath {
+				t.Errorf("Path = %q, want %q", mismatch.Path, tracePath)
+			}
+			if mismatch.Line != test.line {
+				t.Errorf("Line = %v, want %v", mismatch.Line, test.line)
+			}
+			if mismatch.Want != test.want {
+				t.Errorf("Want = %q, want %q", mismatch.Want, test.want)
+			}
+			if mismatch.Got != test.gotL {
+				t.Errorf("Got = %q, want %q", mismatch.Got, test.gotL)
+			}
+		})
+	}
+}
+
+func TestCheckUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "trace.golden")
+
+	// updating creates the file and its directory
+	t.Setenv(UpdateEnv, "1")
+	if !Updating() {
+		t.Fatalf("Updating() = false with %v set", UpdateEnv)
+	}
+	if err := Check(path, []byte(trace)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != trace {
+		t.Errorf("golden file = %q, want %q", data, trace)
+	}
+
+	// a different output replaces it instead of failing
+	if err := Check(path, []byte("updated\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(UpdateEnv, "")
+	if Updating() {
+		t.Fatalf("Updating() = true with %v empty", UpdateEnv)
+	}
+	if err := Check(path, []byte("updated\n")); err != nil {
+		t.Errorf("Check() after update = %v", err)
+	}
+	if err := Check(path, []byte(trace)); err == nil {
+		t.Errorf("Check() passed with the old output")
+	}
+}
+
+func TestCheckMissing(t *testing.T) {
+	t.Setenv(UpdateEnv, "")
+	path := filepath.Join(t.TempDir(), "missing.golden")
+
+	err := Check(path, []byte(trace))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Check() = %v, want a not exist error", err)
+	}
+	var mismatch *MismatchError
+	if errors.As(err, &mismatch) {
+		t.Errorf("Check() returned a mismatch for a missing file")
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("golden file created without %v set", UpdateEnv)
+	}
+}
@@ -30,6 +30,9 @@ import (
 const (
 	// HeaderSize standard NES rom header is 16 bytes
 	HeaderSize = 16
+	// MaxROMSize largest PRG or CHR ROM size accepted by NewHeader, the
+	// NES 2.0 exponent form can describe sizes far beyond any real board
+	MaxROMSize = 64 * 1024 * 1024
 )
 
 // MirroringDirection mirroring direction
@@ -96,6 +99,19 @@ type Header struct {
 	Flag9      uint8   // xxxx xxxT
 	Flag10     uint8   // xxBP xxTT
 	padding    [5]byte // zero padding
+
+	dirtyPadding bool // iNES 1.0 padding was not zero before being masked
+}
+
+// Option adjusts a header after it was parsed by NewHeader
+type Option func(h *Header)
+
+// MaskCorruptMapper clears the upper mapper nibble in Flag7 when the
+// header looks corrupt, see MapperLikelyCorrupt
+func MaskCorruptMapper(h *Header) {
+	if h.MapperLikelyCorrupt() {
+		h.Flag7 &= 0x0F
+	}
 }
 
 var (
@@ -160,7 +176,7 @@ var (
 )
 
 // NewHeader create a new header from data
-func NewHeader(r io.Reader) (header *Header, err error) {
+func NewHeader(r io.Reader, options ...Option) (header *Header, err error) {
 	buf := make([]byte, HeaderSize)
 	n := 0
 	n, err = io.ReadAtLeast(r, buf, HeaderSize)
@@ -186,9 +202,34 @@ func NewHeader(r io.Reader) (header *Header, err error) {
 	header.PRGRAM = buf[8]
 	header.Flag9 = buf[9]
 	header.Flag10 = buf[10]
-	copy(header.padding[:], buf[10:])
-	if !bytes.Equal(header.padding[:], standardPadding) {
-		err = errors.New("invalid padding")
+	copy(header.padding[:], buf[11:16])
+	// NES 2.0 uses bytes 8-15 for extended information, for iNES 1.0
+	// headers bytes 11-15 should be zero, but some old ROM tools wrote
+	// their signature ("DiskDude!" etc.) from byte 7 on. When any of bytes
+	// 12-15 is set, bytes 7-15 are all garbage and cleared as NES 2.0
+	// aware loaders do, a stray byte 11 is just masked out
+	if !header.NES20() && !bytes.Equal(header.padding[:], standardPadding) {
+		if !bytes.Equal(header.padding[1:], standardPadding[1:]) {
+			header.Flag7 = 0
+			header.PRGRAM = 0
+			header.Flag9 = 0
+			header.Flag10 = 0
+		}
+		copy(header.padding[:], standardPadding)
+		header.dirtyPadding = true
+	}
+
+	for _, option := range options {
+		option(header)
+	}
+
+	if header.PRGROMSize() > MaxROMSize {
+		err = fmt.Errorf("PRG ROM size %v out of range", header.PRGROMSize())
+		header = nil
+		return
+	}
+	if header.CHRROMSize() > MaxROMSize {
+		err = fmt.Errorf("CHR ROM size %v out of range", header.CHRROMSize())
 		header = nil
 		return
 	}
@@ -198,14 +239,31 @@ func NewHeader(r io.Reader) (header *Header, err error) {
 
 // PRGROMSize returns PRG ROM size
 func (h *Header) PRGROMSize() int {
+	if h.NES20() {
+		return romSize(h.PRG, h.Flag9&0x0F, 16*1024)
+	}
 	return int(h.PRG) * 16 * 1024
 }
 
 // CHRROMSize returns CHR ROM size
 func (h *Header) CHRROMSize() int {
+	if h.NES20() {
+		return romSize(h.CHR, h.Flag9>>4, 8*1024)
+	}
 	return int(h.CHR) * 8 * 1024
 }
 
+// PRGBanks returns the number of 16KB PRG ROM banks, a partial bank of
+// the NES 2.0 exponent form counts as a whole one
+func (h *Header) PRGBanks() int {
+	return (h.PRGROMSize() + 16*1024 - 1) / (16 * 1024)
+}
+
+// CHRBanks returns the number of 8KB CHR ROM banks, 0 means CHR RAM only
+func (h *Header) CHRBanks() int {
+	return (h.CHRROMSize() + 8*1024 - 1) / (8 * 1024)
+}
+
 // Mapper returns mapper number
 func (h *Header) Mapper() uint8 {
 	low4 := (h.Flag6 & 0xF0) >> 4
@@ -213,6 +271,17 @@ func (h *Header) Mapper() uint8 {
 	return low4 | high4
 }
 
+// MapperLikelyCorrupt returns true when the upper mapper nibble of an
+// iNES 1.0 header is probably garbage. Old ROM tools wrote their signature
+// from byte 7 on, "DiskDude!" turns mapper 1 into 0x41, this shows as
+// unused Flag7 bits set or non zero padding
+func (h *Header) MapperLikelyCorrupt() bool {
+	if h.NES20() {
+		return false
+	}
+	return h.Flag7&0x0C != 0 || h.dirtyPadding
+}
+
 // Flag6
 // --------
 // 76543210
@@ -256,7 +325,7 @@ func (h *Header) Mirroring() MirroringDirection {
 
 // NES20 returns true when header is in iNES2.0 format
 func (h *Header) NES20() bool {
-	return h.Flag7&0x0C != 0
+	return h.Flag7&0x0C == 0x08
 }
 
 // PlayChoice10 returns true if header is a PC-10 game header
@@ -280,6 +349,57 @@ func (h *Header) PRGRAMSize() int {
 	}
 }
 
+// NES 2.0 Byte8
+// --------
+// 76543210
+// SSSSMMMM
+// ||||||||
+// ||||++++- Mapper number bits 8-11
+// ++++----- Submapper number
+
+// MapperExtended returns the 12-bit mapper number of NES 2.0 headers,
+// for iNES 1.0 headers it is the same as Mapper
+func (h *Header) MapperExtended() uint16 {
+	if h.NES20() {
+		return uint16(h.PRGRAM&0x0F)<<8 | uint16(h.Mapper())
+	}
+	return uint16(h.Mapper())
+}
+
+// Submapper returns submapper number of NES 2.0 headers, 0 for iNES 1.0
+func (h *Header) Submapper() uint8 {
+	if h.NES20() {
+		return h.PRGRAM >> 4
+	}
+	return 0
+}
+
+// NES 2.0 Byte9
+// --------
+// 76543210
+// CCCCPPPP
+// ||||||||
+// ||||++++- PRG ROM size bits 8-11
+// ++++----- CHR ROM size bits 8-11
+//
+// When the size MSB nibble is 0xF, the LSB byte is in EEEEEEMM format and
+// the ROM size is 2^E * (MM*2+1) bytes
+
+// romSize returns the size of a NES 2.0 ROM area in bytes
+func romSize(lsb, msb uint8, unit int) int {
+	if msb == 0x0F {
+		exponent := uint(lsb >> 2)
+		if exponent > 27 {
+			// anything above is rejected by NewHeader anyway, keep the
+			// result from overflowing
+			exponent = 27
+		}
+		multiplier := int(lsb&0x03)*2 + 1
+		return (1 << exponent) * multiplier
+	}
+	return (int(msb)<<8 | int(lsb)) * unit
+}
+
 // Flag9
 // --------
 // 76543210
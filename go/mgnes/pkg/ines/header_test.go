@@ -0,0 +1,244 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ines
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNES20Header(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		nes20     bool
+		mapper    uint16
+		submapper uint8
+		prgSize   int
+		chrSize   int
+	}{
+		{
+			// Kirby's Adventure, MMC3 with 512KB PRG, 256KB CHR and 8KB
+			// of battery backed PRG RAM
+			"Kirby's Adventure",
+			[]byte{'N', 'E', 'S', 0x1A, 0x20, 0x20, 0x42, 0x08, 0x00, 0x00, 0x70, 0x00, 0x00, 0x00, 0x00, 0x01},
+			true, 4, 0, 512 * 1024, 256 * 1024,
+		},
+		{
+			"iNES 1.0",
+			[]byte{'N', 'E', 'S', 0x1A, 0x20, 0x20, 0x42, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			false, 4, 0, 512 * 1024, 256 * 1024,
+		},
+		{
+			"extended mapper and submapper",
+			[]byte{'N', 'E', 'S', 0x1A, 0x10, 0x00, 0xF0, 0x18, 0x21, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			true, 0x11F, 2, 256 * 1024, 0,
+		},
+		{
+			"size MSB nibbles",
+			[]byte{'N', 'E', 'S', 0x1A, 0x00, 0x02, 0x00, 0x08, 0x00, 0x21, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			true, 0, 0, 256 * 16 * 1024, 0x202 * 8 * 1024,
+		},
+		{
+			// 2^19 * 3 bytes of PRG ROM, 2^13 * 1 bytes of CHR ROM
+			"exponent sizes",
+			[]byte{'N', 'E', 'S', 0x1A, 0x4D, 0x34, 0x00, 0x08, 0x00, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			true, 0, 0, 3 * 512 * 1024, 8 * 1024,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h, err := NewHeader(bytes.NewReader(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.NES20() != test.nes20 {
+				t.Errorf("NES20() = %v, want %v", h.NES20(), test.nes20)
+			}
+			if got := h.MapperExtended(); got != test.mapper {
+				t.Errorf("MapperExtended() = %#x, want %#x", got, test.mapper)
+			}
+			if got := h.Submapper(); got != test.submapper {
+				t.Errorf("Submapper() = %v, want %v", got, test.submapper)
+			}
+			if got := h.PRGROMSize(); got != test.prgSize {
+				t.Errorf("PRGROMSize() = %v, want %v", got, test.prgSize)
+			}
+			if got := h.CHRROMSize(); got != test.chrSize {
+				t.Errorf("CHRROMSize() = %v, want %v", got, test.chrSize)
+			}
+		})
+	}
+}
+
+func TestNES20HeaderSizeOutOfRange(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		// 2^63 * 7 bytes overflowed int before being rejected
+		{"PRG exponent", []byte{'N', 'E', 'S', 0x1A, 0xFF, 0x01, 0x00, 0x08, 0x00, 0x0F, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"PRG 128MB", []byte{'N', 'E', 'S', 0x1A, 0x6C, 0x01, 0x00, 0x08, 0x00, 0x0F, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"CHR exponent", []byte{'N', 'E', 'S', 0x1A, 0x01, 0xFC, 0x00, 0x08, 0x00, 0xF0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h, err := NewHeader(bytes.NewReader(test.data))
+			if err == nil {
+				t.Fatalf("NewHeader() returned no error, PRG %v CHR %v", h.PRGROMSize(), h.CHRROMSize())
+			}
+			if h != nil {
+				t.Errorf("NewHeader() returned a header with error %v", err)
+			}
+		})
+	}
+
+	// the largest size still accepted
+	data := []byte{'N', 'E', 'S', 0x1A, 0x68, 0x01, 0x00, 0x08, 0x00, 0x0F, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	h, err := NewHeader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.PRGROMSize() != MaxROMSize {
+		t.Errorf("PRGROMSize() = %v, want %v", h.PRGROMSize(), MaxROMSize)
+	}
+}
+
+func TestFlag10AndPadding(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		mapper      uint8
+		tvSystem    TVSystemType
+		busConflict bool
+		prgRAM      bool
+		prgRAMSize  int
+	}{
+		{
+			"bus conflict",
+			[]byte{'N', 'E', 'S', 0x1A, 2, 1, 0x20, 0x00, 0x00, 0x00, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00},
+			2, TVSystemNTSC, true, true, 8,
+		},
+		{
+			"PAL without PRG RAM",
+			[]byte{'N', 'E', 'S', 0x1A, 2, 1, 0x20, 0x00, 0x02, 0x01, 0x12, 0x00, 0x00, 0x00, 0x00, 0x00},
+			2, TVSystemPAL, false, false, 16,
+		},
+		{
+			// only byte 11 is dirty, bytes 7-10 are kept
+			"stray byte 11",
+			[]byte{'N', 'E', 'S', 0x1A, 2, 1, 0x20, 0x00, 0x02, 0x01, 0x20, 0xAA, 0x00, 0x00, 0x00, 0x00},
+			2, TVSystemPAL, true, true, 16,
+		},
+		{
+			// "DiskDude!" from byte 7 on, 's' in byte 9 reads as PAL and
+			// 'k' in byte 10 as bus conflict without PRG RAM
+			"DiskDude",
+			append([]byte{'N', 'E', 'S', 0x1A, 2, 1, 0x10}, []byte("DiskDude!")...),
+			1, TVSystemNTSC, false, true, 8,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h, err := NewHeader(bytes.NewReader(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := h.Mapper(); got != test.mapper {
+				t.Errorf("Mapper() = %v, want %v", got, test.mapper)
+			}
+			if got := h.TVSystem(); got != test.tvSystem {
+				t.Errorf("TVSystem() = %v, want %v", got, test.tvSystem)
+			}
+			if got := h.BusConflict(); got != test.busConflict {
+				t.Errorf("BusConflict() = %v, want %v", got, test.busConflict)
+			}
+			if got := h.PRGRAMPresent(); got != test.prgRAM {
+				t.Errorf("PRGRAMPresent() = %v, want %v", got, test.prgRAM)
+			}
+			if got := h.PRGRAMSize(); got != test.prgRAMSize {
+				t.Errorf("PRGRAMSize() = %v, want %v", got, test.prgRAMSize)
+			}
+			if h.padding != [5]byte{} {
+				t.Errorf("padding = % x, want zeros", h.padding)
+			}
+		})
+	}
+}
+
+func TestMaskCorruptMapper(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		corrupt bool
+		raw     uint8 // Mapper() without MaskCorruptMapper
+		masked  uint8 // Mapper() with MaskCorruptMapper
+	}{
+		{
+			// 'D' in byte 7 turns MMC1 into 0x41, the rest is clean
+			"garbage byte 7",
+			[]byte{'N', 'E', 'S', 0x1A, 2, 1, 0x10, 'D', 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			true, 0x41, 0x01,
+		},
+		{
+			// byte 11 alone is dirty, bytes 7-10 are kept
+			"garbage bytes 7 and 11",
+			[]byte{'N', 'E', 'S', 0x1A, 2, 1, 0x40, 0x40, 0x00, 0x00, 0x00, '!', 0x00, 0x00, 0x00, 0x00},
+			true, 0x44, 0x04,
+		},
+		{
+			"valid iNES 1.0",
+			[]byte{'N', 'E', 'S', 0x1A, 2, 1, 0x10, 0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			false, 0x41, 0x41,
+		},
+		{
+			"NES 2.0",
+			[]byte{'N', 'E', 'S', 0x1A, 2, 1, 0x10, 0x48, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			false, 0x41, 0x41,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h, err := NewHeader(bytes.NewReader(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := h.MapperLikelyCorrupt(); got != test.corrupt {
+				t.Errorf("MapperLikelyCorrupt() = %v, want %v", got, test.corrupt)
+			}
+			if got := h.Mapper(); got != test.raw {
+				t.Errorf("Mapper() = %#02x, want %#02x", got, test.raw)
+			}
+
+			h, err = NewHeader(bytes.NewReader(test.data), MaskCorruptMapper)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := h.Mapper(); got != test.masked {
+				t.Errorf("masked Mapper() = %#02x, want %#02x", got, test.masked)
+			}
+		})
+	}
+}
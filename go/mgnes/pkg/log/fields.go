@@ -0,0 +1,93 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package log
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Field is a named value attached to a log message
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F returns a Field, it keeps LF calls short
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// FieldLogger is a Logger also taking structured fields, loggers which only
+// implement Logger get the message of LF without the fields
+type FieldLogger interface {
+	Logger
+	LogFields(msg string, fields ...Field)
+}
+
+// LF logs msg with fields
+func LF(msg string, fields ...Field) {
+	if l, ok := logger.(FieldLogger); ok {
+		l.LogFields(msg, fields...)
+		return
+	}
+	logger.Log(msg)
+}
+
+// TSVLogger writes one line per message to a writer, the message followed
+// by its fields as key=value, all separated by tabs. uint8 and uint16
+// values are written in hex like the nestest log, e.g. pc=C000
+type TSVLogger struct {
+	w  io.Writer
+	sb strings.Builder
+}
+
+// NewTSVLogger creates and returns a logger writing to w
+func NewTSVLogger(w io.Writer) *TSVLogger {
+	return &TSVLogger{w: w}
+}
+
+// Log writes msg on a line of its own
+func (l *TSVLogger) Log(msg string) {
+	l.LogFields(msg)
+}
+
+// LogFields writes msg and fields on one line
+func (l *TSVLogger) LogFields(msg string, fields ...Field) {
+	l.sb.Reset()
+	l.sb.WriteString(msg)
+	for _, f := range fields {
+		l.sb.WriteByte('\t')
+		l.sb.WriteString(f.Key)
+		l.sb.WriteByte('=')
+		switch v := f.Value.(type) {
+		case uint8:
+			fmt.Fprintf(&l.sb, "%02X", v)
+		case uint16:
+			fmt.Fprintf(&l.sb, "%04X", v)
+		default:
+			fmt.Fprint(&l.sb, v)
+		}
+	}
+	l.sb.WriteByte('\n')
+	io.WriteString(l.w, l.sb.String())
+}
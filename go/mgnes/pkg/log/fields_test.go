@@ -0,0 +1,58 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package log
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTSVLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewTSVLogger(&buf)
+	SetLogger(l)
+	t.Cleanup(func() { SetLogger(nil) })
+
+	LF("C000  4C F5 C5  JMP $C5F5",
+		F("pc", uint16(0xC000)), F("op", uint8(0x4C)), F("a", uint8(0x0A)),
+		F("cyc", uint32(7)), F("name", "nestest"))
+	L("plain message")
+	l.LogFields("no fields")
+
+	want := "C000  4C F5 C5  JMP $C5F5\tpc=C000\top=4C\ta=0A\tcyc=7\tname=nestest\n" +
+		"plain message\n" +
+		"no fields\n"
+	if got := buf.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestLFWithoutFields(t *testing.T) {
+	// loggers without field support only get the message
+	ring := NewRingLogger(4)
+	SetLogger(ring)
+	t.Cleanup(func() { SetLogger(nil) })
+
+	LF("message", F("pc", uint16(0xC000)))
+	if got := ring.Dump(); len(got) != 1 || got[0] != "message" {
+		t.Errorf("Dump() = %q, want [message]", got)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package log
+
+// RingLogger keeps the last messages in memory instead of writing them, so
+// a trace can run for hours and still show what led to a crash
+type RingLogger struct {
+	entries []string
+	next    int
+	full    bool
+}
+
+// NewRingLogger creates and returns a logger keeping the last size
+// messages, at least one
+func NewRingLogger(size int) *RingLogger {
+	if size < 1 {
+		size = 1
+	}
+	return &RingLogger{entries: make([]string, size)}
+}
+
+// Log stores msg, dropping the oldest message once the ring is full
+func (l *RingLogger) Log(msg string) {
+	l.entries[l.next] = msg
+	l.next++
+	if l.next == len(l.entries) {
+		l.next = 0
+		l.full = true
+	}
+}
+
+// Dump returns the stored messages from the oldest to the newest
+func (l *RingLogger) Dump() []string {
+	if !l.full {
+		return append([]string(nil), l.entries[:l.next]...)
+	}
+	dump := make([]string, 0, len(l.entries))
+	dump = append(dump, l.entries[l.next:]...)
+	return append(dump, l.entries[:l.next]...)
+}
@@ -0,0 +1,64 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRingLoggerDump(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		logged int
+		want   []string
+	}{
+		{"empty", 3, 0, []string{}},
+		{"not full", 3, 2, []string{"0", "1"}},
+		{"exactly full", 3, 3, []string{"0", "1", "2"}},
+		{"wrapped", 3, 7, []string{"4", "5", "6"}},
+		{"wrapped to the start", 3, 6, []string{"3", "4", "5"}},
+		{"size below one keeps one", 0, 5, []string{"4"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := NewRingLogger(test.size)
+			for i := 0; i < test.logged; i++ {
+				l.Log(fmt.Sprint(i))
+			}
+			got := l.Dump()
+			if len(got) != len(test.want) || (len(got) > 0 && !reflect.DeepEqual(got, test.want)) {
+				t.Errorf("Dump() = %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	// the dump is a copy
+	l := NewRingLogger(2)
+	l.Log("a")
+	l.Dump()[0] = "changed"
+	if got := l.Dump(); got[0] != "a" {
+		t.Errorf("Dump() = %q after changing a previous dump", got)
+	}
+}
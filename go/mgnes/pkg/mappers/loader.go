@@ -21,17 +21,39 @@
 package mappers
 
 import (
+	"errors"
+	"fmt"
 	"mgnes/pkg/ines"
 )
 
-func Create(header *ines.Header) Mapper {
+// constructor creates a mapper from the PRG and CHR bank counts in the header
+type constructor func(numPRGBanks, numCHRBanks uint8) Mapper
+
+// registry holds all supported mappers, keyed by iNES mapper number.
+// Adding a new mapper only requires registering its constructor here.
+var registry = map[uint16]constructor{
+	0: func(numPRGBanks, numCHRBanks uint8) Mapper { return NewMapper000(numPRGBanks, numCHRBanks) },
+	1: func(numPRGBanks, numCHRBanks uint8) Mapper { return NewMapper001(numPRGBanks, numCHRBanks) },
+	2: func(numPRGBanks, numCHRBanks uint8) Mapper { return NewMapper002(numPRGBanks, numCHRBanks) },
+	3: func(numPRGBanks, numCHRBanks uint8) Mapper { return NewMapper003(numPRGBanks, numCHRBanks) },
+	4: func(numPRGBanks, numCHRBanks uint8) Mapper { return NewMapper004(numPRGBanks, numCHRBanks) },
+}
+
+// Create instantiates the mapper described by the header, an error is
+// returned if the mapper is not supported
+func Create(header *ines.Header) (Mapper, error) {
 	if header == nil {
-		return nil
+		return nil, errors.New("invalid iNES header")
+	}
+
+	id := header.MapperExtended()
+	create, ok := registry[id]
+	if !ok {
+		return nil, fmt.Errorf("unsupported mapper %v (%v)", id, ines.Magic2Mapper(int(id)))
 	}
-	switch header.Mapper() {
-	case 0:
-		return NewMapper000(header.PRG, header.CHR)
-	default:
-		return nil
+	// none of the supported boards addresses more than 255 banks
+	if header.PRGBanks() > 0xFF || header.CHRBanks() > 0xFF {
+		return nil, fmt.Errorf("mapper %v does not support %v PRG and %v CHR banks", id, header.PRGBanks(), header.CHRBanks())
 	}
+	return create(uint8(header.PRGBanks()), uint8(header.CHRBanks())), nil
 }
@@ -0,0 +1,69 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mappers
+
+import (
+	"fmt"
+	"mgnes/pkg/ines"
+	"testing"
+)
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mapper uint8
+		want   string
+	}{
+		{"NROM", 0, "*mappers.Mapper000"},
+		{"MMC1", 1, "*mappers.Mapper001"},
+		{"UNROM", 2, "*mappers.Mapper002"},
+		{"CNROM", 3, "*mappers.Mapper003"},
+		{"MMC3", 4, "*mappers.Mapper004"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := &ines.Header{
+				PRG:   2,
+				CHR:   1,
+				Flag6: test.mapper << 4,
+			}
+
+			m, err := Create(header)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if got := fmt.Sprintf("%T", m); got != test.want {
+				t.Errorf("Create() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCreateUnsupported(t *testing.T) {
+	header := &ines.Header{PRG: 2, CHR: 1, Flag6: 0x50}
+	if m, err := Create(header); err == nil {
+		t.Errorf("Create(mapper 5) = %T, want an error", m)
+	}
+	if m, err := Create(nil); err == nil {
+		t.Errorf("Create(nil) = %T, want an error", m)
+	}
+}
@@ -20,10 +20,58 @@
 
 package mappers
 
+import "encoding/gob"
+
+// Mirror nametable mirroring mode
+type Mirror int
+
+const (
+	MirrorHorizontal Mirror = iota
+	MirrorVertical
+	MirrorOneScreenLo
+	MirrorOneScreenHi
+)
+
+func (m Mirror) String() string {
+	switch m {
+	case MirrorHorizontal:
+		return "Horizontal"
+	case MirrorVertical:
+		return "Vertical"
+	case MirrorOneScreenLo:
+		return "OneScreenLo"
+	case MirrorOneScreenHi:
+		return "OneScreenHi"
+	default:
+		return "N/A"
+	}
+}
+
+// MappedAddrInternal is returned as mapped address when the mapper has
+// handled the access by itself, e.g. a write to one of its registers, so
+// the cartridge must not touch its memory
+const MappedAddrInternal uint32 = 0xFFFFFFFF
+
 // Mapper interface
 type Mapper interface {
 	CpuMapRead(addr uint16) (mappedAddr uint32, flag bool)
-	CpuMapWrite(addr uint16) (mappedAddr uint32, flag bool)
+	CpuMapWrite(addr uint16, data uint8) (mappedAddr uint32, flag bool)
 	PpuMapRead(addr uint16) (mappedAddr uint32, flag bool)
 	PpuMapWrite(addr uint16) (mappedAddr uint32, flag bool)
+	// Mirror returns the mirroring mode selected by the mapper, flag is
+	// false if mirroring is hard wired on the cartridge
+	Mirror() (mirror Mirror, flag bool)
+	Reset()
+	// Serialize writes the bank registers for a save state
+	Serialize(enc *gob.Encoder) error
+	// Deserialize restores the bank registers written by Serialize
+	Deserialize(dec *gob.Decoder) error
+}
+
+// IRQSource is implemented by mappers which are able to interrupt the CPU
+type IRQSource interface {
+	// IRQState returns true while the mapper asserts an interrupt
+	IRQState() bool
+	// ClearIRQ acknowledges a pending interrupt
+	ClearIRQ()
 }
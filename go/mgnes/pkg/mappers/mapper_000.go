@@ -20,6 +20,8 @@
 
 package mappers
 
+import "encoding/gob"
+
 type Mapper000 struct {
 	numPRGBanks uint8
 	numCHRBanks uint8
@@ -52,7 +54,7 @@ func (m *Mapper000) CpuMapRead(addr uint16) (mappedAddr uint32, flag bool) {
 	return
 }
 
-func (m *Mapper000) CpuMapWrite(addr uint16) (mappedAddr uint32, flag bool) {
+func (m *Mapper000) CpuMapWrite(addr uint16, data uint8) (mappedAddr uint32, flag bool) {
 	if addr >= 0x8000 {
 		if m.numPRGBanks > 1 {
 			mappedAddr = uint32(addr & 0x7FFF)
@@ -85,3 +87,20 @@ func (m *Mapper000) PpuMapWrite(addr uint16) (mappedAddr uint32, flag bool) {
 	}
 	return
 }
+
+func (m *Mapper000) Mirror() (mirror Mirror, flag bool) {
+	return
+}
+
+func (m *Mapper000) Reset() {
+}
+
+// Serialize writes nothing, NROM has no bank registers
+func (m *Mapper000) Serialize(enc *gob.Encoder) error {
+	return nil
+}
+
+// Deserialize reads nothing, NROM has no bank registers
+func (m *Mapper000) Deserialize(dec *gob.Decoder) error {
+	return nil
+}
@@ -0,0 +1,238 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mappers
+
+import "encoding/gob"
+
+// Mapper001 MMC1
+// The CPU talks to MMC1 through a 5-bit serial shift register, any write
+// to 0x8000-0xFFFF feeds bit 0 of the data into it. On the fifth write
+// the register content is copied into one of the four internal registers,
+// selected by bit 13 and 14 of the address of that fifth write:
+//
+//	0x8000 -> 0x9FFF: Control
+//	0xA000 -> 0xBFFF: CHR bank 0
+//	0xC000 -> 0xDFFF: CHR bank 1
+//	0xE000 -> 0xFFFF: PRG bank
+//
+// Writing a value with bit 7 set clears the shift register at any time.
+type Mapper001 struct {
+	numPRGBanks uint8
+	numCHRBanks uint8
+
+	// serial port
+	load      uint8
+	loadCount uint8
+
+	// Control register
+	// --------
+	// 43210
+	// CPPMM
+	// |||||
+	// |||++- Mirroring. 0 = one-screen lo, 1 = one-screen hi, 2 = vertical, 3 = horizontal
+	// |++--- PRG bank mode. 0, 1 = 32KB at 0x8000; 2 = first bank fixed at 0x8000, 16KB switchable at 0xC000;
+	// |                     3 = last bank fixed at 0xC000, 16KB switchable at 0x8000
+	// +----- CHR bank mode. 0 = 8KB at a time, 1 = two separate 4KB banks
+	control uint8
+
+	// bank registers as written, the banks they select depend on the
+	// modes of the control register at the time of the access
+	chrBank0 uint8
+	chrBank1 uint8
+	prgBank  uint8
+
+	mirror Mirror
+}
+
+func NewMapper001(numPRGBanks, numCHRBanks uint8) *Mapper001 {
+	m := &Mapper001{
+		numPRGBanks: numPRGBanks,
+		numCHRBanks: numCHRBanks,
+	}
+	m.Reset()
+	return m
+}
+
+func (m *Mapper001) CpuMapRead(addr uint16) (mappedAddr uint32, flag bool) {
+	if addr >= 0x8000 {
+		bank := uint32(m.prgBank & 0x0F)
+		switch (m.control >> 2) & 0x03 {
+		case 0, 1:
+			// 32KB mode ignores the low bit
+			mappedAddr = (bank>>1)*0x8000 + uint32(addr&0x7FFF)
+		case 2:
+			// first bank fixed at 0x8000, 16KB bank switched at 0xC000
+			if addr <= 0xBFFF {
+				mappedAddr = uint32(addr & 0x3FFF)
+			} else {
+				mappedAddr = bank*0x4000 + uint32(addr&0x3FFF)
+			}
+		case 3:
+			// 16KB bank switched at 0x8000, last bank fixed at 0xC000
+			if addr <= 0xBFFF {
+				mappedAddr = bank*0x4000 + uint32(addr&0x3FFF)
+			} else {
+				mappedAddr = uint32(m.numPRGBanks-1)*0x4000 + uint32(addr&0x3FFF)
+			}
+		}
+		flag = true
+	}
+	return
+}
+
+func (m *Mapper001) CpuMapWrite(addr uint16, data uint8) (mappedAddr uint32, flag bool) {
+	if addr < 0x8000 {
+		return
+	}
+
+	// all writes go to the serial port, none of them touches PRG ROM
+	mappedAddr = MappedAddrInternal
+	flag = true
+
+	if data&0x80 != 0 {
+		// reset the shift register and lock PRG ROM at 0xC000 to the last bank
+		m.load = 0x00
+		m.loadCount = 0
+		m.control |= 0x0C
+		return
+	}
+
+	// bits are shifted in from the most significant end, so the first
+	// bit written ends up as bit 0 after five writes
+	m.load >>= 1
+	m.load |= (data & 0x01) << 4
+	m.loadCount++
+
+	if m.loadCount == 5 {
+		switch (addr >> 13) & 0x03 {
+		case 0:
+			// 0x8000 -> 0x9FFF
+			m.control = m.load & 0x1F
+			switch m.control & 0x03 {
+			case 0:
+				m.mirror = MirrorOneScreenLo
+			case 1:
+				m.mirror = MirrorOneScreenHi
+			case 2:
+				m.mirror = MirrorVertical
+			case 3:
+				m.mirror = MirrorHorizontal
+			}
+		case 1:
+			// 0xA000 -> 0xBFFF
+			m.chrBank0 = m.load & 0x1F
+		case 2:
+			// 0xC000 -> 0xDFFF, ignored in 8KB mode
+			m.chrBank1 = m.load & 0x1F
+		case 3:
+			// 0xE000 -> 0xFFFF, bit 4 is the PRG RAM enable
+			m.prgBank = m.load & 0x1F
+		}
+
+		m.load = 0x00
+		m.loadCount = 0
+	}
+
+	return
+}
+
+func (m *Mapper001) PpuMapRead(addr uint16) (mappedAddr uint32, flag bool) {
+	if addr <= 0x1FFF {
+		if m.numCHRBanks == 0 {
+			// CHR RAM is not banked
+			mappedAddr = uint32(addr)
+		} else if m.control&0x10 != 0 {
+			// 4KB mode
+			if addr <= 0x0FFF {
+				mappedAddr = uint32(m.chrBank0)*0x1000 + uint32(addr&0x0FFF)
+			} else {
+				mappedAddr = uint32(m.chrBank1)*0x1000 + uint32(addr&0x0FFF)
+			}
+		} else {
+			// 8KB mode ignores the low bit
+			mappedAddr = uint32(m.chrBank0>>1)*0x2000 + uint32(addr&0x1FFF)
+		}
+		flag = true
+	}
+	return
+}
+
+func (m *Mapper001) PpuMapWrite(addr uint16) (mappedAddr uint32, flag bool) {
+	if addr <= 0x1FFF {
+		if m.numCHRBanks == 0 {
+			// Treat as RAM
+			mappedAddr = uint32(addr)
+			flag = true
+		}
+	}
+	return
+}
+
+func (m *Mapper001) Mirror() (mirror Mirror, flag bool) {
+	return m.mirror, true
+}
+
+func (m *Mapper001) Reset() {
+	m.load = 0x00
+	m.loadCount = 0
+	m.control = 0x1C
+	m.mirror = MirrorOneScreenLo
+
+	m.chrBank0 = 0
+	m.chrBank1 = 0
+	m.prgBank = 0
+}
+
+// mapper001State is the serializable part of Mapper001
+type mapper001State struct {
+	Load, LoadCount, Control uint8
+	CHRBank0, CHRBank1       uint8
+	PRGBank                  uint8
+	Mirror                   Mirror
+}
+
+func (m *Mapper001) Serialize(enc *gob.Encoder) error {
+	return enc.Encode(mapper001State{
+		Load:      m.load,
+		LoadCount: m.loadCount,
+		Control:   m.control,
+		CHRBank0:  m.chrBank0,
+		CHRBank1:  m.chrBank1,
+		PRGBank:   m.prgBank,
+		Mirror:    m.mirror,
+	})
+}
+
+func (m *Mapper001) Deserialize(dec *gob.Decoder) error {
+	var state mapper001State
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	m.load = state.Load
+	m.loadCount = state.LoadCount
+	m.control = state.Control
+	m.chrBank0 = state.CHRBank0
+	m.chrBank1 = state.CHRBank1
+	m.prgBank = state.PRGBank
+	m.mirror = state.Mirror
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mappers
+
+import "testing"
+
+// writeSerial streams the low 5 bits of value into the MMC1 shift
+// register, least significant bit first, the fifth write goes to addr
+func writeSerial(m *Mapper001, addr uint16, value uint8) {
+	for i := 0; i < 5; i++ {
+		m.CpuMapWrite(addr, value>>uint(i)&0x01)
+	}
+}
+
+func TestMapper001ShiftRegister(t *testing.T) {
+	m := NewMapper001(8, 0)
+
+	// the first four writes only fill the shift register
+	for i := 0; i < 4; i++ {
+		if mapped, flag := m.CpuMapWrite(0x8000, 0x00); !flag || mapped != MappedAddrInternal {
+			t.Fatalf("write %v = (%#x, %v), want (%#x, true)", i, mapped, flag, MappedAddrInternal)
+		}
+	}
+	if m.control != 0x1C {
+		t.Errorf("control = %#02x after 4 writes, want unchanged 0x1C", m.control)
+	}
+
+	// a write with bit 7 set discards them
+	m.CpuMapWrite(0x8000, 0x80)
+	if m.loadCount != 0 || m.load != 0 {
+		t.Errorf("shift register = %#02x/%v after reset, want empty", m.load, m.loadCount)
+	}
+
+	writeSerial(m, 0x8000, 0x02)
+	if m.control != 0x02 {
+		t.Errorf("control = %#02x, want 0x02", m.control)
+	}
+	if mirror, _ := m.Mirror(); mirror != MirrorVertical {
+		t.Errorf("mirror = %v, want %v", mirror, MirrorVertical)
+	}
+}
+
+func TestMapper001PRGBanks(t *testing.T) {
+	tests := []struct {
+		name    string
+		control uint8
+		prgBank uint8
+		addr    uint16
+		want    uint32
+	}{
+		{"32KB bank 0", 0x00, 0x00, 0x8000, 0x00000},
+		{"32KB ignores low bit", 0x00, 0x03, 0xC123, 0x08000 + 0x4123},
+		{"16KB fixed first lo", 0x08, 0x01, 0x8000, 0x00000},
+		{"16KB fixed first hi", 0x08, 0x01, 0xC000, 0x04000},
+		{"16KB fixed last lo", 0x0C, 0x01, 0x8000, 0x04000},
+		{"16KB fixed last hi", 0x0C, 0x01, 0xFFFF, 0x1FFFF},
+		{"16KB bank 5", 0x0C, 0x05, 0xA010, 0x14000 + 0x2010},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewMapper001(8, 0)
+			writeSerial(m, 0x8000, test.control)
+			writeSerial(m, 0xE000, test.prgBank)
+
+			if mapped, flag := m.CpuMapRead(test.addr); !flag || mapped != test.want {
+				t.Errorf("CpuMapRead(%#04x) = (%#x, %v), want (%#x, true)", test.addr, mapped, flag, test.want)
+			}
+		})
+	}
+}
+
+func TestMapper001PRGModeChange(t *testing.T) {
+	// the bank register is kept as written, a later mode change moves it
+	m := NewMapper001(8, 0)
+	writeSerial(m, 0xE000, 0x01)
+
+	if mapped, _ := m.CpuMapRead(0x8000); mapped != 0x04000 {
+		t.Errorf("mode 3: 0x8000 -> %#x, want 0x4000", mapped)
+	}
+
+	writeSerial(m, 0x8000, 0x08)
+	if mapped, _ := m.CpuMapRead(0x8000); mapped != 0x00000 {
+		t.Errorf("mode 2: 0x8000 -> %#x, want 0x0000", mapped)
+	}
+	if mapped, _ := m.CpuMapRead(0xC000); mapped != 0x04000 {
+		t.Errorf("mode 2: 0xC000 -> %#x, want 0x4000", mapped)
+	}
+
+	// the reset write goes back to mode 3 with the same bank
+	m.CpuMapWrite(0x8000, 0x80)
+	if mapped, _ := m.CpuMapRead(0x8000); mapped != 0x04000 {
+		t.Errorf("after reset: 0x8000 -> %#x, want 0x4000", mapped)
+	}
+	if mapped, _ := m.CpuMapRead(0xC000); mapped != 0x1C000 {
+		t.Errorf("after reset: 0xC000 -> %#x, want 0x1C000", mapped)
+	}
+}
+
+func TestMapper001CHRBanks(t *testing.T) {
+	tests := []struct {
+		name    string
+		control uint8
+		addr    uint16
+		want    uint32
+	}{
+		{"8KB", 0x00, 0x1234, 0x4000 + 0x1234},
+		{"4KB lo", 0x10, 0x0234, 0x5000 + 0x0234},
+		{"4KB hi", 0x10, 0x1234, 0x7000 + 0x0234},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewMapper001(2, 4)
+			// written before the mode, the banks follow it
+			writeSerial(m, 0xA000, 0x05)
+			writeSerial(m, 0xC000, 0x07)
+			writeSerial(m, 0x8000, test.control)
+
+			if mapped, flag := m.PpuMapRead(test.addr); !flag || mapped != test.want {
+				t.Errorf("PpuMapRead(%#04x) = (%#x, %v), want (%#x, true)", test.addr, mapped, flag, test.want)
+			}
+		})
+	}
+}
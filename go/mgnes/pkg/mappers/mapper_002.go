@@ -0,0 +1,118 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mappers
+
+import "encoding/gob"
+
+// Mapper002 UNROM
+// Any write to 0x8000-0xFFFF selects the 16KB PRG bank mapped at
+// 0x8000-0xBFFF, 0xC000-0xFFFF is fixed to the last bank. CHR is 8KB RAM.
+type Mapper002 struct {
+	numPRGBanks uint8
+	numCHRBanks uint8
+
+	prgBankSelectLo uint8
+	prgBankSelectHi uint8
+}
+
+func NewMapper002(numPRGBanks, numCHRBanks uint8) *Mapper002 {
+	m := &Mapper002{
+		numPRGBanks: numPRGBanks,
+		numCHRBanks: numCHRBanks,
+	}
+	m.Reset()
+	return m
+}
+
+func (m *Mapper002) CpuMapRead(addr uint16) (mappedAddr uint32, flag bool) {
+	// CPU Address Bus          PRG ROM
+	// 0x8000 -> 0xBFFF: Map    selected bank
+	// 0xC000 -> 0xFFFF: Map    last bank
+	if addr >= 0x8000 && addr <= 0xBFFF {
+		mappedAddr = uint32(m.prgBankSelectLo)*0x4000 + uint32(addr&0x3FFF)
+		flag = true
+	} else if addr >= 0xC000 {
+		mappedAddr = uint32(m.prgBankSelectHi)*0x4000 + uint32(addr&0x3FFF)
+		flag = true
+	}
+	return
+}
+
+func (m *Mapper002) CpuMapWrite(addr uint16, data uint8) (mappedAddr uint32, flag bool) {
+	if addr >= 0x8000 {
+		m.prgBankSelectLo = data & 0x0F
+		mappedAddr = MappedAddrInternal
+		flag = true
+	}
+	return
+}
+
+func (m *Mapper002) PpuMapRead(addr uint16) (mappedAddr uint32, flag bool) {
+	// There is no mapping required for PPU
+	// PPU Address Bus          CHR RAM
+	// 0x0000 -> 0x1FFF: Map    0x0000 -> 0x1FFF
+	if addr <= 0x1FFF {
+		mappedAddr = uint32(addr)
+		flag = true
+	}
+	return
+}
+
+func (m *Mapper002) PpuMapWrite(addr uint16) (mappedAddr uint32, flag bool) {
+	if addr <= 0x1FFF {
+		// Treat as RAM
+		mappedAddr = uint32(addr)
+		flag = true
+	}
+	return
+}
+
+func (m *Mapper002) Mirror() (mirror Mirror, flag bool) {
+	return
+}
+
+func (m *Mapper002) Reset() {
+	m.prgBankSelectLo = 0
+	m.prgBankSelectHi = m.numPRGBanks - 1
+}
+
+// mapper002State is the serializable part of Mapper002
+type mapper002State struct {
+	PRGBankSelectLo, PRGBankSelectHi uint8
+}
+
+func (m *Mapper002) Serialize(enc *gob.Encoder) error {
+	return enc.Encode(mapper002State{
+		PRGBankSelectLo: m.prgBankSelectLo,
+		PRGBankSelectHi: m.prgBankSelectHi,
+	})
+}
+
+func (m *Mapper002) Deserialize(dec *gob.Decoder) error {
+	var state mapper002State
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	m.prgBankSelectLo = state.PRGBankSelectLo
+	m.prgBankSelectHi = state.PRGBankSelectHi
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mappers
+
+import "testing"
+
+func TestMapper002BankSwitch(t *testing.T) {
+	tests := []struct {
+		name string
+		bank uint8
+		addr uint16
+		want uint32
+	}{
+		{"bank 3 at 0x8000", 3, 0x8000, 0x3 * 0x4000},
+		{"bank 3 at 0xBFFF", 3, 0xBFFF, 0x3*0x4000 + 0x3FFF},
+		{"last bank at 0xC000", 3, 0xC000, 0x7 * 0x4000},
+		{"last bank at 0xFFFF", 0, 0xFFFF, 0x7*0x4000 + 0x3FFF},
+		{"high bits ignored", 0xF5, 0x8001, 0x5*0x4000 + 0x0001},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewMapper002(8, 0)
+			if mapped, flag := m.CpuMapWrite(0x8000, test.bank); !flag || mapped != MappedAddrInternal {
+				t.Fatalf("CpuMapWrite = (%#x, %v), want (%#x, true)", mapped, flag, MappedAddrInternal)
+			}
+
+			if mapped, flag := m.CpuMapRead(test.addr); !flag || mapped != test.want {
+				t.Errorf("CpuMapRead(%#04x) = (%#x, %v), want (%#x, true)", test.addr, mapped, flag, test.want)
+			}
+		})
+	}
+}
+
+func TestMapper002CHRRAM(t *testing.T) {
+	m := NewMapper002(8, 0)
+	m.CpuMapWrite(0x8000, 3)
+
+	for _, addr := range []uint16{0x0000, 0x1234, 0x1FFF} {
+		if mapped, flag := m.PpuMapRead(addr); !flag || mapped != uint32(addr) {
+			t.Errorf("PpuMapRead(%#04x) = (%#x, %v), want (%#x, true)", addr, mapped, flag, addr)
+		}
+		if mapped, flag := m.PpuMapWrite(addr); !flag || mapped != uint32(addr) {
+			t.Errorf("PpuMapWrite(%#04x) = (%#x, %v), want (%#x, true)", addr, mapped, flag, addr)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mappers
+
+import "encoding/gob"
+
+// Mapper003 CNROM
+// PRG is mapped the same way as Mapper000, any write to 0x8000-0xFFFF
+// selects the 8KB CHR bank visible to the PPU.
+type Mapper003 struct {
+	numPRGBanks uint8
+	numCHRBanks uint8
+
+	chrBankSelect uint8
+}
+
+func NewMapper003(numPRGBanks, numCHRBanks uint8) *Mapper003 {
+	return &Mapper003{
+		numPRGBanks: numPRGBanks,
+		numCHRBanks: numCHRBanks,
+	}
+}
+
+func (m *Mapper003) CpuMapRead(addr uint16) (mappedAddr uint32, flag bool) {
+	// if PRGROM is 16KB
+	//     CPU Address Bus          PRG ROM
+	//     0x8000 -> 0xBFFF: Map    0x0000 -> 0x3FFF
+	//     0xC000 -> 0xFFFF: Mirror 0x0000 -> 0x3FFF
+	// if PRGROM is 32KB
+	//     CPU Address Bus          PRG ROM
+	//     0x8000 -> 0xFFFF: Map    0x0000 -> 0x7FFF
+	if addr >= 0x8000 {
+		if m.numPRGBanks > 1 {
+			mappedAddr = uint32(addr & 0x7FFF)
+		} else {
+			mappedAddr = uint32(addr & 0x3FFF)
+		}
+		flag = true
+	}
+	return
+}
+
+func (m *Mapper003) CpuMapWrite(addr uint16, data uint8) (mappedAddr uint32, flag bool) {
+	if addr >= 0x8000 {
+		m.chrBankSelect = data & 0x03
+		mappedAddr = MappedAddrInternal
+		flag = true
+	}
+	return
+}
+
+func (m *Mapper003) PpuMapRead(addr uint16) (mappedAddr uint32, flag bool) {
+	// PPU Address Bus          CHR ROM
+	// 0x0000 -> 0x1FFF: Map    selected bank
+	if addr <= 0x1FFF {
+		mappedAddr = uint32(m.chrBankSelect)*0x2000 + uint32(addr)
+		flag = true
+	}
+	return
+}
+
+func (m *Mapper003) PpuMapWrite(addr uint16) (mappedAddr uint32, flag bool) {
+	return
+}
+
+func (m *Mapper003) Mirror() (mirror Mirror, flag bool) {
+	return
+}
+
+func (m *Mapper003) Reset() {
+	m.chrBankSelect = 0
+}
+
+// mapper003State is the serializable part of Mapper003
+type mapper003State struct {
+	CHRBankSelect uint8
+}
+
+func (m *Mapper003) Serialize(enc *gob.Encoder) error {
+	return enc.Encode(mapper003State{CHRBankSelect: m.chrBankSelect})
+}
+
+func (m *Mapper003) Deserialize(dec *gob.Decoder) error {
+	var state mapper003State
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	m.chrBankSelect = state.CHRBankSelect
+	return nil
+}
@@ -0,0 +1,73 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mappers
+
+import "testing"
+
+func TestMapper003CHRBankSwitch(t *testing.T) {
+	tests := []struct {
+		name string
+		bank uint8
+		addr uint16
+		want uint32
+	}{
+		{"bank 0", 0, 0x0123, 0x0123},
+		{"bank 2", 2, 0x0000, 0x4000},
+		{"bank 2 end", 2, 0x1FFF, 0x5FFF},
+		{"bank 3", 3, 0x1000, 0x7000},
+		{"high bits ignored", 0xFE, 0x0010, 0x4010},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewMapper003(2, 4)
+			m.CpuMapWrite(0x8000, test.bank)
+
+			if mapped, flag := m.PpuMapRead(test.addr); !flag || mapped != test.want {
+				t.Errorf("PpuMapRead(%#04x) = (%#x, %v), want (%#x, true)", test.addr, mapped, flag, test.want)
+			}
+		})
+	}
+}
+
+func TestMapper003PRGUnaffected(t *testing.T) {
+	tests := []struct {
+		name        string
+		numPRGBanks uint8
+		addr        uint16
+		want        uint32
+	}{
+		{"16KB at 0x8000", 1, 0x8000, 0x0000},
+		{"16KB mirrored at 0xC000", 1, 0xC123, 0x0123},
+		{"32KB at 0xC000", 2, 0xC123, 0x4123},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewMapper003(test.numPRGBanks, 4)
+			m.CpuMapWrite(0x8000, 2)
+
+			if mapped, flag := m.CpuMapRead(test.addr); !flag || mapped != test.want {
+				t.Errorf("CpuMapRead(%#04x) = (%#x, %v), want (%#x, true)", test.addr, mapped, flag, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mappers
+
+import "encoding/gob"
+
+// Mapper004 MMC3
+// PRG is switched in 8KB banks, CHR in 1KB and 2KB banks. Registers are
+// selected by address range and whether the address is even or odd:
+//
+//	0x8000 -> 0x9FFF: Bank select (even), bank data (odd)
+//	0xA000 -> 0xBFFF: Mirroring (even), PRG RAM protect (odd)
+//	0xC000 -> 0xDFFF: IRQ latch (even), IRQ reload (odd)
+//	0xE000 -> 0xFFFF: IRQ disable (even), IRQ enable (odd)
+//
+// The scanline counter is clocked by rising edges of PPU address line A12,
+// which happen once per scanline when background and sprites use different
+// pattern tables.
+type Mapper004 struct {
+	numPRGBanks uint8
+	numCHRBanks uint8
+
+	// Bank select
+	// --------
+	// 76543210
+	// CP---RRR
+	// ||   |||
+	// ||   +++- Bank register to update on next write to bank data
+	// |+------- PRG bank mode. 0 = 0x8000 swappable, 0xC000 fixed to second-last bank;
+	// |                        1 = 0xC000 swappable, 0x8000 fixed to second-last bank
+	// +-------- CHR A12 inversion. 0 = 2KB banks at 0x0000, 1 = 2KB banks at 0x1000
+	bankSelect uint8
+	registers  [8]uint8
+
+	// offsets into PRG and CHR memory of the 8KB PRG and 1KB CHR windows
+	prgBanks [4]uint32
+	chrBanks [8]uint32
+
+	mirror        Mirror
+	prgRAMProtect uint8
+
+	irqLatch   uint8
+	irqCounter uint8
+	irqReload  bool
+	irqEnabled bool
+	irqActive  bool
+
+	// consecutive PPU accesses with A12 low, edges after a short low
+	// period are filtered like the M2 based filter of the real chip
+	a12Low uint8
+}
+
+// number of A12 low PPU accesses before a rising edge clocks the counter
+const a12Filter = 3
+
+func NewMapper004(numPRGBanks, numCHRBanks uint8) *Mapper004 {
+	m := &Mapper004{
+		numPRGBanks: numPRGBanks,
+		numCHRBanks: numCHRBanks,
+	}
+	m.Reset()
+	return m
+}
+
+func (m *Mapper004) CpuMapRead(addr uint16) (mappedAddr uint32, flag bool) {
+	if addr >= 0x8000 {
+		mappedAddr = m.prgBanks[(addr>>13)&0x03] + uint32(addr&0x1FFF)
+		flag = true
+	}
+	return
+}
+
+func (m *Mapper004) CpuMapWrite(addr uint16, data uint8) (mappedAddr uint32, flag bool) {
+	if addr < 0x8000 {
+		return
+	}
+
+	// all writes go to registers, none of them touches PRG ROM
+	mappedAddr = MappedAddrInternal
+	flag = true
+
+	even := addr&0x0001 == 0
+	switch {
+	case addr <= 0x9FFF && even:
+		m.bankSelect = data
+		m.updateBanks()
+	case addr <= 0x9FFF:
+		m.registers[m.bankSelect&0x07] = data
+		m.updateBanks()
+	case addr <= 0xBFFF && even:
+		if data&0x01 != 0 {
+			m.mirror = MirrorHorizontal
+		} else {
+			m.mirror = MirrorVertical
+		}
+	case addr <= 0xBFFF:
+		m.prgRAMProtect = data
+	case addr <= 0xDFFF && even:
+		m.irqLatch = data
+	case addr <= 0xDFFF:
+		// the counter is reloaded on the next clock
+		m.irqCounter = 0
+		m.irqReload = true
+	case even:
+		// disabling also acknowledges a pending interrupt
+		m.irqEnabled = false
+		m.irqActive = false
+	default:
+		m.irqEnabled = true
+	}
+
+	return
+}
+
+func (m *Mapper004) PpuMapRead(addr uint16) (mappedAddr uint32, flag bool) {
+	m.watchA12(addr)
+	if addr <= 0x1FFF {
+		mappedAddr = m.chrBanks[addr>>10] + uint32(addr&0x03FF)
+		flag = true
+	}
+	return
+}
+
+func (m *Mapper004) PpuMapWrite(addr uint16) (mappedAddr uint32, flag bool) {
+	m.watchA12(addr)
+	if addr <= 0x1FFF && m.numCHRBanks == 0 {
+		// Treat as RAM
+		mappedAddr = m.chrBanks[addr>>10] + uint32(addr&0x03FF)
+		flag = true
+	}
+	return
+}
+
+func (m *Mapper004) Mirror() (mirror Mirror, flag bool) {
+	return m.mirror, true
+}
+
+func (m *Mapper004) Reset() {
+	m.bankSelect = 0
+	m.registers = [8]uint8{0, 2, 4, 5, 6, 7, 0, 1}
+	m.mirror = MirrorVertical
+	m.prgRAMProtect = 0
+
+	m.irqLatch = 0
+	m.irqCounter = 0
+	m.irqReload = false
+	m.irqEnabled = false
+	m.irqActive = false
+	m.a12Low = 0
+
+	m.updateBanks()
+}
+
+// IRQState returns true while the scanline counter asserts an interrupt
+func (m *Mapper004) IRQState() bool {
+	return m.irqActive
+}
+
+// ClearIRQ acknowledges a pending interrupt, the counter keeps running
+func (m *Mapper004) ClearIRQ() {
+	m.irqActive = false
+}
+
+// watchA12 clocks the scanline counter on a filtered rising edge of A12
+func (m *Mapper004) watchA12(addr uint16) {
+	if addr&0x1000 == 0 {
+		if m.a12Low < a12Filter {
+			m.a12Low++
+		}
+		return
+	}
+
+	if m.a12Low >= a12Filter {
+		m.clockCounter()
+	}
+	m.a12Low = 0
+}
+
+// clockCounter reloads the counter when it is zero or a reload is
+// pending, decrements it otherwise. An interrupt fires when it is zero
+// after that and interrupts are enabled
+func (m *Mapper004) clockCounter() {
+	if m.irqCounter == 0 || m.irqReload {
+		m.irqCounter = m.irqLatch
+		m.irqReload = false
+	} else {
+		m.irqCounter--
+	}
+
+	if m.irqCounter == 0 && m.irqEnabled {
+		m.irqActive = true
+	}
+}
+
+// updateBanks recalculates the offsets of all PRG and CHR windows
+func (m *Mapper004) updateBanks() {
+	prgCount := uint32(m.numPRGBanks) * 2
+	if prgCount == 0 {
+		prgCount = 1
+	}
+	prg := func(bank uint32) uint32 {
+		return (bank % prgCount) * 0x2000
+	}
+
+	// CPU Address Bus    PRG mode 0       PRG mode 1
+	// 0x8000 -> 0x9FFF:  R6               second-last bank
+	// 0xA000 -> 0xBFFF:  R7               R7
+	// 0xC000 -> 0xDFFF:  second-last      R6
+	// 0xE000 -> 0xFFFF:  last bank        last bank
+	r6 := uint32(m.registers[6] & 0x3F)
+	r7 := uint32(m.registers[7] & 0x3F)
+	if m.bankSelect&0x40 == 0 {
+		m.prgBanks[0] = prg(r6)
+		m.prgBanks[2] = prg(prgCount - 2)
+	} else {
+		m.prgBanks[0] = prg(prgCount - 2)
+		m.prgBanks[2] = prg(r6)
+	}
+	m.prgBanks[1] = prg(r7)
+	m.prgBanks[3] = prg(prgCount - 1)
+
+	// CHR RAM is 8KB, banked the same way as ROM
+	chrCount := uint32(m.numCHRBanks) * 8
+	if chrCount == 0 {
+		chrCount = 8
+	}
+	chr := func(bank uint32) uint32 {
+		return (bank % chrCount) * 0x0400
+	}
+
+	// PPU Address Bus    CHR A12 inversion 0    1
+	// 0x0000 -> 0x07FF:  R0 2KB                 R2, R3 1KB
+	// 0x0800 -> 0x0FFF:  R1 2KB                 R4, R5 1KB
+	// 0x1000 -> 0x17FF:  R2, R3 1KB             R0 2KB
+	// 0x1800 -> 0x1FFF:  R4, R5 1KB             R1 2KB
+	banks := [8]uint32{
+		chr(uint32(m.registers[0] & 0xFE)),
+		chr(uint32(m.registers[0] | 0x01)),
+		chr(uint32(m.registers[1] & 0xFE)),
+		chr(uint32(m.registers[1] | 0x01)),
+		chr(uint32(m.registers[2])),
+		chr(uint32(m.registers[3])),
+		chr(uint32(m.registers[4])),
+		chr(uint32(m.registers[5])),
+	}
+	if m.bankSelect&0x80 == 0 {
+		m.chrBanks = banks
+	} else {
+		copy(m.chrBanks[:4], banks[4:])
+		copy(m.chrBanks[4:], banks[:4])
+	}
+}
+
+// mapper004State is the serializable part of Mapper004
+type mapper004State struct {
+	BankSelect    uint8
+	Registers     [8]uint8
+	Mirror        Mirror
+	PRGRAMProtect uint8
+
+	IRQLatch   uint8
+	IRQCounter uint8
+	IRQReload  bool
+	IRQEnabled bool
+	IRQActive  bool
+	A12Low     uint8
+}
+
+func (m *Mapper004) Serialize(enc *gob.Encoder) error {
+	return enc.Encode(mapper004State{
+		BankSelect:    m.bankSelect,
+		Registers:     m.registers,
+		Mirror:        m.mirror,
+		PRGRAMProtect: m.prgRAMProtect,
+		IRQLatch:      m.irqLatch,
+		IRQCounter:    m.irqCounter,
+		IRQReload:     m.irqReload,
+		IRQEnabled:    m.irqEnabled,
+		IRQActive:     m.irqActive,
+		A12Low:        m.a12Low,
+	})
+}
+
+func (m *Mapper004) Deserialize(dec *gob.Decoder) error {
+	var state mapper004State
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	m.bankSelect = state.BankSelect
+	m.registers = state.Registers
+	m.mirror = state.Mirror
+	m.prgRAMProtect = state.PRGRAMProtect
+	m.irqLatch = state.IRQLatch
+	m.irqCounter = state.IRQCounter
+	m.irqReload = state.IRQReload
+	m.irqEnabled = state.IRQEnabled
+	m.irqActive = state.IRQActive
+	m.a12Low = state.A12Low
+	m.updateBanks()
+	return nil
+}
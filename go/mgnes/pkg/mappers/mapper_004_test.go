@@ -0,0 +1,179 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mappers
+
+import "testing"
+
+// setBank writes value to bank register r, keeping the mode bits of
+// bankSelect
+func setBank(m *Mapper004, bankSelect, r, value uint8) {
+	m.CpuMapWrite(0x8000, bankSelect|r)
+	m.CpuMapWrite(0x8001, value)
+}
+
+func TestMapper004PRGBanks(t *testing.T) {
+	tests := []struct {
+		name string
+		mode uint8
+		addr uint16
+		want uint32
+	}{
+		{"mode 0 R6 at 0x8000", 0x00, 0x8000, 5 * 0x2000},
+		{"mode 0 R7 at 0xA000", 0x00, 0xA001, 9*0x2000 + 0x0001},
+		{"mode 0 second-last at 0xC000", 0x00, 0xC000, 30 * 0x2000},
+		{"mode 0 last at 0xE000", 0x00, 0xFFFF, 31*0x2000 + 0x1FFF},
+		{"mode 1 second-last at 0x8000", 0x40, 0x8000, 30 * 0x2000},
+		{"mode 1 R7 at 0xA000", 0x40, 0xA000, 9 * 0x2000},
+		{"mode 1 R6 at 0xC000", 0x40, 0xC123, 5*0x2000 + 0x0123},
+		{"mode 1 last at 0xE000", 0x40, 0xE000, 31 * 0x2000},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// 16 banks of 16KB are 32 banks of 8KB
+			m := NewMapper004(16, 16)
+			setBank(m, test.mode, 6, 5)
+			setBank(m, test.mode, 7, 9)
+
+			if mapped, flag := m.CpuMapRead(test.addr); !flag || mapped != test.want {
+				t.Errorf("CpuMapRead(%#04x) = (%#x, %v), want (%#x, true)", test.addr, mapped, flag, test.want)
+			}
+		})
+	}
+}
+
+func TestMapper004CHRBanks(t *testing.T) {
+	// R0 and R1 select 2KB banks, the low bit is ignored
+	registers := [6]uint8{0x11, 0x20, 0x30, 0x31, 0x32, 0x33}
+	normal := [8]uint32{0x10, 0x11, 0x20, 0x21, 0x30, 0x31, 0x32, 0x33}
+	inverted := [8]uint32{0x30, 0x31, 0x32, 0x33, 0x10, 0x11, 0x20, 0x21}
+
+	tests := []struct {
+		name  string
+		mode  uint8
+		banks [8]uint32
+	}{
+		{"A12 inversion 0", 0x00, normal},
+		{"A12 inversion 1", 0x80, inverted},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// 16 banks of 8KB are 128 banks of 1KB
+			m := NewMapper004(16, 16)
+			for r, value := range registers {
+				setBank(m, test.mode, uint8(r), value)
+			}
+
+			for window, bank := range test.banks {
+				addr := uint16(window)*0x0400 + 0x0123
+				want := bank*0x0400 + 0x0123
+				if mapped, flag := m.PpuMapRead(addr); !flag || mapped != want {
+					t.Errorf("PpuMapRead(%#04x) = (%#x, %v), want (%#x, true)", addr, mapped, flag, want)
+				}
+			}
+		})
+	}
+}
+
+// scanline drives the PPU accesses of one rendered scanline with the
+// background at 0x0000 and sprites at 0x1000: A12 stays low for the
+// background fetches and rises once for the sprite fetches
+func scanline(m *Mapper004) {
+	for i := 0; i < 8; i++ {
+		m.PpuMapRead(0x0000)
+	}
+	for i := 0; i < 8; i++ {
+		m.PpuMapRead(0x1000)
+	}
+}
+
+func TestMapper004IRQCountdown(t *testing.T) {
+	m := NewMapper004(16, 16)
+	m.CpuMapWrite(0xC000, 3) // latch
+	m.CpuMapWrite(0xC001, 0) // reload
+	m.CpuMapWrite(0xE001, 0) // enable
+
+	// the first edge reloads the counter with 3, the next three count it
+	// down to 0 and fire the interrupt
+	for line := 1; line <= 3; line++ {
+		scanline(m)
+		if m.IRQState() {
+			t.Fatalf("IRQ asserted after %v scanlines, want 4", line)
+		}
+	}
+	scanline(m)
+	if !m.IRQState() {
+		t.Fatalf("IRQ not asserted after 4 scanlines")
+	}
+
+	// the interrupt stays asserted until acknowledged
+	m.PpuMapRead(0x1000)
+	if !m.IRQState() {
+		t.Errorf("IRQ released without an acknowledge")
+	}
+	m.CpuMapWrite(0xE000, 0)
+	if m.IRQState() {
+		t.Errorf("IRQ still asserted after disabling")
+	}
+
+	// the counter keeps running while disabled, it reloads from 0 and
+	// counts down again without asserting
+	for line := 1; line <= 4; line++ {
+		scanline(m)
+	}
+	if m.IRQState() {
+		t.Errorf("IRQ asserted while disabled")
+	}
+	if m.irqCounter != 0 {
+		t.Errorf("counter = %v after a second countdown, want 0", m.irqCounter)
+	}
+}
+
+func TestMapper004A12Filter(t *testing.T) {
+	tests := []struct {
+		name  string
+		low   int // A12 low accesses before the rising edge
+		fires bool
+	}{
+		{"short low period", a12Filter - 1, false},
+		{"filter length", a12Filter, true},
+		{"long low period", 20, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// with a latch of 0 every clock fires the interrupt
+			m := NewMapper004(16, 16)
+			m.CpuMapWrite(0xE001, 0)
+			m.PpuMapRead(0x1000)
+
+			for i := 0; i < test.low; i++ {
+				m.PpuMapRead(0x0FFF)
+			}
+			m.PpuMapRead(0x1FFF)
+
+			if got := m.IRQState(); got != test.fires {
+				t.Errorf("IRQ = %v after %v low accesses, want %v", got, test.low, test.fires)
+			}
+		})
+	}
+}
@@ -20,6 +20,12 @@
 
 package memory
 
+import (
+	"io"
+	"math/rand"
+	"mgnes/pkg/log"
+)
+
 const (
 	// Capacity the size of memory that a 6502 cpu can address
 	Capacity = 65536
@@ -28,6 +34,16 @@ const (
 	CpuMemoryCapacity = 2048
 )
 
+// randomSource provides the power-on content of RAM, nil means zero
+var randomSource io.Reader
+
+// SetRandomSource sets the reader PowerUp takes the power-on content of RAM
+// from, so tests of programs reading uninitialized RAM can inject a known
+// byte stream. nil restores the default of zero filled RAM
+func SetRandomSource(r io.Reader) {
+	randomSource = r
+}
+
 // Memory interface definition
 type Memory interface {
 	Reset()
@@ -35,53 +51,134 @@ type Memory interface {
 	Write(addr uint16, value uint8) (oldValue uint8)
 }
 
-// PlainMemory 64KB of plain bytes
-type PlainMemory [Capacity]uint8
+// Read16 reads a little endian word at addr, the high byte comes from
+// addr+1 which wraps from 0xFFFF to 0x0000
+func Read16(m Memory, addr uint16) uint16 {
+	lo := uint16(m.Read(addr))
+	hi := uint16(m.Read(addr + 1))
+	return hi<<8 | lo
+}
 
-// NewPlainMemory create and returns a plain memory reference
-func NewPlainMemory() *PlainMemory {
-	mem := &PlainMemory{}
+// Read16PageWrap reads a little endian word at addr without crossing a
+// page, the high byte of a word at 0x??FF comes from 0x??00. This is how
+// the 6502 reads zero page pointers, a pointer at 0x00FF takes its high
+// byte from 0x0000, and the indirect JMP target
+func Read16PageWrap(m Memory, addr uint16) uint16 {
+	lo := uint16(m.Read(addr))
+	hi := uint16(m.Read(addr&0xFF00 | (addr+1)&0x00FF))
+	return hi<<8 | lo
+}
+
+// Write16 writes v as a little endian word at addr, the high byte goes to
+// addr+1 which wraps from 0xFFFF to 0x0000
+func Write16(m Memory, addr uint16, v uint16) {
+	m.Write(addr, uint8(v))
+	m.Write(addr+1, uint8(v>>8))
+}
+
+// MirroredRAM is RAM of a power of two size repeated through the 64KB
+// address space, only the low address bits select a byte
+type MirroredRAM struct {
+	data []uint8
+	mask uint16
+	// value of every byte after Reset
+	resetValue uint8
+}
+
+// NewMirroredRAM creates and returns a RAM reference of size bytes, 2048
+// mirrors the RAM every 0x0800 bytes, 65536 is not mirrored at all. It
+// returns nil if size is not a power of two up to 65536
+func NewMirroredRAM(size int, resetValue uint8) *MirroredRAM {
+	if size <= 0 || size > Capacity || size&(size-1) != 0 {
+		log.L("invalid memory size")
+		return nil
+	}
+	mem := &MirroredRAM{
+		data:       make([]uint8, size),
+		mask:       uint16(size - 1),
+		resetValue: resetValue,
+	}
 	mem.Reset()
 	return mem
 }
 
-func (m *PlainMemory) Reset() {
-	for i := 0; i < len(m); i++ {
-		m[i] = 0xFF
-	}
+// PlainMemory 64KB of plain bytes
+type PlainMemory = MirroredRAM
+
+// NewPlainMemory create and returns a plain memory reference, it resets
+// to 0xFF
+func NewPlainMemory() *PlainMemory {
+	return NewMirroredRAM(Capacity, 0xFF)
 }
 
-func (m *PlainMemory) Read(addr uint16) (value uint8) {
-	return m[int(addr)%Capacity]
+// CpuMemory is the 2KB of NES work RAM, mirrored up to 0x1FFF
+type CpuMemory = MirroredRAM
+
+// NewCpuMemory creates and returns a NES work RAM reference, it resets to 0
+func NewCpuMemory() *CpuMemory {
+	return NewMirroredRAM(CpuMemoryCapacity, 0)
 }
 
-func (m *PlainMemory) Write(addr uint16, value uint8) (oldValue uint8) {
-	oldValue = m[int(addr)%Capacity]
-	m[int(addr)%Capacity] = value
+func (m *MirroredRAM) Reset() {
+	for i := range m.data {
+		m.data[i] = m.resetValue
+	}
+}
 
-	return
+// ResetPattern fills the memory by repeating pattern, zero if it is empty.
+// Real consoles power up with RAM in a semi-random state, which some games
+// seed their random number generator from
+func (m *MirroredRAM) ResetPattern(pattern []uint8) {
+	fill(m.data, pattern)
 }
 
-type CpuMemory [CpuMemoryCapacity]byte
+// ResetRandom fills the memory with pseudo-random bytes, the same seed
+// always gives the same content
+func (m *MirroredRAM) ResetRandom(seed int64) {
+	fillRandom(m.data, seed)
+}
 
-func NewCpuMemory() *CpuMemory {
-	mem := &CpuMemory{}
-	mem.Reset()
-	return mem
+// PowerUp fills the memory from the source set by SetRandomSource, bytes
+// the source does not provide are zero
+func (m *MirroredRAM) PowerUp() {
+	PowerUpFill(m.data)
 }
 
-func (m *CpuMemory) Reset() {
-	for i := 0; i < len(m); i++ {
-		m[i] = 0
+// PowerUpFill fills data like PowerUp, for RAM kept outside of a Memory
+func PowerUpFill(data []uint8) {
+	n := 0
+	if randomSource != nil {
+		n, _ = io.ReadFull(randomSource, data)
 	}
+	fill(data[n:], nil)
+}
+
+// Size returns the number of bytes before the memory repeats
+func (m *MirroredRAM) Size() int {
+	return len(m.data)
+}
+
+func (m *MirroredRAM) Read(addr uint16) (value uint8) {
+	return m.data[addr&m.mask]
 }
 
-func (m *CpuMemory) Read(addr uint16) (value uint8) {
-	return m[addr&0x07FF]
+func (m *MirroredRAM) Write(addr uint16, value uint8) (oldValue uint8) {
+	oldValue = m.data[addr&m.mask]
+	m.data[addr&m.mask] = value
+	return
+}
+
+func fill(mem []uint8, pattern []uint8) {
+	for i := range mem {
+		if len(pattern) == 0 {
+			mem[i] = 0
+		} else {
+			mem[i] = pattern[i%len(pattern)]
+		}
+	}
 }
 
-func (m *CpuMemory) Write(addr uint16, value uint8) (oldValue uint8) {
-	oldValue = m[addr&0x07FF]
-	m[addr&0x07FF] = value
-	return oldValue
+func fillRandom(mem []uint8, seed int64) {
+	r := rand.New(rand.NewSource(seed))
+	r.Read(mem)
 }
@@ -0,0 +1,234 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package memory
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMirroredRAM2KB(t *testing.T) {
+	mem := NewCpuMemory()
+	if mem.Size() != CpuMemoryCapacity {
+		t.Fatalf("Size = %v, want %v", mem.Size(), CpuMemoryCapacity)
+	}
+
+	tests := []struct {
+		name  string
+		write uint16
+	}{
+		{"first byte", 0x0000},
+		{"through mirror 1", 0x0801},
+		{"through mirror 2", 0x1234},
+		{"last byte of the last mirror", 0x1FFF},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mem.Reset()
+			mem.Write(test.write, 0xA5)
+
+			// the same byte shows up in all four mirrors of 0x0000-0x1FFF
+			base := test.write & 0x07FF
+			for mirror := uint16(0); mirror < 4; mirror++ {
+				addr := base + mirror*0x0800
+				if got := mem.Read(addr); got != 0xA5 {
+					t.Errorf("Read(%#04x) = %#02x, want 0xa5", addr, got)
+				}
+			}
+			// and nowhere else
+			for addr := uint16(0); addr < 0x0800; addr++ {
+				if addr != base && mem.Read(addr) != 0 {
+					t.Fatalf("Read(%#04x) = %#02x, want 0", addr, mem.Read(addr))
+				}
+			}
+		})
+	}
+}
+
+func TestMirroredRAM64KB(t *testing.T) {
+	mem := NewPlainMemory()
+	if mem.Size() != Capacity {
+		t.Fatalf("Size = %v, want %v", mem.Size(), Capacity)
+	}
+
+	// every address holds its own byte
+	for addr := 0; addr < Capacity; addr++ {
+		if got := mem.Read(uint16(addr)); got != 0xFF {
+			t.Fatalf("Read(%#04x) = %#02x after reset, want 0xff", addr, got)
+		}
+		mem.Write(uint16(addr), uint8(addr^addr>>8))
+	}
+	for addr := 0; addr < Capacity; addr++ {
+		if got, want := mem.Read(uint16(addr)), uint8(addr^addr>>8); got != want {
+			t.Fatalf("Read(%#04x) = %#02x, want %#02x", addr, got, want)
+		}
+	}
+
+	if old := mem.Write(0x0800, 0x42); old != 0x08 {
+		t.Errorf("Write returned old value %#02x, want 0x08", old)
+	}
+	if got := mem.Read(0x0000); got != 0x00 {
+		t.Errorf("Read(0x0000) = %#02x after writing 0x0800, want 0x00", got)
+	}
+}
+
+func TestNewMirroredRAMSize(t *testing.T) {
+	for _, size := range []int{0, -1, 3000, Capacity * 2} {
+		if mem := NewMirroredRAM(size, 0); mem != nil {
+			t.Errorf("NewMirroredRAM(%v) is not nil", size)
+		}
+	}
+}
+
+func TestSetRandomSource(t *testing.T) {
+	t.Cleanup(func() { SetRandomSource(nil) })
+
+	stream := make([]byte, CpuMemoryCapacity)
+	for i := range stream {
+		stream[i] = uint8(i*31 + 7)
+	}
+
+	tests := []struct {
+		name   string
+		source []byte
+		want   func(i int) uint8
+	}{
+		{"full stream", stream, func(i int) uint8 { return stream[i] }},
+		{"short stream, rest is zero", stream[:100], func(i int) uint8 {
+			if i < 100 {
+				return stream[i]
+			}
+			return 0
+		}},
+		{"no source", nil, func(i int) uint8 { return 0 }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetRandomSource(nil)
+			if test.source != nil {
+				SetRandomSource(bytes.NewReader(test.source))
+			}
+
+			mem := NewCpuMemory()
+			mem.Write(0x0000, 0xAA)
+			mem.PowerUp()
+
+			for i := 0; i < CpuMemoryCapacity; i++ {
+				if got, want := mem.Read(uint16(i)), test.want(i); got != want {
+					t.Fatalf("Read(%#04x) = %#02x after power up, want %#02x", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestResetPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern []uint8
+		want    func(i int) uint8
+	}{
+		{"two bytes", []uint8{0x00, 0xFF}, func(i int) uint8 { return uint8(i&1) * 0xFF }},
+		{"does not divide the size", []uint8{1, 2, 3}, func(i int) uint8 { return uint8(i%3 + 1) }},
+		{"empty is zero", nil, func(i int) uint8 { return 0 }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mem := NewCpuMemory()
+			mem.Write(0x0000, 0xAA)
+			mem.ResetPattern(test.pattern)
+
+			for i := 0; i < CpuMemoryCapacity; i++ {
+				if got, want := mem.Read(uint16(i)), test.want(i); got != want {
+					t.Fatalf("Read(%#04x) = %#02x, want %#02x", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestResetRandom(t *testing.T) {
+	dump := func(mem *CpuMemory) []uint8 {
+		data := make([]uint8, mem.Size())
+		for i := range data {
+			data[i] = mem.Read(uint16(i))
+		}
+		return data
+	}
+
+	a, b := NewCpuMemory(), NewCpuMemory()
+	a.ResetRandom(42)
+	b.Write(0x0123, 0x55)
+	b.ResetRandom(42)
+	if !bytes.Equal(dump(a), dump(b)) {
+		t.Errorf("the same seed gave different content")
+	}
+
+	b.ResetRandom(43)
+	if bytes.Equal(dump(a), dump(b)) {
+		t.Errorf("seeds 42 and 43 gave the same content")
+	}
+
+	// not left zero filled
+	if bytes.Equal(dump(a), make([]uint8, a.Size())) {
+		t.Errorf("ResetRandom left the memory zero filled")
+	}
+}
+
+func TestWords(t *testing.T) {
+	mem := NewPlainMemory()
+	mem.Write(0x1234, 0xCD)
+	mem.Write(0x1235, 0xAB)
+	mem.Write(0x00FF, 0x34)
+	mem.Write(0x0000, 0x12)
+	mem.Write(0x0100, 0x99)
+	mem.Write(0xFFFF, 0x78)
+
+	tests := []struct {
+		name string
+		read func(Memory, uint16) uint16
+		addr uint16
+		want uint16
+	}{
+		{"Read16 little endian", Read16, 0x1234, 0xABCD},
+		{"Read16 crosses the page", Read16, 0x00FF, 0x9934},
+		{"Read16 wraps at 0xFFFF", Read16, 0xFFFF, 0x1278},
+		{"Read16PageWrap little endian", Read16PageWrap, 0x1234, 0xABCD},
+		{"Read16PageWrap zero page pointer", Read16PageWrap, 0x00FF, 0x1234},
+	}
+	for _, test := range tests {
+		if got := test.read(mem, test.addr); got != test.want {
+			t.Errorf("%v: got %#04x, want %#04x", test.name, got, test.want)
+		}
+	}
+
+	Write16(mem, 0x2000, 0xBEEF)
+	if lo, hi := mem.Read(0x2000), mem.Read(0x2001); lo != 0xEF || hi != 0xBE {
+		t.Errorf("Write16 stored %#02x, %#02x, want 0xef, 0xbe", lo, hi)
+	}
+	Write16(mem, 0xFFFF, 0x5678)
+	if lo, hi := mem.Read(0xFFFF), mem.Read(0x0000); lo != 0x78 || hi != 0x56 {
+		t.Errorf("Write16 at 0xFFFF stored %#02x, %#02x, want 0x78, 0x56", lo, hi)
+	}
+}
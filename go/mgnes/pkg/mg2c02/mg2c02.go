@@ -21,7 +21,56 @@
 package mg2c02
 
 import (
+	"image"
+	"image/color"
 	"mgnes/pkg/cartridge"
+	"mgnes/pkg/chr"
+	"mgnes/pkg/ines"
+)
+
+const (
+	// a scanline takes 341 PPU clocks
+	cyclesPerScanline = 341
+	// scanlines per frame including the pre-render line
+	scanlinesNTSC = 262
+	scanlinesPAL  = 312
+
+	// ScreenWidth width of the picture in pixels
+	ScreenWidth = 256
+	// ScreenHeight height of the picture in pixels, one per visible scanline
+	ScreenHeight = 240
+
+	// the vertical blank starts on the second clock of scanline 241 and
+	// ends on the second clock of the pre-render line
+	vblankScanline = 241
+	vblankCycle    = 1
+)
+
+const (
+	// PPUCTRL flags
+	ctrlIncrementMode     uint8 = 0x04
+	ctrlPatternSprite     uint8 = 0x08
+	ctrlPatternBackground uint8 = 0x10
+	ctrlSpriteSize        uint8 = 0x20
+	ctrlSlaveMode         uint8 = 0x40
+	ctrlEnableNMI         uint8 = 0x80
+)
+
+const (
+	// PPUSTATUS flags
+	statusVerticalBlank uint8 = 0x80
+)
+
+const (
+	// PPUMASK flags
+	maskGrayscale            uint8 = 0x01
+	maskRenderBackgroundLeft uint8 = 0x02
+	maskRenderSpritesLeft    uint8 = 0x04
+	maskRenderBackground     uint8 = 0x08
+	maskRenderSprites        uint8 = 0x10
+	maskEnhanceRed           uint8 = 0x20
+	maskEnhanceGreen         uint8 = 0x40
+	maskEnhanceBlue          uint8 = 0x80
 )
 
 // MG2C02 emulates NES' PPU unit (2C02 chip) from a software perspective
@@ -30,24 +79,392 @@ type MG2C02 struct {
 	pattern [2][4096]uint8
 	palette [32]uint8
 
-	scanline int16
-	cycle    int16
+	// RGB value of the 64 color indices
+	colors [64]color.RGBA
+
+	// Object Attribute Memory, 64 sprites of 4 bytes each
+	oam [256]uint8
+
+	// registers
+	ctrl    uint8
+	mask    uint8
+	status  uint8
+	oamAddr uint8
+
+	// set by a read of status on the clock before vertical blank starts,
+	// the flag is then not set and no NMI occurs for this frame
+	suppressVBlank bool
+
+	// The data bus between CPU and PPU holds the last value written to or
+	// read from any register. Reading a write only register, or the low 5
+	// bits of status, returns it when open bus emulation is enabled
+	latch   uint8
+	openBus bool
+
+	// PPUADDR and PPUDATA: the address of the next PPUDATA access, the
+	// toggle selecting the high or low byte of the next PPUADDR write, it
+	// is shared with PPUSCROLL, and the buffer of delayed PPUDATA reads
+	vramAddr   uint16
+	writeLatch bool
+	readBuffer uint8
+
+	// secondary OAM: the sprites found on the current scanline for the
+	// next one, and their pattern rows fetched at the end of the scanline
+	spriteCount  int
+	spriteOAM    [spritesPerScanline][4]uint8
+	spritePixels [spritesPerScanline][8]uint8
+
+	// the pre-render scanline is -1, visible scanlines start at 0
+	scanline  int16
+	cycle     int16
+	scanlines int16
+
+	// the picture of the frame being drawn
+	screen *image.RGBA
+
+	cart *cartridge.Cartridge
+}
+
+// NewMG2C02 creates and returns a 2C02 ppu reference
+func NewMG2C02() *MG2C02 {
+	return &MG2C02{
+		colors:    palScreen,
+		scanlines: scanlinesNTSC,
+		screen:    image.NewRGBA(image.Rect(0, 0, ScreenWidth, ScreenHeight)),
+	}
+}
+
+// SetRegion sets the number of scanlines per frame, 262 for NTSC and 312
+// for PAL
+func (ppu *MG2C02) SetRegion(region ines.TVSystemType) {
+	if region == ines.TVSystemPAL {
+		ppu.scanlines = scanlinesPAL
+	} else {
+		ppu.scanlines = scanlinesNTSC
+	}
+}
+
+// Scanline returns the current scanline, -1 for the pre-render line
+func (ppu *MG2C02) Scanline() int {
+	return int(ppu.scanline)
+}
+
+// Cycle returns the current clock within the scanline, from 0 to 340
+func (ppu *MG2C02) Cycle() int {
+	return int(ppu.cycle)
 }
 
 func (ppu *MG2C02) CpuWrite(addr uint16, data uint8) {
-	// ppu.addr & 0x0007 = data
-	return
+	ppu.latch = data
+
+	switch addr & 0x0007 {
+	case 0x0000: // Control
+		ppu.ctrl = data
+	case 0x0001: // Mask
+		ppu.mask = data
+	case 0x0003: // OAM Address
+		ppu.oamAddr = data
+	case 0x0004: // OAM Data
+		ppu.oam[ppu.oamAddr] = data
+		ppu.oamAddr++
+	case 0x0005: // Scroll
+		// scrolling is not implemented yet, but the two writes go
+		// through the same toggle as PPUADDR
+		ppu.writeLatch = !ppu.writeLatch
+	case 0x0006: // PPU Address
+		// high byte first, the PPU bus is 14 bits wide
+		if !ppu.writeLatch {
+			ppu.vramAddr = uint16(data&0x3F)<<8 | ppu.vramAddr&0x00FF
+		} else {
+			ppu.vramAddr = ppu.vramAddr&0xFF00 | uint16(data)
+		}
+		ppu.writeLatch = !ppu.writeLatch
+	case 0x0007: // PPU Data
+		ppu.ppuWrite(ppu.vramAddr, data)
+		ppu.incrementAddr()
+	}
 }
 
 func (ppu *MG2C02) CpuRead(addr uint16, readonly bool) (data uint8) {
-	// data = ppu.addr & 0x0007
+	switch addr & 0x0007 {
+	case 0x0002: // Status
+		// only the top 3 bits are driven
+		data = ppu.status&0xE0 | ppu.openBusBits(0x1F)
+		if !readonly {
+			ppu.latch = ppu.latch&0x1F | data&0xE0
+			// reading clears the vertical blank flag and with it the NMI
+			// output, so a read right after the flag was set suppresses
+			// the NMI of the frame. A read right before it is set reads
+			// it clear and prevents it from being set at all
+			ppu.status &^= statusVerticalBlank
+			ppu.writeLatch = false
+			if ppu.scanline == vblankScanline && ppu.cycle == vblankCycle {
+				ppu.suppressVBlank = true
+			}
+		}
+	case 0x0004: // OAM Data
+		data = ppu.oam[ppu.oamAddr]
+		if !readonly {
+			ppu.latch = data
+		}
+	case 0x0007: // PPU Data
+		// Reads are delayed by one: the buffer filled by the previous
+		// read is returned and refilled from the current address. Palette
+		// memory is returned right away, the buffer gets the name table
+		// byte "underneath" it
+		data = ppu.readBuffer
+		addr := ppu.vramAddr & 0x3FFF
+		if addr >= 0x3F00 {
+			data = ppu.ppuRead(addr) | ppu.openBusBits(0xC0)
+		}
+		if !readonly {
+			if addr >= 0x3F00 {
+				ppu.readBuffer = ppu.ppuRead(addr - 0x1000)
+			} else {
+				ppu.readBuffer = ppu.ppuRead(addr)
+			}
+			ppu.incrementAddr()
+			ppu.latch = data
+		}
+	default:
+		// write only registers
+		data = ppu.openBusBits(0xFF)
+	}
 	return
 }
 
-func (ppu *MG2C02) AttachCartridge(cart *cartridge.Cartridge) {
+// incrementAddr advances the PPUDATA address by 1, or by 32 to go down a
+// name table column when PPUCTRL says so
+func (ppu *MG2C02) incrementAddr() {
+	if ppu.ctrl&ctrlIncrementMode != 0 {
+		ppu.vramAddr += 32
+	} else {
+		ppu.vramAddr++
+	}
+	ppu.vramAddr &= 0x3FFF
+}
+
+// SetOpenBus enables returning the stale data bus value for bits which
+// are not driven by a register read, they read as 0 otherwise
+func (ppu *MG2C02) SetOpenBus(enabled bool) {
+	ppu.openBus = enabled
+}
+
+// openBusBits returns the bits of mask from the data bus latch
+func (ppu *MG2C02) openBusBits(mask uint8) uint8 {
+	if !ppu.openBus {
+		return 0
+	}
+	return ppu.latch & mask
+}
 
+// NMI returns true while the PPU asserts the NMI line of the CPU, which it
+// does during vertical blank when enabled by PPUCTRL. The CPU reacts to the
+// line going active, so enabling NMI in PPUCTRL during vertical blank
+// triggers another one
+func (ppu *MG2C02) NMI() bool {
+	return ppu.status&statusVerticalBlank != 0 && ppu.ctrl&ctrlEnableNMI != 0
 }
 
+// ReadOAM returns a byte of Object Attribute Memory
+func (ppu *MG2C02) ReadOAM(addr uint8) uint8 {
+	return ppu.oam[addr]
+}
+
+// WriteOAM writes a byte to Object Attribute Memory, this is how the DMA
+// transfers a page of CPU memory into the PPU
+func (ppu *MG2C02) WriteOAM(addr uint8, data uint8) {
+	ppu.oam[addr] = data
+}
+
+func (ppu *MG2C02) AttachCartridge(cart *cartridge.Cartridge) {
+	ppu.cart = cart
+}
+
+// Clock performs a PPU clock cycle, drawing one pixel during the visible
+// part of a scanline. The background is not rendered yet, pixels show the
+// sprites over the backdrop color at 0x3F00
 func (ppu *MG2C02) Clock() {
+	if ppu.scanline >= 0 && ppu.scanline < ScreenHeight && ppu.cycle >= 1 && ppu.cycle <= ScreenWidth {
+		x := int(ppu.cycle - 1)
+		palette, pixel := ppu.spritePixel(x)
+		ppu.screen.SetRGBA(x, int(ppu.scanline), ppu.GetColorFromPaletteRam(palette, pixel))
+	}
+
+	// the sprites of the next scanline are evaluated and fetched on the
+	// visible scanlines and the pre-render line while rendering is on
+	if ppu.scanline < ScreenHeight && ppu.cycle >= spriteFetchCycle && ppu.cycle <= spriteFetchEnd {
+		if ppu.mask&(maskRenderBackground|maskRenderSprites) == 0 {
+			ppu.spriteCount = 0
+		} else {
+			offset := int(ppu.cycle - spriteFetchCycle)
+			if offset == 0 {
+				ppu.evaluateSprites()
+			}
+			// the pattern bytes are the last 4 clocks of each 8
+			if offset%8 == 4 {
+				ppu.fetchSprite(offset / 8)
+			}
+		}
+	}
+
+	if ppu.cycle == vblankCycle {
+		if ppu.scanline == vblankScanline {
+			if !ppu.suppressVBlank {
+				ppu.status |= statusVerticalBlank
+			}
+			ppu.suppressVBlank = false
+		} else if ppu.scanline == -1 {
+			ppu.status &^= statusVerticalBlank
+		}
+	}
+
+	ppu.cycle++
+	if ppu.cycle >= cyclesPerScanline {
+		ppu.cycle = 0
+		ppu.scanline++
+		// the last scanline of the frame is the pre-render line of the next
+		if ppu.scanline >= ppu.scanlines-1 {
+			ppu.scanline = -1
+		}
+	}
+}
+
+// GetScreen returns the picture the PPU draws into, pixels are updated as
+// the PPU is clocked
+func (ppu *MG2C02) GetScreen() *image.RGBA {
+	return ppu.screen
+}
+
+// GetColorFromPaletteRam returns the actual color of a pixel value in the
+// given palette. The palette memory starts at 0x3F00, each palette takes
+// 4 bytes so "palette << 2" gives the palette offset, then "pixel" (0-3)
+// selects the entry. The value in palette memory is an index into the 64
+// colors the NES can display. The greyscale and color emphasis bits of
+// PPUMASK are applied.
+func (ppu *MG2C02) GetColorFromPaletteRam(palette, pixel uint8) color.RGBA {
+	return ppu.toRGBA(ppu.ppuRead(0x3F00 + uint16(palette)<<2 + uint16(pixel)))
+}
+
+// GetPatternTable draws one of the two 4KB pattern tables (index 0 or 1)
+// into a 128x128 image, using the given palette to colorize the pixels.
+// This is a debugging utility, it is not required for emulation.
+func (ppu *MG2C02) GetPatternTable(index uint8, palette uint8) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 128, 128))
+
+	// A pattern table is 16x16 tiles, each tile is 8x8 pixels made of 16
+	// bytes. The first 8 bytes are the least significant bit plane, the
+	// next 8 bytes are the most significant bit plane, chr.DecodeTile
+	// combines them like chr2png does.
+	base := uint16(index&0x01) * 0x1000
+	for tileY := uint16(0); tileY < 16; tileY++ {
+		for tileX := uint16(0); tileX < 16; tileX++ {
+			// 256 bytes per row of tiles, 16 bytes per tile
+			offset := tileY*256 + tileX*16
+
+			var plane0, plane1 [8]byte
+			for row := uint16(0); row < 8; row++ {
+				plane0[row] = ppu.ppuRead(base + offset + row)
+				plane1[row] = ppu.ppuRead(base + offset + row + 8)
+			}
+
+			pixels := chr.DecodeTile(plane0, plane1)
+			for i, pixel := range pixels {
+				x := int(tileX)*8 + i%8
+				y := int(tileY)*8 + i/8
+				img.SetRGBA(x, y, ppu.GetColorFromPaletteRam(palette, pixel))
+			}
+		}
+	}
+
+	return img
+}
+
+// ppuRead reads a byte from the PPU bus
+func (ppu *MG2C02) ppuRead(addr uint16) (data uint8) {
+	addr &= 0x3FFF
+
+	flag := false
+	if ppu.cart != nil {
+		data, flag = ppu.cart.PpuRead(addr)
+	}
+
+	if flag {
+		// cartridge address range
+	} else if addr <= 0x1FFF {
+		// pattern memory, the most significant bit selects the table
+		data = ppu.pattern[(addr&0x1000)>>12][addr&0x0FFF]
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		// name table memory
+		data = ppu.name[ppu.nameTable(addr)][addr&0x03FF]
+	} else if addr >= 0x3F00 && addr <= 0x3FFF {
+		// palette memory, in greyscale mode only the grey column
+		// of the 64 colors can be selected
+		data = ppu.palette[paletteIndex(addr)]
+		if ppu.mask&maskGrayscale != 0 {
+			data &= 0x30
+		} else {
+			data &= 0x3F
+		}
+	}
+	return
+}
+
+// ppuWrite writes a byte to the PPU bus
+func (ppu *MG2C02) ppuWrite(addr uint16, data uint8) {
+	addr &= 0x3FFF
+
+	if ppu.cart != nil && ppu.cart.PpuWrite(addr, data) {
+		// cartridge address range
+	} else if addr <= 0x1FFF {
+		ppu.pattern[(addr&0x1000)>>12][addr&0x0FFF] = data
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		ppu.name[ppu.nameTable(addr)][addr&0x03FF] = data
+	} else if addr >= 0x3F00 && addr <= 0x3FFF {
+		ppu.palette[paletteIndex(addr)] = data
+	}
+}
+
+// nameTable resolves a 0x2000-0x3EFF address to the physical 1KB name table
+// it refers to. The PPU addresses four logical name tables but only has two
+// physical ones, which are wired up by the cartridge:
+//
+//	Logical   Horizontal   Vertical   OneScreenLo   OneScreenHi
+//	0x2000    0            0          0             1
+//	0x2400    0            1          0             1
+//	0x2800    1            0          0             1
+//	0x2C00    1            1          0             1
+//
+// 0x3000-0x3EFF is a mirror of 0x2000-0x2EFF.
+func (ppu *MG2C02) nameTable(addr uint16) int {
+	mirror := cartridge.MirrorHorizontal
+	if ppu.cart != nil {
+		mirror = ppu.cart.Mirror
+	}
+
+	// logical table index 0-3
+	logical := (addr & 0x0FFF) >> 10
+	switch mirror {
+	case cartridge.MirrorVertical:
+		return int(logical & 0x01)
+	case cartridge.MirrorOneScreenLo:
+		return 0
+	case cartridge.MirrorOneScreenHi:
+		return 1
+	default:
+		return int(logical >> 1)
+	}
+}
 
+// paletteIndex maps a 0x3F00-0x3FFF address to an offset in palette memory.
+// The 32 bytes are mirrored through the whole range, and the first entry of
+// each sprite palette (0x3F10/0x3F14/0x3F18/0x3F1C) is a mirror of the
+// corresponding background palette entry (0x3F00/0x3F04/0x3F08/0x3F0C).
+func paletteIndex(addr uint16) uint16 {
+	addr &= 0x001F
+	switch addr {
+	case 0x0010, 0x0014, 0x0018, 0x001C:
+		addr &= 0x000F
+	}
+	return addr
 }
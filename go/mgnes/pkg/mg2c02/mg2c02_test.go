@@ -0,0 +1,291 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg2c02
+
+import (
+	"bytes"
+	"mgnes/pkg/cartridge"
+	"testing"
+)
+
+// setAddr points PPUADDR at addr
+func setAddr(ppu *MG2C02, addr uint16) {
+	ppu.CpuWrite(0x2006, uint8(addr>>8))
+	ppu.CpuWrite(0x2006, uint8(addr))
+}
+
+// writeVRAM writes data at addr through PPUADDR and PPUDATA
+func writeVRAM(ppu *MG2C02, addr uint16, data uint8) {
+	setAddr(ppu, addr)
+	ppu.CpuWrite(0x2007, data)
+}
+
+// readVRAM reads addr through PPUADDR and PPUDATA, skipping the buffered
+// read of addresses below the palette
+func readVRAM(ppu *MG2C02, addr uint16) uint8 {
+	setAddr(ppu, addr)
+	data := ppu.CpuRead(0x2007, false)
+	if addr&0x3FFF < 0x3F00 {
+		setAddr(ppu, addr)
+		data = ppu.CpuRead(0x2007, false)
+	}
+	return data
+}
+
+func TestPaletteMirrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		mirror uint16
+		base   uint16
+	}{
+		{"3F10", 0x3F10, 0x3F00},
+		{"3F14", 0x3F14, 0x3F04},
+		{"3F18", 0x3F18, 0x3F08},
+		{"3F1C", 0x3F1C, 0x3F0C},
+		{"3F20", 0x3F20, 0x3F00},
+		{"3FE5", 0x3FE5, 0x3F05},
+		{"3FFC", 0x3FFC, 0x3F0C},
+		{"7F01", 0x7F01, 0x3F01},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ppu := NewMG2C02()
+
+			writeVRAM(ppu, test.mirror, 0x2A)
+			if got := readVRAM(ppu, test.base); got != 0x2A {
+				t.Errorf("write $%04X, read $%04X = $%02X, want $2A", test.mirror, test.base, got)
+			}
+
+			writeVRAM(ppu, test.base, 0x15)
+			if got := readVRAM(ppu, test.mirror); got != 0x15 {
+				t.Errorf("write $%04X, read $%04X = $%02X, want $15", test.base, test.mirror, got)
+			}
+		})
+	}
+}
+
+func TestPaletteNotMirrored(t *testing.T) {
+	// only the first entry of the sprite palettes is shared
+	for _, addr := range []uint16{0x3F11, 0x3F15, 0x3F19, 0x3F1D} {
+		ppu := NewMG2C02()
+		writeVRAM(ppu, addr, 0x30)
+		if got := readVRAM(ppu, addr&^0x0010); got != 0x00 {
+			t.Errorf("write $%04X, read $%04X = $%02X, want $00", addr, addr&^0x0010, got)
+		}
+		if got := readVRAM(ppu, addr); got != 0x30 {
+			t.Errorf("read $%04X = $%02X, want $30", addr, got)
+		}
+	}
+}
+
+func TestPaletteGreyscale(t *testing.T) {
+	ppu := NewMG2C02()
+	writeVRAM(ppu, 0x3F00, 0x2A)
+
+	ppu.CpuWrite(0x2001, maskGrayscale)
+	if got := readVRAM(ppu, 0x3F00); got != 0x20 {
+		t.Errorf("greyscale read = $%02X, want $20", got)
+	}
+	if got, want := ppu.GetColorFromPaletteRam(0, 0), palScreen[0x20]; got != want {
+		t.Errorf("greyscale color = %v, want %v", got, want)
+	}
+
+	ppu.CpuWrite(0x2001, 0x00)
+	if got := readVRAM(ppu, 0x3F00); got != 0x2A {
+		t.Errorf("read = $%02X, want $2A", got)
+	}
+}
+
+func TestPPUDataReadBuffer(t *testing.T) {
+	ppu := NewMG2C02()
+	writeVRAM(ppu, 0x2000, 0x11)
+	ppu.CpuWrite(0x2007, 0x22)
+
+	setAddr(ppu, 0x2000)
+	if got := ppu.CpuRead(0x2007, false); got != 0x00 {
+		t.Errorf("first read = $%02X, want the stale buffer $00", got)
+	}
+	if got := ppu.CpuRead(0x2007, false); got != 0x11 {
+		t.Errorf("second read = $%02X, want $11", got)
+	}
+	if got := ppu.CpuRead(0x2007, true); got != 0x22 {
+		t.Errorf("readonly read = $%02X, want $22", got)
+	}
+	if got := ppu.CpuRead(0x2007, false); got != 0x22 {
+		t.Errorf("read after readonly read = $%02X, want $22", got)
+	}
+}
+
+func TestPPUDataIncrement(t *testing.T) {
+	ppu := NewMG2C02()
+	ppu.CpuWrite(0x2000, ctrlIncrementMode)
+	setAddr(ppu, 0x2000)
+	ppu.CpuWrite(0x2007, 0x01)
+	ppu.CpuWrite(0x2007, 0x02)
+
+	ppu.CpuWrite(0x2000, 0x00)
+	if got := readVRAM(ppu, 0x2020); got != 0x02 {
+		t.Errorf("read $2020 = $%02X, want $02", got)
+	}
+}
+
+// clockTo clocks the PPU until the next dot it runs is cycle of scanline
+func clockTo(ppu *MG2C02, scanline, cycle int) {
+	for int(ppu.scanline) != scanline || int(ppu.cycle) != cycle {
+		ppu.Clock()
+	}
+}
+
+func TestVBlankRace(t *testing.T) {
+	tests := []struct {
+		name string
+		// the next dot when PPUSTATUS is read, -1 for no read
+		readAt   int
+		wantRead uint8
+		wantNMI  bool
+	}{
+		// one clock before the flag is set: it reads clear and is never
+		// set for the frame
+		{"dot 0", 1, 0x00, false},
+		// on the clock the flag is set: it reads set and the NMI is
+		// cancelled as the read clears it
+		{"dot 1", 2, 0x80, false},
+		{"no read", -1, 0x00, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ppu := NewMG2C02()
+			ppu.CpuWrite(0x2000, 0x80) // NMI enabled
+			clockTo(ppu, vblankScanline-1, 0)
+
+			nmi := false
+			for ppu.scanline != vblankScanline || ppu.cycle < 20 {
+				if ppu.scanline == vblankScanline && int(ppu.cycle) == test.readAt {
+					if got := ppu.CpuRead(0x2002, false) & 0x80; got != test.wantRead {
+						t.Errorf("PPUSTATUS vertical blank = %#02x, want %#02x", got, test.wantRead)
+					}
+				}
+				nmi = nmi || ppu.NMI()
+				ppu.Clock()
+			}
+			if nmi != test.wantNMI {
+				t.Errorf("NMI = %v, want %v", nmi, test.wantNMI)
+			}
+			if test.readAt >= 0 && ppu.status&statusVerticalBlank != 0 {
+				t.Errorf("vertical blank flag set after the read")
+			}
+		})
+	}
+}
+
+func TestGetPatternTable(t *testing.T) {
+	// tile 1 of the second table: the top row starts with pixels 3 and 2,
+	// the bottom row ends with pixel 1
+	image := make([]byte, 16+0x4000+0x2000)
+	copy(image, []byte{'N', 'E', 'S', 0x1A, 1, 1})
+	chr := image[16+0x4000:]
+	chr[0x1010] = 0x80   // plane 0, row 0
+	chr[0x1010+8] = 0xC0 // plane 1, row 0
+	chr[0x1017] = 0x01   // plane 0, row 7
+	cart, err := cartridge.Load(bytes.NewReader(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ppu := NewMG2C02()
+	ppu.AttachCartridge(cart)
+	for i, c := range []uint8{0x0F, 0x01, 0x11, 0x21} {
+		writeVRAM(ppu, 0x3F08+uint16(i), c) // palette 2
+	}
+
+	tests := []struct {
+		x, y  int
+		color uint8
+	}{
+		{8, 0, 0x21},  // pixel 3
+		{9, 0, 0x11},  // pixel 2
+		{10, 0, 0x0F}, // pixel 0
+		{15, 7, 0x01}, // pixel 1
+		{8, 7, 0x0F},
+		{0, 0, 0x0F}, // tile 0 is empty
+		{16, 0, 0x0F},
+	}
+	img := ppu.GetPatternTable(1, 2)
+	if img.Bounds().Dx() != 128 || img.Bounds().Dy() != 128 {
+		t.Fatalf("bounds = %v, want 128x128", img.Bounds())
+	}
+	for _, tt := range tests {
+		if got := img.RGBAAt(tt.x, tt.y); got != palScreen[tt.color] {
+			t.Errorf("pixel (%v, %v) = %v, want color $%02X %v", tt.x, tt.y, got, tt.color, palScreen[tt.color])
+		}
+	}
+
+	// the first table does not have the tile
+	if got := ppu.GetPatternTable(0, 2).RGBAAt(8, 0); got != palScreen[0x0F] {
+		t.Errorf("pixel (8, 0) of table 0 = %v, want color $0F", got)
+	}
+}
+
+func TestNameTableMirroring(t *testing.T) {
+	image := make([]byte, 16+0x4000+0x2000)
+	copy(image, []byte{'N', 'E', 'S', 0x1A, 1, 1})
+	cart, err := cartridge.Load(bytes.NewReader(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// physical table of the logical ones at 0x2000, 0x2400, 0x2800 and
+	// 0x2C00
+	tests := []struct {
+		mirror cartridge.Mirror
+		want   [4]int
+	}{
+		{cartridge.MirrorHorizontal, [4]int{0, 0, 1, 1}},
+		{cartridge.MirrorVertical, [4]int{0, 1, 0, 1}},
+		{cartridge.MirrorOneScreenLo, [4]int{0, 0, 0, 0}},
+		{cartridge.MirrorOneScreenHi, [4]int{1, 1, 1, 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mirror.String(), func(t *testing.T) {
+			cart.Mirror = test.mirror
+			ppu := NewMG2C02()
+			ppu.AttachCartridge(cart)
+
+			for logical, physical := range test.want {
+				// 0x3000-0x3EFF mirrors 0x2000-0x2EFF
+				for _, base := range []uint16{0x2000, 0x3000} {
+					addr := base + uint16(logical)*0x0400 + 0x0123
+					ppu.name = [2][1024]uint8{}
+					writeVRAM(ppu, addr, 0xA5)
+					if ppu.name[physical][0x0123] != 0xA5 || ppu.name[physical^1][0x0123] != 0x00 {
+						t.Errorf("write $%04X did not land in table %v only", addr, physical)
+					}
+					if got := readVRAM(ppu, addr); got != 0xA5 {
+						t.Errorf("read $%04X = $%02X, want $A5", addr, got)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg2c02
+
+import (
+	"errors"
+	"image/color"
+	"mgnes/pkg/nespalette"
+)
+
+// palScreen holds the 64 colors the NES is able to output, the PPU stores
+// 6-bit indices into this table inside its palette memory. It is the
+// default, SetPalette selects another one
+var palScreen = [64]color.RGBA{
+	{84, 84, 84, 255}, {0, 30, 116, 255}, {8, 16, 144, 255}, {48, 0, 136, 255},
+	{68, 0, 100, 255}, {92, 0, 48, 255}, {84, 4, 0, 255}, {60, 24, 0, 255},
+	{32, 42, 0, 255}, {8, 58, 0, 255}, {0, 64, 0, 255}, {0, 60, 0, 255},
+	{0, 50, 60, 255}, {0, 0, 0, 255}, {0, 0, 0, 255}, {0, 0, 0, 255},
+
+	{152, 150, 152, 255}, {8, 76, 196, 255}, {48, 50, 236, 255}, {92, 30, 228, 255},
+	{136, 20, 176, 255}, {160, 20, 100, 255}, {152, 34, 32, 255}, {120, 60, 0, 255},
+	{84, 90, 0, 255}, {40, 114, 0, 255}, {8, 124, 0, 255}, {0, 118, 40, 255},
+	{0, 102, 120, 255}, {0, 0, 0, 255}, {0, 0, 0, 255}, {0, 0, 0, 255},
+
+	{236, 238, 236, 255}, {76, 154, 236, 255}, {120, 124, 236, 255}, {176, 98, 236, 255},
+	{228, 84, 236, 255}, {236, 88, 180, 255}, {236, 106, 100, 255}, {212, 136, 32, 255},
+	{160, 170, 0, 255}, {116, 196, 0, 255}, {76, 208, 32, 255}, {56, 204, 108, 255},
+	{56, 180, 204, 255}, {60, 60, 60, 255}, {0, 0, 0, 255}, {0, 0, 0, 255},
+
+	{236, 238, 236, 255}, {168, 204, 236, 255}, {188, 188, 236, 255}, {212, 178, 236, 255},
+	{236, 174, 236, 255}, {236, 174, 212, 255}, {236, 180, 176, 255}, {228, 196, 144, 255},
+	{204, 210, 120, 255}, {180, 222, 120, 255}, {168, 226, 144, 255}, {152, 226, 180, 255},
+	{160, 214, 228, 255}, {160, 162, 160, 255}, {0, 0, 0, 255}, {0, 0, 0, 255},
+}
+
+// SetPalette selects the 64 colors of a named palette of the nespalette
+// package, see nespalette.Names. An empty name restores the default
+func (ppu *MG2C02) SetPalette(name string) error {
+	if name == "" {
+		ppu.colors = palScreen
+		return nil
+	}
+
+	raw, ok := nespalette.Get(name)
+	if !ok || len(raw) < len(ppu.colors)*3 {
+		return errors.New("unknown palette " + name)
+	}
+	for i := range ppu.colors {
+		ppu.colors[i] = color.RGBA{R: raw[i*3], G: raw[i*3+1], B: raw[i*3+2], A: 0xFF}
+	}
+	return nil
+}
+
+// emphasisFactor is how much a color channel is attenuated when PPUMASK
+// emphasizes the other channels
+const emphasisFactor = 0.746
+
+// toRGBA converts a 6-bit color index to RGBA, applying the color
+// emphasis bits of PPUMASK. Each emphasized channel keeps its intensity
+// while the others are darkened, emphasizing all three darkens the whole
+// picture. Greyscale is applied to the index when palette memory is read
+func (ppu *MG2C02) toRGBA(index uint8) color.RGBA {
+	c := ppu.colors[index&0x3F]
+
+	emphasis := ppu.mask & (maskEnhanceRed | maskEnhanceGreen | maskEnhanceBlue)
+	if emphasis == 0 {
+		return c
+	}
+	all := emphasis == maskEnhanceRed|maskEnhanceGreen|maskEnhanceBlue
+	if all || emphasis&maskEnhanceRed == 0 {
+		c.R = attenuate(c.R)
+	}
+	if all || emphasis&maskEnhanceGreen == 0 {
+		c.G = attenuate(c.G)
+	}
+	if all || emphasis&maskEnhanceBlue == 0 {
+		c.B = attenuate(c.B)
+	}
+	return c
+}
+
+func attenuate(v uint8) uint8 {
+	return uint8(float64(v) * emphasisFactor)
+}
@@ -0,0 +1,91 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg2c02
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestColorEmphasis(t *testing.T) {
+	// color $21 is {76, 154, 236}, an attenuated channel is 0.746 of it
+	tests := []struct {
+		name string
+		mask uint8
+		want color.RGBA
+	}{
+		{"none", 0x00, color.RGBA{76, 154, 236, 255}},
+		{"red", maskEnhanceRed, color.RGBA{76, 114, 176, 255}},
+		{"green", maskEnhanceGreen, color.RGBA{56, 154, 176, 255}},
+		{"blue", maskEnhanceBlue, color.RGBA{56, 114, 236, 255}},
+		{"red and green", maskEnhanceRed | maskEnhanceGreen, color.RGBA{76, 154, 176, 255}},
+		{"all", maskEnhanceRed | maskEnhanceGreen | maskEnhanceBlue, color.RGBA{56, 114, 176, 255}},
+		// greyscale selects $20, {236, 238, 236}, before the emphasis
+		{"greyscale", maskGrayscale, color.RGBA{236, 238, 236, 255}},
+		{"greyscale and red", maskGrayscale | maskEnhanceRed, color.RGBA{236, 177, 176, 255}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ppu := NewMG2C02()
+			writeVRAM(ppu, 0x3F00, 0x21)
+			ppu.CpuWrite(0x2001, test.mask)
+
+			if got := ppu.GetColorFromPaletteRam(0, 0); got != test.want {
+				t.Errorf("color = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSetPalette(t *testing.T) {
+	ppu := NewMG2C02()
+	writeVRAM(ppu, 0x3F00, 0x21)
+	def := color.RGBA{76, 154, 236, 255}
+
+	// the greyscale palette has $97 in all channels for $21
+	if err := ppu.SetPalette("Grayscale"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ppu.GetColorFromPaletteRam(0, 0), (color.RGBA{0x97, 0x97, 0x97, 255}); got != want {
+		t.Errorf("Grayscale color = %v, want %v", got, want)
+	}
+	// and it is what the screen shows, the backdrop color here
+	clockTo(ppu, 10, 0)
+	if got, want := ppu.GetScreen().RGBAAt(100, 5), (color.RGBA{0x97, 0x97, 0x97, 255}); got != want {
+		t.Errorf("Grayscale screen pixel = %v, want %v", got, want)
+	}
+
+	// an unknown name keeps the current palette
+	if err := ppu.SetPalette("NoSuchPalette"); err == nil {
+		t.Errorf("SetPalette of an unknown name returned no error")
+	}
+	if got, want := ppu.GetColorFromPaletteRam(0, 0), (color.RGBA{0x97, 0x97, 0x97, 255}); got != want {
+		t.Errorf("color after a failed SetPalette = %v, want %v", got, want)
+	}
+
+	if err := ppu.SetPalette(""); err != nil {
+		t.Fatal(err)
+	}
+	if got := ppu.GetColorFromPaletteRam(0, 0); got != def {
+		t.Errorf("default color = %v, want %v", got, def)
+	}
+}
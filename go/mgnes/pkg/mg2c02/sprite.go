@@ -0,0 +1,152 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg2c02
+
+const (
+	// OAM sprite attribute flags
+	attrPalette        uint8 = 0x03
+	attrFlipHorizontal uint8 = 0x40
+	attrFlipVertical   uint8 = 0x80
+)
+
+const (
+	// sprites drawn on a scanline at most, the rest are dropped
+	spritesPerScanline = 8
+
+	// sprite evaluation happens on the first clock of the sprite fetches,
+	// which take 8 clocks per sprite up to cycle 320
+	spriteFetchCycle = 257
+	spriteFetchEnd   = 320
+)
+
+// spriteHeight returns 16 when PPUCTRL selects 8x16 sprites, 8 otherwise
+func (ppu *MG2C02) spriteHeight() int {
+	if ppu.ctrl&ctrlSpriteSize != 0 {
+		return 16
+	}
+	return 8
+}
+
+// spritePatternAddr returns the address of the low bit plane of row (0 to
+// height-1, counted from the top of the sprite on screen) of a sprite.
+//
+// 8x8 sprites take their pattern table from PPUCTRL. 8x16 sprites ignore
+// it and use bit 0 of the tile index instead, the top half is the even
+// tile and the bottom half the next one:
+//
+//	76543210
+//	TTTTTTTP
+//	|||||||+- pattern table, 0x0000 or 0x1000
+//	+++++++-- top tile number / 2
+//
+// With vertical flip the rows of the whole sprite are reversed, so for an
+// 8x16 sprite the halves swap as well
+func (ppu *MG2C02) spritePatternAddr(tile, attr uint8, row int) uint16 {
+	height := ppu.spriteHeight()
+	if attr&attrFlipVertical != 0 {
+		row = height - 1 - row
+	}
+
+	var table, index uint16
+	if height == 16 {
+		table = uint16(tile&0x01) << 12
+		index = uint16(tile & 0xFE)
+		if row >= 8 {
+			index++
+			row -= 8
+		}
+	} else {
+		if ppu.ctrl&ctrlPatternSprite != 0 {
+			table = 0x1000
+		}
+		index = uint16(tile)
+	}
+
+	// 16 bytes per tile, the high bit plane follows 8 bytes later
+	return table | index<<4 | uint16(row)
+}
+
+// spriteRow returns the 8 pixels of a sprite row from left to right as
+// 2-bit values, horizontal flip applied
+func (ppu *MG2C02) spriteRow(tile, attr uint8, row int) (pixels [8]uint8) {
+	addr := ppu.spritePatternAddr(tile, attr, row)
+	lsb := ppu.ppuRead(addr)
+	msb := ppu.ppuRead(addr + 8)
+
+	for col := 0; col < 8; col++ {
+		// the most significant bit is the leftmost pixel
+		bit := uint(7 - col)
+		if attr&attrFlipHorizontal != 0 {
+			bit = uint(col)
+		}
+		pixels[col] = (msb>>bit&0x01)<<1 | lsb>>bit&0x01
+	}
+	return
+}
+
+// evaluateSprites copies the first 8 sprites in OAM covering the next
+// scanline to the secondary OAM. OAM Y is the scanline above the top row of
+// the sprite, so the row to draw is the current scanline minus Y
+func (ppu *MG2C02) evaluateSprites() {
+	ppu.spriteCount = 0
+	height := ppu.spriteHeight()
+	for i := 0; i < len(ppu.oam) && ppu.spriteCount < spritesPerScanline; i += 4 {
+		row := int(ppu.scanline) - int(ppu.oam[i])
+		if row >= 0 && row < height {
+			copy(ppu.spriteOAM[ppu.spriteCount][:], ppu.oam[i:i+4])
+			ppu.spriteCount++
+		}
+	}
+}
+
+// fetchSprite reads the pattern row of the sprite in slot of the secondary
+// OAM. Empty slots fetch tile 0xFF like the real chip, the data is dropped
+// but mappers watching the PPU bus see the access
+func (ppu *MG2C02) fetchSprite(slot int) {
+	if slot >= ppu.spriteCount {
+		ppu.spriteRow(0xFF, 0x00, 0)
+		return
+	}
+
+	sprite := ppu.spriteOAM[slot]
+	row := int(ppu.scanline) - int(sprite[0])
+	ppu.spritePixels[slot] = ppu.spriteRow(sprite[1], sprite[2], row)
+}
+
+// spritePixel returns the palette and pixel value of the first opaque
+// sprite at x on the current scanline, pixel is 0 when there is none
+func (ppu *MG2C02) spritePixel(x int) (palette, pixel uint8) {
+	if ppu.mask&maskRenderSprites == 0 || (x < 8 && ppu.mask&maskRenderSpritesLeft == 0) {
+		return
+	}
+
+	for slot := 0; slot < ppu.spriteCount; slot++ {
+		col := x - int(ppu.spriteOAM[slot][3])
+		if col < 0 || col >= 8 {
+			continue
+		}
+		if pixel = ppu.spritePixels[slot][col]; pixel != 0 {
+			// sprite palettes are 4 to 7
+			return 4 + ppu.spriteOAM[slot][2]&attrPalette, pixel
+		}
+	}
+	return
+}
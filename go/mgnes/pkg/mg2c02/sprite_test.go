@@ -0,0 +1,152 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg2c02
+
+import "testing"
+
+// newSpritePPU returns a PPU in 8x16 sprite mode with two tiles in the
+// second pattern table:
+//
+//	tile 2, top half:    plane 0 row r = 0x80 >> r, plane 1 = 0
+//	tile 3, bottom half: plane 0 row r = 0xFF on even rows, 0 on odd ones,
+//	                     plane 1 row r = 0x01 << r
+func newSpritePPU() *MG2C02 {
+	ppu := NewMG2C02()
+	ppu.ctrl = ctrlSpriteSize
+	for r := 0; r < 8; r++ {
+		ppu.pattern[1][2*16+r] = 0x80 >> r
+		if r%2 == 0 {
+			ppu.pattern[1][3*16+r] = 0xFF
+		}
+		ppu.pattern[1][3*16+8+r] = 0x01 << r
+	}
+	return ppu
+}
+
+// 8x16 sprite rows counted from the top on screen, tile 0x03 selects the
+// second pattern table and tiles 2 and 3
+var sprite8x16Tests = []struct {
+	name string
+	attr uint8
+	row  int
+	want [8]uint8
+}{
+	{"top row", 0x00, 0, [8]uint8{1, 0, 0, 0, 0, 0, 0, 0}},
+	{"last row of the top half", 0x00, 7, [8]uint8{0, 0, 0, 0, 0, 0, 0, 1}},
+	{"first row of the bottom half", 0x00, 8, [8]uint8{1, 1, 1, 1, 1, 1, 1, 3}},
+	{"second row of the bottom half", 0x00, 9, [8]uint8{0, 0, 0, 0, 0, 0, 2, 0}},
+	{"bottom row", 0x00, 15, [8]uint8{2, 0, 0, 0, 0, 0, 0, 0}},
+
+	// vertical flip reverses all 16 rows, the bottom tile comes first
+	{"flipped top row", attrFlipVertical, 0, [8]uint8{2, 0, 0, 0, 0, 0, 0, 0}},
+	{"flipped row 6", attrFlipVertical, 6, [8]uint8{0, 0, 0, 0, 0, 0, 2, 0}},
+	{"flipped last row of the top half", attrFlipVertical, 7, [8]uint8{1, 1, 1, 1, 1, 1, 1, 3}},
+	{"flipped first row of the bottom half", attrFlipVertical, 8, [8]uint8{0, 0, 0, 0, 0, 0, 0, 1}},
+	{"flipped bottom row", attrFlipVertical, 15, [8]uint8{1, 0, 0, 0, 0, 0, 0, 0}},
+
+	{"both flips row 7", attrFlipVertical | attrFlipHorizontal, 7, [8]uint8{3, 1, 1, 1, 1, 1, 1, 1}},
+	{"both flips row 9", attrFlipVertical | attrFlipHorizontal, 9, [8]uint8{0, 1, 0, 0, 0, 0, 0, 0}},
+}
+
+func TestSpriteRow8x16(t *testing.T) {
+	for _, test := range sprite8x16Tests {
+		t.Run(test.name, func(t *testing.T) {
+			ppu := newSpritePPU()
+			if got := ppu.spriteRow(0x03, test.attr, test.row); got != test.want {
+				t.Errorf("spriteRow(0x03, %#02x, %v) = %v, want %v", test.attr, test.row, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSpriteRender8x16(t *testing.T) {
+	const (
+		spriteX = 100
+		spriteY = 19 // drawn from scanline 20
+	)
+
+	for _, test := range sprite8x16Tests {
+		t.Run(test.name, func(t *testing.T) {
+			ppu := newSpritePPU()
+			ppu.mask = maskRenderSprites
+			// the sprite uses palette 5
+			ppu.oam[4] = spriteY
+			ppu.oam[5] = 0x03
+			ppu.oam[6] = test.attr | 0x01
+			ppu.oam[7] = spriteX
+			ppu.palette[0x00] = 0x0F
+			ppu.palette[0x15] = 0x16
+			ppu.palette[0x16] = 0x2A
+			ppu.palette[0x17] = 0x12
+			// hide the other sprites below the picture
+			for i := 8; i < len(ppu.oam); i += 4 {
+				ppu.oam[i] = 0xF0
+			}
+			ppu.oam[0] = 0xF0
+
+			for i := 0; i < scanlinesNTSC*cyclesPerScanline; i++ {
+				ppu.Clock()
+			}
+
+			y := spriteY + 1 + test.row
+			screen := ppu.GetScreen()
+			for col, pixel := range test.want {
+				want := ppu.GetColorFromPaletteRam(0, 0)
+				if pixel != 0 {
+					want = ppu.GetColorFromPaletteRam(5, pixel)
+				}
+				if got := screen.RGBAAt(spriteX+col, y); got != want {
+					t.Errorf("pixel (%v, %v) = %v, want %v", spriteX+col, y, got, want)
+				}
+			}
+			// the pixels left and right of the sprite show the backdrop
+			for _, x := range []int{spriteX - 1, spriteX + 8} {
+				if got := screen.RGBAAt(x, y); got != ppu.GetColorFromPaletteRam(0, 0) {
+					t.Errorf("pixel (%v, %v) = %v, want the backdrop", x, y, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSpriteLimit(t *testing.T) {
+	ppu := NewMG2C02()
+	ppu.mask = maskRenderSprites
+	// 10 sprites on scanline 51, only the first 8 are kept
+	for i := 0; i < len(ppu.oam); i += 4 {
+		ppu.oam[i] = 0xF0
+	}
+	for i := 0; i < 10; i++ {
+		ppu.oam[i*4] = 50
+		ppu.oam[i*4+3] = uint8(i * 16)
+	}
+
+	ppu.scanline = 50
+	ppu.evaluateSprites()
+	if ppu.spriteCount != spritesPerScanline {
+		t.Fatalf("%v sprites found, want %v", ppu.spriteCount, spritesPerScanline)
+	}
+	for slot := 0; slot < ppu.spriteCount; slot++ {
+		if x := ppu.spriteOAM[slot][3]; x != uint8(slot*16) {
+			t.Errorf("slot %v holds the sprite at x %v, want %v", slot, x, slot*16)
+		}
+	}
+}
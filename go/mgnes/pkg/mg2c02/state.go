@@ -0,0 +1,98 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg2c02
+
+import "encoding/gob"
+
+// ppuState is the serializable part of the PPU
+type ppuState struct {
+	Name    [2][1024]uint8
+	Pattern [2][4096]uint8
+	Palette [32]uint8
+	OAM     [256]uint8
+
+	Ctrl    uint8
+	Mask    uint8
+	Status  uint8
+	OAMAddr uint8
+	Latch   uint8
+
+	VRAMAddr   uint16
+	WriteLatch bool
+	ReadBuffer uint8
+
+	SpriteCount  int
+	SpriteOAM    [spritesPerScanline][4]uint8
+	SpritePixels [spritesPerScanline][8]uint8
+
+	Scanline int16
+	Cycle    int16
+}
+
+// Serialize writes registers, VRAM, OAM and palette memory for a save state
+func (ppu *MG2C02) Serialize(enc *gob.Encoder) error {
+	return enc.Encode(ppuState{
+		Name:         ppu.name,
+		Pattern:      ppu.pattern,
+		Palette:      ppu.palette,
+		OAM:          ppu.oam,
+		Ctrl:         ppu.ctrl,
+		Mask:         ppu.mask,
+		Status:       ppu.status,
+		OAMAddr:      ppu.oamAddr,
+		Latch:        ppu.latch,
+		VRAMAddr:     ppu.vramAddr,
+		WriteLatch:   ppu.writeLatch,
+		ReadBuffer:   ppu.readBuffer,
+		SpriteCount:  ppu.spriteCount,
+		SpriteOAM:    ppu.spriteOAM,
+		SpritePixels: ppu.spritePixels,
+		Scanline:     ppu.scanline,
+		Cycle:        ppu.cycle,
+	})
+}
+
+// Deserialize restores the state written by Serialize
+func (ppu *MG2C02) Deserialize(dec *gob.Decoder) error {
+	var state ppuState
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	ppu.name = state.Name
+	ppu.pattern = state.Pattern
+	ppu.palette = state.Palette
+	ppu.oam = state.OAM
+	ppu.ctrl = state.Ctrl
+	ppu.mask = state.Mask
+	ppu.status = state.Status
+	ppu.oamAddr = state.OAMAddr
+	ppu.latch = state.Latch
+	ppu.vramAddr = state.VRAMAddr
+	ppu.writeLatch = state.WriteLatch
+	ppu.readBuffer = state.ReadBuffer
+	ppu.spriteCount = state.SpriteCount
+	ppu.spriteOAM = state.SpriteOAM
+	ppu.spritePixels = state.SpritePixels
+	ppu.scanline = state.Scanline
+	ppu.cycle = state.Cycle
+	return nil
+}
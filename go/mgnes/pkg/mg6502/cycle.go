@@ -0,0 +1,220 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg6502
+
+// Cycle stepped execution ======================================================
+// By default the cpu performs all the work of an instruction on its first
+// cycle and idles for the rest, which is fast and good enough for most
+// programs. With CycleStepped set, instructions accessing memory through an
+// addressing mode, and the 2 cycle implied ones, are broken down into their
+// documented cycles instead: each Clock performs exactly the bus access the
+// real chip does on that cycle, dummy accesses included. This matters for
+// registers with side effects, like the PPU status register, read on the last
+// cycle of "LDA $2002" and not on the first.
+//
+// Instructions with their own sequence (BRK, JSR, JMP, RTS, RTI, the stack
+// instructions, branches and the 3-4 cycle NOPs) still run on their first cycle.
+
+// access kinds of the instructions in cycle stepped mode
+const (
+	accessNone    uint8 = iota // executed on the first cycle
+	accessImplied              // dummy read of the next byte, then execute
+	accessRead                 // the operand is read on the last cycle
+	accessWrite                // the operand is written on the last cycle
+	accessRMW                  // read, write back the unmodified value, write the result
+)
+
+// accessKinds classifies the instructions of lookup for cycle stepped mode
+func accessKinds(lookup []*Instruction) (kinds [256]uint8) {
+	for i, instruction := range lookup {
+		switch instruction.addrMode {
+		case AddrModeIMP:
+			if instruction.cycles == 2 {
+				kinds[i] = accessImplied
+			}
+		case AddrModeIMM, AddrModeZP0, AddrModeZPX, AddrModeZPY, AddrModeABS,
+			AddrModeABX, AddrModeABY, AddrModeIZX, AddrModeIZY:
+			switch instruction.name {
+			case "BRK", "JSR", "JMP":
+				kinds[i] = accessNone
+			case "STA", "STX", "STY", "SAX", "STZ":
+				kinds[i] = accessWrite
+			case "ASL", "LSR", "ROL", "ROR", "INC", "DEC", "SLO", "SRE", "RLA", "RRA", "ISC", "DCP", "TSB", "TRB":
+				kinds[i] = accessRMW
+			default:
+				kinds[i] = accessRead
+			}
+		}
+	}
+	return
+}
+
+// microStep performs the bus access of the next cycle of a cycle stepped
+// instruction, the opcode was fetched on cycle 1
+func (cpu *MG6502) microStep() {
+	cpu.step++
+
+	// the writes of a read-modify-write instruction, one per cycle
+	if cpu.numPending > 0 {
+		pending := cpu.pending[0]
+		cpu.pending[0] = cpu.pending[1]
+		cpu.numPending--
+		cpu.writer.CpuWrite(pending.addr, pending.data)
+		return
+	}
+
+	instruction := cpu.lookup[cpu.opcode]
+	switch instruction.addrMode {
+	case AddrModeIMP:
+		cpu.read(cpu.PC)
+		amIMP(cpu)
+		cpu.operate()
+	case AddrModeIMM:
+		cpu.addrAbs = cpu.PC
+		cpu.PC++
+		cpu.operate()
+	case AddrModeZP0:
+		if cpu.step == 2 {
+			cpu.addrAbs = uint16(cpu.fetchPC())
+		} else {
+			cpu.operate()
+		}
+	case AddrModeZPX, AddrModeZPY:
+		switch cpu.step {
+		case 2:
+			cpu.addrAbs = uint16(cpu.fetchPC())
+		case 3:
+			// the unindexed address is read while the index is added
+			cpu.read(cpu.addrAbs)
+			cpu.addrAbs = uint16(uint8(cpu.addrAbs) + cpu.index())
+		default:
+			cpu.operate()
+		}
+	case AddrModeABS:
+		switch cpu.step {
+		case 2:
+			cpu.addrAbs = uint16(cpu.fetchPC())
+		case 3:
+			cpu.addrAbs |= uint16(cpu.fetchPC()) << 8
+		default:
+			cpu.operate()
+		}
+	case AddrModeABX, AddrModeABY:
+		switch cpu.step {
+		case 2:
+			cpu.base = uint16(cpu.fetchPC())
+		case 3:
+			cpu.base |= uint16(cpu.fetchPC()) << 8
+			cpu.addrAbs = cpu.base + uint16(cpu.index())
+		case 4:
+			cpu.fixup()
+		default:
+			cpu.operate()
+		}
+	case AddrModeIZX:
+		switch cpu.step {
+		case 2:
+			cpu.base = uint16(cpu.fetchPC())
+		case 3:
+			// the pointer is read while X is added
+			cpu.read(cpu.base)
+			cpu.base = (cpu.base + uint16(cpu.X)) & 0x00FF
+		case 4:
+			cpu.addrAbs = uint16(cpu.read(cpu.base))
+		case 5:
+			cpu.addrAbs |= uint16(cpu.read((cpu.base+1)&0x00FF)) << 8
+		default:
+			cpu.operate()
+		}
+	case AddrModeIZY:
+		switch cpu.step {
+		case 2:
+			cpu.addrAbs = uint16(cpu.fetchPC())
+		case 3:
+			cpu.base = uint16(cpu.read(cpu.addrAbs))
+		case 4:
+			cpu.base |= uint16(cpu.read((cpu.addrAbs+1)&0x00FF)) << 8
+			cpu.addrAbs = cpu.base + uint16(cpu.Y)
+		case 5:
+			cpu.fixup()
+		default:
+			cpu.operate()
+		}
+	}
+}
+
+// fetchPC reads the next instruction byte
+func (cpu *MG6502) fetchPC() uint8 {
+	data := cpu.read(cpu.PC)
+	cpu.PC++
+	return data
+}
+
+// index returns the index register of the indexed addressing modes
+func (cpu *MG6502) index() uint8 {
+	switch cpu.lookup[cpu.opcode].addrMode {
+	case AddrModeZPY, AddrModeABY, AddrModeIZY:
+		return cpu.Y
+	}
+	return cpu.X
+}
+
+// fixup is the cycle of an indexed address where the low byte has been
+// indexed but not yet the page. A read without page crossing already has its
+// operand, otherwise the unfixed address is read and, for reads only, one
+// more cycle is taken
+func (cpu *MG6502) fixup() {
+	crossed := cpu.addrAbs&0xFF00 != cpu.base&0xFF00
+	kind := cpu.access[cpu.opcode]
+	if kind == accessRead && !crossed {
+		cpu.operate()
+		return
+	}
+
+	cpu.read(cpu.base&0xFF00 | cpu.addrAbs&0x00FF)
+	if kind == accessRead {
+		cpu.cycles++
+		cpu.instrTotal++
+	}
+}
+
+// operate executes the instruction on the cycle of its operand access. The
+// writes of a read-modify-write instruction are held back for the next cycles
+func (cpu *MG6502) operate() {
+	if cpu.access[cpu.opcode] == accessRMW {
+		cpu.deferWrites = true
+	}
+	cpu.lookup[cpu.opcode].op(cpu)
+	cpu.deferWrites = false
+
+	// always set the unused flag to 1
+	cpu.SetFlag(FlagUnused, true)
+}
+
+// finishSteps performs the remaining accesses of a cycle stepped instruction
+// at once, an interrupt taking over the cpu lets the instruction complete first
+func (cpu *MG6502) finishSteps() {
+	for cpu.step != 0 && cpu.cycles > 0 {
+		cpu.microStep()
+		cpu.cycles--
+	}
+	cpu.step = 0
+}
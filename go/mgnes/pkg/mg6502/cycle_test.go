@@ -0,0 +1,78 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg6502
+
+import "testing"
+
+// clockBus records the cycle of each read with side effects
+type clockBus struct {
+	*RAMBus
+	cycle int
+	reads []cycleRead
+}
+
+type cycleRead struct {
+	cycle int
+	addr  uint16
+}
+
+func (bus *clockBus) CpuRead(addr uint16, readonly bool) uint8 {
+	if !readonly {
+		bus.reads = append(bus.reads, cycleRead{bus.cycle, addr})
+	}
+	return bus.RAMBus.CpuRead(addr, readonly)
+}
+
+func TestCycleSteppedReads(t *testing.T) {
+	cpu, ram := newTestCPU([]uint8{0xAD, 0x10, 0x02}) // LDA $0210
+	ram[0x0210] = 0x42
+	bus := &clockBus{RAMBus: ram}
+	cpu.SetReader(bus)
+	cpu.CycleStepped = true
+
+	// one read on each cycle, the operand last
+	want := []cycleRead{
+		{1, 0x8000},
+		{2, 0x8001},
+		{3, 0x8002},
+		{4, 0x0210},
+	}
+	for bus.cycle = 1; bus.cycle <= 4; bus.cycle++ {
+		cpu.Clock()
+		if bus.cycle < 4 && cpu.A != 0x00 {
+			t.Errorf("A = %#02x after cycle %v, want it loaded on cycle 4", cpu.A, bus.cycle)
+		}
+	}
+	if !cpu.Complete() {
+		t.Errorf("LDA abs not complete after 4 cycles")
+	}
+	if cpu.A != 0x42 {
+		t.Errorf("A = %#02x, want 0x42", cpu.A)
+	}
+	if len(bus.reads) != len(want) {
+		t.Fatalf("reads = %+v, want %+v", bus.reads, want)
+	}
+	for i := range want {
+		if bus.reads[i] != want[i] {
+			t.Errorf("read %v = %+v, want %+v", i, bus.reads[i], want[i])
+		}
+	}
+}
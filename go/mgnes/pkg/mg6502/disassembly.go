@@ -28,28 +28,21 @@ import (
 type Disassembly struct {
 	// Index contains address list
 	Index []uint16
-	// Op maps addr to opcode name
-	Op map[uint16]string
-	// Desc maps addr to opcode addressing mode
-	Desc map[uint16]string
+	// Lines maps addr to the disassembled instruction
+	Lines map[uint16]string
 }
 
-// String implementation
-func (d *Disassembly) Stringify(addr uint16, length int) string {
-	op := d.Op[addr]
-	desc := d.Desc[addr]
-
-	sb := &strings.Builder{}
-	sb.WriteString(op)
-	if sb.Len()+len(desc) > length {
-		sb.WriteRune(' ')
-	} else {
-		for sb.Len()+len(desc) < length {
-			sb.WriteRune(' ')
-		}
+// Stringify returns the line at addr padded with spaces or truncated to
+// exactly width characters, a negative width is treated as 0
+func (d *Disassembly) Stringify(addr uint16, width int) string {
+	if width < 0 {
+		width = 0
 	}
 
-	sb.WriteString(desc)
+	line := d.Lines[addr]
+	if len(line) >= width {
+		return line[:width]
+	}
 
-	return sb.String()
+	return line + strings.Repeat(" ", width-len(line))
 }
@@ -0,0 +1,238 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg6502
+
+import "testing"
+
+func TestStringify(t *testing.T) {
+	d := &Disassembly{
+		Index: []uint16{0x8000},
+		Lines: map[uint16]string{0x8000: "$8000: LDA #$01 {IMM}"},
+	}
+
+	tests := []struct {
+		name  string
+		addr  uint16
+		width int
+		want  string
+	}{
+		{"exact", 0x8000, 21, "$8000: LDA #$01 {IMM}"},
+		{"padded", 0x8000, 24, "$8000: LDA #$01 {IMM}   "},
+		{"truncated", 0x8000, 10, "$8000: LDA"},
+		{"zero width", 0x8000, 0, ""},
+		{"negative width", 0x8000, -1, ""},
+		{"missing line", 0x9000, 3, "   "},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := d.Stringify(test.addr, test.width); got != test.want {
+				t.Errorf("Stringify(%#04x, %v) = %q, want %q", test.addr, test.width, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDisassembleAt(t *testing.T) {
+	tests := []struct {
+		name string
+		addr uint16
+		code []uint8 // placed at addr, wrapping to 0x0000
+		want string
+		len  uint8
+	}{
+		{"implied", 0x8000, []uint8{0xE8}, "$8000: INX {IMP}", 1},
+		{"accumulator", 0x8000, []uint8{0x0A}, "$8000: ASL {IMP}", 1},
+		{"immediate", 0x8000, []uint8{0xA9, 0x10}, "$8000: LDA #$10 {IMM}", 2},
+		{"absolute", 0x8000, []uint8{0x8D, 0x00, 0x02}, "$8000: STA $0200 {ABS}", 3},
+		{"implied at $FFFF", 0xFFFF, []uint8{0xE8}, "$FFFF: INX {IMP}", 1},
+		{"immediate at $FFFF", 0xFFFF, []uint8{0xA9, 0x10}, "$FFFF: LDA #$10 {IMM}", 2},
+		{"absolute at $FFFF", 0xFFFF, []uint8{0x8D, 0x00, 0x02}, "$FFFF: STA $0200 {ABS}", 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bus := NewRAMBus()
+			for i, b := range test.code {
+				bus[test.addr+uint16(i)] = b
+			}
+			cpu := NewMG6502()
+			cpu.SetReader(bus)
+
+			text, length := cpu.DisassembleAt(test.addr)
+			if text != test.want || length != test.len {
+				t.Errorf("DisassembleAt(%#04x) = %q, %v, want %q, %v", test.addr, text, length, test.want, test.len)
+			}
+		})
+	}
+}
+
+func TestDisassembleSymbols(t *testing.T) {
+	bus := NewRAMBus()
+	copy(bus[0x8000:], []uint8{
+		0x20, 0x00, 0x90, // $8000 JSR $9000
+		0x8D, 0x00, 0x02, // $8003 STA $0200
+		0xF0, 0xF8, // $8006 BEQ $8000
+		0x6C, 0x00, 0x30, // $8008 JMP ($3000)
+		0xAD, 0x34, 0x12, // $800B LDA $1234
+	})
+	cpu := NewMG6502()
+	cpu.SetReader(bus)
+
+	symbols := map[uint16]string{
+		0x8000: "Start",
+		0x9000: "Multiply",
+		0x0200: "result",
+		0x3000: "vector",
+	}
+	want := []string{
+		"$8000: JSR Multiply {ABS}",
+		"$8003: STA result {ABS}",
+		"$8006: BEQ $F8 [Start] {REL}",
+		"$8008: JMP (vector) {IND}",
+		// no symbol, the address stays in hex
+		"$800B: LDA $1234 {ABS}",
+	}
+
+	d := cpu.DisassembleSymbols(0x8000, 0x800D, symbols)
+	if len(d.Index) != len(want) {
+		t.Fatalf("%v instructions decoded, want %v: %q", len(d.Index), len(want), d.Lines)
+	}
+	for i, addr := range d.Index {
+		if d.Lines[addr] != want[i] {
+			t.Errorf("line %v = %q, want %q", i, d.Lines[addr], want[i])
+		}
+	}
+
+	// without symbols the output is the one of Disassemble
+	if got := cpu.DisassembleSymbols(0x8000, 0x8002, nil).Lines[0x8000]; got != "$8000: JSR $9000 {ABS}" {
+		t.Errorf("line without symbols = %q", got)
+	}
+}
+
+func TestDisassembleWraparound(t *testing.T) {
+	tests := []struct {
+		name string
+		addr uint16
+		code []uint8 // placed at addr, wrapping to 0x0000
+		want string
+		len  uint8
+	}{
+		{"absolute at $FFFE", 0xFFFE, []uint8{0xAD, 0x34, 0x12}, "$FFFE: LDA $1234 {ABS}", 3},
+		{"absolute at $FFFF", 0xFFFF, []uint8{0x4C, 0x00, 0x80}, "$FFFF: JMP $8000 {ABS}", 3},
+		{"relative at $FFFF", 0xFFFF, []uint8{0xD0, 0x02}, "$FFFF: BNE $02 [$0003] {REL}", 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bus := NewRAMBus()
+			for i, b := range test.code {
+				bus[test.addr+uint16(i)] = b
+			}
+			cpu := NewMG6502()
+			cpu.SetReader(bus)
+
+			text, length := cpu.DisassembleAt(test.addr)
+			if text != test.want || length != test.len {
+				t.Errorf("DisassembleAt(%#04x) = %q, %v, want %q, %v", test.addr, text, length, test.want, test.len)
+			}
+		})
+	}
+
+	// the range ends at $FFFF even though the instruction runs past it
+	bus := NewRAMBus()
+	copy(bus[0xFFFE:], []uint8{0xAD, 0x34})
+	bus[0x0000] = 0x12
+	cpu := NewMG6502()
+	cpu.SetReader(bus)
+
+	d := cpu.Disassemble(0xFFFE, 0xFFFF)
+	if len(d.Index) != 1 || d.Index[0] != 0xFFFE {
+		t.Fatalf("Disassemble($FFFE, $FFFF) index = %x, want [fffe]", d.Index)
+	}
+	if got, want := d.Lines[0xFFFE], "$FFFE: LDA $1234 {ABS}"; got != want {
+		t.Errorf("line = %q, want %q", got, want)
+	}
+}
+
+func TestDisassembleUnofficialNOPs(t *testing.T) {
+	bus := NewRAMBus()
+	copy(bus[0x8000:], []uint8{
+		0x04, 0x10, // NOP zp
+		0x0C, 0x34, 0x12, // NOP abs
+		0x1C, 0x00, 0x02, // NOP abs,X
+		0x80, 0x55, // NOP imm
+		0xA9, 0x01, // LDA #$01, only decoded right if the NOPs are
+	})
+	cpu := NewMG6502()
+	cpu.SetReader(bus)
+
+	want := []struct {
+		addr uint16
+		line string
+	}{
+		{0x8000, "$8000: NOP $10 {ZP0}"},
+		{0x8002, "$8002: NOP $1234 {ABS}"},
+		{0x8005, "$8005: NOP $0200, X {ABX}"},
+		{0x8008, "$8008: NOP #$55 {IMM}"},
+		{0x800A, "$800A: LDA #$01 {IMM}"},
+	}
+
+	d := cpu.Disassemble(0x8000, 0x800B)
+	if len(d.Index) != len(want) {
+		t.Fatalf("%v instructions decoded, want %v: %q", len(d.Index), len(want), d.Lines)
+	}
+	for i, w := range want {
+		if d.Index[i] != w.addr || d.Lines[w.addr] != w.line {
+			t.Errorf("instruction %v = %#04x %q, want %#04x %q", i, d.Index[i], d.Lines[d.Index[i]], w.addr, w.line)
+		}
+	}
+}
+
+func TestUnofficialNOPCycles(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   []uint8
+		x      uint8
+		cycles uint32
+	}{
+		{"0x04 zp", []uint8{0x04, 0x10}, 0x00, 3},
+		{"0x0C abs", []uint8{0x0C, 0x34, 0x12}, 0x00, 4},
+		{"0x1C abs,X", []uint8{0x1C, 0x00, 0x02}, 0x01, 4},
+		{"0x1C abs,X page cross", []uint8{0x1C, 0xFF, 0x02}, 0x01, 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpu, _ := newTestCPU(test.code)
+			cpu.X = test.x
+
+			record := cpu.StepInstruction()
+
+			if record.Cycles != test.cycles {
+				t.Errorf("took %v cycles, want %v", record.Cycles, test.cycles)
+			}
+			if want := 0x8000 + uint16(len(test.code)); cpu.PC != want {
+				t.Errorf("PC = %#04x, want %#04x", cpu.PC, want)
+			}
+		})
+	}
+}
@@ -75,18 +75,18 @@ func amZP0(cpu *MG6502) uint8 {
 // is added to the supplied single byte address. This is useful for iterating through
 // ranges within the first page.
 func amZPX(cpu *MG6502) uint8 {
-	cpu.addrAbs = uint16(cpu.read(cpu.PC) + cpu.X)
+	// the sum wraps around within the zero page
+	cpu.addrAbs = uint16(uint8(cpu.read(cpu.PC) + cpu.X))
 	cpu.PC++
-	cpu.addrAbs &= 0x00FF
 	return 0
 }
 
 // Address Mode: Zero Page with Y Offset
 // Same as above but uses Y Register for offset
 func amZPY(cpu *MG6502) uint8 {
-	cpu.addrAbs = uint16(cpu.read(cpu.PC) + cpu.Y)
+	// the sum wraps around within the zero page
+	cpu.addrAbs = uint16(uint8(cpu.read(cpu.PC) + cpu.Y))
 	cpu.PC++
-	cpu.addrAbs &= 0x00FF
 	return 0
 }
 
@@ -123,6 +123,7 @@ func amABX(cpu *MG6502) uint8 {
 
 	if cpu.addrAbs&0xFF00 != addr&0xFF00 {
 		// page changed
+		cpu.dummyRead(addr&0xFF00 | cpu.addrAbs&0x00FF)
 		return 1
 	} else {
 		return 0
@@ -136,10 +137,12 @@ func amABX(cpu *MG6502) uint8 {
 func amABY(cpu *MG6502) uint8 {
 	addr := cpu.read16(cpu.PC)
 	cpu.PC += 2
+	cpu.addrAbs = addr
 	cpu.addrAbs += uint16(cpu.Y)
 
 	if cpu.addrAbs&0xFF00 != addr&0xFF00 {
 		// page changed
+		cpu.dummyRead(addr&0xFF00 | cpu.addrAbs&0x00FF)
 		return 1
 	} else {
 		return 0
@@ -206,6 +209,7 @@ func amIZY(cpu *MG6502) uint8 {
 	cpu.addrAbs += uint16(cpu.Y)
 
 	if cpu.addrAbs&0xFF00 != (hi << 8) {
+		cpu.dummyRead(hi<<8 | cpu.addrAbs&0x00FF)
 		return 1
 	} else {
 		return 0
@@ -351,6 +355,7 @@ func opASL(cpu *MG6502) uint8 {
 	if cpu.lookup[cpu.opcode].addrMode == AddrModeIMP {
 		cpu.A = uint8(cpu.temp & 0x00FF)
 	} else {
+		cpu.dummyWrite()
 		cpu.write(cpu.addrAbs, uint8(cpu.temp&0x00FF))
 	}
 
@@ -466,16 +471,10 @@ func opBPL(cpu *MG6502) uint8 {
 // Instruction: Break
 // Function: Program Sourced Interrupt
 func opBRK(cpu *MG6502) uint8 {
+	// skip the padding byte, RTI returns past it
 	cpu.PC++
 
-	cpu.SetFlag(FlagInterrupt, true)
-	cpu.pushPC()
-
-	cpu.SetFlag(FlagBreak, true)
-	cpu.push(cpu.FLAG)
-	cpu.SetFlag(FlagBreak, false)
-
-	cpu.PC = cpu.read16(0xFFFE)
+	cpu.interrupt(vectorIRQ, true)
 
 	return 0
 }
@@ -579,6 +578,7 @@ func opCPY(cpu *MG6502) uint8 {
 // Flags Out: N, Z
 func opDEC(cpu *MG6502) uint8 {
 	cpu.fetch()
+	cpu.dummyWrite()
 	cpu.temp = uint16(cpu.fetched - 1)
 	cpu.write(cpu.addrAbs, uint8(cpu.temp&0x00FF))
 	cpu.SetFlag(FlagZero, cpu.temp&0x00FF == 0x0000)
@@ -622,6 +622,7 @@ func opEOR(cpu *MG6502) uint8 {
 // Flags Out: N, Z
 func opINC(cpu *MG6502) uint8 {
 	cpu.fetch()
+	cpu.dummyWrite()
 	cpu.temp = uint16(cpu.fetched + 1)
 	cpu.write(cpu.addrAbs, uint8(cpu.temp&0x00FF))
 	cpu.SetFlag(FlagZero, cpu.temp&0x00FF == 0x0000)
@@ -709,6 +710,7 @@ func opLSR(cpu *MG6502) uint8 {
 	if cpu.lookup[cpu.opcode].addrMode == AddrModeIMP {
 		cpu.A = uint8(cpu.temp & 0x00FF)
 	} else {
+		cpu.dummyWrite()
 		cpu.write(cpu.addrAbs, uint8(cpu.temp&0x00FF))
 	}
 	return 0
@@ -719,7 +721,10 @@ func opNOP(cpu *MG6502) uint8 {
 	// Sadly not all NOPs are equal, Ive added a few here
 	// based on https://wiki.nesdev.com/w/index.php/CPU_unofficial_opcodes
 	// and will add more based on game compatibility, and ultimately
-	// I'd like to cover all illegal opcodes too
+	// I'd like to cover all illegal opcodes too. The ones with an operand
+	// read it like a load does, and the absolute X ones take a cycle more
+	// when crossing a page
+	cpu.fetch()
 	switch cpu.opcode {
 	case 0x1C, 0x3C, 0x5C, 0x7C, 0xDC, 0xFC:
 		return 1
@@ -787,6 +792,7 @@ func opROL(cpu *MG6502) uint8 {
 	if cpu.lookup[cpu.opcode].addrMode == AddrModeIMP {
 		cpu.A = uint8(cpu.temp & 0x00FF)
 	} else {
+		cpu.dummyWrite()
 		cpu.write(cpu.addrAbs, uint8(cpu.temp&0x00FF))
 	}
 	return 0
@@ -803,6 +809,7 @@ func opROR(cpu *MG6502) uint8 {
 	if cpu.lookup[cpu.opcode].addrMode == AddrModeIMP {
 		cpu.A = uint8(cpu.temp & 0x00FF)
 	} else {
+		cpu.dummyWrite()
 		cpu.write(cpu.addrAbs, uint8(cpu.temp&0x00FF))
 	}
 	return 0
@@ -969,8 +976,138 @@ func opTYA(cpu *MG6502) uint8 {
 	return 0
 }
 
-// capture all "unofficial" opcodes with this function.
-// It is functionally identical to a NOP
+// Unofficial opcodes =========================================================
+// The stable unofficial opcodes combine two official instructions, sharing
+// their addressing modes. These are the ones relied upon by real games, see
+// https://wiki.nesdev.com/w/index.php/CPU_unofficial_opcodes
+
+// Instruction: Load Accumulator and X Register
+// Function: A = X = M
+// Flags Out: N, Z
+func opLAX(cpu *MG6502) uint8 {
+	cpu.fetch()
+	cpu.A = cpu.fetched
+	cpu.X = cpu.fetched
+	cpu.SetFlag(FlagZero, cpu.A == 0x00)
+	cpu.SetFlag(FlagNegative, cpu.A&0x80 != 0)
+	return 1
+}
+
+// Instruction: Store Accumulator AND X Register
+// Function: M = A & X
+func opSAX(cpu *MG6502) uint8 {
+	cpu.write(cpu.addrAbs, cpu.A&cpu.X)
+	return 0
+}
+
+// Instruction: Decrement Memory then Compare with Accumulator
+// Function: M = M - 1, C <- A >= M Z <- (A - M) == 0
+// Flags Out: N, C, Z
+func opDCP(cpu *MG6502) uint8 {
+	cpu.fetch()
+	cpu.dummyWrite()
+	value := cpu.fetched - 1
+	cpu.write(cpu.addrAbs, value)
+	cpu.temp = uint16(cpu.A) - uint16(value)
+	cpu.SetFlag(FlagCarry, cpu.A >= value)
+	cpu.SetFlag(FlagZero, cpu.temp&0x00FF == 0x0000)
+	cpu.SetFlag(FlagNegative, cpu.temp&0x0080 != 0)
+	return 0
+}
+
+// Instruction: Increment Memory then Subtract with Borrow In
+// Function: M = M + 1, A = A - M - (1 - C)
+// Flags Out: C, V, N, Z
+func opISC(cpu *MG6502) uint8 {
+	cpu.fetch()
+	cpu.dummyWrite()
+	cpu.fetched++
+	cpu.write(cpu.addrAbs, cpu.fetched)
+
+	// same as SBC
+	value := uint16(cpu.fetched) ^ 0x00FF
+	cpu.temp = uint16(cpu.A) + value + uint16(cpu.GetFlag(FlagCarry))
+	cpu.SetFlag(FlagCarry, cpu.temp&0xFF00 != 0)
+	cpu.SetFlag(FlagZero, cpu.temp&0x00FF == 0)
+	overflow := (cpu.temp ^ uint16(cpu.A)) & ((cpu.temp ^ value) & 0x0080)
+	cpu.SetFlag(FlagOverflow, overflow != 0)
+	cpu.SetFlag(FlagNegative, cpu.temp&0x0080 != 0)
+	cpu.A = uint8(cpu.temp & 0x00FF)
+	return 0
+}
+
+// Instruction: Arithmetic Shift Left then Bitwise Logic OR
+// Function: M = C <- (M << 1) <- 0, A = A | M
+// Flags Out: N, Z, C
+func opSLO(cpu *MG6502) uint8 {
+	cpu.fetch()
+	cpu.dummyWrite()
+	cpu.SetFlag(FlagCarry, cpu.fetched&0x80 != 0)
+	cpu.fetched <<= 1
+	cpu.write(cpu.addrAbs, cpu.fetched)
+	cpu.A |= cpu.fetched
+	cpu.SetFlag(FlagZero, cpu.A == 0x00)
+	cpu.SetFlag(FlagNegative, cpu.A&0x80 != 0)
+	return 0
+}
+
+// Instruction: Rotate Left then Bitwise Logic AND
+// Function: M = C <- (M << 1) <- C, A = A & M
+// Flags Out: N, Z, C
+func opRLA(cpu *MG6502) uint8 {
+	cpu.fetch()
+	cpu.dummyWrite()
+	carry := cpu.GetFlag(FlagCarry)
+	cpu.SetFlag(FlagCarry, cpu.fetched&0x80 != 0)
+	cpu.fetched = cpu.fetched<<1 | carry
+	cpu.write(cpu.addrAbs, cpu.fetched)
+	cpu.A &= cpu.fetched
+	cpu.SetFlag(FlagZero, cpu.A == 0x00)
+	cpu.SetFlag(FlagNegative, cpu.A&0x80 != 0)
+	return 0
+}
+
+// Instruction: Logical Shift Right then Bitwise Logic XOR
+// Function: M = 0 -> (M >> 1) -> C, A = A ^ M
+// Flags Out: N, Z, C
+func opSRE(cpu *MG6502) uint8 {
+	cpu.fetch()
+	cpu.dummyWrite()
+	cpu.SetFlag(FlagCarry, cpu.fetched&0x01 != 0)
+	cpu.fetched >>= 1
+	cpu.write(cpu.addrAbs, cpu.fetched)
+	cpu.A ^= cpu.fetched
+	cpu.SetFlag(FlagZero, cpu.A == 0x00)
+	cpu.SetFlag(FlagNegative, cpu.A&0x80 != 0)
+	return 0
+}
+
+// Instruction: Rotate Right then Add with Carry In
+// Function: M = C -> (M >> 1) -> C, A = A + M + C
+// Flags Out: C, V, N, Z
+func opRRA(cpu *MG6502) uint8 {
+	cpu.fetch()
+	cpu.dummyWrite()
+	carry := cpu.GetFlag(FlagCarry)
+	cpu.SetFlag(FlagCarry, cpu.fetched&0x01 != 0)
+	cpu.fetched = cpu.fetched>>1 | carry<<7
+	cpu.write(cpu.addrAbs, cpu.fetched)
+
+	// same as ADC
+	cpu.temp = uint16(cpu.A) + uint16(cpu.fetched) + uint16(cpu.GetFlag(FlagCarry))
+	cpu.SetFlag(FlagCarry, cpu.temp > 255)
+	cpu.SetFlag(FlagZero, (cpu.temp&0x00FF) == 0)
+	overflow := (^(uint16(cpu.A) ^ uint16(cpu.fetched)) & (uint16(cpu.A) ^ cpu.temp)) & 0x0080
+	cpu.SetFlag(FlagOverflow, overflow != 0)
+	cpu.SetFlag(FlagNegative, cpu.temp&0x80 != 0)
+	cpu.A = uint8(cpu.temp & 0x00FF)
+	return 0
+}
+
+// capture all other "unofficial" opcodes with this function.
+// It is functionally identical to a NOP. This includes the unstable ones
+// such as ANE ($8B) and LXA ($AB), whose results depend on analog effects
+// of the chip and are not emulated
 func opXXX(cpu *MG6502) uint8 {
 	_ = cpu
 	return 0
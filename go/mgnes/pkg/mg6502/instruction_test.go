@@ -0,0 +1,272 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg6502
+
+import "testing"
+
+// newTestCPU returns a cpu on a RAMBus with code loaded at 0x8000 and the
+// reset sequence completed, so the next Clock executes the first instruction
+func newTestCPU(code []uint8) (*MG6502, *RAMBus) {
+	bus := NewRAMBus()
+	copy(bus[0x8000:], code)
+	bus[0xFFFC] = 0x00
+	bus[0xFFFD] = 0x80
+
+	cpu := NewMG6502()
+	cpu.SetReader(bus)
+	cpu.SetWriter(bus)
+	cpu.PowerUp()
+	for !cpu.Complete() {
+		cpu.Clock()
+	}
+	return cpu, bus
+}
+
+// flags compared by the instruction tests
+const flagsNVZC = FlagNegative | FlagOverflow | FlagZero | FlagCarry
+
+func TestUnofficialOpcodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     []uint8
+		a, x     uint8
+		carry    bool
+		mem      uint8 // at the operand address
+		wantA    uint8
+		wantX    uint8
+		wantMem  uint8
+		wantFlag uint8
+	}{
+		{"LAX zp", []uint8{0xA7, 0x10}, 0x00, 0x00, false, 0x80, 0x80, 0x80, 0x80, FlagNegative},
+		{"LAX zp zero", []uint8{0xA7, 0x10}, 0x55, 0x66, false, 0x00, 0x00, 0x00, 0x00, FlagZero},
+		{"LAX abs", []uint8{0xAF, 0x10, 0x00}, 0x00, 0x00, true, 0x42, 0x42, 0x42, 0x42, FlagCarry},
+		// 0xAB is the unstable LXA, a documented NOP
+		{"LAX imm", []uint8{0xAB, 0x10}, 0x55, 0x66, false, 0x00, 0x55, 0x66, 0x00, 0x00},
+		{"SAX zp", []uint8{0x87, 0x10}, 0xF0, 0x3C, false, 0x00, 0xF0, 0x3C, 0x30, 0x00},
+		{"DCP abs equal", []uint8{0xCF, 0x10, 0x00}, 0x40, 0x00, false, 0x41, 0x40, 0x00, 0x40, FlagZero | FlagCarry},
+		{"DCP abs wrap", []uint8{0xCF, 0x10, 0x00}, 0x10, 0x00, true, 0x00, 0x10, 0x00, 0xFF, 0x00},
+		{"DCP abs less", []uint8{0xCF, 0x10, 0x00}, 0x10, 0x00, false, 0x50, 0x10, 0x00, 0x4F, FlagNegative},
+		{"ISC abs", []uint8{0xEF, 0x10, 0x00}, 0x20, 0x00, true, 0x0F, 0x10, 0x00, 0x10, FlagCarry},
+		{"SLO zp", []uint8{0x07, 0x10}, 0x02, 0x00, false, 0x81, 0x02, 0x00, 0x02, FlagCarry},
+		{"RLA zp", []uint8{0x27, 0x10}, 0xFF, 0x00, true, 0x80, 0x01, 0x00, 0x01, FlagCarry},
+		{"SRE zp", []uint8{0x47, 0x10}, 0x01, 0x00, false, 0x03, 0x00, 0x00, 0x01, FlagZero | FlagCarry},
+		{"RRA zp", []uint8{0x67, 0x10}, 0x10, 0x00, true, 0x02, 0x91, 0x00, 0x81, FlagNegative},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpu, bus := newTestCPU(test.code)
+			bus[0x0010] = test.mem
+			cpu.A = test.a
+			cpu.X = test.x
+			cpu.SetFlag(FlagCarry, test.carry)
+
+			cpu.StepInstruction()
+
+			if cpu.A != test.wantA || cpu.X != test.wantX {
+				t.Errorf("A, X = %#02x, %#02x, want %#02x, %#02x", cpu.A, cpu.X, test.wantA, test.wantX)
+			}
+			if bus[0x0010] != test.wantMem {
+				t.Errorf("M = %#02x, want %#02x", bus[0x0010], test.wantMem)
+			}
+			if got := cpu.FLAG & flagsNVZC; got != test.wantFlag {
+				t.Errorf("NVZC = %08b, want %08b", got, test.wantFlag)
+			}
+		})
+	}
+}
+
+func TestADCSBC(t *testing.T) {
+	const (
+		adc = 0x69 // ADC #imm
+		sbc = 0xE9 // SBC #imm
+	)
+
+	tests := []struct {
+		name     string
+		opcode   uint8
+		a, m     uint8
+		carry    bool
+		wantA    uint8
+		wantFlag uint8
+	}{
+		{"0x50+0x50", adc, 0x50, 0x50, false, 0xA0, FlagOverflow | FlagNegative},
+		{"0x50+0xD0", adc, 0x50, 0xD0, false, 0x20, FlagCarry},
+		{"0xD0+0x90", adc, 0xD0, 0x90, false, 0x60, FlagCarry | FlagOverflow},
+		{"0x7F+0x01", adc, 0x7F, 0x01, false, 0x80, FlagOverflow | FlagNegative},
+		{"0x80+0x80", adc, 0x80, 0x80, false, 0x00, FlagCarry | FlagOverflow | FlagZero},
+		{"0xFF+0x01", adc, 0xFF, 0x01, false, 0x00, FlagCarry | FlagZero},
+		{"0x50+0x10", adc, 0x50, 0x10, false, 0x60, 0x00},
+		{"0x7F+0x00 C=1", adc, 0x7F, 0x00, true, 0x80, FlagOverflow | FlagNegative},
+		{"0xFF+0x00 C=1", adc, 0xFF, 0x00, true, 0x00, FlagCarry | FlagZero},
+		{"0x50+0x50 C=1", adc, 0x50, 0x50, true, 0xA1, FlagOverflow | FlagNegative},
+
+		// the carry is the inverted borrow, C=1 subtracts nothing extra
+		{"0x50-0xB0", sbc, 0x50, 0xB0, true, 0xA0, FlagOverflow | FlagNegative},
+		{"0x50-0x70", sbc, 0x50, 0x70, true, 0xE0, FlagNegative},
+		{"0xD0-0x70", sbc, 0xD0, 0x70, true, 0x60, FlagCarry | FlagOverflow},
+		{"0xD0-0x30", sbc, 0xD0, 0x30, true, 0xA0, FlagCarry | FlagNegative},
+		{"0x80-0x01", sbc, 0x80, 0x01, true, 0x7F, FlagCarry | FlagOverflow},
+		{"0x50-0x50", sbc, 0x50, 0x50, true, 0x00, FlagCarry | FlagZero},
+		{"0x50-0x50 C=0", sbc, 0x50, 0x50, false, 0xFF, FlagNegative},
+		{"0x00-0x00 C=0", sbc, 0x00, 0x00, false, 0xFF, FlagNegative},
+		{"0x80-0x00 C=0", sbc, 0x80, 0x00, false, 0x7F, FlagCarry | FlagOverflow},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpu, _ := newTestCPU([]uint8{test.opcode, test.m})
+			cpu.A = test.a
+			cpu.SetFlag(FlagCarry, test.carry)
+
+			cpu.StepInstruction()
+
+			if cpu.A != test.wantA {
+				t.Errorf("A = %#02x, want %#02x", cpu.A, test.wantA)
+			}
+			if got := cpu.FLAG & flagsNVZC; got != test.wantFlag {
+				t.Errorf("NVZC = %08b, want %08b", got, test.wantFlag)
+			}
+		})
+	}
+}
+
+// access is a bus access seen by hookBus
+type access struct {
+	write bool
+	addr  uint16
+	data  uint8
+}
+
+// hookBus records the accesses to 0x0200-0x03FF, the pages the dummy
+// access tests use for their operands
+type hookBus struct {
+	*RAMBus
+	log []access
+}
+
+func (bus *hookBus) CpuRead(addr uint16, readonly bool) uint8 {
+	data := bus.RAMBus.CpuRead(addr, readonly)
+	if addr&0xFE00 == 0x0200 && !readonly {
+		bus.log = append(bus.log, access{false, addr, data})
+	}
+	return data
+}
+
+func (bus *hookBus) CpuWrite(addr uint16, data uint8) {
+	if addr&0xFE00 == 0x0200 {
+		bus.log = append(bus.log, access{true, addr, data})
+	}
+	bus.RAMBus.CpuWrite(addr, data)
+}
+
+func TestDummyAccesses(t *testing.T) {
+	inc := []uint8{0xEE, 0x10, 0x02} // INC $0210
+	lda := []uint8{0xBD, 0xFF, 0x02} // LDA $02FF,X with X = 1
+
+	tests := []struct {
+		name     string
+		code     []uint8
+		accurate bool
+		stepped  bool
+		want     []access
+	}{
+		{"INC abs", inc, false, false, []access{
+			{false, 0x0210, 0x41},
+			{true, 0x0210, 0x42},
+		}},
+		{"INC abs accurate", inc, true, false, []access{
+			{false, 0x0210, 0x41},
+			{true, 0x0210, 0x41},
+			{true, 0x0210, 0x42},
+		}},
+		{"INC abs stepped", inc, false, true, []access{
+			{false, 0x0210, 0x41},
+			{true, 0x0210, 0x41},
+			{true, 0x0210, 0x42},
+		}},
+		{"LDA abs,X page cross", lda, false, false, []access{
+			{false, 0x0300, 0x77},
+		}},
+		// the dummy read is of the unfixed address in the page before
+		{"LDA abs,X page cross accurate", lda, true, false, []access{
+			{false, 0x0200, 0x55},
+			{false, 0x0300, 0x77},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpu, ram := newTestCPU(test.code)
+			bus := &hookBus{RAMBus: ram}
+			cpu.SetReader(bus)
+			cpu.SetWriter(bus)
+			cpu.CycleAccurate = test.accurate
+			cpu.CycleStepped = test.stepped
+			cpu.X = 0x01
+			ram[0x0210] = 0x41
+			ram[0x0200] = 0x55
+			ram[0x0300] = 0x77
+
+			cpu.StepInstruction()
+
+			if len(bus.log) != len(test.want) {
+				t.Fatalf("accesses = %+v, want %+v", bus.log, test.want)
+			}
+			for i, want := range test.want {
+				if bus.log[i] != want {
+					t.Errorf("access %v = %+v, want %+v", i, bus.log[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestZeroPageIndexWrap(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    []uint8
+		stepped bool
+		load    func(cpu *MG6502) uint8
+	}{
+		{"LDA zp,X", []uint8{0xB5, 0xFF}, false, func(cpu *MG6502) uint8 { return cpu.A }},
+		{"LDA zp,X stepped", []uint8{0xB5, 0xFF}, true, func(cpu *MG6502) uint8 { return cpu.A }},
+		{"LDX zp,Y", []uint8{0xB6, 0xFF}, false, func(cpu *MG6502) uint8 { return cpu.X }},
+		{"LDX zp,Y stepped", []uint8{0xB6, 0xFF}, true, func(cpu *MG6502) uint8 { return cpu.X }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpu, ram := newTestCPU(test.code)
+			cpu.CycleStepped = test.stepped
+			cpu.X = 0x02
+			cpu.Y = 0x02
+			ram[0x0001] = 0x42
+			ram[0x0101] = 0x99 // $FF + 2 without the wrap
+
+			cpu.StepInstruction()
+
+			if got := test.load(cpu); got != 0x42 {
+				t.Errorf("loaded %#02x, want 0x42 from $0001", got)
+			}
+		})
+	}
+}
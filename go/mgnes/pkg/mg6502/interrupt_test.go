@@ -0,0 +1,156 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg6502
+
+import "testing"
+
+// irqReturn clocks cpu until it has entered the IRQ handler at 0x9000 and
+// returns the address the interrupt pushed, the instruction the handler
+// returns to
+func irqReturn(t *testing.T, cpu *MG6502, bus *RAMBus) uint16 {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		cpu.Clock()
+		if cpu.Complete() && cpu.PC == 0x9000 {
+			sp := 0x0100 + uint16(cpu.SP)
+			return uint16(bus[sp+3])<<8 | uint16(bus[sp+2])
+		}
+	}
+	t.Fatalf("IRQ not taken after 100 cycles")
+	return 0
+}
+
+func TestIRQPolling(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     []uint8
+		disabled bool
+		carry    bool
+		clocks   int // cycles of the first instruction before the line goes active
+		want     uint16
+	}{
+		// the line is polled at the end of the second to last cycle
+		{"LDA abs cycle 1", []uint8{0xAD, 0x34, 0x12, 0xEA, 0xEA}, false, false, 1, 0x8003},
+		{"LDA abs before the poll", []uint8{0xAD, 0x34, 0x12, 0xEA, 0xEA}, false, false, 2, 0x8003},
+		{"LDA abs after the poll", []uint8{0xAD, 0x34, 0x12, 0xEA, 0xEA}, false, false, 3, 0x8004},
+		{"LDA zp cycle 2", []uint8{0xA5, 0x10, 0xEA, 0xEA}, false, false, 1, 0x8002},
+
+		// a taken branch without page crossing only polls on its first
+		// cycle, an IRQ arriving later waits for the next instruction
+		{"branch taken cycle 1", []uint8{0x90, 0x00, 0xEA, 0xEA}, false, false, 0, 0x8002},
+		{"branch taken cycle 2", []uint8{0x90, 0x00, 0xEA, 0xEA}, false, false, 1, 0x8003},
+		{"branch not taken", []uint8{0x90, 0x00, 0xEA, 0xEA}, false, true, 0, 0x8002},
+
+		// CLI and SEI change the flag after the poll: the instruction
+		// after CLI still runs, SEI still lets the IRQ in
+		{"CLI", []uint8{0x58, 0xEA, 0xEA}, true, false, 0, 0x8002},
+		{"SEI", []uint8{0x78, 0xEA, 0xEA}, false, false, 0, 0x8001},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpu, bus := newTestCPU(test.code)
+			bus[0xFFFE] = 0x00
+			bus[0xFFFF] = 0x90
+			bus[0x9000] = 0xEA
+			cpu.SetFlag(FlagInterrupt, test.disabled)
+			cpu.SetFlag(FlagCarry, test.carry)
+
+			for i := 0; i < test.clocks; i++ {
+				cpu.Clock()
+			}
+			cpu.SetIRQ(true)
+
+			if got := irqReturn(t, cpu, bus); got != test.want {
+				t.Errorf("IRQ returns to %#04x, want %#04x", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIRQDisabled(t *testing.T) {
+	cpu, bus := newTestCPU([]uint8{0xEA, 0xEA, 0xEA, 0xEA})
+	bus[0xFFFE] = 0x00
+	bus[0xFFFF] = 0x90
+	cpu.SetFlag(FlagInterrupt, true)
+	cpu.SetIRQ(true)
+
+	for i := 0; i < 8; i++ {
+		cpu.Clock()
+	}
+	if cpu.PC != 0x8004 {
+		t.Errorf("PC = %#04x with interrupts disabled, want 0x8004", cpu.PC)
+	}
+}
+
+func TestPushedStatus(t *testing.T) {
+	const (
+		entryInstruction = iota
+		entryIRQ
+		entryNMI
+	)
+
+	tests := []struct {
+		name  string
+		code  []uint8
+		entry int
+		want  uint8
+	}{
+		// B is set by the instructions only, U is always pushed set
+		{"BRK", []uint8{0x00, 0x00}, entryInstruction, FlagNegative | FlagBreak | FlagUnused | FlagCarry},
+		{"PHP", []uint8{0x08}, entryInstruction, FlagNegative | FlagBreak | FlagUnused | FlagCarry},
+		{"IRQ", []uint8{0xEA, 0xEA, 0xEA}, entryIRQ, FlagNegative | FlagUnused | FlagCarry},
+		{"NMI", []uint8{0xEA, 0xEA, 0xEA}, entryNMI, FlagNegative | FlagUnused | FlagCarry},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpu, bus := newTestCPU(test.code)
+			bus[0xFFFA], bus[0xFFFB] = 0x00, 0x91
+			bus[0xFFFE], bus[0xFFFF] = 0x00, 0x90
+			// B and U are not stored in the register, the pushed byte
+			// must not depend on them
+			cpu.FLAG = FlagNegative | FlagBreak | FlagCarry
+
+			switch test.entry {
+			case entryInstruction:
+				cpu.StepInstruction()
+			case entryIRQ, entryNMI:
+				if test.entry == entryIRQ {
+					cpu.SetIRQ(true)
+				} else {
+					cpu.SetNMI(true)
+				}
+				// stop on the first instruction of the handler
+				for i := 0; i < 20 && !(cpu.Complete() && cpu.PC >= 0x9000); i++ {
+					cpu.Clock()
+				}
+				if cpu.PC < 0x9000 {
+					t.Fatalf("handler not entered, PC = %#04x", cpu.PC)
+				}
+			}
+
+			if got := bus[0x0100+uint16(cpu.SP)+1]; got != test.want {
+				t.Errorf("pushed status = %08b, want %08b", got, test.want)
+			}
+		})
+	}
+}
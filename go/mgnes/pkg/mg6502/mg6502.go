@@ -21,7 +21,6 @@
 package mg6502
 
 import (
-	"fmt"
 	"mgnes/pkg/log"
 	"strings"
 )
@@ -70,8 +69,30 @@ const (
 	AddrModeIZX
 	// Addressing Mode Indirect Y
 	AddrModeIZY
+	// Addressing Mode Zero Page Indirect, 65C02 only
+	AddrModeZPI
+	// Addressing Mode Absolute Indexed Indirect, 65C02 only
+	AddrModeIAX
 )
 
+// interrupt vectors
+const (
+	vectorNMI   uint16 = 0xFFFA
+	vectorReset uint16 = 0xFFFC
+	vectorIRQ   uint16 = 0xFFFE
+)
+
+// opcodes changing the interrupt disable flag after the interrupt poll
+const (
+	opcodePLP = 0x28
+	opcodeCLI = 0x58
+	opcodeSEI = 0x78
+)
+
+// an NMI asserted during the first four cycles of a BRK or IRQ sequence
+// hijacks it, after that the vector has already been fetched
+const hijackCycles = 3
+
 // MG6502 emulates a 6502 cpu from software perspective
 type MG6502 struct {
 	// registers
@@ -89,6 +110,28 @@ type MG6502 struct {
 	// Flag status register
 	FLAG uint8
 
+	// CycleAccurate enables the dummy bus accesses of the real chip: the
+	// read of the unfixed address when an indexed address crosses a page,
+	// and read-modify-write instructions writing the unmodified value back
+	// before the result. Some mappers rely on them, most programs do not
+	CycleAccurate bool
+
+	// CycleStepped spreads the bus accesses of an instruction over its cycles,
+	// one per Clock, instead of performing them all on the first cycle. It
+	// implies the dummy accesses of CycleAccurate, see cycle.go
+	CycleStepped bool
+
+	// OnInstructionRetired, when set, is called once each time an instruction
+	// completes, with its address, opcode and the cycles it took in total.
+	// Reset and interrupt sequences are not instructions and do not invoke it
+	OnInstructionRetired func(pc uint16, opcode uint8, cycles uint8)
+
+	// OnStackWrap, when set, is called each time a push or pull moves the
+	// stack pointer around page 1, from 0x00 to 0xFF or back. The real chip
+	// wraps silently, but it usually means runaway recursion or an
+	// unbalanced stack
+	OnStackWrap func()
+
 	// bus
 	reader Reader
 	writer Writer
@@ -101,11 +144,39 @@ type MG6502 struct {
 	opcode     uint8  // Instruction byte
 	cycles     uint8  // How many cycles the instruction has remaining
 	clockCount uint32 // Global accumulation of the number of clocks
+	vector     uint16 // Vector of the interrupt sequence in progress, 0 if none
+	instrPC    uint16 // Address of the instruction in progress
+	instrTotal uint8  // Total cycles of the instruction in progress, 0 if none
+
+	// cycle stepped execution
+	step        uint8      // Cycle of the stepped instruction in progress, 0 if none
+	base        uint16     // Unindexed address or zero page pointer
+	pending     [2]pending // Writes held back by a read-modify-write instruction
+	numPending  uint8
+	deferWrites bool
+	access      [256]uint8 // access kind of each opcode
+
+	// interrupt lines and the result of polling them
+	irqLine     bool // level of the IRQ line
+	nmiLine     bool // level of the NMI line
+	nmiEdge     bool // NMI line went active and the NMI is not serviced yet
+	irqTaken    bool // IRQ is serviced after the instruction in progress
+	nmiTaken    bool // NMI is serviced after the instruction in progress
+	wasDisabled bool // interrupt disable flag before the instruction in progress
+
+	variant Variant
+	halt    uint8 // set by WAI and STP
 
 	// lookup table of opcode to instructions
 	lookup []*Instruction
 }
 
+// pending is a bus write held back for a later cycle
+type pending struct {
+	addr uint16
+	data uint8
+}
+
 // NewMG6502 creates and return a 6502 cpu reference
 func NewMG6502() *MG6502 {
 	cpu := &MG6502{
@@ -126,36 +197,66 @@ func NewMG6502() *MG6502 {
 		clockCount: 0,
 		lookup:     newInstructionSet(),
 	}
+	cpu.access = accessKinds(cpu.lookup)
 
 	return cpu
 }
 
-// Reset interrupt
-// Force the 6502 into a known state. This is hard-wired inside the CPU.
-// The registers are set to 0x00, the status register is cleared except for unused
-// bit which remains at 1. An absolute address is read from location 0xFFFC
-// which contains a second address that the program counter is set to. This
-// allows the programmer to jump to a known and programmable location in the memory
-// to start executing from. Typically the programmer would set the value at location
-// 0xFFFC at compile time
-func (cpu *MG6502) Reset() {
-	// get interrupt vector
-	cpu.PC = cpu.read16(0xFFFC)
-
+// PowerUp puts the cpu into the documented 2A03 power-on state.
+// A, X and Y are cleared, the stack pointer is 0xFD and the status register
+// is 0x34 (interrupt disable, break and unused bits set). Like a reset, an
+// absolute address is then read from location 0xFFFC and the program
+// counter is set to it.
+func (cpu *MG6502) PowerUp() {
 	// clear register
 	cpu.A = 0
 	cpu.X = 0
 	cpu.Y = 0
 	cpu.SP = 0xFD
-	cpu.FLAG = 0x00 | FlagUnused
+	cpu.FLAG = FlagInterrupt | FlagBreak | FlagUnused
+
+	cpu.reset()
+}
+
+// Reset interrupt
+// Force the 6502 into a known state. This is hard-wired inside the CPU.
+// Unlike PowerUp, a reset leaves A, X and Y untouched. The real chip runs
+// the same sequence as an interrupt but with the bus writes suppressed, so
+// the stack pointer is decremented by 3 without anything being pushed, and
+// the "disable interrupt" flag is set. An absolute address is read from
+// location 0xFFFC which contains a second address that the program counter
+// is set to. This allows the programmer to jump to a known and programmable
+// location in the memory to start executing from. Typically the programmer
+// would set the value at location 0xFFFC at compile time
+func (cpu *MG6502) Reset() {
+	cpu.SP -= 3
+	cpu.SetFlag(FlagInterrupt, true)
+	cpu.SetFlag(FlagUnused, true)
+
+	cpu.reset()
+}
+
+// reset loads the reset vector and clears internal state, shared by
+// PowerUp and Reset
+func (cpu *MG6502) reset() {
+	// get interrupt vector
+	cpu.PC = cpu.read16(vectorReset)
+	cpu.vector = 0
+	cpu.instrTotal = 0
+	cpu.step = 0
+	cpu.numPending = 0
+	cpu.irqTaken = false
+	cpu.nmiTaken = false
+	cpu.nmiEdge = false
+	cpu.halt = haltNone
 
 	// clear internal stuff
 	cpu.addrRel = 0
 	cpu.addrAbs = 0
 	cpu.fetched = 0
 
-	// reset op time
-	cpu.cycles = 8
+	// the reset sequence takes 7 cycles
+	cpu.cycles = 7
 }
 
 // IRQ Interrupt Request
@@ -169,23 +270,23 @@ func (cpu *MG6502) Reset() {
 // This is implemented by the "RTI" instruction. Once the IRQ has happened,
 // in a similar way to a reset, a programmable address is read from hard coded
 // location 0xFFFE, which is subsequently set to the program counter.
+// IRQ takes effect immediately, devices use SetIRQ instead to get the
+// interrupt latency of the real chip
 func (cpu *MG6502) IRQ() {
+	// a request ends WAI even if it is masked
+	if cpu.halt == haltWait {
+		cpu.halt = haltNone
+	}
+
 	// check interrupt disable flag
 	if cpu.GetFlag(FlagInterrupt) != 0 {
 		return
 	}
 
-	// push the program counter to the stack
-	cpu.pushPC()
-
-	// push status register
-	cpu.SetFlag(FlagBreak, false)
-	cpu.SetFlag(FlagUnused, true)
-	cpu.SetFlag(FlagInterrupt, true)
-	cpu.push(cpu.FLAG)
-
-	// read new program counter vector
-	cpu.PC = cpu.read16(0xFFFE)
+	// an instruction still in progress has already taken effect
+	cpu.finishSteps()
+	cpu.retire()
+	cpu.interrupt(vectorIRQ, false)
 
 	// IRQs take time
 	cpu.cycles = 7
@@ -194,28 +295,164 @@ func (cpu *MG6502) IRQ() {
 // NMI Non-Maskable Interrupt
 // A non-maskable interrupt cannot be ignored. It behaves in exactly the
 // same way as a regular IRQ, but reads the new program counter address
-// form location 0xFFFA
+// form location 0xFFFA. NMI has priority over BRK and IRQ, if it is
+// asserted while one of their sequences has not yet fetched its vector the
+// sequence is hijacked: the state already pushed stays on the stack, B
+// flag included, but execution continues at the NMI vector
 func (cpu *MG6502) NMI() {
+	if cpu.vector == vectorIRQ && cpu.cycles > hijackCycles {
+		cpu.vector = vectorNMI
+		cpu.PC = cpu.read16(vectorNMI)
+		return
+	}
+
+	if cpu.halt == haltWait {
+		cpu.halt = haltNone
+	}
+
+	cpu.finishSteps()
+	cpu.retire()
+	cpu.interrupt(vectorNMI, false)
+
+	cpu.cycles = 7
+}
+
+// SetIRQ drives the IRQ line, active low on the real chip. While the line is
+// asserted and interrupts are not disabled, the IRQ is serviced after the
+// instruction in progress. Unlike IRQ, this respects the interrupt latency
+// of the real chip, see poll
+func (cpu *MG6502) SetIRQ(asserted bool) {
+	cpu.irqLine = asserted
+}
+
+// SetNMI drives the NMI line. The NMI is edge triggered: it is serviced
+// once each time the line goes active, after the instruction in progress.
+// An edge during the first cycles of a BRK or IRQ sequence hijacks it like
+// NMI does
+func (cpu *MG6502) SetNMI(asserted bool) {
+	edge := asserted && !cpu.nmiLine
+	cpu.nmiLine = asserted
+	if !edge {
+		return
+	}
+
+	if cpu.vector == vectorIRQ && cpu.cycles > hijackCycles {
+		cpu.NMI()
+		return
+	}
+	cpu.nmiEdge = true
+}
+
+// poll samples the interrupt lines, the real chip does so at the end of the
+// second to last cycle of each instruction. Whatever is found is serviced
+// instead of the next instruction. CLI, SEI and PLP change the interrupt
+// disable flag after the poll, so the instruction following them still runs
+// with the previous setting. A taken branch that does not cross a page does
+// not poll on its last cycles, see pollCycle
+func (cpu *MG6502) poll() {
+	if cpu.nmiEdge {
+		cpu.nmiTaken = true
+	}
+
+	disabled := cpu.FLAG&FlagInterrupt != 0
+	switch cpu.opcode {
+	case opcodePLP, opcodeCLI, opcodeSEI:
+		disabled = cpu.wasDisabled
+	}
+	cpu.irqTaken = cpu.irqLine && !disabled
+}
+
+// pollCycle returns the remaining cycles of the instruction in progress when
+// the interrupt lines are polled. Interrupt sequences, BRK included, do not
+// poll, so at least one instruction of a handler runs before the next
+// interrupt is serviced
+func (cpu *MG6502) pollCycle() (cycles uint8, ok bool) {
+	if cpu.instrTotal == 0 || cpu.vector != 0 {
+		return 0, false
+	}
+
+	// only the first cycle of a taken branch without page crossing polls
+	if cpu.instrTotal == 3 && cpu.lookup[cpu.opcode].addrMode == AddrModeREL {
+		return 2, true
+	}
+	return 1, true
+}
+
+// service starts the sequence of an interrupt found by poll, it returns
+// false if there is none
+func (cpu *MG6502) service() bool {
+	if cpu.nmiTaken {
+		cpu.nmiTaken = false
+		cpu.nmiEdge = false
+		cpu.irqTaken = false
+		cpu.interrupt(vectorNMI, false)
+		cpu.cycles = 7
+		return true
+	}
+
+	if cpu.irqTaken {
+		cpu.irqTaken = false
+		cpu.interrupt(vectorIRQ, false)
+		cpu.cycles = 7
+		return true
+	}
+
+	return false
+}
+
+// interrupt is the entry sequence shared by BRK, IRQ and NMI. The program
+// counter and status register are pushed to the stack, then the interrupt
+// disable flag is set and the program counter is read from the vector.
+// There is no B flag inside the cpu, it only exists on the pushed copy of
+// the status register: set when pushed by BRK or PHP, clear when pushed by
+// a hardware interrupt. The unused bit is always pushed as 1
+func (cpu *MG6502) interrupt(vector uint16, brk bool) {
 	cpu.pushPC()
 
+	status := cpu.FLAG | FlagUnused
+	if brk {
+		status |= FlagBreak
+	} else {
+		status &^= FlagBreak
+	}
+	cpu.push(status)
+
 	cpu.SetFlag(FlagBreak, false)
-	cpu.SetFlag(FlagUnused, true)
 	cpu.SetFlag(FlagInterrupt, true)
-	cpu.push(cpu.FLAG)
-
-	cpu.PC = cpu.read16(0xFFFA)
+	// the CMOS chip also leaves decimal mode
+	if cpu.variant == Variant65C02 {
+		cpu.SetFlag(FlagDecimal, false)
+	}
 
-	cpu.cycles = 8
+	cpu.vector = vector
+	cpu.PC = cpu.read16(vector)
 }
 
 // Clock perform a clock cycle
 func (cpu *MG6502) Clock() {
-	if cpu.cycles == 0 {
+	if cpu.cycles == 0 && cpu.halt != haltNone && cpu.waiting() {
+		cpu.clockCount++
+		return
+	}
+
+	if cpu.cycles == 0 && !cpu.service() {
+		cpu.instrPC = cpu.PC
+		cpu.wasDisabled = cpu.FLAG&FlagInterrupt != 0
+
+		if log.IsLoggingEnable() {
+			// loggers without fields only get the nestest line
+			log.LF(cpu.NestestLine(),
+				log.F("pc", cpu.PC), log.F("op", cpu.peek(cpu.PC)),
+				log.F("a", cpu.A), log.F("x", cpu.X), log.F("y", cpu.Y),
+				log.F("p", cpu.FLAG), log.F("sp", cpu.SP), log.F("cyc", cpu.clockCount))
+		}
+
 		cpu.opcode = cpu.read(cpu.PC)
 
 		instruction := cpu.lookup[cpu.opcode]
 
-		logPC := cpu.PC
+		// a new instruction ends any interrupt sequence
+		cpu.vector = 0
 
 		// always set the unused flag to 1
 		cpu.SetFlag(FlagUnused, true)
@@ -223,35 +460,28 @@ func (cpu *MG6502) Clock() {
 		cpu.PC++
 		// get instruction cycle cost
 		cpu.cycles = instruction.cycles
-		// perform fetch of immediate data using the required addressing mode
-		addressingCycles := instruction.am(cpu)
-		// perform opcode
-		executionCycles := instruction.op(cpu)
 
-		// the address mode and opcode may altered the number of cycles
-		// this instruction requires before its completed
-		cpu.cycles += addressingCycles & executionCycles
+		if cpu.CycleStepped && cpu.access[cpu.opcode] != accessNone {
+			// the rest of the instruction happens on the next cycles
+			cpu.step = 1
+			cpu.instrTotal = cpu.cycles
+		} else {
+			// perform fetch of immediate data using the required addressing mode
+			addressingCycles := instruction.am(cpu)
+			// perform opcode
+			executionCycles := instruction.op(cpu)
 
-		// always set the unused flag to 1
-		cpu.SetFlag(FlagUnused, true)
+			// the address mode and opcode may altered the number of cycles
+			// this instruction requires before its completed
+			cpu.cycles += addressingCycles & executionCycles
 
-		if log.IsLoggingEnable() {
-			flagString := "NVUBDIZC"
-			flagValues := []uint8{FlagNegative, FlagOverflow, FlagUnused, FlagBreak, FlagDecimal, FlagInterrupt, FlagZero, FlagCarry}
-
-			sb := &strings.Builder{}
-			for i, c := range flagString {
-				if cpu.GetFlag(flagValues[i]) != 0 {
-					sb.WriteRune(c)
-				} else {
-					sb.WriteRune('.')
-				}
-			}
-
-			log.L(fmt.Sprintf("%10d:%02d PC:%04X %s A:%02X X:%02X Y:%02X %s STKP:%02X",
-				cpu.clockCount, 0, logPC, "XXX", cpu.A, cpu.X, cpu.Y,
-				sb.String(), cpu.SP))
+			cpu.instrTotal = cpu.cycles
+
+			// always set the unused flag to 1
+			cpu.SetFlag(FlagUnused, true)
 		}
+	} else if cpu.step != 0 {
+		cpu.microStep()
 	}
 
 	// use for logging
@@ -259,6 +489,28 @@ func (cpu *MG6502) Clock() {
 
 	// decrement the number of cycles remaining for current instruction
 	cpu.cycles--
+
+	if cycles, ok := cpu.pollCycle(); ok && cpu.cycles == cycles {
+		cpu.poll()
+	}
+
+	if cpu.cycles == 0 {
+		cpu.step = 0
+		cpu.retire()
+	}
+}
+
+// retire reports the instruction in progress, if any, to OnInstructionRetired
+func (cpu *MG6502) retire() {
+	if cpu.instrTotal == 0 {
+		return
+	}
+
+	total := cpu.instrTotal
+	cpu.instrTotal = 0
+	if cpu.OnInstructionRetired != nil {
+		cpu.OnInstructionRetired(cpu.instrPC, cpu.opcode, total)
+	}
 }
 
 // Complete indicate the current instruction has completed by returning true.
@@ -268,6 +520,28 @@ func (cpu *MG6502) Complete() bool {
 	return cpu.cycles == 0
 }
 
+// InterruptPending returns true if an interrupt found by the last poll is
+// serviced instead of the next instruction
+func (cpu *MG6502) InterruptPending() bool {
+	return cpu.nmiTaken || cpu.irqTaken
+}
+
+// ResetVector returns the address the reset vector at 0xFFFC points to, it
+// is read without side effects on the bus
+func (cpu *MG6502) ResetVector() uint16 {
+	return cpu.peek16(vectorReset)
+}
+
+// NMIVector returns the address the NMI vector at 0xFFFA points to
+func (cpu *MG6502) NMIVector() uint16 {
+	return cpu.peek16(vectorNMI)
+}
+
+// IRQVector returns the address the IRQ/BRK vector at 0xFFFE points to
+func (cpu *MG6502) IRQVector() uint16 {
+	return cpu.peek16(vectorIRQ)
+}
+
 func (cpu *MG6502) SetReader(reader Reader) {
 	cpu.reader = reader
 }
@@ -283,24 +557,59 @@ func (cpu *MG6502) SetWriter(writer Writer) {
 // human readable form. Its included as part of the emulator because it can take
 // advantage of many of the CPUs internal operations to do this.
 func (cpu *MG6502) Disassemble(start, end uint16) *Disassembly {
+	return cpu.DisassembleSymbols(start, end, nil)
+}
+
+// DisassembleSymbols disassembles a range of memory like Disassemble, but
+// renders absolute and branch target addresses found in symbols as their
+// label, e.g. "JSR ResetRoutine" instead of "JSR $8000"
+func (cpu *MG6502) DisassembleSymbols(start, end uint16, symbols map[uint16]string) *Disassembly {
 	addr := uint32(start)
-	var value, lo, hi uint8
-	var lineAddr uint16
 	disassembly := &Disassembly{
 		Index: []uint16{},
-		Op:    make(map[uint16]string),
-		Desc:  make(map[uint16]string),
+		Lines: make(map[uint16]string),
+	}
+
+	for addr <= uint32(end) {
+		line, length := cpu.disassembleInstruction(uint16(addr), symbols)
+		disassembly.Index = append(disassembly.Index, uint16(addr))
+		disassembly.Lines[uint16(addr)] = line
+		addr += uint32(length)
 	}
 
+	return disassembly
+}
+
+// DisassembleAt decodes the single instruction at addr, text is formatted
+// like the lines of Disassemble and length is the instruction size in bytes
+func (cpu *MG6502) DisassembleAt(addr uint16) (text string, length uint8) {
+	return cpu.disassembleInstruction(addr, nil)
+}
+
+// disassembleInstruction decodes the instruction at start using the
+// addressing mode from the lookup table
+func (cpu *MG6502) disassembleInstruction(start uint16, symbols map[uint16]string) (line string, length uint8) {
+	// operands wrap around at 0xFFFF like the CPU address bus does
+	addr := start
+	var value, lo, hi uint8
+
 	hex := func(n uint32, d uint8) []byte {
-		s := []byte{'0', '0', '0', '0'}
-		for i := d - 1; i != 0; i-- {
+		s := make([]byte, d)
+		for i := int(d) - 1; i >= 0; i-- {
 			s[i] = "0123456789ABCDEF"[n&0xF]
 			n >>= 4
 		}
 		return s
 	}
 
+	// label returns the symbol of target, or target in hex if it has none
+	label := func(target uint16) []byte {
+		if name, ok := symbols[target]; ok {
+			return []byte(name)
+		}
+		return append([]byte{'$'}, hex(uint32(target), 4)...)
+	}
+
 	// Starting at the specified address we read an instruction
 	// byte, which in turn yields information from the lookup table
 	// as to how many additional bytes we need to read and what the
@@ -311,133 +620,138 @@ func (cpu *MG6502) Disassemble(start, end uint16) *Disassembly {
 	// with the readable output
 	sbOp := &strings.Builder{}
 	sbDesc := &strings.Builder{}
-	for addr <= uint32(end) {
-		lineAddr = uint16(addr)
 
-		// Prefix line with instruction address
+	// Prefix line with instruction address
+	sbOp.WriteRune('$')
+	sbOp.Write(hex(uint32(addr), 4))
+	sbOp.WriteString(": ")
+
+	// CpuRead instruction, and get its mnemonic name
+	opcode := cpu.reader.CpuRead(addr, true)
+	opName := cpu.lookup[opcode].name
+	addr++
+	sbOp.WriteString(opName)
+	sbOp.WriteRune(' ')
+
+	// Get oprands from desired locations, and form the
+	// instruction based upon its addressing mode. These
+	// routines mimic the actual fetch routine of the
+	// 6502 in order to get accurate data as part of the
+	// instruction
+	switch cpu.lookup[opcode].addrMode {
+	case AddrModeIMP:
+		sbDesc.WriteString("{IMP}")
+	case AddrModeIMM:
+		value = cpu.reader.CpuRead(addr, true)
+		addr++
+		sbOp.WriteString("#$")
+		sbOp.Write(hex(uint32(value), 2))
+		sbDesc.WriteString("{IMM}")
+	case AddrModeZP0:
+		lo = cpu.reader.CpuRead(addr, true)
+		addr++
+		// hi = 0x00
 		sbOp.WriteRune('$')
-		sbOp.Write(hex(addr, 4))
-		sbOp.WriteString(": ")
-
-		// CpuRead instruction, and get its mnemonic name
-		opcode := cpu.reader.CpuRead(uint16(addr), true)
-		opName := cpu.lookup[opcode].name
+		sbOp.Write(hex(uint32(lo), 2))
+		sbDesc.WriteString("{ZP0}")
+	case AddrModeZPX:
+		lo = cpu.reader.CpuRead(addr, true)
 		addr++
-		sbOp.WriteString(opName)
-		sbOp.WriteRune(' ')
-
-		// Get oprands from desired locations, and form the
-		// instruction based upon its addressing mode. These
-		// routines mimic the actual fetch routine of the
-		// 6502 in order to get accurate data as part of the
-		// instruction
-		switch cpu.lookup[opcode].addrMode {
-		case AddrModeIMP:
-			sbDesc.WriteString("{IMP}")
-		case AddrModeIMM:
-			value = cpu.reader.CpuRead(uint16(addr), true)
-			addr++
-			sbOp.WriteString("#$")
-			sbOp.Write(hex(uint32(value), 2))
-			sbDesc.WriteString("{IMM}")
-		case AddrModeZP0:
-			lo = cpu.reader.CpuRead(uint16(addr), true)
-			addr++
-			// hi = 0x00
-			sbOp.WriteRune('$')
-			sbOp.Write(hex(uint32(lo), 2))
-			sbDesc.WriteString("{ZP0}")
-		case AddrModeZPX:
-			lo = cpu.reader.CpuRead(uint16(addr), true)
-			addr++
-			// hi = 0x00
-			sbOp.WriteRune('$')
-			sbOp.Write(hex(uint32(lo), 2))
-			sbOp.WriteString(", X")
-			sbDesc.WriteString("{ZPX}")
-		case AddrModeZPY:
-			lo = cpu.reader.CpuRead(uint16(addr), true)
-			addr++
-			// hi = 0x00
-			sbOp.WriteRune('$')
-			sbOp.Write(hex(uint32(lo), 2))
-			sbOp.WriteString(", Y")
-			sbDesc.WriteString("{ZPY}")
-		case AddrModeIZX:
-			lo = cpu.reader.CpuRead(uint16(addr), true)
-			addr++
-			// hi = 0x00
-			sbOp.WriteString("($")
-			sbOp.Write(hex(uint32(lo), 2))
-			sbOp.WriteString(", X)")
-			sbDesc.WriteString("{IZX}")
-		case AddrModeIZY:
-			lo = cpu.reader.CpuRead(uint16(addr), true)
-			addr++
-			// hi = 0x00
-			sbOp.WriteString("($")
-			sbOp.Write(hex(uint32(lo), 2))
-			sbOp.WriteString(", Y)")
-			sbDesc.WriteString("{IZY}")
-		case AddrModeABS:
-			lo = cpu.reader.CpuRead(uint16(addr), true)
-			addr++
-			hi = cpu.reader.CpuRead(uint16(addr), true)
-			addr++
-			sbOp.WriteRune('$')
-			sbOp.Write(hex(uint32(hi)<<8|uint32(lo), 4))
-			sbDesc.WriteString("{ABS}")
-		case AddrModeABX:
-			lo = cpu.reader.CpuRead(uint16(addr), true)
-			addr++
-			hi = cpu.reader.CpuRead(uint16(addr), true)
-			addr++
-			sbOp.WriteRune('$')
-			sbOp.Write(hex(uint32(hi)<<8|uint32(lo), 4))
-			sbOp.WriteString(", X")
-			sbDesc.WriteString("{ABX}")
-		case AddrModeABY:
-			lo = cpu.reader.CpuRead(uint16(addr), true)
-			addr++
-			hi = cpu.reader.CpuRead(uint16(addr), true)
-			addr++
-			sbOp.WriteRune('$')
-			sbOp.Write(hex(uint32(hi)<<8|uint32(lo), 4))
-			sbOp.WriteString(", Y")
-			sbDesc.WriteString("{ABY}")
-		case AddrModeIND:
-			lo = cpu.reader.CpuRead(uint16(addr), true)
-			addr++
-			hi = cpu.reader.CpuRead(uint16(addr), true)
-			addr++
-			sbOp.WriteString("($")
-			sbOp.Write(hex(uint32(hi)<<8|uint32(lo), 4))
-			sbOp.WriteString(")")
-			sbDesc.WriteString("{IND}")
-		case AddrModeREL:
-			value = cpu.reader.CpuRead(uint16(addr), true)
-			addr++
-			sbOp.WriteRune('$')
-			sbOp.Write(hex(uint32(value), 2))
-			sbOp.WriteString(" [$")
-			sbOp.Write(hex(addr+uint32(value), 4))
-			sbOp.WriteRune(']')
-			sbDesc.WriteString("{REL}")
-		}
-
-		disassembly.Index = append(disassembly.Index, lineAddr)
-		disassembly.Op[lineAddr] = sbOp.String()
-		if opName != "???" {
-			disassembly.Desc[lineAddr] = sbDesc.String()
-		} else {
-			disassembly.Desc[lineAddr] = ""
-		}
-
-		sbOp.Reset()
-		sbDesc.Reset()
+		// hi = 0x00
+		sbOp.WriteRune('$')
+		sbOp.Write(hex(uint32(lo), 2))
+		sbOp.WriteString(", X")
+		sbDesc.WriteString("{ZPX}")
+	case AddrModeZPY:
+		lo = cpu.reader.CpuRead(addr, true)
+		addr++
+		// hi = 0x00
+		sbOp.WriteRune('$')
+		sbOp.Write(hex(uint32(lo), 2))
+		sbOp.WriteString(", Y")
+		sbDesc.WriteString("{ZPY}")
+	case AddrModeIZX:
+		lo = cpu.reader.CpuRead(addr, true)
+		addr++
+		// hi = 0x00
+		sbOp.WriteString("($")
+		sbOp.Write(hex(uint32(lo), 2))
+		sbOp.WriteString(", X)")
+		sbDesc.WriteString("{IZX}")
+	case AddrModeIZY:
+		lo = cpu.reader.CpuRead(addr, true)
+		addr++
+		// hi = 0x00
+		sbOp.WriteString("($")
+		sbOp.Write(hex(uint32(lo), 2))
+		sbOp.WriteString(", Y)")
+		sbDesc.WriteString("{IZY}")
+	case AddrModeABS:
+		lo = cpu.reader.CpuRead(addr, true)
+		addr++
+		hi = cpu.reader.CpuRead(addr, true)
+		addr++
+		sbOp.Write(label(uint16(hi)<<8 | uint16(lo)))
+		sbDesc.WriteString("{ABS}")
+	case AddrModeABX:
+		lo = cpu.reader.CpuRead(addr, true)
+		addr++
+		hi = cpu.reader.CpuRead(addr, true)
+		addr++
+		sbOp.Write(label(uint16(hi)<<8 | uint16(lo)))
+		sbOp.WriteString(", X")
+		sbDesc.WriteString("{ABX}")
+	case AddrModeABY:
+		lo = cpu.reader.CpuRead(addr, true)
+		addr++
+		hi = cpu.reader.CpuRead(addr, true)
+		addr++
+		sbOp.Write(label(uint16(hi)<<8 | uint16(lo)))
+		sbOp.WriteString(", Y")
+		sbDesc.WriteString("{ABY}")
+	case AddrModeIND:
+		lo = cpu.reader.CpuRead(addr, true)
+		addr++
+		hi = cpu.reader.CpuRead(addr, true)
+		addr++
+		sbOp.WriteRune('(')
+		sbOp.Write(label(uint16(hi)<<8 | uint16(lo)))
+		sbOp.WriteString(")")
+		sbDesc.WriteString("{IND}")
+	case AddrModeZPI:
+		lo = cpu.reader.CpuRead(addr, true)
+		addr++
+		sbOp.WriteString("($")
+		sbOp.Write(hex(uint32(lo), 2))
+		sbOp.WriteString(")")
+		sbDesc.WriteString("{ZPI}")
+	case AddrModeIAX:
+		lo = cpu.reader.CpuRead(addr, true)
+		addr++
+		hi = cpu.reader.CpuRead(addr, true)
+		addr++
+		sbOp.WriteRune('(')
+		sbOp.Write(label(uint16(hi)<<8 | uint16(lo)))
+		sbOp.WriteString(", X)")
+		sbDesc.WriteString("{IAX}")
+	case AddrModeREL:
+		value = cpu.reader.CpuRead(addr, true)
+		addr++
+		sbOp.WriteRune('$')
+		sbOp.Write(hex(uint32(value), 2))
+		// the offset is signed, relative to the next instruction
+		sbOp.WriteString(" [")
+		sbOp.Write(label(addr + uint16(int8(value))))
+		sbOp.WriteRune(']')
+		sbDesc.WriteString("{REL}")
 	}
 
-	return disassembly
+	line = strings.TrimRight(sbOp.String(), " ")
+	if opName != "???" {
+		line += " " + sbDesc.String()
+	}
+	length = uint8(addr - start)
+	return
 }
 
 // GetFlag returns the flag
@@ -458,30 +772,81 @@ func (cpu *MG6502) SetFlag(flag uint8, v bool) {
 	}
 }
 
+// StatusFlags is the status register broken down into its flags
+type StatusFlags struct {
+	N, V, U, B, D, I, Z, C bool
+}
+
+// Flags returns all flags of the status register at once
+func (cpu *MG6502) Flags() StatusFlags {
+	return StatusFlags{
+		N: cpu.FLAG&FlagNegative != 0,
+		V: cpu.FLAG&FlagOverflow != 0,
+		U: cpu.FLAG&FlagUnused != 0,
+		B: cpu.FLAG&FlagBreak != 0,
+		D: cpu.FLAG&FlagDecimal != 0,
+		I: cpu.FLAG&FlagInterrupt != 0,
+		Z: cpu.FLAG&FlagZero != 0,
+		C: cpu.FLAG&FlagCarry != 0,
+	}
+}
+
+// SetFlags sets all flags of the status register at once
+func (cpu *MG6502) SetFlags(flags StatusFlags) {
+	cpu.FLAG = 0
+	cpu.SetFlag(FlagNegative, flags.N)
+	cpu.SetFlag(FlagOverflow, flags.V)
+	cpu.SetFlag(FlagUnused, flags.U)
+	cpu.SetFlag(FlagBreak, flags.B)
+	cpu.SetFlag(FlagDecimal, flags.D)
+	cpu.SetFlag(FlagInterrupt, flags.I)
+	cpu.SetFlag(FlagZero, flags.Z)
+	cpu.SetFlag(FlagCarry, flags.C)
+}
+
 // push data byte to stack
 func (cpu *MG6502) push(data uint8) {
 	cpu.write(0x0100+uint16(cpu.SP), data)
-	cpu.SP--
+	cpu.decSP()
 }
 
 // pop data from stack
 func (cpu *MG6502) pop() uint8 {
-	cpu.SP++
+	cpu.incSP()
 	return cpu.read(0x0100 + uint16(cpu.SP))
 }
 
 // push program counter to the stack
 func (cpu *MG6502) pushPC() {
 	cpu.write(0x0100+uint16(cpu.SP), uint8((cpu.PC>>8)&0x00FF))
-	cpu.SP--
+	cpu.decSP()
 	cpu.write(0x0100+uint16(cpu.SP), uint8(cpu.PC&0x00FF))
-	cpu.SP--
+	cpu.decSP()
 }
 
-// pop program counter from the stack
+// pop program counter from the stack, the high byte is read from the next
+// location in page 1 even if SP wraps in between
 func (cpu *MG6502) popPC() {
-	cpu.SP++
-	cpu.PC = cpu.read16(0x0100 + uint16(cpu.SP))
+	cpu.incSP()
+	lo := uint16(cpu.read(0x0100 + uint16(cpu.SP)))
+	cpu.incSP()
+	hi := uint16(cpu.read(0x0100 + uint16(cpu.SP)))
+	cpu.PC = hi<<8 | lo
+}
+
+// decSP moves the stack pointer down after a push
+func (cpu *MG6502) decSP() {
+	if cpu.SP == 0x00 && cpu.OnStackWrap != nil {
+		cpu.OnStackWrap()
+	}
+	cpu.SP--
+}
+
+// incSP moves the stack pointer up before a pull
+func (cpu *MG6502) incSP() {
+	if cpu.SP == 0xFF && cpu.OnStackWrap != nil {
+		cpu.OnStackWrap()
+	}
 	cpu.SP++
 }
 
@@ -505,11 +870,39 @@ func (cpu *MG6502) read16(addr uint16) uint16 {
 	return hi<<8 | lo
 }
 
+// peek16 reads a 16-bit data like read16, but readonly so devices on the bus
+// keep their state
+func (cpu *MG6502) peek16(addr uint16) uint16 {
+	lo := uint16(cpu.peek(addr))
+	hi := uint16(cpu.peek(addr + 1))
+	return hi<<8 | lo
+}
+
 // writes a byte to the bus at the specified address
 func (cpu *MG6502) write(addr uint16, data uint8) {
+	if cpu.deferWrites && cpu.numPending < uint8(len(cpu.pending)) {
+		cpu.pending[cpu.numPending] = pending{addr, data}
+		cpu.numPending++
+		return
+	}
 	cpu.writer.CpuWrite(addr, data)
 }
 
+// dummyRead reads addr and discards the data, only in cycle accurate mode
+func (cpu *MG6502) dummyRead(addr uint16) {
+	if cpu.CycleAccurate {
+		cpu.read(addr)
+	}
+}
+
+// dummyWrite writes the fetched value back to where it was read from,
+// only in cycle accurate or cycle stepped mode
+func (cpu *MG6502) dummyWrite() {
+	if cpu.CycleAccurate || cpu.CycleStepped {
+		cpu.write(cpu.addrAbs, cpu.fetched)
+	}
+}
+
 // This function sources the data used by the instruction into
 // a convenient numeric variable. Some instructions dont have to
 // fetch data as the source is implied by the instruction. For example
@@ -0,0 +1,261 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg6502
+
+import "testing"
+
+func TestPowerUpReset(t *testing.T) {
+	cpu, bus := newTestCPU([]uint8{
+		0xA9, 0x11, // $8000 LDA #$11
+		0xA2, 0x22, // $8002 LDX #$22
+		0xA0, 0x33, // $8004 LDY #$33
+		0x58,             // $8006 CLI
+		0x4C, 0x07, 0x80, // $8007 JMP $8007
+	})
+	cpu.PowerUp()
+
+	// PowerUp clears the registers whatever ran before
+	if cpu.A != 0 || cpu.X != 0 || cpu.Y != 0 {
+		t.Errorf("A, X, Y = %#02x, %#02x, %#02x after power-up, want 0", cpu.A, cpu.X, cpu.Y)
+	}
+	if cpu.SP != 0xFD {
+		t.Errorf("SP = %#02x after power-up, want 0xFD", cpu.SP)
+	}
+	if cpu.FLAG != 0x34 {
+		t.Errorf("FLAG = %08b after power-up, want 00110100", cpu.FLAG)
+	}
+	if cpu.PC != 0x8000 {
+		t.Errorf("PC = %#04x after power-up, want 0x8000", cpu.PC)
+	}
+	for !cpu.Complete() {
+		cpu.Clock()
+	}
+
+	for i := 0; i < 5; i++ {
+		cpu.StepInstruction()
+	}
+	if cpu.GetFlag(FlagInterrupt) != 0 {
+		t.Fatalf("I flag set after CLI")
+	}
+
+	// Reset keeps A, X and Y, and moves SP down by 3 without pushing
+	sp := cpu.SP
+	stack := bus[0x0100:0x0200]
+	before := string(stack)
+	bus[0xFFFC], bus[0xFFFD] = 0x04, 0x80
+	cpu.Reset()
+	if cpu.A != 0x11 || cpu.X != 0x22 || cpu.Y != 0x33 {
+		t.Errorf("A, X, Y = %#02x, %#02x, %#02x after reset, want 0x11, 0x22, 0x33", cpu.A, cpu.X, cpu.Y)
+	}
+	if cpu.SP != sp-3 {
+		t.Errorf("SP = %#02x after reset, want %#02x", cpu.SP, sp-3)
+	}
+	if cpu.GetFlag(FlagInterrupt) == 0 {
+		t.Errorf("I flag clear after reset")
+	}
+	if string(stack) != before {
+		t.Errorf("reset wrote to the stack")
+	}
+	if cpu.PC != 0x8004 {
+		t.Errorf("PC = %#04x after reset, want 0x8004", cpu.PC)
+	}
+
+	// the reset sequence takes 7 cycles
+	clocks := 0
+	for !cpu.Complete() {
+		cpu.Clock()
+		clocks++
+	}
+	if clocks != 7 {
+		t.Errorf("reset took %v cycles, want 7", clocks)
+	}
+}
+
+func TestOnInstructionRetired(t *testing.T) {
+	tests := []struct {
+		name    string
+		stepped bool
+	}{
+		{"atomic", false},
+		{"cycle stepped", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu, _ := newTestCPU(benchProgram)
+			cpu.CycleStepped = tt.stepped
+
+			type retired struct {
+				pc     uint16
+				opcode uint8
+				cycles uint8
+			}
+			var got []retired
+			cpu.OnInstructionRetired = func(pc uint16, opcode uint8, cycles uint8) {
+				got = append(got, retired{pc, opcode, cycles})
+			}
+
+			// long enough to loop in the multiply and branch back to the start
+			for i := 0; i < 60; i++ {
+				record := cpu.StepInstruction()
+				if len(got) != i+1 {
+					t.Fatalf("instruction %v at %#04x retired %v times", i, record.PC, len(got)-i)
+				}
+				want := retired{record.PC, record.Opcode, uint8(record.Cycles)}
+				if got[i] != want {
+					t.Fatalf("instruction %v retired as %+v, want %+v", i, got[i], want)
+				}
+			}
+
+			// the first one is LDX #$0A, taking 2 cycles
+			if want := (retired{0x8000, 0xA2, 2}); got[0] != want {
+				t.Errorf("first retired %+v, want %+v", got[0], want)
+			}
+
+			// the reset sequence is not an instruction
+			got = got[:0]
+			cpu.Reset()
+			for !cpu.Complete() {
+				cpu.Clock()
+			}
+			if len(got) != 0 {
+				t.Errorf("reset retired %+v", got)
+			}
+		})
+	}
+}
+
+func TestOnStackWrap(t *testing.T) {
+	cpu, bus := newTestCPU([]uint8{
+		0xA2, 0x01, // $8000 LDX #$01
+		0x9A,       // $8002 TXS
+		0xA9, 0x11, // $8003 LDA #$11
+		0x48,       // $8005 PHA, to $0101
+		0xA9, 0x22, // $8006 LDA #$22
+		0x48, // $8008 PHA, to $0100 and SP wraps to $FF
+		0x68, // $8009 PLA, SP wraps to $00 and from $0100
+		0x68, // $800A PLA, from $0101
+	})
+	wraps := 0
+	cpu.OnStackWrap = func() { wraps++ }
+
+	tests := []struct {
+		sp    uint8
+		wraps int
+	}{
+		{0xFD, 0}, // LDX
+		{0x01, 0}, // TXS
+		{0x01, 0}, // LDA
+		{0x00, 0}, // PHA
+		{0x00, 0}, // LDA
+		{0xFF, 1}, // PHA past $0100
+		{0x00, 2}, // PLA past $01FF
+		{0x01, 2}, // PLA
+	}
+	for i, tt := range tests {
+		record := cpu.StepInstruction()
+		if cpu.SP != tt.sp || wraps != tt.wraps {
+			t.Fatalf("step %v at %#04x: SP = %#02x, wraps = %v, want %#02x, %v",
+				i, record.PC, cpu.SP, wraps, tt.sp, tt.wraps)
+		}
+	}
+
+	// the wrap is silent, the bytes stay in page 1
+	if bus[0x0100] != 0x22 || bus[0x0101] != 0x11 {
+		t.Errorf("stack = %#02x, %#02x, want 0x22, 0x11", bus[0x0100], bus[0x0101])
+	}
+	if cpu.A != 0x11 {
+		t.Errorf("A = %#02x after pulls, want 0x11", cpu.A)
+	}
+}
+
+// peekBus counts the reads with side effects
+type peekBus struct {
+	*RAMBus
+	reads int
+}
+
+func (bus *peekBus) CpuRead(addr uint16, readonly bool) uint8 {
+	if !readonly {
+		bus.reads++
+	}
+	return bus.RAMBus.CpuRead(addr, readonly)
+}
+
+func TestVectors(t *testing.T) {
+	cpu, ram := newTestCPU(nil)
+	ram[0xFFFA], ram[0xFFFB] = 0x34, 0x12
+	ram[0xFFFC], ram[0xFFFD] = 0x78, 0x56
+	ram[0xFFFE], ram[0xFFFF] = 0xBC, 0x9A
+	bus := &peekBus{RAMBus: ram}
+	cpu.SetReader(bus)
+
+	if got := cpu.NMIVector(); got != 0x1234 {
+		t.Errorf("NMIVector() = %#04x, want 0x1234", got)
+	}
+	if got := cpu.ResetVector(); got != 0x5678 {
+		t.Errorf("ResetVector() = %#04x, want 0x5678", got)
+	}
+	if got := cpu.IRQVector(); got != 0x9ABC {
+		t.Errorf("IRQVector() = %#04x, want 0x9ABC", got)
+	}
+	if bus.reads != 0 {
+		t.Errorf("reading the vectors made %v bus reads with side effects", bus.reads)
+	}
+}
+
+func TestFlagsRoundTrip(t *testing.T) {
+	cpu := NewMG6502()
+
+	// every status value survives Flags and SetFlags
+	for p := 0; p < 0x100; p++ {
+		cpu.FLAG = uint8(p)
+		flags := cpu.Flags()
+		cpu.FLAG = ^uint8(p)
+		cpu.SetFlags(flags)
+		if cpu.FLAG != uint8(p) {
+			t.Fatalf("FLAG %08b became %08b", p, cpu.FLAG)
+		}
+	}
+
+	// each field maps to its own bit
+	tests := []struct {
+		flags StatusFlags
+		want  uint8
+	}{
+		{StatusFlags{N: true}, FlagNegative},
+		{StatusFlags{V: true}, FlagOverflow},
+		{StatusFlags{U: true}, FlagUnused},
+		{StatusFlags{B: true}, FlagBreak},
+		{StatusFlags{D: true}, FlagDecimal},
+		{StatusFlags{I: true}, FlagInterrupt},
+		{StatusFlags{Z: true}, FlagZero},
+		{StatusFlags{C: true}, FlagCarry},
+	}
+	for _, tt := range tests {
+		cpu.SetFlags(tt.flags)
+		if cpu.FLAG != tt.want {
+			t.Errorf("SetFlags(%+v) FLAG = %08b, want %08b", tt.flags, cpu.FLAG, tt.want)
+		}
+		if got := cpu.Flags(); got != tt.flags {
+			t.Errorf("Flags() = %+v, want %+v", got, tt.flags)
+		}
+	}
+}
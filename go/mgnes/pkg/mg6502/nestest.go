@@ -0,0 +1,247 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg6502
+
+import "strconv"
+
+// NestestLine formats the instruction at the program counter in the
+// Nintendulator log format used by the nestest.log golden file, e.g.
+//
+//	C000  4C F5 C5  JMP $C5F5                       A:00 X:00 Y:00 P:24 SP:FD CYC:7
+//
+// The PPU column is not part of the line. It should be called between
+// instructions, before the one at the program counter is executed, since
+// the operand values shown are read from the bus as they are at that time.
+// All reads are read only
+func (cpu *MG6502) NestestLine() string {
+	return string(cpu.AppendNestestLine(nil))
+}
+
+// AppendNestestLine appends the line of NestestLine to dst and returns the
+// extended buffer. It does not allocate when dst has room for the line, so
+// tracing every instruction stays cheap
+func (cpu *MG6502) AppendNestestLine(dst []byte) []byte {
+	pc := cpu.PC
+	opcode := cpu.peek(pc)
+	instruction := cpu.lookup[opcode]
+
+	length := operandLength(instruction.addrMode)
+	var lo, hi uint8
+	if length > 0 {
+		lo = cpu.peek(pc + 1)
+	}
+	if length > 1 {
+		hi = cpu.peek(pc + 2)
+	}
+	operand := uint16(hi)<<8 | uint16(lo)
+
+	dst = appendHex16(dst, pc)
+	dst = append(dst, ' ', ' ')
+
+	// instruction bytes
+	column := len(dst)
+	dst = appendHex8(dst, opcode)
+	for i := uint16(1); i <= length; i++ {
+		dst = append(dst, ' ')
+		dst = appendHex8(dst, cpu.peek(pc+i))
+	}
+	dst = pad(dst, column+8)
+
+	marker := byte(' ')
+	if unofficial(opcode, instruction.name) {
+		marker = '*'
+	}
+	dst = append(dst, ' ', marker)
+
+	column = len(dst)
+	if instruction.name == "ISC" {
+		// Nintendulator spelling
+		dst = append(dst, "ISB"...)
+	} else {
+		dst = append(dst, instruction.name...)
+	}
+
+	switch instruction.addrMode {
+	case AddrModeIMP:
+		// accumulator versions of ASL, LSR, ROL and ROR
+		switch opcode {
+		case 0x0A, 0x2A, 0x4A, 0x6A:
+			dst = append(dst, " A"...)
+		}
+	case AddrModeIMM:
+		dst = append(dst, " #$"...)
+		dst = appendHex8(dst, lo)
+	case AddrModeZP0:
+		dst = append(dst, " $"...)
+		dst = appendHex8(dst, lo)
+		dst = cpu.appendValue(dst, uint16(lo))
+	case AddrModeZPX, AddrModeZPY:
+		index, name := cpu.X, ",X @ "
+		if instruction.addrMode == AddrModeZPY {
+			index, name = cpu.Y, ",Y @ "
+		}
+		addr := uint16(lo + index)
+		dst = append(dst, " $"...)
+		dst = appendHex8(dst, lo)
+		dst = append(dst, name...)
+		dst = appendHex8(dst, uint8(addr))
+		dst = cpu.appendValue(dst, addr)
+	case AddrModeREL:
+		target := pc + 2 + uint16(int8(lo))
+		dst = append(dst, " $"...)
+		dst = appendHex16(dst, target)
+	case AddrModeABS:
+		dst = append(dst, " $"...)
+		dst = appendHex16(dst, operand)
+		if instruction.name != "JMP" && instruction.name != "JSR" {
+			dst = cpu.appendValue(dst, operand)
+		}
+	case AddrModeABX, AddrModeABY:
+		index, name := cpu.X, ",X @ "
+		if instruction.addrMode == AddrModeABY {
+			index, name = cpu.Y, ",Y @ "
+		}
+		addr := operand + uint16(index)
+		dst = append(dst, " $"...)
+		dst = appendHex16(dst, operand)
+		dst = append(dst, name...)
+		dst = appendHex16(dst, addr)
+		dst = cpu.appendValue(dst, addr)
+	case AddrModeIND:
+		// the pointer high byte does not cross a page boundary
+		target := uint16(cpu.peek(operand&0xFF00|(operand+1)&0x00FF))<<8 | uint16(cpu.peek(operand))
+		dst = append(dst, " ($"...)
+		dst = appendHex16(dst, operand)
+		dst = append(dst, ") = "...)
+		dst = appendHex16(dst, target)
+	case AddrModeIZX:
+		ptr := lo + cpu.X
+		addr := cpu.peekZP16(ptr)
+		dst = append(dst, " ($"...)
+		dst = appendHex8(dst, lo)
+		dst = append(dst, ",X) @ "...)
+		dst = appendHex8(dst, ptr)
+		dst = append(dst, " = "...)
+		dst = appendHex16(dst, addr)
+		dst = cpu.appendValue(dst, addr)
+	case AddrModeZPI:
+		addr := cpu.peekZP16(lo)
+		dst = append(dst, " ($"...)
+		dst = appendHex8(dst, lo)
+		dst = append(dst, ") = "...)
+		dst = appendHex16(dst, addr)
+		dst = cpu.appendValue(dst, addr)
+	case AddrModeIAX:
+		ptr := operand + uint16(cpu.X)
+		target := uint16(cpu.peek(ptr+1))<<8 | uint16(cpu.peek(ptr))
+		dst = append(dst, " ($"...)
+		dst = appendHex16(dst, operand)
+		dst = append(dst, ",X) = "...)
+		dst = appendHex16(dst, target)
+	case AddrModeIZY:
+		base := cpu.peekZP16(lo)
+		addr := base + uint16(cpu.Y)
+		dst = append(dst, " ($"...)
+		dst = appendHex8(dst, lo)
+		dst = append(dst, "),Y = "...)
+		dst = appendHex16(dst, base)
+		dst = append(dst, " @ "...)
+		dst = appendHex16(dst, addr)
+		dst = cpu.appendValue(dst, addr)
+	}
+	dst = pad(dst, column+31)
+
+	dst = append(dst, " A:"...)
+	dst = appendHex8(dst, cpu.A)
+	dst = append(dst, " X:"...)
+	dst = appendHex8(dst, cpu.X)
+	dst = append(dst, " Y:"...)
+	dst = appendHex8(dst, cpu.Y)
+	dst = append(dst, " P:"...)
+	dst = appendHex8(dst, cpu.FLAG)
+	dst = append(dst, " SP:"...)
+	dst = appendHex8(dst, cpu.SP)
+	dst = append(dst, " CYC:"...)
+	return strconv.AppendUint(dst, uint64(cpu.clockCount), 10)
+}
+
+// appendValue appends " = " and the byte at addr
+func (cpu *MG6502) appendValue(dst []byte, addr uint16) []byte {
+	dst = append(dst, " = "...)
+	return appendHex8(dst, cpu.peek(addr))
+}
+
+// appendHex8 appends v as 2 upper case hex digits
+func appendHex8(dst []byte, v uint8) []byte {
+	const digits = "0123456789ABCDEF"
+	return append(dst, digits[v>>4], digits[v&0x0F])
+}
+
+// appendHex16 appends v as 4 upper case hex digits
+func appendHex16(dst []byte, v uint16) []byte {
+	return appendHex8(appendHex8(dst, uint8(v>>8)), uint8(v))
+}
+
+// pad appends spaces until dst is n bytes long, like a %-*s verb
+func pad(dst []byte, n int) []byte {
+	for len(dst) < n {
+		dst = append(dst, ' ')
+	}
+	return dst
+}
+
+// unofficial returns true for the opcodes marked with '*' in the log. The
+// unofficial NOPs and 0xEB share their mnemonic with an official opcode
+func unofficial(opcode uint8, name string) bool {
+	switch name {
+	case "???", "LAX", "SAX", "DCP", "ISC", "SLO", "RLA", "SRE", "RRA":
+		return true
+	case "NOP":
+		return opcode != 0xEA
+	case "SBC":
+		return opcode == 0xEB
+	}
+	return false
+}
+
+// operandLength returns the number of operand bytes following the opcode
+// for the addressing mode
+func operandLength(addrMode int) uint16 {
+	switch addrMode {
+	case AddrModeIMP:
+		return 0
+	case AddrModeABS, AddrModeABX, AddrModeABY, AddrModeIND, AddrModeIAX:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// peek reads a byte without side effects on the bus
+func (cpu *MG6502) peek(addr uint16) uint8 {
+	return cpu.reader.CpuRead(addr, true)
+}
+
+// peekZP16 reads a 16-bit pointer from zero page, the high byte wraps
+// around within the zero page
+func (cpu *MG6502) peekZP16(addr uint8) uint16 {
+	return uint16(cpu.peek(uint16(addr+1)))<<8 | uint16(cpu.peek(uint16(addr)))
+}
@@ -0,0 +1,136 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg6502
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"testing"
+)
+
+func TestNestestLine(t *testing.T) {
+	type registers struct {
+		a, x, y, p, sp uint8
+		cycles         uint32
+	}
+	initial := registers{0x00, 0x00, 0x00, 0x24, 0xFD, 7}
+	running := registers{0xAA, 0x97, 0x4E, 0xEF, 0xF5, 100}
+
+	tests := []struct {
+		name string
+		pc   uint16
+		code []uint8
+		regs registers
+		want string
+	}{
+		{"JMP", 0xC000, []uint8{0x4C, 0xF5, 0xC5}, initial,
+			"C000  4C F5 C5  JMP $C5F5                       A:00 X:00 Y:00 P:24 SP:FD CYC:7"},
+		{"LDX imm", 0xC5F5, []uint8{0xA2, 0x00}, running,
+			"C5F5  A2 00     LDX #$00                        A:AA X:97 Y:4E P:EF SP:F5 CYC:100"},
+		{"NOP zp", 0xC6BD, []uint8{0x04, 0xA9}, running,
+			"C6BD  04 A9    *NOP $A9 = 00                    A:AA X:97 Y:4E P:EF SP:F5 CYC:100"},
+		{"NOP abs", 0xC6BF, []uint8{0x0C, 0x00, 0x03}, running,
+			"C6BF  0C 00 03 *NOP $0300 = 89                  A:AA X:97 Y:4E P:EF SP:F5 CYC:100"},
+		{"NOP implied", 0xC6C2, []uint8{0x1A}, running,
+			"C6C2  1A       *NOP                             A:AA X:97 Y:4E P:EF SP:F5 CYC:100"},
+		{"NOP abs,X", 0xC6C3, []uint8{0x1C, 0xA9, 0xA9}, running,
+			"C6C3  1C A9 A9 *NOP $A9A9,X @ AA40 = 00         A:AA X:97 Y:4E P:EF SP:F5 CYC:100"},
+		{"SBC unofficial", 0xE543, []uint8{0xEB, 0x40}, running,
+			"E543  EB 40    *SBC #$40                        A:AA X:97 Y:4E P:EF SP:F5 CYC:100"},
+		{"NOP official", 0xC6C6, []uint8{0xEA}, running,
+			"C6C6  EA        NOP                             A:AA X:97 Y:4E P:EF SP:F5 CYC:100"},
+		{"ISB", 0xC6C7, []uint8{0xE7, 0x47}, running,
+			"C6C7  E7 47    *ISB $47 = 00                    A:AA X:97 Y:4E P:EF SP:F5 CYC:100"},
+		{"LSR A", 0xC6C9, []uint8{0x4A}, running,
+			"C6C9  4A        LSR A                           A:AA X:97 Y:4E P:EF SP:F5 CYC:100"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bus := NewRAMBus()
+			copy(bus[test.pc:], test.code)
+			bus[0x0300] = 0x89
+
+			cpu := NewMG6502()
+			cpu.SetReader(bus)
+			cpu.SetWriter(bus)
+			cpu.PC = test.pc
+			cpu.A, cpu.X, cpu.Y = test.regs.a, test.regs.x, test.regs.y
+			cpu.FLAG, cpu.SP = test.regs.p, test.regs.sp
+			cpu.clockCount = test.regs.cycles
+
+			if got := cpu.NestestLine(); got != test.want {
+				t.Errorf("NestestLine()\n got %q\nwant %q", got, test.want)
+			}
+		})
+	}
+}
+
+// ppuColumn matches the PPU column of nestest.log, which NestestLine omits
+var ppuColumn = regexp.MustCompile(` PPU:\s*\d+,\s*\d+`)
+
+// TestNestestLog runs nestest.nes in automated mode, starting at 0xC000, and
+// compares the trace against the golden log up to the first mismatch. The
+// ROM and log are not distributed with the source, put them in testdata to
+// run it
+func TestNestestLog(t *testing.T) {
+	rom, err := os.ReadFile("testdata/nestest.nes")
+	if err != nil {
+		t.Skip("testdata/nestest.nes not found")
+	}
+	golden, err := os.Open("testdata/nestest.log")
+	if err != nil {
+		t.Skip("testdata/nestest.log not found")
+	}
+	defer golden.Close()
+
+	// NROM with a single 16KB PRG bank, mirrored at 0x8000 and 0xC000
+	const prgSize = 0x4000
+	if len(rom) < 16+prgSize {
+		t.Fatalf("nestest.nes is %v bytes, too short", len(rom))
+	}
+	bus := NewRAMBus()
+	copy(bus[0x8000:], rom[16:16+prgSize])
+	copy(bus[0xC000:], rom[16:16+prgSize])
+
+	cpu := NewMG6502()
+	cpu.SetReader(bus)
+	cpu.SetWriter(bus)
+	cpu.PowerUp()
+	for !cpu.Complete() {
+		cpu.Clock()
+	}
+	cpu.PC = 0xC000
+	cpu.FLAG = 0x24
+
+	scanner := bufio.NewScanner(golden)
+	for n := 1; scanner.Scan(); n++ {
+		want := ppuColumn.ReplaceAllString(scanner.Text(), "")
+		if got := cpu.NestestLine(); got != want {
+			t.Fatalf("mismatch at line %v\n got %q\nwant %q", n, got, want)
+		}
+		cpu.StepInstruction()
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -22,22 +22,36 @@ package mg6502
 
 func newInstructionSet() []*Instruction {
 	lookup := []*Instruction{
-		{"BRK", opBRK, amIMM, 7, AddrModeIMM}, {"ORA", opORA, amIZX, 6, AddrModeIZX}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 8, AddrModeIMP}, {"???", opNOP, amIMP, 3, AddrModeIMP}, {"ORA", opORA, amZP0, 3, AddrModeZP0}, {"ASL", opASL, amZP0, 5, AddrModeZP0}, {"???", opXXX, amIMP, 5, AddrModeIMP}, {"PHP", opPHP, amIMP, 3, AddrModeIMP}, {"ORA", opORA, amIMM, 2, AddrModeIMM}, {"ASL", opASL, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"???", opNOP, amIMP, 4, AddrModeIMP}, {"ORA", opORA, amABS, 4, AddrModeABS}, {"ASL", opASL, amABS, 6, AddrModeABS}, {"???", opXXX, amIMP, 6, AddrModeIMP},
-		{"BPL", opBPL, amREL, 2, AddrModeREL}, {"ORA", opORA, amIZY, 5, AddrModeIZY}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 8, AddrModeIMP}, {"???", opNOP, amIMP, 4, AddrModeIMP}, {"ORA", opORA, amZPX, 4, AddrModeZPX}, {"ASL", opASL, amZPX, 6, AddrModeZPX}, {"???", opXXX, amIMP, 6, AddrModeIMP}, {"CLC", opCLC, amIMP, 2, AddrModeIMP}, {"ORA", opORA, amABY, 4, AddrModeABY}, {"???", opNOP, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 7, AddrModeIMP}, {"???", opNOP, amIMP, 4, AddrModeIMP}, {"ORA", opORA, amABX, 4, AddrModeABX}, {"ASL", opASL, amABX, 7, AddrModeABX}, {"???", opXXX, amIMP, 7, AddrModeIMP},
-		{"JSR", opJSR, amABS, 6, AddrModeABS}, {"AND", opAND, amIZX, 6, AddrModeIZX}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 8, AddrModeIMP}, {"BIT", opBIT, amZP0, 3, AddrModeZP0}, {"AND", opAND, amZP0, 3, AddrModeZP0}, {"ROL", opROL, amZP0, 5, AddrModeZP0}, {"???", opXXX, amIMP, 5, AddrModeIMP}, {"PLP", opPLP, amIMP, 4, AddrModeIMP}, {"AND", opAND, amIMM, 2, AddrModeIMM}, {"ROL", opROL, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"BIT", opBIT, amABS, 4, AddrModeABS}, {"AND", opAND, amABS, 4, AddrModeABS}, {"ROL", opROL, amABS, 6, AddrModeABS}, {"???", opXXX, amIMP, 6, AddrModeIMP},
-		{"BMI", opBMI, amREL, 2, AddrModeREL}, {"AND", opAND, amIZY, 5, AddrModeIZY}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 8, AddrModeIMP}, {"???", opNOP, amIMP, 4, AddrModeIMP}, {"AND", opAND, amZPX, 4, AddrModeZPX}, {"ROL", opROL, amZPX, 6, AddrModeZPX}, {"???", opXXX, amIMP, 6, AddrModeIMP}, {"SEC", opSEC, amIMP, 2, AddrModeIMP}, {"AND", opAND, amABY, 4, AddrModeABY}, {"???", opNOP, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 7, AddrModeIMP}, {"???", opNOP, amIMP, 4, AddrModeIMP}, {"AND", opAND, amABX, 4, AddrModeABX}, {"ROL", opROL, amABX, 7, AddrModeABX}, {"???", opXXX, amIMP, 7, AddrModeIMP},
-		{"RTI", opRTI, amIMP, 6, AddrModeIMP}, {"EOR", opEOR, amIZX, 6, AddrModeIZX}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 8, AddrModeIMP}, {"???", opNOP, amIMP, 3, AddrModeIMP}, {"EOR", opEOR, amZP0, 3, AddrModeZP0}, {"LSR", opLSR, amZP0, 5, AddrModeZP0}, {"???", opXXX, amIMP, 5, AddrModeIMP}, {"PHA", opPHA, amIMP, 3, AddrModeIMP}, {"EOR", opEOR, amIMM, 2, AddrModeIMM}, {"LSR", opLSR, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"JMP", opJMP, amABS, 3, AddrModeABS}, {"EOR", opEOR, amABS, 4, AddrModeABS}, {"LSR", opLSR, amABS, 6, AddrModeABS}, {"???", opXXX, amIMP, 6, AddrModeIMP},
-		{"BVC", opBVC, amREL, 2, AddrModeREL}, {"EOR", opEOR, amIZY, 5, AddrModeIZY}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 8, AddrModeIMP}, {"???", opNOP, amIMP, 4, AddrModeIMP}, {"EOR", opEOR, amZPX, 4, AddrModeZPX}, {"LSR", opLSR, amZPX, 6, AddrModeZPX}, {"???", opXXX, amIMP, 6, AddrModeIMP}, {"CLI", opCLI, amIMP, 2, AddrModeIMP}, {"EOR", opEOR, amABY, 4, AddrModeABY}, {"???", opNOP, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 7, AddrModeIMP}, {"???", opNOP, amIMP, 4, AddrModeIMP}, {"EOR", opEOR, amABX, 4, AddrModeABX}, {"LSR", opLSR, amABX, 7, AddrModeABX}, {"???", opXXX, amIMP, 7, AddrModeIMP},
-		{"RTS", opRTS, amIMP, 6, AddrModeIMP}, {"ADC", opADC, amIZX, 6, AddrModeIZX}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 8, AddrModeIMP}, {"???", opNOP, amIMP, 3, AddrModeIMP}, {"ADC", opADC, amZP0, 3, AddrModeZP0}, {"ROR", opROR, amZP0, 5, AddrModeZP0}, {"???", opXXX, amIMP, 5, AddrModeIMP}, {"PLA", opPLA, amIMP, 4, AddrModeIMP}, {"ADC", opADC, amIMM, 2, AddrModeIMM}, {"ROR", opROR, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"JMP", opJMP, amIND, 5, AddrModeIND}, {"ADC", opADC, amABS, 4, AddrModeABS}, {"ROR", opROR, amABS, 6, AddrModeABS}, {"???", opXXX, amIMP, 6, AddrModeIMP},
-		{"BVS", opBVS, amREL, 2, AddrModeREL}, {"ADC", opADC, amIZY, 5, AddrModeIZY}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 8, AddrModeIMP}, {"???", opNOP, amIMP, 4, AddrModeIMP}, {"ADC", opADC, amZPX, 4, AddrModeZPX}, {"ROR", opROR, amZPX, 6, AddrModeZPX}, {"???", opXXX, amIMP, 6, AddrModeIMP}, {"SEI", opSEI, amIMP, 2, AddrModeIMP}, {"ADC", opADC, amABY, 4, AddrModeABY}, {"???", opNOP, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 7, AddrModeIMP}, {"???", opNOP, amIMP, 4, AddrModeIMP}, {"ADC", opADC, amABX, 4, AddrModeABX}, {"ROR", opROR, amABX, 7, AddrModeABX}, {"???", opXXX, amIMP, 7, AddrModeIMP},
-		{"???", opNOP, amIMP, 2, AddrModeIMP}, {"STA", opSTA, amIZX, 6, AddrModeIZX}, {"???", opNOP, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 6, AddrModeIMP}, {"STY", opSTY, amZP0, 3, AddrModeZP0}, {"STA", opSTA, amZP0, 3, AddrModeZP0}, {"STX", opSTX, amZP0, 3, AddrModeZP0}, {"???", opXXX, amIMP, 3, AddrModeIMP}, {"DEY", opDEY, amIMP, 2, AddrModeIMP}, {"???", opNOP, amIMP, 2, AddrModeIMP}, {"TXA", opTXA, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"STY", opSTY, amABS, 4, AddrModeABS}, {"STA", opSTA, amABS, 4, AddrModeABS}, {"STX", opSTX, amABS, 4, AddrModeABS}, {"???", opXXX, amIMP, 4, AddrModeIMP},
-		{"BCC", opBCC, amREL, 2, AddrModeREL}, {"STA", opSTA, amIZY, 6, AddrModeIZY}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 6, AddrModeIMP}, {"STY", opSTY, amZPX, 4, AddrModeZPX}, {"STA", opSTA, amZPX, 4, AddrModeZPX}, {"STX", opSTX, amZPY, 4, AddrModeZPY}, {"???", opXXX, amIMP, 4, AddrModeIMP}, {"TYA", opTYA, amIMP, 2, AddrModeIMP}, {"STA", opSTA, amABY, 5, AddrModeABY}, {"TXS", opTXS, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 5, AddrModeIMP}, {"???", opNOP, amIMP, 5, AddrModeIMP}, {"STA", opSTA, amABX, 5, AddrModeABX}, {"???", opXXX, amIMP, 5, AddrModeIMP}, {"???", opXXX, amIMP, 5, AddrModeIMP},
-		{"LDY", opLDY, amIMM, 2, AddrModeIMM}, {"LDA", opLDA, amIZX, 6, AddrModeIZX}, {"LDX", opLDX, amIMM, 2, AddrModeIMM}, {"???", opXXX, amIMP, 6, AddrModeIMP}, {"LDY", opLDY, amZP0, 3, AddrModeZP0}, {"LDA", opLDA, amZP0, 3, AddrModeZP0}, {"LDX", opLDX, amZP0, 3, AddrModeZP0}, {"???", opXXX, amIMP, 3, AddrModeIMP}, {"TAY", opTAY, amIMP, 2, AddrModeIMP}, {"LDA", opLDA, amIMM, 2, AddrModeIMM}, {"TAX", opTAX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"LDY", opLDY, amABS, 4, AddrModeABS}, {"LDA", opLDA, amABS, 4, AddrModeABS}, {"LDX", opLDX, amABS, 4, AddrModeABS}, {"???", opXXX, amIMP, 4, AddrModeIMP},
-		{"BCS", opBCS, amREL, 2, AddrModeREL}, {"LDA", opLDA, amIZY, 5, AddrModeIZY}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 5, AddrModeIMP}, {"LDY", opLDY, amZPX, 4, AddrModeZPX}, {"LDA", opLDA, amZPX, 4, AddrModeZPX}, {"LDX", opLDX, amZPY, 4, AddrModeZPY}, {"???", opXXX, amIMP, 4, AddrModeIMP}, {"CLV", opCLV, amIMP, 2, AddrModeIMP}, {"LDA", opLDA, amABY, 4, AddrModeABY}, {"TSX", opTSX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 4, AddrModeIMP}, {"LDY", opLDY, amABX, 4, AddrModeABX}, {"LDA", opLDA, amABX, 4, AddrModeABX}, {"LDX", opLDX, amABY, 4, AddrModeABY}, {"???", opXXX, amIMP, 4, AddrModeIMP},
-		{"CPY", opCPY, amIMM, 2, AddrModeIMM}, {"CMP", opCMP, amIZX, 6, AddrModeIZX}, {"???", opNOP, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 8, AddrModeIMP}, {"CPY", opCPY, amZP0, 3, AddrModeZP0}, {"CMP", opCMP, amZP0, 3, AddrModeZP0}, {"DEC", opDEC, amZP0, 5, AddrModeZP0}, {"???", opXXX, amIMP, 5, AddrModeIMP}, {"INY", opINY, amIMP, 2, AddrModeIMP}, {"CMP", opCMP, amIMM, 2, AddrModeIMM}, {"DEX", opDEX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"CPY", opCPY, amABS, 4, AddrModeABS}, {"CMP", opCMP, amABS, 4, AddrModeABS}, {"DEC", opDEC, amABS, 6, AddrModeABS}, {"???", opXXX, amIMP, 6, AddrModeIMP},
-		{"BNE", opBNE, amREL, 2, AddrModeREL}, {"CMP", opCMP, amIZY, 5, AddrModeIZY}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 8, AddrModeIMP}, {"???", opNOP, amIMP, 4, AddrModeIMP}, {"CMP", opCMP, amZPX, 4, AddrModeZPX}, {"DEC", opDEC, amZPX, 6, AddrModeZPX}, {"???", opXXX, amIMP, 6, AddrModeIMP}, {"CLD", opCLD, amIMP, 2, AddrModeIMP}, {"CMP", opCMP, amABY, 4, AddrModeABY}, {"NOP", opNOP, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 7, AddrModeIMP}, {"???", opNOP, amIMP, 4, AddrModeIMP}, {"CMP", opCMP, amABX, 4, AddrModeABX}, {"DEC", opDEC, amABX, 7, AddrModeABX}, {"???", opXXX, amIMP, 7, AddrModeIMP},
-		{"CPX", opCPX, amIMM, 2, AddrModeIMM}, {"SBC", opSBC, amIZX, 6, AddrModeIZX}, {"???", opNOP, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 8, AddrModeIMP}, {"CPX", opCPX, amZP0, 3, AddrModeZP0}, {"SBC", opSBC, amZP0, 3, AddrModeZP0}, {"INC", opINC, amZP0, 5, AddrModeZP0}, {"???", opXXX, amIMP, 5, AddrModeIMP}, {"INX", opINX, amIMP, 2, AddrModeIMP}, {"SBC", opSBC, amIMM, 2, AddrModeIMM}, {"NOP", opNOP, amIMP, 2, AddrModeIMP}, {"???", opSBC, amIMP, 2, AddrModeIMP}, {"CPX", opCPX, amABS, 4, AddrModeABS}, {"SBC", opSBC, amABS, 4, AddrModeABS}, {"INC", opINC, amABS, 6, AddrModeABS}, {"???", opXXX, amIMP, 6, AddrModeIMP},
-		{"BEQ", opBEQ, amREL, 2, AddrModeREL}, {"SBC", opSBC, amIZY, 5, AddrModeIZY}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 8, AddrModeIMP}, {"???", opNOP, amIMP, 4, AddrModeIMP}, {"SBC", opSBC, amZPX, 4, AddrModeZPX}, {"INC", opINC, amZPX, 6, AddrModeZPX}, {"???", opXXX, amIMP, 6, AddrModeIMP}, {"SED", opSED, amIMP, 2, AddrModeIMP}, {"SBC", opSBC, amABY, 4, AddrModeABY}, {"NOP", opNOP, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 7, AddrModeIMP}, {"???", opNOP, amIMP, 4, AddrModeIMP}, {"SBC", opSBC, amABX, 4, AddrModeABX}, {"INC", opINC, amABX, 7, AddrModeABX}, {"???", opXXX, amIMP, 7, AddrModeIMP},
+		{"BRK", opBRK, amIMM, 7, AddrModeIMM}, {"ORA", opORA, amIZX, 6, AddrModeIZX}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"SLO", opSLO, amIZX, 8, AddrModeIZX}, {"NOP", opNOP, amZP0, 3, AddrModeZP0}, {"ORA", opORA, amZP0, 3, AddrModeZP0}, {"ASL", opASL, amZP0, 5, AddrModeZP0}, {"SLO", opSLO, amZP0, 5, AddrModeZP0}, {"PHP", opPHP, amIMP, 3, AddrModeIMP}, {"ORA", opORA, amIMM, 2, AddrModeIMM}, {"ASL", opASL, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"NOP", opNOP, amABS, 4, AddrModeABS}, {"ORA", opORA, amABS, 4, AddrModeABS}, {"ASL", opASL, amABS, 6, AddrModeABS}, {"SLO", opSLO, amABS, 6, AddrModeABS},
+		{"BPL", opBPL, amREL, 2, AddrModeREL}, {"ORA", opORA, amIZY, 5, AddrModeIZY}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"SLO", opSLO, amIZY, 8, AddrModeIZY}, {"NOP", opNOP, amZPX, 4, AddrModeZPX}, {"ORA", opORA, amZPX, 4, AddrModeZPX}, {"ASL", opASL, amZPX, 6, AddrModeZPX}, {"SLO", opSLO, amZPX, 6, AddrModeZPX}, {"CLC", opCLC, amIMP, 2, AddrModeIMP}, {"ORA", opORA, amABY, 4, AddrModeABY}, {"NOP", opNOP, amIMP, 2, AddrModeIMP}, {"SLO", opSLO, amABY, 7, AddrModeABY}, {"NOP", opNOP, amABX, 4, AddrModeABX}, {"ORA", opORA, amABX, 4, AddrModeABX}, {"ASL", opASL, amABX, 7, AddrModeABX}, {"SLO", opSLO, amABX, 7, AddrModeABX},
+		{"JSR", opJSR, amABS, 6, AddrModeABS}, {"AND", opAND, amIZX, 6, AddrModeIZX}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"RLA", opRLA, amIZX, 8, AddrModeIZX}, {"BIT", opBIT, amZP0, 3, AddrModeZP0}, {"AND", opAND, amZP0, 3, AddrModeZP0}, {"ROL", opROL, amZP0, 5, AddrModeZP0}, {"RLA", opRLA, amZP0, 5, AddrModeZP0}, {"PLP", opPLP, amIMP, 4, AddrModeIMP}, {"AND", opAND, amIMM, 2, AddrModeIMM}, {"ROL", opROL, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"BIT", opBIT, amABS, 4, AddrModeABS}, {"AND", opAND, amABS, 4, AddrModeABS}, {"ROL", opROL, amABS, 6, AddrModeABS}, {"RLA", opRLA, amABS, 6, AddrModeABS},
+		{"BMI", opBMI, amREL, 2, AddrModeREL}, {"AND", opAND, amIZY, 5, AddrModeIZY}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"RLA", opRLA, amIZY, 8, AddrModeIZY}, {"NOP", opNOP, amZPX, 4, AddrModeZPX}, {"AND", opAND, amZPX, 4, AddrModeZPX}, {"ROL", opROL, amZPX, 6, AddrModeZPX}, {"RLA", opRLA, amZPX, 6, AddrModeZPX}, {"SEC", opSEC, amIMP, 2, AddrModeIMP}, {"AND", opAND, amABY, 4, AddrModeABY}, {"NOP", opNOP, amIMP, 2, AddrModeIMP}, {"RLA", opRLA, amABY, 7, AddrModeABY}, {"NOP", opNOP, amABX, 4, AddrModeABX}, {"AND", opAND, amABX, 4, AddrModeABX}, {"ROL", opROL, amABX, 7, AddrModeABX}, {"RLA", opRLA, amABX, 7, AddrModeABX},
+		{"RTI", opRTI, amIMP, 6, AddrModeIMP}, {"EOR", opEOR, amIZX, 6, AddrModeIZX}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"SRE", opSRE, amIZX, 8, AddrModeIZX}, {"NOP", opNOP, amZP0, 3, AddrModeZP0}, {"EOR", opEOR, amZP0, 3, AddrModeZP0}, {"LSR", opLSR, amZP0, 5, AddrModeZP0}, {"SRE", opSRE, amZP0, 5, AddrModeZP0}, {"PHA", opPHA, amIMP, 3, AddrModeIMP}, {"EOR", opEOR, amIMM, 2, AddrModeIMM}, {"LSR", opLSR, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"JMP", opJMP, amABS, 3, AddrModeABS}, {"EOR", opEOR, amABS, 4, AddrModeABS}, {"LSR", opLSR, amABS, 6, AddrModeABS}, {"SRE", opSRE, amABS, 6, AddrModeABS},
+		{"BVC", opBVC, amREL, 2, AddrModeREL}, {"EOR", opEOR, amIZY, 5, AddrModeIZY}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"SRE", opSRE, amIZY, 8, AddrModeIZY}, {"NOP", opNOP, amZPX, 4, AddrModeZPX}, {"EOR", opEOR, amZPX, 4, AddrModeZPX}, {"LSR", opLSR, amZPX, 6, AddrModeZPX}, {"SRE", opSRE, amZPX, 6, AddrModeZPX}, {"CLI", opCLI, amIMP, 2, AddrModeIMP}, {"EOR", opEOR, amABY, 4, AddrModeABY}, {"NOP", opNOP, amIMP, 2, AddrModeIMP}, {"SRE", opSRE, amABY, 7, AddrModeABY}, {"NOP", opNOP, amABX, 4, AddrModeABX}, {"EOR", opEOR, amABX, 4, AddrModeABX}, {"LSR", opLSR, amABX, 7, AddrModeABX}, {"SRE", opSRE, amABX, 7, AddrModeABX},
+		{"RTS", opRTS, amIMP, 6, AddrModeIMP}, {"ADC", opADC, amIZX, 6, AddrModeIZX}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"RRA", opRRA, amIZX, 8, AddrModeIZX}, {"NOP", opNOP, amZP0, 3, AddrModeZP0}, {"ADC", opADC, amZP0, 3, AddrModeZP0}, {"ROR", opROR, amZP0, 5, AddrModeZP0}, {"RRA", opRRA, amZP0, 5, AddrModeZP0}, {"PLA", opPLA, amIMP, 4, AddrModeIMP}, {"ADC", opADC, amIMM, 2, AddrModeIMM}, {"ROR", opROR, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"JMP", opJMP, amIND, 5, AddrModeIND}, {"ADC", opADC, amABS, 4, AddrModeABS}, {"ROR", opROR, amABS, 6, AddrModeABS}, {"RRA", opRRA, amABS, 6, AddrModeABS},
+		{"BVS", opBVS, amREL, 2, AddrModeREL}, {"ADC", opADC, amIZY, 5, AddrModeIZY}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"RRA", opRRA, amIZY, 8, AddrModeIZY}, {"NOP", opNOP, amZPX, 4, AddrModeZPX}, {"ADC", opADC, amZPX, 4, AddrModeZPX}, {"ROR", opROR, amZPX, 6, AddrModeZPX}, {"RRA", opRRA, amZPX, 6, AddrModeZPX}, {"SEI", opSEI, amIMP, 2, AddrModeIMP}, {"ADC", opADC, amABY, 4, AddrModeABY}, {"NOP", opNOP, amIMP, 2, AddrModeIMP}, {"RRA", opRRA, amABY, 7, AddrModeABY}, {"NOP", opNOP, amABX, 4, AddrModeABX}, {"ADC", opADC, amABX, 4, AddrModeABX}, {"ROR", opROR, amABX, 7, AddrModeABX}, {"RRA", opRRA, amABX, 7, AddrModeABX},
+		{"NOP", opNOP, amIMM, 2, AddrModeIMM}, {"STA", opSTA, amIZX, 6, AddrModeIZX}, {"NOP", opNOP, amIMM, 2, AddrModeIMM}, {"SAX", opSAX, amIZX, 6, AddrModeIZX}, {"STY", opSTY, amZP0, 3, AddrModeZP0}, {"STA", opSTA, amZP0, 3, AddrModeZP0}, {"STX", opSTX, amZP0, 3, AddrModeZP0}, {"SAX", opSAX, amZP0, 3, AddrModeZP0}, {"DEY", opDEY, amIMP, 2, AddrModeIMP}, {"NOP", opNOP, amIMM, 2, AddrModeIMM}, {"TXA", opTXA, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"STY", opSTY, amABS, 4, AddrModeABS}, {"STA", opSTA, amABS, 4, AddrModeABS}, {"STX", opSTX, amABS, 4, AddrModeABS}, {"SAX", opSAX, amABS, 4, AddrModeABS},
+		{"BCC", opBCC, amREL, 2, AddrModeREL}, {"STA", opSTA, amIZY, 6, AddrModeIZY}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 6, AddrModeIMP}, {"STY", opSTY, amZPX, 4, AddrModeZPX}, {"STA", opSTA, amZPX, 4, AddrModeZPX}, {"STX", opSTX, amZPY, 4, AddrModeZPY}, {"SAX", opSAX, amZPY, 4, AddrModeZPY}, {"TYA", opTYA, amIMP, 2, AddrModeIMP}, {"STA", opSTA, amABY, 5, AddrModeABY}, {"TXS", opTXS, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 5, AddrModeIMP}, {"???", opNOP, amIMP, 5, AddrModeIMP}, {"STA", opSTA, amABX, 5, AddrModeABX}, {"???", opXXX, amIMP, 5, AddrModeIMP}, {"???", opXXX, amIMP, 5, AddrModeIMP},
+		{"LDY", opLDY, amIMM, 2, AddrModeIMM}, {"LDA", opLDA, amIZX, 6, AddrModeIZX}, {"LDX", opLDX, amIMM, 2, AddrModeIMM}, {"LAX", opLAX, amIZX, 6, AddrModeIZX}, {"LDY", opLDY, amZP0, 3, AddrModeZP0}, {"LDA", opLDA, amZP0, 3, AddrModeZP0}, {"LDX", opLDX, amZP0, 3, AddrModeZP0}, {"LAX", opLAX, amZP0, 3, AddrModeZP0}, {"TAY", opTAY, amIMP, 2, AddrModeIMP}, {"LDA", opLDA, amIMM, 2, AddrModeIMM}, {"TAX", opTAX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"LDY", opLDY, amABS, 4, AddrModeABS}, {"LDA", opLDA, amABS, 4, AddrModeABS}, {"LDX", opLDX, amABS, 4, AddrModeABS}, {"LAX", opLAX, amABS, 4, AddrModeABS},
+		{"BCS", opBCS, amREL, 2, AddrModeREL}, {"LDA", opLDA, amIZY, 5, AddrModeIZY}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"LAX", opLAX, amIZY, 5, AddrModeIZY}, {"LDY", opLDY, amZPX, 4, AddrModeZPX}, {"LDA", opLDA, amZPX, 4, AddrModeZPX}, {"LDX", opLDX, amZPY, 4, AddrModeZPY}, {"LAX", opLAX, amZPY, 4, AddrModeZPY}, {"CLV", opCLV, amIMP, 2, AddrModeIMP}, {"LDA", opLDA, amABY, 4, AddrModeABY}, {"TSX", opTSX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 4, AddrModeIMP}, {"LDY", opLDY, amABX, 4, AddrModeABX}, {"LDA", opLDA, amABX, 4, AddrModeABX}, {"LDX", opLDX, amABY, 4, AddrModeABY}, {"LAX", opLAX, amABY, 4, AddrModeABY},
+		{"CPY", opCPY, amIMM, 2, AddrModeIMM}, {"CMP", opCMP, amIZX, 6, AddrModeIZX}, {"NOP", opNOP, amIMM, 2, AddrModeIMM}, {"DCP", opDCP, amIZX, 8, AddrModeIZX}, {"CPY", opCPY, amZP0, 3, AddrModeZP0}, {"CMP", opCMP, amZP0, 3, AddrModeZP0}, {"DEC", opDEC, amZP0, 5, AddrModeZP0}, {"DCP", opDCP, amZP0, 5, AddrModeZP0}, {"INY", opINY, amIMP, 2, AddrModeIMP}, {"CMP", opCMP, amIMM, 2, AddrModeIMM}, {"DEX", opDEX, amIMP, 2, AddrModeIMP}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"CPY", opCPY, amABS, 4, AddrModeABS}, {"CMP", opCMP, amABS, 4, AddrModeABS}, {"DEC", opDEC, amABS, 6, AddrModeABS}, {"DCP", opDCP, amABS, 6, AddrModeABS},
+		{"BNE", opBNE, amREL, 2, AddrModeREL}, {"CMP", opCMP, amIZY, 5, AddrModeIZY}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"DCP", opDCP, amIZY, 8, AddrModeIZY}, {"NOP", opNOP, amZPX, 4, AddrModeZPX}, {"CMP", opCMP, amZPX, 4, AddrModeZPX}, {"DEC", opDEC, amZPX, 6, AddrModeZPX}, {"DCP", opDCP, amZPX, 6, AddrModeZPX}, {"CLD", opCLD, amIMP, 2, AddrModeIMP}, {"CMP", opCMP, amABY, 4, AddrModeABY}, {"NOP", opNOP, amIMP, 2, AddrModeIMP}, {"DCP", opDCP, amABY, 7, AddrModeABY}, {"NOP", opNOP, amABX, 4, AddrModeABX}, {"CMP", opCMP, amABX, 4, AddrModeABX}, {"DEC", opDEC, amABX, 7, AddrModeABX}, {"DCP", opDCP, amABX, 7, AddrModeABX},
+		{"CPX", opCPX, amIMM, 2, AddrModeIMM}, {"SBC", opSBC, amIZX, 6, AddrModeIZX}, {"NOP", opNOP, amIMM, 2, AddrModeIMM}, {"ISC", opISC, amIZX, 8, AddrModeIZX}, {"CPX", opCPX, amZP0, 3, AddrModeZP0}, {"SBC", opSBC, amZP0, 3, AddrModeZP0}, {"INC", opINC, amZP0, 5, AddrModeZP0}, {"ISC", opISC, amZP0, 5, AddrModeZP0}, {"INX", opINX, amIMP, 2, AddrModeIMP}, {"SBC", opSBC, amIMM, 2, AddrModeIMM}, {"NOP", opNOP, amIMP, 2, AddrModeIMP}, {"SBC", opSBC, amIMM, 2, AddrModeIMM}, {"CPX", opCPX, amABS, 4, AddrModeABS}, {"SBC", opSBC, amABS, 4, AddrModeABS}, {"INC", opINC, amABS, 6, AddrModeABS}, {"ISC", opISC, amABS, 6, AddrModeABS},
+		{"BEQ", opBEQ, amREL, 2, AddrModeREL}, {"SBC", opSBC, amIZY, 5, AddrModeIZY}, {"???", opXXX, amIMP, 2, AddrModeIMP}, {"ISC", opISC, amIZY, 8, AddrModeIZY}, {"NOP", opNOP, amZPX, 4, AddrModeZPX}, {"SBC", opSBC, amZPX, 4, AddrModeZPX}, {"INC", opINC, amZPX, 6, AddrModeZPX}, {"ISC", opISC, amZPX, 6, AddrModeZPX}, {"SED", opSED, amIMP, 2, AddrModeIMP}, {"SBC", opSBC, amABY, 4, AddrModeABY}, {"NOP", opNOP, amIMP, 2, AddrModeIMP}, {"ISC", opISC, amABY, 7, AddrModeABY}, {"NOP", opNOP, amABX, 4, AddrModeABX}, {"SBC", opSBC, amABX, 4, AddrModeABX}, {"INC", opINC, amABX, 7, AddrModeABX}, {"ISC", opISC, amABX, 7, AddrModeABX},
 	}
 	return lookup
 }
+
+// instructionSet is the lookup table shared by OpcodeInfo
+var instructionSet = newInstructionSet()
+
+// OpcodeInfo returns the mnemonic, addressing mode (one of the AddrMode
+// constants), length in bytes including the opcode and base cycle count of
+// op. Unstable illegal opcodes without an implementation are named "???",
+// the stable ones have their usual mnemonic. The cycles do not include the
+// extra ones for page crossing and taken branches
+func OpcodeInfo(op uint8) (name string, mode int, bytes uint8, cycles uint8) {
+	instruction := instructionSet[op]
+	bytes = 1 + uint8(operandLength(instruction.addrMode))
+	return instruction.name, instruction.addrMode, bytes, instruction.cycles
+}
@@ -0,0 +1,55 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg6502
+
+import "testing"
+
+func TestOpcodeInfo(t *testing.T) {
+	tests := []struct {
+		op     uint8
+		name   string
+		mode   int
+		bytes  uint8
+		cycles uint8
+	}{
+		{0xA9, "LDA", AddrModeIMM, 2, 2},
+		{0xBD, "LDA", AddrModeABX, 3, 4}, // page crossing not included
+		{0xB1, "LDA", AddrModeIZY, 2, 5},
+		{0x6C, "JMP", AddrModeIND, 3, 5},
+		{0xD0, "BNE", AddrModeREL, 2, 2}, // taken branch not included
+		{0xEA, "NOP", AddrModeIMP, 1, 2},
+		{0x00, "BRK", AddrModeIMM, 2, 7},
+		// stable unofficial opcodes have their usual mnemonic
+		{0xA7, "LAX", AddrModeZP0, 2, 3},
+		{0xC7, "DCP", AddrModeZP0, 2, 5},
+		{0x1A, "NOP", AddrModeIMP, 1, 2},
+		// unstable ones and the jams do not
+		{0x8B, "???", AddrModeIMP, 1, 2},
+		{0x02, "???", AddrModeIMP, 1, 2},
+	}
+	for _, tt := range tests {
+		name, mode, bytes, cycles := OpcodeInfo(tt.op)
+		if name != tt.name || mode != tt.mode || bytes != tt.bytes || cycles != tt.cycles {
+			t.Errorf("OpcodeInfo(%#02x) = %v, %v, %v, %v, want %v, %v, %v, %v",
+				tt.op, name, mode, bytes, cycles, tt.name, tt.mode, tt.bytes, tt.cycles)
+		}
+	}
+}
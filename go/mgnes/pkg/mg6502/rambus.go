@@ -0,0 +1,38 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg6502
+
+// RAMBus is a flat 64KB of RAM implementing both Reader and Writer, it
+// lets the CPU run without any PPU or cartridge attached
+type RAMBus [0x10000]uint8
+
+// NewRAMBus creates and returns a zero filled RAMBus reference
+func NewRAMBus() *RAMBus {
+	return &RAMBus{}
+}
+
+func (bus *RAMBus) CpuRead(addr uint16, readonly bool) (data uint8) {
+	return bus[addr]
+}
+
+func (bus *RAMBus) CpuWrite(addr uint16, data uint8) {
+	bus[addr] = data
+}
@@ -20,7 +20,9 @@
 
 package mg6502
 
-// Reader defines an interface for CPU to read data from
+// Reader defines an interface for CPU to read data from. A readonly read
+// must not change the state of any device, the disassembler and debuggers
+// rely on it
 type Reader interface {
 	CpuRead(addr uint16, readonly bool) (data uint8)
 }
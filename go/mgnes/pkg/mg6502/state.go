@@ -0,0 +1,141 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg6502
+
+import "encoding/gob"
+
+// CPUState holds the registers and internal state of the CPU, it is used
+// for save states and test fixtures
+type CPUState struct {
+	A, X, Y, SP, FLAG uint8
+	PC                uint16
+
+	Fetched    uint8
+	Temp       uint16
+	AddrAbs    uint16
+	AddrRel    uint16
+	Opcode     uint8
+	Cycles     uint8
+	ClockCount uint32
+	Vector     uint16
+	InstrPC    uint16
+	InstrTotal uint8
+
+	Step        uint8
+	Base        uint16
+	PendingAddr [2]uint16
+	PendingData [2]uint8
+	NumPending  uint8
+
+	IRQLine     bool
+	NMILine     bool
+	NMIEdge     bool
+	IRQTaken    bool
+	NMITaken    bool
+	WasDisabled bool
+	Halt        uint8
+}
+
+// Snapshot returns a copy of the current CPU state
+func (cpu *MG6502) Snapshot() (state CPUState) {
+	state = CPUState{
+		A:           cpu.A,
+		X:           cpu.X,
+		Y:           cpu.Y,
+		SP:          cpu.SP,
+		FLAG:        cpu.FLAG,
+		PC:          cpu.PC,
+		Fetched:     cpu.fetched,
+		Temp:        cpu.temp,
+		AddrAbs:     cpu.addrAbs,
+		AddrRel:     cpu.addrRel,
+		Opcode:      cpu.opcode,
+		Cycles:      cpu.cycles,
+		ClockCount:  cpu.clockCount,
+		Vector:      cpu.vector,
+		InstrPC:     cpu.instrPC,
+		InstrTotal:  cpu.instrTotal,
+		Step:        cpu.step,
+		Base:        cpu.base,
+		NumPending:  cpu.numPending,
+		IRQLine:     cpu.irqLine,
+		NMILine:     cpu.nmiLine,
+		NMIEdge:     cpu.nmiEdge,
+		IRQTaken:    cpu.irqTaken,
+		NMITaken:    cpu.nmiTaken,
+		WasDisabled: cpu.wasDisabled,
+		Halt:        cpu.halt,
+	}
+	for i, p := range cpu.pending {
+		state.PendingAddr[i] = p.addr
+		state.PendingData[i] = p.data
+	}
+	return state
+}
+
+// Restore puts the CPU back into a state returned by Snapshot
+func (cpu *MG6502) Restore(state CPUState) {
+	cpu.A = state.A
+	cpu.X = state.X
+	cpu.Y = state.Y
+	cpu.SP = state.SP
+	cpu.FLAG = state.FLAG
+	cpu.PC = state.PC
+	cpu.fetched = state.Fetched
+	cpu.temp = state.Temp
+	cpu.addrAbs = state.AddrAbs
+	cpu.addrRel = state.AddrRel
+	cpu.opcode = state.Opcode
+	cpu.cycles = state.Cycles
+	cpu.clockCount = state.ClockCount
+	cpu.vector = state.Vector
+	cpu.instrPC = state.InstrPC
+	cpu.instrTotal = state.InstrTotal
+	cpu.step = state.Step
+	cpu.base = state.Base
+	cpu.numPending = state.NumPending
+	cpu.irqLine = state.IRQLine
+	cpu.nmiLine = state.NMILine
+	cpu.nmiEdge = state.NMIEdge
+	cpu.irqTaken = state.IRQTaken
+	cpu.nmiTaken = state.NMITaken
+	cpu.wasDisabled = state.WasDisabled
+	cpu.halt = state.Halt
+	for i := range cpu.pending {
+		cpu.pending[i] = pending{state.PendingAddr[i], state.PendingData[i]}
+	}
+}
+
+// Serialize writes the registers and internal state for a save state
+func (cpu *MG6502) Serialize(enc *gob.Encoder) error {
+	return enc.Encode(cpu.Snapshot())
+}
+
+// Deserialize restores the state written by Serialize
+func (cpu *MG6502) Deserialize(dec *gob.Decoder) error {
+	var state CPUState
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	cpu.Restore(state)
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg6502
+
+import "testing"
+
+func TestSnapshotRestore(t *testing.T) {
+	cpu, bus := newTestCPU(benchProgram)
+	// stop in the middle of an instruction
+	for i := 0; i < 53; i++ {
+		cpu.Clock()
+	}
+	cpu.SetIRQ(true)
+	cpu.SetFlag(FlagInterrupt, true)
+
+	snapshot := cpu.Snapshot()
+	ram := *bus
+
+	// run on, recording the state after every clock
+	var trace []CPUState
+	for i := 0; i < 200; i++ {
+		cpu.Clock()
+		trace = append(trace, cpu.Snapshot())
+	}
+	cpu.A, cpu.X, cpu.Y, cpu.SP, cpu.PC = 0x11, 0x22, 0x33, 0x44, 0x5555
+	cpu.SetIRQ(false)
+
+	cpu.Restore(snapshot)
+	*bus = ram
+	if got := cpu.Snapshot(); got != snapshot {
+		t.Fatalf("state after Restore = %+v, want %+v", got, snapshot)
+	}
+
+	// execution continues exactly as it did after the snapshot
+	for i, want := range trace {
+		cpu.Clock()
+		if got := cpu.Snapshot(); got != want {
+			t.Fatalf("clock %v: state = %+v, want %+v", i, got, want)
+		}
+	}
+}
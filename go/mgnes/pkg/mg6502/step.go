@@ -0,0 +1,80 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg6502
+
+// ExecRecord describes a single executed instruction
+type ExecRecord struct {
+	// PC is the address the instruction was fetched from
+	PC uint16
+	// Opcode byte
+	Opcode uint8
+	// Mnemonic of the opcode, "???" for unstable illegal ones
+	Mnemonic string
+	// Addr is the resolved operand address, the branch target for relative
+	// addressing and 0 for implied addressing
+	Addr uint16
+	// registers before the instruction was executed
+	A    uint8
+	X    uint8
+	Y    uint8
+	SP   uint8
+	FLAG uint8
+	// Cycles consumed by the instruction
+	Cycles uint32
+}
+
+// StepInstruction executes exactly one instruction and returns a record of
+// it. Any instruction or interrupt sequence still in flight, or an interrupt
+// due to be serviced, is completed first and is not part of the record
+func (cpu *MG6502) StepInstruction() (record ExecRecord) {
+	for !cpu.Complete() || cpu.InterruptPending() {
+		cpu.Clock()
+	}
+
+	record.PC = cpu.PC
+	record.A = cpu.A
+	record.X = cpu.X
+	record.Y = cpu.Y
+	record.SP = cpu.SP
+	record.FLAG = cpu.FLAG
+
+	start := cpu.clockCount
+	cpu.Clock()
+	for !cpu.Complete() {
+		cpu.Clock()
+	}
+	record.Cycles = cpu.clockCount - start
+
+	instruction := cpu.lookup[cpu.opcode]
+	record.Opcode = cpu.opcode
+	record.Mnemonic = instruction.name
+
+	switch instruction.addrMode {
+	case AddrModeIMP:
+		record.Addr = 0
+	case AddrModeREL:
+		record.Addr = record.PC + 2 + cpu.addrRel
+	default:
+		record.Addr = cpu.addrAbs
+	}
+
+	return
+}
@@ -0,0 +1,76 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg6502
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStepInstruction(t *testing.T) {
+	cpu, bus := newTestCPU(benchProgram)
+
+	// 10 * 3, adding 3 ten times
+	want := []string{"LDX", "STX", "LDX", "STX", "LDY", "LDA", "CLC"}
+	for i := 0; i < 10; i++ {
+		want = append(want, "ADC", "DEY", "BNE")
+	}
+	want = append(want, "STA", "NOP", "NOP", "NOP", "JMP", "LDX")
+
+	var got []string
+	var records []ExecRecord
+	for range want {
+		record := cpu.StepInstruction()
+		got = append(got, record.Mnemonic)
+		records = append(records, record)
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("mnemonics = %v, want %v", got, want)
+	}
+	if bus[0x0002] != 30 {
+		t.Errorf("result = %v, want 30", bus[0x0002])
+	}
+
+	// the first ADC, a taken and the final untaken BNE
+	tests := []struct {
+		index  int
+		pc     uint16
+		opcode uint8
+		addr   uint16
+		cycles uint32
+	}{
+		{7, 0x8010, 0x6D, 0x0001, 4},
+		{9, 0x8014, 0xD0, 0x8010, 3},
+		{36, 0x8014, 0xD0, 0x8010, 2},
+		{37, 0x8016, 0x8D, 0x0002, 4},
+	}
+	for _, test := range tests {
+		r := records[test.index]
+		if r.PC != test.pc || r.Opcode != test.opcode || r.Addr != test.addr || r.Cycles != test.cycles {
+			t.Errorf("record %v = PC %#04x op %#02x addr %#04x %v cycles, want PC %#04x op %#02x addr %#04x %v cycles",
+				test.index, r.PC, r.Opcode, r.Addr, r.Cycles, test.pc, test.opcode, test.addr, test.cycles)
+		}
+	}
+	// registers are the ones before the instruction ran
+	if r := records[36]; r.Y != 0 || r.A != 30 {
+		t.Errorf("last BNE saw A %v Y %v, want 30 0", r.A, r.Y)
+	}
+}
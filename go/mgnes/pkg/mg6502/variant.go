@@ -0,0 +1,288 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg6502
+
+// Variant selects the instruction set of the cpu
+type Variant int
+
+const (
+	// Variant2A03 is the NMOS 6502 core of the NES 2A03, unofficial opcodes
+	// included. It is the default
+	Variant2A03 Variant = iota
+	// Variant65C02 is the CMOS 65C02 as made by WDC: BRA, PHX/PHY/PLX/PLY,
+	// STZ, TSB/TRB, INC A/DEC A, the new BIT and JMP modes, zero page
+	// indirect addressing, WAI and STP, and JMP ($xxFF) without the page
+	// bug. The undefined opcodes are NOPs. Decimal mode is not emulated by
+	// either variant and the Rockwell bit instructions (RMB, SMB, BBR, BBS)
+	// are not implemented
+	Variant65C02
+)
+
+// halt states entered by WAI and STP
+const (
+	haltNone uint8 = iota
+	haltWait       // WAI: until an interrupt is requested
+	haltStop       // STP: until reset
+)
+
+// SetVariant switches the instruction set, call it before PowerUp
+func (cpu *MG6502) SetVariant(variant Variant) {
+	cpu.variant = variant
+	if variant == Variant65C02 {
+		cpu.lookup = new65C02InstructionSet()
+	} else {
+		cpu.lookup = newInstructionSet()
+	}
+	cpu.access = accessKinds(cpu.lookup)
+}
+
+// new65C02InstructionSet returns the NMOS lookup table patched with the
+// CMOS changes
+func new65C02InstructionSet() []*Instruction {
+	lookup := newInstructionSet()
+
+	// undefined opcodes are NOPs of various lengths, the x3, x7, xB and xF
+	// columns are single byte, single cycle ones
+	for i := range lookup {
+		if i&0x03 == 0x03 {
+			lookup[i] = &Instruction{"???", opNOP, amIMP, 1, AddrModeIMP}
+		}
+	}
+	for _, op := range []uint8{0x02, 0x22, 0x42, 0x62, 0x82, 0xC2, 0xE2} {
+		lookup[op] = &Instruction{"???", opNOP, amIMM, 2, AddrModeIMM}
+	}
+	lookup[0x44] = &Instruction{"???", opNOP, amZP0, 3, AddrModeZP0}
+	for _, op := range []uint8{0x54, 0xD4, 0xF4} {
+		lookup[op] = &Instruction{"???", opNOP, amZPX, 4, AddrModeZPX}
+	}
+	lookup[0x5C] = &Instruction{"???", opNOP, amABS, 8, AddrModeABS}
+	lookup[0xDC] = &Instruction{"???", opNOP, amABS, 4, AddrModeABS}
+	lookup[0xFC] = &Instruction{"???", opNOP, amABS, 4, AddrModeABS}
+
+	additions := map[uint8]*Instruction{
+		0x80: {"BRA", opBRA, amREL, 2, AddrModeREL},
+		0x12: {"ORA", opORA, amZPI, 5, AddrModeZPI},
+		0x32: {"AND", opAND, amZPI, 5, AddrModeZPI},
+		0x52: {"EOR", opEOR, amZPI, 5, AddrModeZPI},
+		0x72: {"ADC", opADC, amZPI, 5, AddrModeZPI},
+		0x92: {"STA", opSTA, amZPI, 5, AddrModeZPI},
+		0xB2: {"LDA", opLDA, amZPI, 5, AddrModeZPI},
+		0xD2: {"CMP", opCMP, amZPI, 5, AddrModeZPI},
+		0xF2: {"SBC", opSBC, amZPI, 5, AddrModeZPI},
+		0x04: {"TSB", opTSB, amZP0, 5, AddrModeZP0},
+		0x0C: {"TSB", opTSB, amABS, 6, AddrModeABS},
+		0x14: {"TRB", opTRB, amZP0, 5, AddrModeZP0},
+		0x1C: {"TRB", opTRB, amABS, 6, AddrModeABS},
+		0x1A: {"INC", opINA, amIMP, 2, AddrModeIMP},
+		0x3A: {"DEC", opDEA, amIMP, 2, AddrModeIMP},
+		0x34: {"BIT", opBIT, amZPX, 4, AddrModeZPX},
+		0x3C: {"BIT", opBIT, amABX, 4, AddrModeABX},
+		0x89: {"BIT", opBIM, amIMM, 2, AddrModeIMM},
+		0x5A: {"PHY", opPHY, amIMP, 3, AddrModeIMP},
+		0x7A: {"PLY", opPLY, amIMP, 4, AddrModeIMP},
+		0xDA: {"PHX", opPHX, amIMP, 3, AddrModeIMP},
+		0xFA: {"PLX", opPLX, amIMP, 4, AddrModeIMP},
+		0x64: {"STZ", opSTZ, amZP0, 3, AddrModeZP0},
+		0x74: {"STZ", opSTZ, amZPX, 4, AddrModeZPX},
+		0x9C: {"STZ", opSTZ, amABS, 4, AddrModeABS},
+		0x9E: {"STZ", opSTZ, amABX, 5, AddrModeABX},
+		0x6C: {"JMP", opJMP, amINDC, 6, AddrModeIND},
+		0x7C: {"JMP", opJMP, amIAX, 6, AddrModeIAX},
+		0xCB: {"WAI", opWAI, amIMP, 3, AddrModeIMP},
+		0xDB: {"STP", opSTP, amIMP, 3, AddrModeIMP},
+	}
+	for op, instruction := range additions {
+		lookup[op] = instruction
+	}
+
+	return lookup
+}
+
+// waiting returns true while WAI or STP halt the cpu. WAI ends as soon as an
+// interrupt is requested, even a masked IRQ which then resumes execution
+// after the WAI without being serviced
+func (cpu *MG6502) waiting() bool {
+	switch cpu.halt {
+	case haltStop:
+		return true
+	case haltWait:
+		if !cpu.irqLine && !cpu.nmiEdge {
+			return true
+		}
+		cpu.halt = haltNone
+		cpu.poll()
+	}
+	return false
+}
+
+// Address Mode: Zero Page Indirect (65C02)
+// Like Indirect Y without adding the Y Register, the supplied 8-bit address
+// indexes a pointer in page 0x00
+func amZPI(cpu *MG6502) uint8 {
+	t := cpu.read(cpu.PC)
+	cpu.PC++
+
+	lo := uint16(cpu.read(uint16(t)))
+	hi := uint16(cpu.read(uint16(t + 1)))
+
+	cpu.addrAbs = (hi << 8) | lo
+	return 0
+}
+
+// Address Mode: Absolute Indexed Indirect (65C02)
+// The supplied 16-bit address is offset by X Register and the actual 16-bit
+// address is read from there, only used by JMP
+func amIAX(cpu *MG6502) uint8 {
+	ptr := cpu.read16(cpu.PC) + uint16(cpu.X)
+	cpu.PC += 2
+
+	cpu.addrAbs = cpu.read16(ptr)
+	return 0
+}
+
+// Address Mode: Indirect (65C02)
+// Without the page boundary bug of the NMOS chip
+func amINDC(cpu *MG6502) uint8 {
+	ptr := cpu.read16(cpu.PC)
+	cpu.PC += 2
+
+	cpu.addrAbs = cpu.read16(ptr)
+	return 0
+}
+
+// Instruction: Branch Always
+// Function: pc = address
+func opBRA(cpu *MG6502) uint8 {
+	cpu.cycles++
+	cpu.addrAbs = cpu.PC + cpu.addrRel
+	if cpu.addrAbs&0xFF00 != cpu.PC&0xFF00 {
+		cpu.cycles++
+	}
+
+	cpu.PC = cpu.addrAbs
+	return 0
+}
+
+// Instruction: Test Bits in Memory with Accumulator, immediate
+// Function: A & M
+// Flags Out: Z, unlike the other modes N and V are left unchanged
+func opBIM(cpu *MG6502) uint8 {
+	cpu.fetch()
+	cpu.SetFlag(FlagZero, cpu.A&cpu.fetched == 0x00)
+	return 0
+}
+
+// Instruction: Increment Accumulator
+// Function: A = A + 1
+// Flags Out: N, Z
+func opINA(cpu *MG6502) uint8 {
+	cpu.A++
+	cpu.SetFlag(FlagZero, cpu.A == 0x00)
+	cpu.SetFlag(FlagNegative, cpu.A&0x80 != 0)
+	return 0
+}
+
+// Instruction: Decrement Accumulator
+// Function: A = A - 1
+// Flags Out: N, Z
+func opDEA(cpu *MG6502) uint8 {
+	cpu.A--
+	cpu.SetFlag(FlagZero, cpu.A == 0x00)
+	cpu.SetFlag(FlagNegative, cpu.A&0x80 != 0)
+	return 0
+}
+
+// Instruction: Push X Register to Stack
+// Function: X -> stack
+func opPHX(cpu *MG6502) uint8 {
+	cpu.push(cpu.X)
+	return 0
+}
+
+// Instruction: Push Y Register to Stack
+// Function: Y -> stack
+func opPHY(cpu *MG6502) uint8 {
+	cpu.push(cpu.Y)
+	return 0
+}
+
+// Instruction: Pop X Register off Stack
+// Function: X <- stack
+// Flags Out: N, Z
+func opPLX(cpu *MG6502) uint8 {
+	cpu.X = cpu.pop()
+	cpu.SetFlag(FlagZero, cpu.X == 0x00)
+	cpu.SetFlag(FlagNegative, cpu.X&0x80 != 0)
+	return 0
+}
+
+// Instruction: Pop Y Register off Stack
+// Function: Y <- stack
+// Flags Out: N, Z
+func opPLY(cpu *MG6502) uint8 {
+	cpu.Y = cpu.pop()
+	cpu.SetFlag(FlagZero, cpu.Y == 0x00)
+	cpu.SetFlag(FlagNegative, cpu.Y&0x80 != 0)
+	return 0
+}
+
+// Instruction: Store Zero at Address
+// Function: M = 0
+func opSTZ(cpu *MG6502) uint8 {
+	cpu.write(cpu.addrAbs, 0x00)
+	return 0
+}
+
+// Instruction: Test and Set Bits
+// Function: Z <- A & M == 0, M = M | A
+// Flags Out: Z
+func opTSB(cpu *MG6502) uint8 {
+	cpu.fetch()
+	cpu.dummyWrite()
+	cpu.SetFlag(FlagZero, cpu.A&cpu.fetched == 0x00)
+	cpu.write(cpu.addrAbs, cpu.fetched|cpu.A)
+	return 0
+}
+
+// Instruction: Test and Reset Bits
+// Function: Z <- A & M == 0, M = M & ^A
+// Flags Out: Z
+func opTRB(cpu *MG6502) uint8 {
+	cpu.fetch()
+	cpu.dummyWrite()
+	cpu.SetFlag(FlagZero, cpu.A&cpu.fetched == 0x00)
+	cpu.write(cpu.addrAbs, cpu.fetched&^cpu.A)
+	return 0
+}
+
+// Instruction: Wait for Interrupt
+// The cpu halts until an interrupt is requested
+func opWAI(cpu *MG6502) uint8 {
+	cpu.halt = haltWait
+	return 0
+}
+
+// Instruction: Stop the Clock
+// The cpu halts until it is reset
+func opSTP(cpu *MG6502) uint8 {
+	cpu.halt = haltStop
+	return 0
+}
@@ -0,0 +1,199 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mg6502
+
+import "testing"
+
+// newCMOSCPU is newTestCPU for a 65C02, with the IRQ and NMI handlers at
+// 0x9000 and 0x9100
+func newCMOSCPU(code []uint8) (*MG6502, *RAMBus) {
+	bus := NewRAMBus()
+	copy(bus[0x8000:], code)
+	bus[0xFFFA], bus[0xFFFB] = 0x00, 0x91
+	bus[0xFFFC], bus[0xFFFD] = 0x00, 0x80
+	bus[0xFFFE], bus[0xFFFF] = 0x00, 0x90
+	bus[0x9000] = 0xEA
+	bus[0x9100] = 0xEA
+
+	cpu := NewMG6502()
+	cpu.SetReader(bus)
+	cpu.SetWriter(bus)
+	cpu.SetVariant(Variant65C02)
+	cpu.PowerUp()
+	for !cpu.Complete() {
+		cpu.Clock()
+	}
+	return cpu, bus
+}
+
+func TestSTZ(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   []uint8
+		addr   uint16
+		cycles uint32
+	}{
+		{"zp", []uint8{0x64, 0x10}, 0x0010, 3},
+		{"zp,X", []uint8{0x74, 0x10}, 0x0014, 4},
+		{"abs", []uint8{0x9C, 0x34, 0x02}, 0x0234, 4},
+		{"abs,X", []uint8{0x9E, 0x30, 0x02}, 0x0234, 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpu, bus := newCMOSCPU(test.code)
+			bus[test.addr] = 0xFF
+			bus[test.addr+1] = 0xFF
+			cpu.A = 0x55
+			cpu.X = 0x04
+			flags := cpu.FLAG
+
+			record := cpu.StepInstruction()
+
+			if bus[test.addr] != 0x00 {
+				t.Errorf("M = %#02x, want 0", bus[test.addr])
+			}
+			if bus[test.addr+1] != 0xFF {
+				t.Errorf("the next byte was written")
+			}
+			if cpu.A != 0x55 || cpu.FLAG != flags {
+				t.Errorf("A, P = %#02x, %#02x, want them unchanged", cpu.A, cpu.FLAG)
+			}
+			if record.Cycles != test.cycles {
+				t.Errorf("took %v cycles, want %v", record.Cycles, test.cycles)
+			}
+		})
+	}
+
+	// 0x64 is an unofficial NOP on the NMOS core
+	cpu, bus := newTestCPU([]uint8{0x64, 0x10})
+	bus[0x0010] = 0xFF
+	cpu.StepInstruction()
+	if bus[0x0010] != 0xFF {
+		t.Errorf("0x64 stored zero on the 2A03")
+	}
+}
+
+func TestBRA(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset uint8
+		want   uint16
+		cycles uint32
+	}{
+		{"forward", 0x04, 0x8006, 3},
+		{"to itself", 0xFE, 0x8000, 3},
+		{"backward across a page", 0x80, 0x7F82, 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpu, _ := newCMOSCPU([]uint8{0x80, test.offset})
+			// taken whatever the flags say
+			cpu.FLAG = 0xFF
+
+			record := cpu.StepInstruction()
+
+			if cpu.PC != test.want {
+				t.Errorf("PC = %#04x, want %#04x", cpu.PC, test.want)
+			}
+			if record.Cycles != test.cycles {
+				t.Errorf("took %v cycles, want %v", record.Cycles, test.cycles)
+			}
+		})
+	}
+}
+
+func TestWAI(t *testing.T) {
+	// WAI, NOP
+	code := []uint8{0xCB, 0xEA}
+
+	t.Run("halts", func(t *testing.T) {
+		cpu, _ := newCMOSCPU(code)
+		cpu.StepInstruction()
+		for i := 0; i < 100; i++ {
+			cpu.Clock()
+		}
+		if cpu.PC != 0x8001 {
+			t.Errorf("PC = %#04x while waiting, want 0x8001", cpu.PC)
+		}
+	})
+
+	t.Run("IRQ", func(t *testing.T) {
+		cpu, bus := newCMOSCPU(code)
+		cpu.SetFlag(FlagInterrupt, false)
+		cpu.StepInstruction()
+		for i := 0; i < 10; i++ {
+			cpu.Clock()
+		}
+		cpu.SetIRQ(true)
+
+		if got := irqReturn(t, cpu, bus); got != 0x8001 {
+			t.Errorf("IRQ returns to %#04x, want 0x8001", got)
+		}
+	})
+
+	t.Run("masked IRQ resumes", func(t *testing.T) {
+		cpu, _ := newCMOSCPU(code)
+		cpu.SetFlag(FlagInterrupt, true)
+		cpu.StepInstruction()
+		for i := 0; i < 10; i++ {
+			cpu.Clock()
+		}
+		cpu.SetIRQ(true)
+
+		// the NOP after WAI runs, the IRQ is not serviced
+		record := cpu.StepInstruction()
+		if record.PC != 0x8001 || cpu.PC != 0x8002 {
+			t.Errorf("ran %#04x, PC = %#04x, want the NOP at 0x8001", record.PC, cpu.PC)
+		}
+	})
+
+	t.Run("NMI", func(t *testing.T) {
+		cpu, _ := newCMOSCPU(code)
+		cpu.StepInstruction()
+		for i := 0; i < 10; i++ {
+			cpu.Clock()
+		}
+		cpu.SetNMI(true)
+
+		for i := 0; i < 20 && cpu.PC != 0x9100; i++ {
+			cpu.Clock()
+		}
+		if cpu.PC != 0x9100 {
+			t.Errorf("PC = %#04x, want the NMI handler at 0x9100", cpu.PC)
+		}
+	})
+
+	t.Run("STP ignores interrupts", func(t *testing.T) {
+		cpu, _ := newCMOSCPU([]uint8{0xDB, 0xEA})
+		cpu.SetFlag(FlagInterrupt, false)
+		cpu.StepInstruction()
+		cpu.SetIRQ(true)
+		cpu.SetNMI(true)
+		for i := 0; i < 100; i++ {
+			cpu.Clock()
+		}
+		if cpu.PC != 0x8001 {
+			t.Errorf("PC = %#04x after STP, want 0x8001", cpu.PC)
+		}
+	})
+}
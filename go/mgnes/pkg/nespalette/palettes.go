@@ -1,6 +1,9 @@
-package main
+package nespalette
 
-import "encoding/hex"
+import (
+	"encoding/hex"
+	"sort"
+)
 
 const (
 	palette3DSVC                        = "73737321188c0000ad42009c8c0073ad0010a500007b080042290000420000520000391018395a000000000000000000bdbdbd0073ef2139ef8400f7bd00bde7005ade2900ce4a088c730000940000ad0000943900848c101010000000000000ffffff39bdff5a94ffa58cfff77bffff73b5ff7363ff9c39f7bd3984d6104ade4a5aff9c00efde393939000000000000ffffffade7ffc6d6ffd6ceffffc6ffffc6deffbdb5ffdeadffe7a5e7ffa5adf7bdb5ffce9cfff78c8c8c000000000000"
@@ -93,34 +96,44 @@ func init() {
 		"PVMtoDigitalFBXbeta02":        palettePVMtoDigitalFBXbeta02,
 		"PVMtoDigitalFBXbeta03":        palettePVMtoDigitalFBXbeta03,
 		"PVMtoDigitalFBXbeta04":        palettePVMtoDigitalFBXbeta04,
-		"Raw":              paletteRaw,
-		"RGB":              paletteRGB,
-		"Rockman9":         paletteRockman9,
-		"Rockman921to2C":   paletteRockman921to2C,
-		"Sony":             paletteSony,
-		"UnsaturatedFinal": paletteUnsaturatedFinal,
-		"UnsaturatedV4":    paletteUnsaturatedV4,
-		"UnsaturatedV5":    paletteUnsaturatedV5,
-		"UnsaturatedV6":    paletteUnsaturatedV6,
-		"UnsaturatedV7":    paletteUnsaturatedV7,
-		"WiiVC":            paletteWiiVC,
-		"WiiVCbrighter":    paletteWiiVCbrighter,
-		"xvzgjw":           palettexvzgjw,
-		"YUV":              paletteYUV,
-		"YUVV3":            paletteYUVV3,
-		"YUVCorrected":     paletteYUVCorrected,
+		"Raw":                          paletteRaw,
+		"RGB":                          paletteRGB,
+		"Rockman9":                     paletteRockman9,
+		"Rockman921to2C":               paletteRockman921to2C,
+		"Sony":                         paletteSony,
+		"UnsaturatedFinal":             paletteUnsaturatedFinal,
+		"UnsaturatedV4":                paletteUnsaturatedV4,
+		"UnsaturatedV5":                paletteUnsaturatedV5,
+		"UnsaturatedV6":                paletteUnsaturatedV6,
+		"UnsaturatedV7":                paletteUnsaturatedV7,
+		"WiiVC":                        paletteWiiVC,
+		"WiiVCbrighter":                paletteWiiVCbrighter,
+		"xvzgjw":                       palettexvzgjw,
+		"YUV":                          paletteYUV,
+		"YUVV3":                        paletteYUVV3,
+		"YUVCorrected":                 paletteYUVCorrected,
 	}
 }
 
-func getPalette(name string) []byte {
+// Get returns the 64 RGB colors, 192 bytes, of the palette with name
+func Get(name string) ([]byte, bool) {
 	if raw, ok := paletteMap[name]; ok {
 		p, err := hex.DecodeString(raw)
 		if err != nil {
-			return nil
-		} else {
-			return p
+			return nil, false
 		}
+		return p, true
 	}
 
-	return nil
+	return nil, false
+}
+
+// Names returns the names of all palettes, sorted
+func Names() []string {
+	names := make([]string, 0, len(paletteMap))
+	for name := range paletteMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
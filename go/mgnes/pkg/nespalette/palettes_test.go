@@ -0,0 +1,49 @@
+// Copyright © 2019 mg
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package nespalette
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestPalettes(t *testing.T) {
+	names := Names()
+	if len(names) != len(paletteMap) {
+		t.Fatalf("Names() has %v names, want %v", len(names), len(paletteMap))
+	}
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("Names() is not sorted")
+	}
+
+	for _, name := range names {
+		p, ok := Get(name)
+		if !ok {
+			t.Errorf("Get(%q) failed", name)
+		} else if len(p) != 192 {
+			t.Errorf("Get(%q) returned %v bytes, want 192", name, len(p))
+		}
+	}
+
+	if _, ok := Get("NoSuchPalette"); ok {
+		t.Errorf("Get of an unknown name succeeded")
+	}
+}